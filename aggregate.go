@@ -0,0 +1,89 @@
+package clickhouse
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// AggregateState is a value of AggregateFunction column, the serialized
+// state of the aggregate function. It can be inserted as is into a column of
+// the same type, e.g. to copy the states between AggregatingMergeTree
+// tables. The states are read with the text formats only.
+type AggregateState []byte
+
+var reflectTypeAggregateState = reflect.TypeOf(AggregateState{})
+
+// Value implements driver.Valuer
+func (s AggregateState) Value() (driver.Value, error) {
+	return []byte(quote(escape(string(s)))), nil
+}
+
+// MarshalText implements the encoding.TextMarshaler, the state is encoded
+// in base64
+func (s AggregateState) MarshalText() ([]byte, error) {
+	text := make([]byte, base64.StdEncoding.EncodedLen(len(s)))
+	base64.StdEncoding.Encode(text, s)
+	return text, nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler, the state is
+// decoded from base64
+func (s *AggregateState) UnmarshalText(text []byte) error {
+	state := make([]byte, base64.StdEncoding.DecodedLen(len(text)))
+	n, err := base64.StdEncoding.Decode(state, text)
+	if err != nil {
+		return err
+	}
+	*s = state[:n]
+	return nil
+}
+
+// aggregateStateParser reads the states byte by byte, they are not valid
+// UTF-8 strings
+type aggregateStateParser struct {
+	unquote bool
+}
+
+func (p *aggregateStateParser) Parse(s io.RuneScanner) (driver.Value, error) {
+	bs, ok := s.(io.ByteScanner)
+	if !ok {
+		return nil, fmt.Errorf("can not read the bytes of the state of aggregate function")
+	}
+	if p.unquote {
+		if b, err := bs.ReadByte(); err != nil || b != '\'' {
+			return nil, fmt.Errorf("unexpected character instead of a quote")
+		}
+	}
+	state := AggregateState{}
+	for {
+		b, err := bs.ReadByte()
+		if err == io.EOF && !p.unquote {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unexpected end of the state of aggregate function")
+		}
+		if b == '\'' && p.unquote {
+			break
+		}
+		if b == '\\' {
+			if b, err = bs.ReadByte(); err != nil {
+				return nil, fmt.Errorf("incorrect escaping in the state of aggregate function")
+			}
+			// the escape sequences of readEscaped
+			if i := strings.IndexByte("bfrnt0", b); i >= 0 {
+				b = "\b\f\r\n\t\x00"[i]
+			}
+		}
+		state = append(state, b)
+	}
+	return state, nil
+}
+
+func (p *aggregateStateParser) Type() reflect.Type {
+	return reflectTypeAggregateState
+}
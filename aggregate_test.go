@@ -0,0 +1,51 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregateFunction(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		if r.Method == http.MethodGet {
+			w.Write([]byte("s\tn\tst\nSimpleAggregateFunction(sum, UInt64)\tSimpleAggregateFunction(anyLast, Nullable(String))\tAggregateFunction(quantiles(0.5, 0.9), UInt64)\n" +
+				"42\t\\N\t\x01\\0\xff\\\\\\t\\'\n"))
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var (
+		sum   uint64
+		last  sql.NullString
+		state AggregateState
+	)
+	require.NoError(t, db.QueryRow("SELECT s, n, st").Scan(&sum, &last, &state))
+	assert.Equal(t, uint64(42), sum)
+	assert.False(t, last.Valid)
+	assert.Equal(t, AggregateState("\x01\x00\xff\\\t'"), state)
+
+	var raw []byte
+	require.NoError(t, db.QueryRow("SELECT s, n, st").Scan(&sum, &last, &raw))
+	assert.Equal(t, []byte(state), raw)
+
+	_, err = db.Exec("INSERT INTO t VALUES (?)", state)
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO t VALUES ('\x01\x00\xff\\\\\t\\'')", queries[len(queries)-1])
+
+	text, err := state.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "AQD/XAkn", string(text))
+	var decoded AggregateState
+	require.NoError(t, decoded.UnmarshalText(text))
+	assert.Equal(t, state, decoded)
+}
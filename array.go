@@ -0,0 +1,85 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+)
+
+// ScanArray returns a sql.Scanner which scans an Array column into dest,
+// which must be a pointer to a slice. Elements are converted to the element
+// type of dest, also in nested arrays, e.g. Array(Array(UInt8)) can be scanned
+// into [][]int and Array(Nullable(Int32)) into []*int64 or []interface{}. An
+// Array column can also be scanned directly into a slice of the exact type,
+// like [][]uint8 or []*int32.
+func ScanArray(dest interface{}) sql.Scanner {
+	return &arrayScanner{dest: dest}
+}
+
+type arrayScanner struct {
+	dest interface{}
+}
+
+// Scan implements the sql.Scanner
+func (s *arrayScanner) Scan(src interface{}) error {
+	dv := reflect.ValueOf(s.dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() || dv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("clickhouse: expected pointer to slice, got %T", s.dest)
+	}
+	dv = dv.Elem()
+	if src == nil {
+		dv.Set(reflect.Zero(dv.Type()))
+		return nil
+	}
+	sv := reflect.ValueOf(src)
+	if sv.Kind() != reflect.Slice {
+		return fmt.Errorf("clickhouse: can not scan %T as Array", src)
+	}
+	v, err := convertArrayElem(sv, dv.Type())
+	if err != nil {
+		return err
+	}
+	dv.Set(v)
+	return nil
+}
+
+// convertArrayElem converts v to t, the elements of slices and the values
+// of pointers of nullable elements are converted recursively
+func convertArrayElem(v reflect.Value, t reflect.Type) (reflect.Value, error) {
+	switch {
+	case v.Kind() == reflect.Ptr && t.Kind() == reflect.Interface:
+		// nullable elements are scanned as values or nil
+		if v.IsNil() {
+			return reflect.Zero(t), nil
+		}
+		return convertArrayElem(v.Elem(), t)
+	case v.Type().AssignableTo(t):
+		return convertMapElem(v, t)
+	case v.Kind() == reflect.Slice && t.Kind() == reflect.Slice:
+		slice := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			elem, err := convertArrayElem(v.Index(i), t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			slice.Index(i).Set(elem)
+		}
+		return slice, nil
+	case v.Kind() == reflect.Ptr && v.IsNil():
+		if t.Kind() != reflect.Ptr {
+			return reflect.Value{}, fmt.Errorf("clickhouse: can not convert NULL to %s", t)
+		}
+		return reflect.Zero(t), nil
+	case v.Kind() == reflect.Ptr && t.Kind() == reflect.Ptr:
+		elem, err := convertArrayElem(v.Elem(), t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	case v.Kind() == reflect.Ptr:
+		return convertArrayElem(v.Elem(), t)
+	}
+	return convertMapElem(v, t)
+}
@@ -0,0 +1,56 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanArray(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		if r.Method == http.MethodGet {
+			w.Write([]byte("a\tn\tt\n" +
+				"Array(Array(UInt8))\tArray(Nullable(Int32))\tArray(DateTime)\n" +
+				"[[1,2],[]]\t[1,NULL]\t['2020-01-02 03:04:05']\n"))
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var (
+		exact    [][]uint8
+		nullable []*int32
+		times    []time.Time
+	)
+	require.NoError(t, db.QueryRow("SELECT a, n, t").Scan(&exact, &nullable, &times))
+	assert.Equal(t, [][]uint8{{1, 2}, {}}, exact)
+	assert.Equal(t, []*int32{int32Ptr(1), nil}, nullable)
+	assert.Equal(t, []time.Time{time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}, times)
+
+	var (
+		converted [][]int
+		pointers  []*int64
+		generic   []interface{}
+	)
+	require.NoError(t, db.QueryRow("SELECT a, n, t").Scan(ScanArray(&converted), ScanArray(&pointers), ScanArray(&generic)))
+	assert.Equal(t, [][]int{{1, 2}, {}}, converted)
+	one := int64(1)
+	assert.Equal(t, []*int64{&one, nil}, pointers)
+	assert.Equal(t, []interface{}{time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}, generic)
+	var values []int32
+	assert.Error(t, db.QueryRow("SELECT a, n, t").Scan(ScanArray(&converted), ScanArray(&values), &times))
+	assert.Error(t, db.QueryRow("SELECT a, n, t").Scan(ScanArray(&exact), ScanArray(&queries), &times))
+
+	_, err = db.Exec("INSERT INTO t VALUES (?, ?, ?)", [][]int{{1}, {}}, []*int32{int32Ptr(1), nil}, []string{"it's"})
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO t VALUES ([[1],[]], [1,NULL], ['it\\'s'])", queries[len(queries)-1])
+}
@@ -0,0 +1,96 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// withSettings returns a context which adds the settings to the URL
+// parameters of requests, the settings override those of the parent context
+func withSettings(ctx context.Context, settings map[string]string) context.Context {
+	if parent, ok := ctx.Value(settingsKey).(map[string]string); ok {
+		merged := make(map[string]string, len(parent)+len(settings))
+		for k, v := range parent {
+			merged[k] = v
+		}
+		for k, v := range settings {
+			merged[k] = v
+		}
+		settings = merged
+	}
+	return context.WithValue(ctx, settingsKey, settings)
+}
+
+// FireAndForget sends the INSERT query as an asynchronous insert which returns
+// as soon as the server has received the data, without waiting for the data to
+// be flushed to a table part. Errors of the flush are not reported to the caller.
+func FireAndForget(ctx context.Context, db *sql.DB, query string, args ...interface{}) error {
+	words, err := splitSQL(query)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 || !words[0].is("INSERT") {
+		return fmt.Errorf("clickhouse: FireAndForget expects an INSERT query")
+	}
+	_, err = db.ExecContext(WithAsyncInsert(ctx, false), query, args...)
+	return err
+}
+
+// WithAsyncInsert returns a copy of ctx which sends the INSERT queries executed
+// with it as asynchronous inserts (the async_insert setting), the server
+// buffers the data of many small inserts and flushes it to the table at once.
+// If wait is set, the insert returns once its data is flushed, otherwise once
+// the data is buffered. The errors of asynchronous inserts are *AsyncInsertError.
+func WithAsyncInsert(ctx context.Context, wait bool) context.Context {
+	waitSetting := "0"
+	if wait {
+		waitSetting = "1"
+	}
+	return withSettings(ctx, map[string]string{
+		"async_insert":          "1",
+		"wait_for_async_insert": waitSetting,
+	})
+}
+
+// AsyncInsertError is the error of an asynchronous insert. The server
+// acknowledges an insert which does not wait once the data is buffered, so
+// its error is about receiving and parsing the data, the errors of the flush
+// are not reported. An insert which waits also fails with the errors of the
+// flush, but the flush is not cancelled if the wait times out.
+type AsyncInsertError struct {
+	// Wait is set if the insert waited for the flush
+	Wait bool
+	Err  error
+}
+
+func (e *AsyncInsertError) Error() string {
+	return "clickhouse: asynchronous insert: " + e.Err.Error()
+}
+
+// Unwrap returns the error of the request
+func (e *AsyncInsertError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports whether the wait for the flush timed out, the data may
+// still be inserted in this case
+func (e *AsyncInsertError) Timeout() bool {
+	var ex *Exception
+	return e.Wait && errors.As(e.Err, &ex) && ex.Code == 159
+}
+
+// asyncInsertError wraps the error of the query if it is an asynchronous insert
+func asyncInsertError(query string, req *http.Request, err error) error {
+	if err == nil || err == driver.ErrBadConn || req.URL.Query().Get("async_insert") != "1" {
+		return err
+	}
+	if words, werr := splitSQL(query); werr != nil || len(words) == 0 || !words[0].is("INSERT") {
+		return err
+	}
+	// the server waits by default
+	return &AsyncInsertError{Wait: req.URL.Query().Get("wait_for_async_insert") != "0", Err: err}
+}
@@ -0,0 +1,86 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFireAndForget(t *testing.T) {
+	var params []url.Values
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		if query == "SELECT version()" {
+			w.Write([]byte("version()\nString\n23.8.2.7\n"))
+			return
+		}
+		params = append(params, r.URL.Query())
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	require.NoError(t, FireAndForget(ctx, db, "INSERT INTO t VALUES (?)", 1))
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES (2)")
+	require.NoError(t, err)
+	assert.Error(t, FireAndForget(ctx, db, "SELECT 1"))
+
+	require.Len(t, params, 2)
+	assert.Equal(t, "1", params[0].Get("async_insert"))
+	assert.Equal(t, "0", params[0].Get("wait_for_async_insert"))
+	assert.Empty(t, params[1].Get("async_insert"))
+}
+
+func TestAsyncInsert(t *testing.T) {
+	var params url.Values
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		params = r.URL.Query()
+		switch {
+		case query == "INSERT INTO t VALUES (1)":
+		case params.Get("wait_for_async_insert") == "1":
+			http.Error(w, "Code: 159. DB::Exception: Wait for async insert timeout (1000 ms) exceeded). (TIMEOUT_EXCEEDED)", http.StatusInternalServerError)
+		default:
+			http.Error(w, "Code: 27. DB::Exception: Cannot parse input. (CANNOT_PARSE_INPUT_ASSERTION_FAILED)", http.StatusBadRequest)
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := WithAsyncInsert(context.Background(), true)
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, "1", params.Get("async_insert"))
+	assert.Equal(t, "1", params.Get("wait_for_async_insert"))
+
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES (2)")
+	var asyncErr *AsyncInsertError
+	if assert.True(t, errors.As(err, &asyncErr), "%v", err) {
+		assert.True(t, asyncErr.Wait)
+		assert.True(t, asyncErr.Timeout())
+		var ex *Exception
+		assert.True(t, errors.As(err, &ex))
+		assert.Equal(t, 159, ex.Code)
+	}
+
+	_, err = db.ExecContext(WithAsyncInsert(context.Background(), false), "INSERT INTO t VALUES (x)")
+	if assert.True(t, errors.As(err, &asyncErr), "%v", err) {
+		assert.False(t, asyncErr.Wait)
+		assert.False(t, asyncErr.Timeout())
+	}
+	assert.Equal(t, "0", params.Get("wait_for_async_insert"))
+
+	// only inserts are asynchronous
+	_, err = db.ExecContext(ctx, "ALTER TABLE t DELETE WHERE 1")
+	assert.False(t, errors.As(err, &asyncErr), "%v", err)
+}
@@ -0,0 +1,73 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Authentication modes of Config.AuthMode
+const (
+	// AuthModeBasic sends the user and the password with the HTTP basic
+	// authentication, it is the default mode
+	AuthModeBasic = "basic"
+	// AuthModeHeaders sends the user and the password in the X-ClickHouse-User
+	// and X-ClickHouse-Key headers
+	AuthModeHeaders = "headers"
+	// AuthModeJWT sends the token in the Authorization: Bearer header
+	AuthModeJWT = "jwt"
+)
+
+const (
+	userHeader = "X-ClickHouse-User"
+	keyHeader  = "X-ClickHouse-Key"
+)
+
+// TokenFunc returns the current secret of the connection: the JWT with
+// AuthModeJWT or the password otherwise. It is called for every request,
+// so credentials can rotate without recreating the connection pool; it should
+// cache the secret until it expires.
+type TokenFunc func(ctx context.Context) (string, error)
+
+// authenticate sets the credentials of the request according to the auth mode
+func (c *conn) authenticate(ctx context.Context, req *http.Request) error {
+	var user, secret string
+	if c.user != nil {
+		user = c.user.Username()
+		secret, _ = c.user.Password()
+	}
+	user, secret, err := c.providedCredentials(ctx, user, secret)
+	if err != nil {
+		return err
+	}
+	if c.authMode == AuthModeJWT {
+		secret = c.token
+	}
+	if c.tokenFunc != nil {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		token, err := c.tokenFunc(ctx)
+		if err != nil {
+			return fmt.Errorf("clickhouse: failed to get token: %v", err)
+		}
+		secret = token
+	}
+	switch c.authMode {
+	case AuthModeHeaders:
+		if len(user) > 0 {
+			req.Header.Set(userHeader, user)
+		}
+		if len(secret) > 0 {
+			req.Header.Set(keyHeader, secret)
+		}
+	case AuthModeJWT:
+		req.Header.Set("Authorization", "Bearer "+secret)
+	default:
+		// http.Transport ignores url.User argument, handle it here
+		if len(user) > 0 || len(secret) > 0 {
+			req.SetBasicAuth(user, secret)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthModes(t *testing.T) {
+	var headers http.Header
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		headers = r.Header
+	})
+	defer ts.Close()
+
+	exec := func(cfg *Config) error {
+		cfg.InsecureHTTP = true
+		db := sql.OpenDB(NewConnector(cfg))
+		defer db.Close()
+		_, err := db.Exec("INSERT INTO t VALUES (1)")
+		return err
+	}
+
+	cfg, err := ParseDSN(dsn)
+	require.NoError(t, err)
+	cfg.User, cfg.Password = "user", "password"
+	require.NoError(t, exec(cfg))
+	user, password, ok := (&http.Request{Header: headers}).BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "user", user)
+	assert.Equal(t, "password", password)
+
+	cfg, err = ParseDSN(dsn + "?auth_mode=headers")
+	require.NoError(t, err)
+	cfg.User, cfg.Password = "user", "password"
+	require.NoError(t, exec(cfg))
+	assert.Equal(t, "user", headers.Get("X-ClickHouse-User"))
+	assert.Equal(t, "password", headers.Get("X-ClickHouse-Key"))
+	assert.Empty(t, headers.Get("Authorization"))
+
+	cfg, err = ParseDSN(dsn + "?auth_mode=jwt&token=jwt1")
+	require.NoError(t, err)
+	require.NoError(t, exec(cfg))
+	assert.Equal(t, "Bearer jwt1", headers.Get("Authorization"))
+
+	// the token is requested for every query
+	tokens := []string{"jwt2", "jwt3"}
+	cfg.TokenFunc = func(ctx context.Context) (string, error) {
+		if len(tokens) == 0 {
+			return "", errors.New("expired")
+		}
+		token := tokens[0]
+		tokens = tokens[1:]
+		return token, nil
+	}
+	cfg.InsecureHTTP = true
+	db := sql.OpenDB(NewConnector(cfg))
+	defer db.Close()
+	_, err = db.Exec("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer jwt2", headers.Get("Authorization"))
+	_, err = db.Exec("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer jwt3", headers.Get("Authorization"))
+	_, err = db.Exec("INSERT INTO t VALUES (1)")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "expired")
+	}
+}
+
+func TestParseDSNAuthMode(t *testing.T) {
+	cfg, err := ParseDSN("https://localhost:8123/test?auth_mode=jwt&token=secret")
+	if assert.NoError(t, err) {
+		assert.Equal(t, AuthModeJWT, cfg.AuthMode)
+		assert.Equal(t, "secret", cfg.Token)
+		assert.Empty(t, cfg.Params)
+		assert.Contains(t, cfg.FormatDSN(), "auth_mode=jwt")
+		assert.Contains(t, cfg.FormatDSN(), "token=secret")
+	}
+	_, err = ParseDSN("https://localhost:8123/test?auth_mode=digest")
+	assert.Error(t, err)
+}
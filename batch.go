@@ -0,0 +1,262 @@
+package clickhouse
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// batchBlockSize is the size of a block of encoded rows written to the request body
+const batchBlockSize = 1 << 20
+
+var (
+	// ErrBatchSent is returned by the methods of Batch after Send or Abort
+	ErrBatchSent = errors.New("clickhouse: batch has already been sent")
+	// errBatchAborted is the error of the request body of an aborted batch
+	errBatchAborted = errors.New("clickhouse: batch is aborted")
+)
+
+// Batch streams rows of an INSERT query to the server. Rows are encoded into
+// blocks of about 1MB which are written to the body of a single request, so
+// the memory use does not depend on the number of rows. A Batch is not safe
+// for concurrent use.
+type Batch struct {
+	conn  *sql.Conn
+	pw    *io.PipeWriter
+	buf   *bufio.Writer
+	done  chan error
+	rows  int
+	size  int64
+	limit int64
+	err   error
+}
+
+// PrepareBatch starts the INSERT query, which must have no VALUES or FORMAT
+// clause, e.g. "INSERT INTO t" or "INSERT INTO t (a, b)". The batch holds a
+// connection of db until Send or Abort is called. The request is cancelled
+// with ctx, Config.MaxRequestBodySize limits the size of the encoded rows.
+func PrepareBatch(ctx context.Context, db *sql.DB, query string) (*Batch, error) {
+	if err := checkInsertPrefix(query, "PrepareBatch"); err != nil {
+		return nil, err
+	}
+	sqlConn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	b := &Batch{
+		conn: sqlConn,
+		pw:   pw,
+		buf:  bufio.NewWriterSize(pw, batchBlockSize),
+		done: make(chan error, 1),
+	}
+	err = sqlConn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*conn)
+		if !ok {
+			return errNotClickHouseConn
+		}
+		b.limit = c.maxBodySize
+		go func() {
+			err := c.execStream(ctx, query+" VALUES ", pr)
+			// unblock writes if the request fails before the body is read
+			if err != nil {
+				pr.CloseWithError(err)
+			} else {
+				pr.CloseWithError(ErrBatchSent)
+			}
+			b.done <- err
+		}()
+		return nil
+	})
+	if err != nil {
+		sqlConn.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// WriteToTable executes the INSERT query with the FORMAT clause, e.g.
+// "INSERT INTO t FORMAT CSV", with the data in the format streamed from r,
+// e.g. CSV, TSVRaw, JSONEachRow or Parquet data of a file. The data is sent
+// as it is in the body of a single request, compressed if the connection
+// uses compression, so the memory use does not depend on its size. The
+// request is cancelled with ctx, Config.MaxRequestBodySize limits the size
+// of the data.
+func WriteToTable(ctx context.Context, db *sql.DB, query string, r io.Reader) error {
+	if err := checkInsertFormat(query); err != nil {
+		return err
+	}
+	sqlConn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer sqlConn.Close()
+	return sqlConn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*conn)
+		if !ok {
+			return errNotClickHouseConn
+		}
+		if c.maxBodySize > 0 {
+			r = &payloadLimitReader{r: r, limit: c.maxBodySize}
+		}
+		// the data starts after the first newline following the format name
+		return c.execStream(ctx, query+"\n", r)
+	})
+}
+
+// checkInsertFormat checks that the query is an INSERT query with a FORMAT
+// clause and without the data
+func checkInsertFormat(query string) error {
+	words, err := splitSQL(query)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 || !words[0].is("INSERT") {
+		return fmt.Errorf("clickhouse: WriteToTable expects an INSERT query")
+	}
+	for i, w := range words {
+		if w.is("VALUES", "SELECT") {
+			return fmt.Errorf("clickhouse: WriteToTable expects an INSERT query without %s", w.text)
+		}
+		if w.is("FORMAT") {
+			if i != len(words)-2 || words[i+1].quoted {
+				return fmt.Errorf("clickhouse: WriteToTable expects an INSERT query ending with FORMAT <format>")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("clickhouse: WriteToTable expects an INSERT query with FORMAT")
+}
+
+// payloadLimitReader fails with ErrPayloadTooLarge once more than limit
+// bytes are read
+type payloadLimitReader struct {
+	r     io.Reader
+	n     int64
+	limit int64
+}
+
+func (l *payloadLimitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.limit {
+		return 0, ErrPayloadTooLarge{Actual: l.n, Limit: l.limit}
+	}
+	return n, err
+}
+
+// checkInsertPrefix checks that the query is an INSERT query without the data,
+// fn is the name of the function for the error
+func checkInsertPrefix(query, fn string) error {
+	words, err := splitSQL(query)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 || !words[0].is("INSERT") {
+		return fmt.Errorf("clickhouse: %s expects an INSERT query", fn)
+	}
+	for _, w := range words {
+		if w.is("VALUES", "FORMAT", "SELECT") {
+			return fmt.Errorf("clickhouse: %s expects an INSERT query without %s", fn, w.text)
+		}
+	}
+	return nil
+}
+
+// Append encodes the row and buffers it, a full block of rows is written to
+// the request body
+func (b *Batch) Append(args ...interface{}) error {
+	if b.err != nil {
+		return b.err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("clickhouse: empty row")
+	}
+	row := make([]byte, 0, 64)
+	if b.rows > 0 {
+		row = append(row, ", "...)
+	}
+	row = append(row, '(')
+	for i, arg := range args {
+		v, err := converter{}.ConvertValue(arg)
+		if err != nil {
+			return err
+		}
+		encoded, err := textEncode.Encode(v)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			row = append(row, ", "...)
+		}
+		row = append(row, encoded...)
+	}
+	row = append(row, ')')
+	if b.limit > 0 && b.size+int64(len(row)) > b.limit {
+		return ErrPayloadTooLarge{Actual: b.size + int64(len(row)), Limit: b.limit}
+	}
+	if _, err := b.buf.Write(row); err != nil {
+		return b.fail(err)
+	}
+	b.rows++
+	b.size += int64(len(row))
+	return nil
+}
+
+// Flush writes the buffered rows to the request body
+func (b *Batch) Flush() error {
+	if b.err != nil {
+		return b.err
+	}
+	if err := b.buf.Flush(); err != nil {
+		return b.fail(err)
+	}
+	return nil
+}
+
+// Send writes the buffered rows, finishes the request and waits for the
+// response of the server
+func (b *Batch) Send() error {
+	if b.err != nil {
+		return b.err
+	}
+	b.err = ErrBatchSent
+	flushErr := b.buf.Flush()
+	b.pw.Close()
+	err := <-b.done
+	if err == nil {
+		err = flushErr
+	}
+	if cerr := b.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Abort breaks the request body, so the server fails the query. ClickHouse
+// inserts the data of large requests by blocks, the blocks received before
+// Abort may be inserted.
+func (b *Batch) Abort() error {
+	if b.err != nil {
+		return b.err
+	}
+	b.err = ErrBatchSent
+	b.pw.CloseWithError(errBatchAborted)
+	<-b.done
+	return b.conn.Close()
+}
+
+// fail aborts the batch after a failed write, the error of the request is
+// returned if the write failed because of it
+func (b *Batch) fail(err error) error {
+	b.err = ErrBatchSent
+	b.pw.CloseWithError(err)
+	if rerr := <-b.done; rerr != nil {
+		err = rerr
+	}
+	b.conn.Close()
+	return err
+}
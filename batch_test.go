@@ -0,0 +1,136 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch(t *testing.T) {
+	var (
+		queries        []string
+		contentLengths []int64
+	)
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		contentLengths = append(contentLengths, r.ContentLength)
+		if strings.HasPrefix(query, "INSERT INTO bad") {
+			http.Error(w, "Code: 60, Message: Table default.bad doesn't exist", http.StatusNotFound)
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	_, err = PrepareBatch(ctx, db, "INSERT INTO t VALUES (1)")
+	assert.Error(t, err)
+
+	b, err := PrepareBatch(ctx, db, "INSERT INTO t (a, b)")
+	require.NoError(t, err)
+	require.NoError(t, b.Append(1, "x"))
+	require.NoError(t, b.Flush())
+	require.NoError(t, b.Append(2, "it's"))
+	require.NoError(t, b.Send())
+	assert.Equal(t, ErrBatchSent, b.Append(3, "z"))
+	assert.Equal(t, ErrBatchSent, b.Send())
+
+	b, err = PrepareBatch(ctx, db, "INSERT INTO big")
+	require.NoError(t, err)
+	row := strings.Repeat("a", 1000)
+	for i := 0; i < 2000; i++ {
+		require.NoError(t, b.Append(row))
+	}
+	require.NoError(t, b.Send())
+
+	b, err = PrepareBatch(ctx, db, "INSERT INTO bad")
+	require.NoError(t, err)
+	require.NoError(t, b.Append(1))
+	assert.Error(t, b.Send())
+
+	require.Len(t, queries, 3)
+	assert.Equal(t, "INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'it\\'s')", queries[0])
+	assert.Equal(t, int64(-1), contentLengths[0])
+	assert.Equal(t, len("INSERT INTO big VALUES ")+2000*(len(row)+6)-2, len(queries[1]))
+
+	// the connections are released
+	assert.Equal(t, 0, db.Stats().InUse)
+}
+
+func TestBatchAbort(t *testing.T) {
+	bodyErr := make(chan error, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := ioutil.ReadAll(r.Body)
+		bodyErr <- err
+	}))
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", "http://"+ts.Listener.Addr().String()+"/default?max_body_size=100")
+	require.NoError(t, err)
+	defer db.Close()
+
+	b, err := PrepareBatch(context.Background(), db, "INSERT INTO t")
+	require.NoError(t, err)
+	require.NoError(t, b.Append(1))
+	require.NoError(t, b.Flush())
+	assert.Equal(t, ErrPayloadTooLarge{Actual: 109, Limit: 100}, b.Append(strings.Repeat("a", 100)))
+	require.NoError(t, b.Abort())
+	assert.Error(t, <-bodyErr)
+	assert.Equal(t, 0, db.Stats().InUse)
+}
+
+func TestWriteToTable(t *testing.T) {
+	queries := make(chan string, 1)
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries <- query
+		if strings.HasPrefix(query, "INSERT INTO missing") {
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, "Code: 60. DB::Exception: Table default.missing does not exist. (UNKNOWN_TABLE) (version 23.3.1.1)")
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	require.NoError(t, WriteToTable(ctx, db, "INSERT INTO t FORMAT CSV", strings.NewReader("1,a\n2,b\n")))
+	assert.Equal(t, "INSERT INTO t FORMAT CSV\n1,a\n2,b\n", <-queries)
+
+	err = WriteToTable(ctx, db, "INSERT INTO missing FORMAT Parquet", strings.NewReader("PAR1"))
+	var chErr *Error
+	require.True(t, errors.As(err, &chErr))
+	assert.Equal(t, 60, chErr.Code)
+	<-queries
+
+	for _, query := range []string{
+		"SELECT 1",
+		"INSERT INTO t",
+		"INSERT INTO t VALUES (1)",
+		"INSERT INTO t FORMAT CSV 1,a",
+		"INSERT INTO t SELECT 1 FORMAT CSV",
+	} {
+		assert.Error(t, WriteToTable(ctx, db, query, strings.NewReader("")), query)
+	}
+	assert.Equal(t, 0, db.Stats().InUse)
+
+	limited, err := sql.Open("clickhouse", dsn+"?max_body_size=4")
+	require.NoError(t, err)
+	defer limited.Close()
+	err = WriteToTable(ctx, limited, "INSERT INTO t FORMAT TSVRaw", strings.NewReader("1\n2\n3\n"))
+	var tooLarge ErrPayloadTooLarge
+	require.True(t, errors.As(err, &tooLarge), "%v", err)
+	assert.EqualValues(t, 4, tooLarge.Limit)
+}
@@ -0,0 +1,104 @@
+package clickhouse
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+)
+
+var reflectTypeBigInt = reflect.TypeOf((*big.Int)(nil))
+
+// bigIntParser parses Int128, Int256, UInt128 and UInt256 values
+type bigIntParser struct {
+	signed  bool
+	bitSize int
+}
+
+func (p *bigIntParser) Parse(s io.RuneScanner) (driver.Value, error) {
+	repr, err := readNumber(s)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := new(big.Int).SetString(repr, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid %s value %q", bigIntTypeName(p.signed, p.bitSize), repr)
+	}
+	if err := checkBigIntRange(v, p.signed, p.bitSize); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (p *bigIntParser) Type() reflect.Type {
+	return reflectTypeBigInt
+}
+
+// Int128 checks that v fits into Int128 column and passes it as a number
+func Int128(v *big.Int) driver.Valuer {
+	return bigInt{v, true, 128}
+}
+
+// Int256 checks that v fits into Int256 column and passes it as a number
+func Int256(v *big.Int) driver.Valuer {
+	return bigInt{v, true, 256}
+}
+
+// UInt128 checks that v fits into UInt128 column and passes it as a number
+func UInt128(v *big.Int) driver.Valuer {
+	return bigInt{v, false, 128}
+}
+
+// UInt256 checks that v fits into UInt256 column and passes it as a number
+func UInt256(v *big.Int) driver.Valuer {
+	return bigInt{v, false, 256}
+}
+
+type bigInt struct {
+	v       *big.Int
+	signed  bool
+	bitSize int
+}
+
+// Value implements driver.Valuer
+func (b bigInt) Value() (driver.Value, error) {
+	if b.v == nil {
+		return nil, nil
+	}
+	if err := checkBigIntRange(b.v, b.signed, b.bitSize); err != nil {
+		return nil, err
+	}
+	return []byte(b.v.String()), nil
+}
+
+// bigIntRange returns the minimum and the maximum values of the integers of
+// bitSize bits
+func bigIntRange(signed bool, bitSize int) (min, max *big.Int) {
+	if signed {
+		max = new(big.Int).Lsh(big.NewInt(1), uint(bitSize-1))
+		min = new(big.Int).Neg(max)
+		max.Sub(max, big.NewInt(1))
+	} else {
+		max = new(big.Int).Lsh(big.NewInt(1), uint(bitSize))
+		max.Sub(max, big.NewInt(1))
+		min = new(big.Int)
+	}
+	return min, max
+}
+
+// checkBigIntRange checks that v is in the range of the integer type
+func checkBigIntRange(v *big.Int, signed bool, bitSize int) error {
+	min, max := bigIntRange(signed, bitSize)
+	if v.Cmp(min) < 0 || v.Cmp(max) > 0 {
+		return fmt.Errorf("clickhouse: %s is out of range of %s", v, bigIntTypeName(signed, bitSize))
+	}
+	return nil
+}
+
+func bigIntTypeName(signed bool, bitSize int) string {
+	if signed {
+		return fmt.Sprintf("Int%d", bitSize)
+	}
+	return fmt.Sprintf("UInt%d", bitSize)
+}
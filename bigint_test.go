@@ -0,0 +1,68 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"math/big"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBigIntValue(t *testing.T) {
+	maxUInt256, _ := new(big.Int).SetString("115792089237316195423570985008687907853269984665640564039457584007913129639935", 10)
+	minInt128, _ := new(big.Int).SetString("-170141183460469231731687303715884105728", 10)
+	testCases := []struct {
+		value    driver.Valuer
+		expected driver.Value
+		fail     bool
+	}{
+		{value: UInt256(maxUInt256), expected: []byte(maxUInt256.String())},
+		{value: Int128(minInt128), expected: []byte(minInt128.String())},
+		{value: UInt128(big.NewInt(0)), expected: []byte("0")},
+		{value: Int256(big.NewInt(-1)), expected: []byte("-1")},
+		{value: UInt128(nil), expected: nil},
+		{value: UInt256(new(big.Int).Add(maxUInt256, big.NewInt(1))), fail: true},
+		{value: Int128(new(big.Int).Sub(minInt128, big.NewInt(1))), fail: true},
+		{value: UInt128(big.NewInt(-1)), fail: true},
+	}
+	for _, tc := range testCases {
+		dv, err := tc.value.Value()
+		if tc.fail {
+			assert.Error(t, err)
+		} else if assert.NoError(t, err) {
+			assert.Equal(t, tc.expected, dv)
+		}
+	}
+}
+
+func TestBigInt(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		if r.Method == http.MethodGet {
+			w.Write([]byte("a\tb\nInt128\tArray(UInt256)\n-170141183460469231731687303715884105728\t[1,2]\n"))
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var (
+		a *big.Int
+		b []*big.Int
+	)
+	require.NoError(t, db.QueryRow("SELECT a, b").Scan(&a, &b))
+	assert.Equal(t, "-170141183460469231731687303715884105728", a.String())
+	assert.Equal(t, []*big.Int{big.NewInt(1), big.NewInt(2)}, b)
+
+	_, err = db.Exec("INSERT INTO t VALUES (?, ?, ?)", a, Int256(big.NewInt(5)), Array([]*big.Int{big.NewInt(3)}))
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO t VALUES (-170141183460469231731687303715884105728, 5, [3])", queries[len(queries)-1])
+	_, err = db.Exec("INSERT INTO t VALUES (?)", UInt128(a))
+	assert.Error(t, err)
+}
@@ -0,0 +1,874 @@
+package clickhouse
+
+import (
+	"bufio"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// maxBinaryLength limits the lengths of strings, arrays and maps and the
+// number of columns read from a binary result, larger ones are malformed
+const maxBinaryLength = 1 << 31
+
+// binaryReader reads values of the RowBinary format
+type binaryReader struct {
+	r   *bufio.Reader
+	buf [32]byte
+}
+
+// read reads the next n bytes, the returned slice is only valid until the
+// next read
+func (r *binaryReader) read(n int) ([]byte, error) {
+	var b []byte
+	if n <= len(r.buf) {
+		b = r.buf[:n]
+	} else {
+		b = make([]byte, n)
+	}
+	_, err := io.ReadFull(r.r, b)
+	return b, err
+}
+
+// length reads the length of a string, an array or a map
+func (r *binaryReader) length() (int, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return 0, err
+	}
+	if n >= maxBinaryLength {
+		return 0, fmt.Errorf("malformed length %d", n)
+	}
+	return int(n), nil
+}
+
+// string reads a string prefixed with its length
+func (r *binaryReader) string() (string, error) {
+	n, err := r.length()
+	if err != nil {
+		return "", err
+	}
+	if n <= r.r.Size() {
+		// convert the buffered bytes without the intermediate copy
+		b, err := r.r.Peek(n)
+		if err != nil {
+			return "", noEOF(err)
+		}
+		s := string(b)
+		r.r.Discard(n)
+		return s, nil
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return "", noEOF(err)
+	}
+	return string(b), nil
+}
+
+// noEOF reports the end of the body in the middle of a value as unexpected
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// binaryDecoder decodes values of a column from the RowBinary format, the
+// values are of the same types as the values of DataParser
+type binaryDecoder interface {
+	decode(r *binaryReader) (driver.Value, error)
+	Type() reflect.Type
+}
+
+type binaryIntDecoder struct {
+	signed  bool
+	bitSize int
+}
+
+func (d *binaryIntDecoder) decode(r *binaryReader) (driver.Value, error) {
+	b, err := r.read(d.bitSize / 8)
+	if err != nil {
+		return nil, err
+	}
+	switch d.bitSize {
+	case 8:
+		if d.signed {
+			return int8(b[0]), nil
+		}
+		return b[0], nil
+	case 16:
+		v := binary.LittleEndian.Uint16(b)
+		if d.signed {
+			return int16(v), nil
+		}
+		return v, nil
+	case 32:
+		v := binary.LittleEndian.Uint32(b)
+		if d.signed {
+			return int32(v), nil
+		}
+		return v, nil
+	default:
+		v := binary.LittleEndian.Uint64(b)
+		if d.signed {
+			return int64(v), nil
+		}
+		return v, nil
+	}
+}
+
+func (d *binaryIntDecoder) Type() reflect.Type {
+	return (&intParser{d.signed, d.bitSize}).Type()
+}
+
+type binaryFloatDecoder struct {
+	bitSize int
+}
+
+func (d *binaryFloatDecoder) decode(r *binaryReader) (driver.Value, error) {
+	b, err := r.read(d.bitSize / 8)
+	if err != nil {
+		return nil, err
+	}
+	if d.bitSize == 32 {
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+}
+
+func (d *binaryFloatDecoder) Type() reflect.Type {
+	return (&floatParser{d.bitSize}).Type()
+}
+
+// binaryBigIntDecoder decodes Int128, Int256, UInt128 and UInt256 values
+type binaryBigIntDecoder struct {
+	signed  bool
+	bitSize int
+}
+
+func (d *binaryBigIntDecoder) decode(r *binaryReader) (driver.Value, error) {
+	return readBigInt(r, d.signed, d.bitSize)
+}
+
+func (d *binaryBigIntDecoder) Type() reflect.Type {
+	return reflectTypeBigInt
+}
+
+// readBigInt reads a little endian two's complement integer
+func readBigInt(r *binaryReader, signed bool, bitSize int) (*big.Int, error) {
+	b, err := r.read(bitSize / 8)
+	if err != nil {
+		return nil, err
+	}
+	be := make([]byte, len(b))
+	for i, c := range b {
+		be[len(b)-1-i] = c
+	}
+	v := new(big.Int).SetBytes(be)
+	if signed && be[0]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(bitSize)))
+	}
+	return v, nil
+}
+
+// binaryDecimalDecoder decodes decimals into strings like stringParser
+type binaryDecimalDecoder struct {
+	bitSize int
+	scale   int32
+}
+
+func (d *binaryDecimalDecoder) decode(r *binaryReader) (driver.Value, error) {
+	var unscaled *big.Int
+	switch d.bitSize {
+	case 32:
+		b, err := r.read(4)
+		if err != nil {
+			return nil, err
+		}
+		unscaled = big.NewInt(int64(int32(binary.LittleEndian.Uint32(b))))
+	case 64:
+		b, err := r.read(8)
+		if err != nil {
+			return nil, err
+		}
+		unscaled = big.NewInt(int64(binary.LittleEndian.Uint64(b)))
+	default:
+		var err error
+		if unscaled, err = readBigInt(r, true, d.bitSize); err != nil {
+			return nil, err
+		}
+	}
+	return Decimal{unscaled: unscaled, scale: d.scale}.String(), nil
+}
+
+func (d *binaryDecimalDecoder) Type() reflect.Type {
+	return reflectTypeString
+}
+
+type binaryStringDecoder struct {
+	length int
+}
+
+func (d *binaryStringDecoder) decode(r *binaryReader) (driver.Value, error) {
+	s, err := d.decodeString(r)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (d *binaryStringDecoder) decodeString(r *binaryReader) (string, error) {
+	if d.length == 0 {
+		return r.string()
+	}
+	b, err := r.read(d.length)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (d *binaryStringDecoder) Type() reflect.Type {
+	return reflectTypeString
+}
+
+type binaryEnumDecoder struct {
+	bitSize  int
+	names    map[int16]string
+	asNumber bool
+}
+
+func (d *binaryEnumDecoder) decode(r *binaryReader) (driver.Value, error) {
+	if !d.asNumber {
+		name, err := d.decodeString(r)
+		if err != nil {
+			return nil, err
+		}
+		return name, nil
+	}
+	v, err := d.decodeNumber(r)
+	if err != nil {
+		return nil, err
+	}
+	if d.bitSize == 8 {
+		return int8(v), nil
+	}
+	return v, nil
+}
+
+// decodeNumber decodes the number of the enum element
+func (d *binaryEnumDecoder) decodeNumber(r *binaryReader) (int16, error) {
+	b, err := r.read(d.bitSize / 8)
+	if err != nil {
+		return 0, err
+	}
+	if d.bitSize == 8 {
+		return int16(int8(b[0])), nil
+	}
+	return int16(binary.LittleEndian.Uint16(b)), nil
+}
+
+// decodeString decodes the name of the enum element
+func (d *binaryEnumDecoder) decodeString(r *binaryReader) (string, error) {
+	v, err := d.decodeNumber(r)
+	if err != nil {
+		return "", err
+	}
+	name, ok := d.names[v]
+	if !ok {
+		return "", fmt.Errorf("unknown enum element %d", v)
+	}
+	return name, nil
+}
+
+func (d *binaryEnumDecoder) Type() reflect.Type {
+	if !d.asNumber {
+		return reflectTypeString
+	}
+	if d.bitSize == 8 {
+		return reflectTypeInt8
+	}
+	return reflectTypeInt16
+}
+
+type binaryUUIDDecoder struct{}
+
+func (d *binaryUUIDDecoder) decode(r *binaryReader) (driver.Value, error) {
+	s, err := d.decodeString(r)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (d *binaryUUIDDecoder) decodeString(r *binaryReader) (string, error) {
+	b, err := r.read(16)
+	if err != nil {
+		return "", err
+	}
+	// the halves are little endian 64-bit numbers
+	var u UUID
+	for i := 0; i < 8; i++ {
+		u[i] = b[7-i]
+		u[8+i] = b[15-i]
+	}
+	return u.String(), nil
+}
+
+func (d *binaryUUIDDecoder) Type() reflect.Type {
+	return reflectTypeString
+}
+
+type binaryIPDecoder struct {
+	v4 bool
+}
+
+func (d *binaryIPDecoder) decode(r *binaryReader) (driver.Value, error) {
+	if d.v4 {
+		b, err := r.read(4)
+		if err != nil {
+			return nil, err
+		}
+		return net.IPv4(b[3], b[2], b[1], b[0]).To4(), nil
+	}
+	b, err := r.read(16)
+	if err != nil {
+		return nil, err
+	}
+	return append(net.IP(nil), b...), nil
+}
+
+func (d *binaryIPDecoder) Type() reflect.Type {
+	return reflectTypeIP
+}
+
+type binaryDateDecoder struct {
+	location *time.Location
+	date32   bool
+}
+
+func (d *binaryDateDecoder) decode(r *binaryReader) (driver.Value, error) {
+	return decodeTimeValue(d, r)
+}
+
+func (d *binaryDateDecoder) decodeTime(r *binaryReader) (time.Time, error) {
+	var days int
+	if d.date32 {
+		// the signed number of days since 1970-01-01
+		b, err := r.read(4)
+		if err != nil {
+			return time.Time{}, err
+		}
+		days = int(int32(binary.LittleEndian.Uint32(b)))
+	} else {
+		b, err := r.read(2)
+		if err != nil {
+			return time.Time{}, err
+		}
+		days = int(binary.LittleEndian.Uint16(b))
+	}
+	return time.Date(1970, 1, 1+days, 0, 0, 0, 0, d.location), nil
+}
+
+func (d *binaryDateDecoder) Type() reflect.Type {
+	return reflectTypeTime
+}
+
+type binaryDateTimeDecoder struct {
+	location *time.Location
+}
+
+func (d *binaryDateTimeDecoder) decode(r *binaryReader) (driver.Value, error) {
+	return decodeTimeValue(d, r)
+}
+
+func (d *binaryDateTimeDecoder) decodeTime(r *binaryReader) (time.Time, error) {
+	b, err := r.read(4)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(binary.LittleEndian.Uint32(b)), 0).In(d.location), nil
+}
+
+func (d *binaryDateTimeDecoder) Type() reflect.Type {
+	return reflectTypeTime
+}
+
+type binaryDateTime64Decoder struct {
+	precision int
+	location  *time.Location
+}
+
+func (d *binaryDateTime64Decoder) decode(r *binaryReader) (driver.Value, error) {
+	return decodeTimeValue(d, r)
+}
+
+func (d *binaryDateTime64Decoder) decodeTime(r *binaryReader) (time.Time, error) {
+	b, err := r.read(8)
+	if err != nil {
+		return time.Time{}, err
+	}
+	ticks := int64(binary.LittleEndian.Uint64(b))
+	scale := int64(math.Pow10(d.precision))
+	sec, frac := ticks/scale, ticks%scale
+	if frac < 0 {
+		sec--
+		frac += scale
+	}
+	return time.Unix(sec, frac*int64(math.Pow10(maxDateTime64Precision-d.precision))).In(d.location), nil
+}
+
+func (d *binaryDateTime64Decoder) Type() reflect.Type {
+	return reflectTypeTime
+}
+
+// binaryTimeDecoder is a decoder of Date and DateTime values
+type binaryTimeDecoder interface {
+	decodeTime(r *binaryReader) (time.Time, error)
+}
+
+func decodeTimeValue(d binaryTimeDecoder, r *binaryReader) (driver.Value, error) {
+	t, err := d.decodeTime(r)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// binaryIntervalDecoder decodes Interval values like intervalParser
+type binaryIntervalDecoder struct {
+	unit time.Duration
+}
+
+func (d *binaryIntervalDecoder) decode(r *binaryReader) (driver.Value, error) {
+	b, err := r.read(8)
+	if err != nil {
+		return nil, err
+	}
+	return intervalValue(int64(binary.LittleEndian.Uint64(b)), d.unit)
+}
+
+func (d *binaryIntervalDecoder) Type() reflect.Type {
+	return (&intervalParser{d.unit}).Type()
+}
+
+type binaryNothingDecoder struct{}
+
+func (d *binaryNothingDecoder) decode(r *binaryReader) (driver.Value, error) {
+	_, err := r.read(1)
+	return nil, err
+}
+
+func (d *binaryNothingDecoder) Type() reflect.Type {
+	return reflectTypeEmptyStruct
+}
+
+// binaryNullableDecoder decodes Nullable values like nullableParser, the
+// nested values are returned as pointers
+type binaryNullableDecoder struct {
+	arg    binaryDecoder
+	nested bool
+}
+
+func (d *binaryNullableDecoder) decode(r *binaryReader) (driver.Value, error) {
+	b, err := r.read(1)
+	if err != nil {
+		return nil, err
+	}
+	if b[0] != 0 {
+		if d.nested {
+			return reflect.Zero(d.Type()).Interface(), nil
+		}
+		return nil, nil
+	}
+	v, err := d.arg.decode(r)
+	if err != nil || !d.nested {
+		return v, noEOF(err)
+	}
+	ptr := reflect.New(d.arg.Type())
+	if v != nil {
+		ptr.Elem().Set(reflect.ValueOf(v))
+	}
+	return ptr.Interface(), nil
+}
+
+func (d *binaryNullableDecoder) Type() reflect.Type {
+	return reflect.PtrTo(d.arg.Type())
+}
+
+type binaryArrayDecoder struct {
+	arg binaryDecoder
+	typ reflect.Type
+}
+
+func (d *binaryArrayDecoder) decode(r *binaryReader) (driver.Value, error) {
+	n, err := r.length()
+	if err != nil {
+		return nil, err
+	}
+	slice := reflect.MakeSlice(d.typ, n, n)
+	for i := 0; i < n; i++ {
+		v, err := d.arg.decode(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode array element: %v", noEOF(err))
+		}
+		if v != nil {
+			slice.Index(i).Set(reflect.ValueOf(v))
+		}
+	}
+	return slice.Interface(), nil
+}
+
+func (d *binaryArrayDecoder) Type() reflect.Type {
+	return d.typ
+}
+
+type binaryTupleDecoder struct {
+	args []binaryDecoder
+	typ  reflect.Type
+}
+
+func (d *binaryTupleDecoder) decode(r *binaryReader) (driver.Value, error) {
+	struc := reflect.New(d.typ).Elem()
+	for i, arg := range d.args {
+		v, err := arg.decode(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode tuple element: %v", noEOF(err))
+		}
+		if v != nil {
+			struc.Field(i).Set(reflect.ValueOf(v))
+		}
+	}
+	return struc.Interface(), nil
+}
+
+func (d *binaryTupleDecoder) Type() reflect.Type {
+	return d.typ
+}
+
+type binaryMapDecoder struct {
+	key   binaryDecoder
+	value binaryDecoder
+	typ   reflect.Type
+}
+
+func (d *binaryMapDecoder) decode(r *binaryReader) (driver.Value, error) {
+	n, err := r.length()
+	if err != nil {
+		return nil, err
+	}
+	m := reflect.MakeMapWithSize(d.typ, n)
+	for i := 0; i < n; i++ {
+		k, err := d.key.decode(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode map key: %v", noEOF(err))
+		}
+		v, err := d.value.decode(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode map value: %v", noEOF(err))
+		}
+		m.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v))
+	}
+	return m.Interface(), nil
+}
+
+func (d *binaryMapDecoder) Type() reflect.Type {
+	return d.typ
+}
+
+// decimalBitSize returns the size of the decimal with the given precision
+func decimalBitSize(precision int64) int {
+	switch {
+	case precision <= 9:
+		return 32
+	case precision <= 18:
+		return 64
+	case precision <= 38:
+		return 128
+	default:
+		return 256
+	}
+}
+
+// newBinaryDecoder creates a decoder of the values of t, nested is set for
+// the elements of arrays, tuples and maps
+func newBinaryDecoder(t *TypeDesc, nested bool, opt *DataParserOptions) (binaryDecoder, error) {
+	switch t.Name {
+	case "Nothing":
+		return &binaryNothingDecoder{}, nil
+	case "Nullable":
+		if len(t.Args) != 1 {
+			return nil, fmt.Errorf("element type not specified for Nullable")
+		}
+		arg, err := newBinaryDecoder(t.Args[0], nested, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create decoder for Nullable elements: %v", err)
+		}
+		return &binaryNullableDecoder{arg: arg, nested: nested}, nil
+	case "Date", "Date32":
+		loc := time.UTC
+		if opt != nil && opt.Location != nil {
+			loc = opt.Location
+		}
+		return &binaryDateDecoder{location: loc, date32: t.Name == "Date32"}, nil
+	case "DateTime":
+		loc, err := columnLocation(t.Args, opt)
+		if err != nil {
+			return nil, err
+		}
+		return &binaryDateTimeDecoder{scanLocation(loc, opt)}, nil
+	case "DateTime64":
+		if len(t.Args) < 1 {
+			return nil, fmt.Errorf("precision not specified for DateTime64")
+		}
+		precision, err := strconv.Atoi(t.Args[0].Name)
+		if err != nil || precision < 0 || precision > maxDateTime64Precision {
+			return nil, fmt.Errorf("malformed precision specified for DateTime64: %s", t.Args[0].Name)
+		}
+		loc, err := columnLocation(t.Args[1:], opt)
+		if err != nil {
+			return nil, err
+		}
+		return &binaryDateTime64Decoder{precision, scanLocation(loc, opt)}, nil
+	case "UInt8":
+		return &binaryIntDecoder{false, 8}, nil
+	case "UInt16":
+		return &binaryIntDecoder{false, 16}, nil
+	case "UInt32":
+		return &binaryIntDecoder{false, 32}, nil
+	case "UInt64":
+		return &binaryIntDecoder{false, 64}, nil
+	case "Int8":
+		return &binaryIntDecoder{true, 8}, nil
+	case "Int16":
+		return &binaryIntDecoder{true, 16}, nil
+	case "Int32":
+		return &binaryIntDecoder{true, 32}, nil
+	case "Int64":
+		return &binaryIntDecoder{true, 64}, nil
+	case "UInt128":
+		return &binaryBigIntDecoder{false, 128}, nil
+	case "UInt256":
+		return &binaryBigIntDecoder{false, 256}, nil
+	case "Int128":
+		return &binaryBigIntDecoder{true, 128}, nil
+	case "Int256":
+		return &binaryBigIntDecoder{true, 256}, nil
+	case "Float32":
+		return &binaryFloatDecoder{32}, nil
+	case "Float64":
+		return &binaryFloatDecoder{64}, nil
+	case "Enum8", "Enum16":
+		bitSize := 8
+		if t.Name == "Enum16" {
+			bitSize = 16
+		}
+		if t.EnumValues == nil {
+			return nil, fmt.Errorf("element values not specified for %s", t.Name)
+		}
+		names := make(map[int16]string, len(t.EnumValues))
+		for name, v := range t.EnumValues {
+			names[v] = name
+		}
+		return &binaryEnumDecoder{bitSize: bitSize, names: names, asNumber: opt != nil && opt.EnumAsNumber}, nil
+	case "Decimal":
+		if len(t.Args) != 2 {
+			return nil, fmt.Errorf("precision and scale not specified for Decimal")
+		}
+		precision, err := strconv.ParseInt(t.Args[0].Name, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed precision specified for Decimal: %v", err)
+		}
+		scale, err := strconv.ParseInt(t.Args[1].Name, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("malformed scale specified for Decimal: %v", err)
+		}
+		return &binaryDecimalDecoder{decimalBitSize(precision), int32(scale)}, nil
+	case "Decimal32", "Decimal64", "Decimal128", "Decimal256":
+		if len(t.Args) != 1 {
+			return nil, fmt.Errorf("scale not specified for %s", t.Name)
+		}
+		scale, err := strconv.ParseInt(t.Args[0].Name, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("malformed scale specified for %s: %v", t.Name, err)
+		}
+		return &binaryDecimalDecoder{decimalBitSize(decimalPrecisions[t.Name]), int32(scale)}, nil
+	case "String":
+		return &binaryStringDecoder{}, nil
+	case "UUID":
+		return &binaryUUIDDecoder{}, nil
+	case "IPv4", "IPv6":
+		return &binaryIPDecoder{v4: t.Name == "IPv4"}, nil
+	case "FixedString":
+		if len(t.Args) != 1 {
+			return nil, fmt.Errorf("length not specified for FixedString")
+		}
+		length, err := strconv.Atoi(t.Args[0].Name)
+		if err != nil || length <= 0 {
+			return nil, fmt.Errorf("malformed length specified for FixedString: %s", t.Args[0].Name)
+		}
+		return &binaryStringDecoder{length}, nil
+	case "IntervalNanosecond", "IntervalMicrosecond", "IntervalMillisecond", "IntervalSecond", "IntervalMinute",
+		"IntervalHour", "IntervalDay", "IntervalWeek", "IntervalMonth", "IntervalQuarter", "IntervalYear":
+		return &binaryIntervalDecoder{intervalUnits[t.Name]}, nil
+	case "Array":
+		if len(t.Args) != 1 {
+			return nil, fmt.Errorf("element type not specified for Array")
+		}
+		arg, err := newBinaryDecoder(t.Args[0], true, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create decoder for array elements: %v", err)
+		}
+		return &binaryArrayDecoder{arg: arg, typ: reflect.SliceOf(arg.Type())}, nil
+	case "Tuple", "Nested":
+		if len(t.Args) < 1 {
+			return nil, fmt.Errorf("element types not specified for %s", t.Name)
+		}
+		if t.Name == "Nested" && len(t.ArgNames) != len(t.Args) {
+			return nil, fmt.Errorf("element names and types not specified for Nested")
+		}
+		args := make([]binaryDecoder, len(t.Args))
+		types := make([]reflect.Type, len(t.Args))
+		for i, arg := range t.Args {
+			d, err := newBinaryDecoder(arg, true, opt)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create decoder for %s element: %v", t.Name, err)
+			}
+			args[i], types[i] = d, d.Type()
+		}
+		tuple := &binaryTupleDecoder{args: args, typ: tupleType(types, t.ArgNames)}
+		if t.Name == "Nested" {
+			return &binaryArrayDecoder{arg: tuple, typ: reflect.SliceOf(tuple.typ)}, nil
+		}
+		return tuple, nil
+	case "Map":
+		if len(t.Args) != 2 {
+			return nil, fmt.Errorf("key and value types not specified for Map")
+		}
+		key, err := newBinaryDecoder(t.Args[0], true, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create decoder for map keys: %v", err)
+		}
+		if !key.Type().Comparable() {
+			return nil, fmt.Errorf("map keys of type %s are not supported", t.Args[0].Name)
+		}
+		value, err := newBinaryDecoder(t.Args[1], true, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create decoder for map values: %v", err)
+		}
+		return &binaryMapDecoder{key: key, value: value, typ: reflect.MapOf(key.Type(), value.Type())}, nil
+	case "Point", "Ring", "Polygon", "MultiPolygon":
+		return newGeoDecoder(t.Name), nil
+	case "SimpleAggregateFunction":
+		if len(t.Args) != 2 {
+			return nil, fmt.Errorf("function and type not specified for SimpleAggregateFunction")
+		}
+		return newBinaryDecoder(t.Args[1], nested, opt)
+	case "AggregateFunction":
+		// the states are sent without their length
+		return nil, fmt.Errorf("type %s is not supported in %s format", t.Name, FormatRowBinaryWithNamesAndTypes)
+	case "JSON", "Object":
+		// requires output_format_binary_write_json_as_string=1
+		return &binaryStringDecoder{}, nil
+	case "Variant", "Dynamic":
+		return nil, fmt.Errorf("type %s is not supported in %s format", t.Name, FormatRowBinaryWithNamesAndTypes)
+	case "LowCardinality":
+		if len(t.Args) != 1 {
+			return nil, fmt.Errorf("element type not specified for LowCardinality")
+		}
+		// RowBinary sends the values without the dictionary
+		return newBinaryDecoder(t.Args[0], nested, opt)
+	default:
+		return nil, fmt.Errorf("type %s is not supported", t.Name)
+	}
+}
+
+func newBinaryRows(c *conn, body io.ReadCloser, opt *DataParserOptions) (*binaryRows, error) {
+	r := &binaryRows{reader: binaryReader{r: newBodyReader(c, body)}}
+	columns, types, descs, err := readBinaryHeader(&r.reader)
+	if err != nil {
+		return nil, err
+	}
+	decoders := make([]binaryDecoder, len(descs))
+	for i, desc := range descs {
+		if decoders[i], err = newBinaryDecoder(desc, false, opt); err != nil {
+			return nil, err
+		}
+	}
+
+	r.resultRows = resultRows{
+		c:        c,
+		respBody: body,
+		columns:  columns,
+		types:    types,
+		descs:    descs,
+	}
+	r.decoders = decoders
+	return r, nil
+}
+
+// readBinaryHeader reads the names and the types of the columns of a result
+// in RowBinaryWithNamesAndTypes format
+func readBinaryHeader(r *binaryReader) (columns, types []string, descs []*TypeDesc, err error) {
+	n, err := r.length()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	columns = make([]string, n)
+	for i := range columns {
+		if columns[i], err = r.string(); err != nil {
+			return nil, nil, nil, noEOF(err)
+		}
+	}
+	types = make([]string, n)
+	for i := range types {
+		if types[i], err = r.string(); err != nil {
+			return nil, nil, nil, noEOF(err)
+		}
+	}
+	descs = make([]*TypeDesc, n)
+	for i, typ := range types {
+		if descs[i], err = ParseTypeDesc(typ); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return columns, types, descs, nil
+}
+
+// binaryRows reads the rows of RowBinaryWithNamesAndTypes format
+type binaryRows struct {
+	resultRows
+	reader   binaryReader
+	decoders []binaryDecoder
+}
+
+func (r *binaryRows) Next(dest []driver.Value) error {
+	if len(r.decoders) == 0 {
+		return io.EOF
+	}
+	for i, d := range r.decoders {
+		v, err := d.decode(&r.reader)
+		if err != nil {
+			if i > 0 || err != io.EOF {
+				// the end of the body is only expected between the rows
+				return noEOF(err)
+			}
+			return err
+		}
+		dest[i] = v
+	}
+	r.rowsRead++
+	return nil
+}
+
+// ColumnTypeScanType implements the driver.RowsColumnTypeScanType
+func (r *binaryRows) ColumnTypeScanType(index int) reflect.Type {
+	return r.decoders[index].Type()
+}
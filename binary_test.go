@@ -0,0 +1,174 @@
+package clickhouse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rowBinary builds a RowBinary body
+type rowBinary struct {
+	bytes.Buffer
+}
+
+func (b *rowBinary) str(s string) *rowBinary {
+	var n [binary.MaxVarintLen64]byte
+	b.Write(n[:binary.PutUvarint(n[:], uint64(len(s)))])
+	b.WriteString(s)
+	return b
+}
+
+func (b *rowBinary) le(v interface{}) *rowBinary {
+	binary.Write(b, binary.LittleEndian, v)
+	return b
+}
+
+func TestBinaryRows(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+	b := &rowBinary{}
+	b.le(uint8(3)).str("id").str("name").str("ts")
+	b.str("Int32").str("String").str("DateTime('Europe/Moscow')")
+	b.le(int32(-1)).str("hello").le(uint32(1600000000))
+	b.le(int32(2)).str("world").le(uint32(0))
+
+	rows, err := newBinaryRows(&conn{}, &bufReadCloser{bytes.NewReader(b.Bytes())}, &DataParserOptions{Location: time.UTC, UseDBLocation: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "ts"}, rows.Columns())
+	assert.Equal(t, reflect.TypeOf(int32(0)), rows.ColumnTypeScanType(0))
+	assert.Equal(t, "DateTime('Europe/Moscow')", rows.ColumnTypeDatabaseTypeName(2))
+
+	dest := make([]driver.Value, 3)
+	require.NoError(t, rows.Next(dest))
+	assert.Equal(t, []driver.Value{int32(-1), "hello", time.Unix(1600000000, 0).In(moscow)}, dest)
+	require.NoError(t, rows.Next(dest))
+	assert.Equal(t, []driver.Value{int32(2), "world", time.Unix(0, 0).In(moscow)}, dest)
+	assert.Equal(t, io.EOF, rows.Next(dest))
+	assert.NoError(t, rows.Close())
+
+	// the body ends in the middle of a row
+	truncated := b.Bytes()[:b.Len()-2]
+	rows, err = newBinaryRows(&conn{}, &bufReadCloser{bytes.NewReader(truncated)}, &DataParserOptions{Location: time.UTC, UseDBLocation: true})
+	require.NoError(t, err)
+	require.NoError(t, rows.Next(dest))
+	assert.Equal(t, io.ErrUnexpectedEOF, rows.Next(dest))
+}
+
+func TestBinaryDecoders(t *testing.T) {
+	uuid, err := ParseUUID("417ddc5d-e556-4d27-95dd-a34d84e46a50")
+	require.NoError(t, err)
+	int128 := new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 100))
+	int128Bytes := make([]byte, 16)
+	for i, c := range new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 128), int128).FillBytes(make([]byte, 16)) {
+		int128Bytes[15-i] = c
+	}
+
+	testCases := []struct {
+		typ      string
+		body     *rowBinary
+		expected interface{}
+	}{
+		{"UInt8", new(rowBinary).le(uint8(255)), uint8(255)},
+		{"Int16", new(rowBinary).le(int16(-300)), int16(-300)},
+		{"UInt64", new(rowBinary).le(uint64(math.MaxUint64)), uint64(math.MaxUint64)},
+		{"Float32", new(rowBinary).le(float32(1.5)), float32(1.5)},
+		{"Float64", new(rowBinary).le(-2.25), -2.25},
+		{"Int128", new(rowBinary).le(int128Bytes), int128},
+		{"Decimal(9, 2)", new(rowBinary).le(int32(-150)), "-1.50"},
+		{"Decimal64(3)", new(rowBinary).le(int64(1)), "0.001"},
+		{"FixedString(3)", new(rowBinary).le([]byte("ab\x00")), "ab\x00"},
+		{"FixedString(2)", new(rowBinary).le([]byte{0xff, 0}), "\xff\x00"},
+		{"IntervalMillisecond", new(rowBinary).le(int64(-1500)), -1500 * time.Millisecond},
+		{"IntervalYear", new(rowBinary).le(int64(2)), int64(2)},
+		{"LowCardinality(String)", new(rowBinary).str("low"), "low"},
+		{"JSON", new(rowBinary).str(`{"a":1}`), `{"a":1}`},
+		{"SimpleAggregateFunction(sum, UInt64)", new(rowBinary).le(uint64(5)), uint64(5)},
+		{"Enum8('a' = 1, 'b' = -2)", new(rowBinary).le(int8(-2)), "b"},
+		{"UUID", new(rowBinary).le([]byte{0x27, 0x4d, 0x56, 0xe5, 0x5d, 0xdc, 0x7d, 0x41, 0x50, 0x6a, 0xe4, 0x84, 0x4d, 0xa3, 0xdd, 0x95}), uuid.String()},
+		{"IPv4", new(rowBinary).le(uint32(0x0a000001)), net.IPv4(10, 0, 0, 1).To4()},
+		{"IPv6", new(rowBinary).le([]byte(net.ParseIP("2001:db8::1"))), net.ParseIP("2001:db8::1")},
+		{"Date", new(rowBinary).le(uint16(18628)), time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"Date32", new(rowBinary).le(int32(-25567)), time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"Date32", new(rowBinary).le(int32(120529)), time.Date(2299, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{"DateTime64(3, 'UTC')", new(rowBinary).le(int64(-1)), time.Date(1969, 12, 31, 23, 59, 59, 999000000, time.UTC)},
+		{"Array(Array(Int8))", new(rowBinary).le(uint8(2)).le(uint8(1)).le(int8(1)).le(uint8(0)), [][]int8{{1}, {}}},
+		{"Map(String, UInt8)", new(rowBinary).le(uint8(1)).str("a").le(uint8(1)), map[string]uint8{"a": 1}},
+		{"Nullable(Int8)", new(rowBinary).le(uint8(1)), nil},
+		{"Nullable(Int8)", new(rowBinary).le(uint8(0)).le(int8(-1)), int8(-1)},
+		{"Array(Nullable(String))", new(rowBinary).le(uint8(2)).le(uint8(1)).le(uint8(0)).str("a"), []*string{nil, stringPtr("a")}},
+		{"Tuple(Nullable(UInt8), LowCardinality(Nullable(String)))", new(rowBinary).le(uint8(0)).le(uint8(7)).le(uint8(1)), struct {
+			Field0 *uint8
+			Field1 *string
+		}{uint8Ptr(7), nil}},
+		{"Tuple(String, Int8)", new(rowBinary).str("x").le(int8(-1)), struct {
+			Field0 string
+			Field1 int8
+		}{"x", -1}},
+	}
+	for _, tc := range testCases {
+		desc, err := ParseTypeDesc(tc.typ)
+		require.NoError(t, err, tc.typ)
+		d, err := newBinaryDecoder(desc, false, &DataParserOptions{})
+		require.NoError(t, err, tc.typ)
+		v, err := d.decode(&binaryReader{r: bufio.NewReader(tc.body)})
+		if assert.NoError(t, err, tc.typ) {
+			assert.Equal(t, tc.expected, v, tc.typ)
+			if _, ok := d.(*binaryNullableDecoder); !ok {
+				assert.Equal(t, d.Type(), reflect.TypeOf(v), tc.typ)
+			}
+		}
+	}
+}
+
+func TestRowBinaryFormat(t *testing.T) {
+	var formats []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		format := r.URL.Query().Get("default_format")
+		formats = append(formats, format)
+		if format == FormatRowBinaryWithNamesAndTypes {
+			b := &rowBinary{}
+			b.le(uint8(1)).str("a").str("UInt8").le(uint8(1)).le(uint8(2))
+			w.Write(b.Bytes())
+		} else {
+			w.Write([]byte("a\nUInt8\n1\n2\n"))
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn+"?format="+FormatRowBinaryWithNamesAndTypes)
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, ctx := range []context.Context{context.Background(), WithChecksum(context.Background())} {
+		rows, err := db.QueryContext(ctx, "SELECT a")
+		require.NoError(t, err)
+		var values []uint8
+		for rows.Next() {
+			var v uint8
+			require.NoError(t, rows.Scan(&v))
+			values = append(values, v)
+		}
+		require.NoError(t, rows.Err())
+		rows.Close()
+		assert.Equal(t, []uint8{1, 2}, values)
+	}
+	// the checksums are computed over the text result
+	assert.Equal(t, []string{FormatRowBinaryWithNamesAndTypes, FormatTabSeparatedWithNamesAndTypes}, formats)
+
+	_, err = ParseDSN(dsn + "?format=Native")
+	assert.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package clickhouse
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// killQueryTimeout limits the time of sending KILL QUERY for a cancelled query
+const killQueryTimeout = 10 * time.Second
+
+// WithQueryIDCallback returns a context which makes the driver call fn with
+// the query_id of every query executed with it, e.g. to log it. Queries with
+// the callback, a tracer or a context which can be cancelled get a generated
+// query_id unless it is set with WithQueryID, so fn gets the query_id of the
+// query in system.query_log.
+func WithQueryIDCallback(ctx context.Context, fn func(queryID string)) context.Context {
+	return context.WithValue(ctx, queryIDCallbackKey, fn)
+}
+
+// WithQueryID returns a copy of ctx which sets the query_id of the queries
+// executed with it, e.g. to correlate them with the entries of
+// system.query_log. It is the same as the value of the QueryID key, an empty
+// id fails the queries.
+func WithQueryID(ctx context.Context, id string) context.Context {
+	if len(id) == 0 {
+		return context.WithValue(ctx, settingsErrKey, fmt.Errorf("clickhouse: empty query_id"))
+	}
+	return context.WithValue(ctx, QueryID, id)
+}
+
+// newQueryID returns a random UUID
+func newQueryID() string {
+	var b [16]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		return ""
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// watchCancel kills the query on the server if ctx is done before the
+// returned function is called. The HTTP request is aborted on cancellation,
+// but ClickHouse does not always notice that the client has gone and may
+// keep executing the query.
+func (c *conn) watchCancel(ctx context.Context, req *http.Request) func() {
+	queryID := req.URL.Query().Get(queryIDParamName)
+	if ctx.Done() == nil || len(queryID) == 0 {
+		return func() {}
+	}
+	var (
+		once sync.Once
+		stop = make(chan struct{})
+	)
+	transport, host := c.transport, req.URL.Host
+	go func() {
+		select {
+		case <-ctx.Done():
+			select {
+			case <-stop:
+			default:
+				c.killQuery(transport, host, queryID)
+			}
+		case <-stop:
+		}
+	}()
+	return func() {
+		once.Do(func() { close(stop) })
+	}
+}
+
+// killQuery sends KILL QUERY for the query with the transport of the connection,
+// the connection itself may be busy with the cancelled request
+func (c *conn) killQuery(transport http.RoundTripper, host, queryID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), killQueryTimeout)
+	defer cancel()
+	req, err := c.buildRequest(ctx, "KILL QUERY WHERE query_id = ? ASYNC", []driver.Value{queryID}, false)
+	if err != nil {
+		return
+	}
+	req.URL.Host = host
+	// the session is locked by the query
+	query := req.URL.Query()
+	query.Del("session_id")
+	query.Del("session_timeout")
+	req.URL.RawQuery = query.Encode()
+	resp, err := transport.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		c.logf(Logger.Infof, "kill query %s: %v", queryID, err)
+		return
+	}
+	resp.Body.Close()
+	c.logf(Logger.Infof, "kill query %s: %s", queryID, resp.Status)
+}
+
+// watchedBody stops watching for cancellation of the query once it is closed
+type watchedBody struct {
+	io.ReadCloser
+	stop func()
+}
+
+func (b *watchedBody) Close() error {
+	b.stop()
+	return b.ReadCloser.Close()
+}
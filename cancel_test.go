@@ -0,0 +1,97 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKillQueryOnCancel(t *testing.T) {
+	killed := make(chan string, 1)
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		switch {
+		case strings.HasPrefix(query, "KILL QUERY"):
+			killed <- query
+		case strings.HasPrefix(query, "SELECT sleep"):
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var queryIDs []string
+	ctx, cancel := context.WithTimeout(WithQueryIDCallback(context.Background(), func(queryID string) {
+		queryIDs = append(queryIDs, queryID)
+	}), 100*time.Millisecond)
+	defer cancel()
+	_, err = db.ExecContext(ctx, "SELECT sleep(3)")
+	assert.Error(t, err)
+
+	select {
+	case query := <-killed:
+		require.Len(t, queryIDs, 1)
+		assert.Len(t, queryIDs[0], 36)
+		assert.Equal(t, "KILL QUERY WHERE query_id = '"+queryIDs[0]+"' ASYNC", query)
+	case <-time.After(5 * time.Second):
+		t.Fatal("query is not killed")
+	}
+
+	// finished queries are not killed
+	ctx, cancel = context.WithCancel(context.WithValue(context.Background(), QueryID, "finished"))
+	_, err = db.ExecContext(ctx, "SELECT 1")
+	require.NoError(t, err)
+	cancel()
+	select {
+	case query := <-killed:
+		t.Fatalf("unexpected %s", query)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWithQueryID(t *testing.T) {
+	params := make(chan url.Values, 1)
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		params <- r.URL.Query()
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var queryIDs []string
+	ctx := WithQueryIDCallback(context.Background(), func(queryID string) {
+		queryIDs = append(queryIDs, queryID)
+	})
+	_, err = db.ExecContext(WithLogComment(WithQueryID(ctx, "traced-id"), "trace 42"), "INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	p := <-params
+	assert.Equal(t, "traced-id", p.Get("query_id"))
+	assert.Equal(t, "trace 42", p.Get("log_comment"))
+
+	// the query without an id gets a generated one for the callback
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	p = <-params
+	require.Len(t, queryIDs, 2)
+	assert.Equal(t, "traced-id", queryIDs[0])
+	assert.Len(t, queryIDs[1], 36)
+	assert.Equal(t, queryIDs[1], p.Get("query_id"))
+	assert.Empty(t, p.Get("log_comment"))
+
+	_, err = db.ExecContext(WithQueryID(ctx, ""), "INSERT INTO t VALUES (1)")
+	assert.EqualError(t, err, "clickhouse: empty query_id")
+}
@@ -0,0 +1,55 @@
+package clickhouse
+
+import (
+	"context"
+	"hash/crc32"
+	"sync"
+)
+
+// WithChecksum returns a copy of ctx which enables checksumming of the rows
+// returned by queries executed with it. The result can be read with Checksum.
+func WithChecksum(ctx context.Context) context.Context {
+	return context.WithValue(ctx, checksumKey, new(rowsChecksum))
+}
+
+// Checksum returns a running CRC32 (IEEE) of the values of all rows scanned
+// so far by queries executed with ctx. Each row is hashed as it was received
+// from the server: values separated by tabs and terminated by a new line.
+// It returns zero if ctx was not created by WithChecksum.
+//
+// The checksum is computed on the client only. It is the CRC32 of the
+// TabSeparated text of the rows and can not be compared with checksums
+// computed by the server, e.g. by sipHash64 or cityHash64, but only with the
+// CRC32 of the same TabSeparated output of the query, e.g. exported by
+// clickhouse-client. It is read from ctx rather than from sql.Rows because
+// database/sql does not expose the driver rows of sql.Rows.
+func Checksum(ctx context.Context) uint32 {
+	if c, ok := ctx.Value(checksumKey).(*rowsChecksum); ok {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.crc
+	}
+	return 0
+}
+
+type rowsChecksum struct {
+	mu  sync.Mutex
+	crc uint32
+}
+
+var (
+	tabSeparator     = []byte{'\t'}
+	newLineSeparator = []byte{'\n'}
+)
+
+func (c *rowsChecksum) update(row []string) {
+	c.mu.Lock()
+	for i, v := range row {
+		if i > 0 {
+			c.crc = crc32.Update(c.crc, crc32.IEEETable, tabSeparator)
+		}
+		c.crc = crc32.Update(c.crc, crc32.IEEETable, []byte(v))
+	}
+	c.crc = crc32.Update(c.crc, crc32.IEEETable, newLineSeparator)
+	c.mu.Unlock()
+}
@@ -0,0 +1,30 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"hash/crc32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksum(t *testing.T) {
+	assert.Zero(t, Checksum(context.Background()))
+
+	ctx := WithChecksum(context.Background())
+	buf := bytes.NewReader([]byte("Number\tText\nInt32\tString\n1\thello\n2\tworld\n"))
+	rows, err := newTextRows(&conn{}, &bufReadCloser{buf}, &DataParserOptions{Location: time.Local})
+	if !assert.NoError(t, err) {
+		return
+	}
+	rows.checksum = ctx.Value(checksumKey).(*rowsChecksum)
+
+	dest := make([]driver.Value, 2)
+	assert.NoError(t, rows.Next(dest))
+	assert.Equal(t, crc32.ChecksumIEEE([]byte("1\thello\n")), Checksum(ctx))
+	assert.NoError(t, rows.Next(dest))
+	assert.Equal(t, crc32.ChecksumIEEE([]byte("1\thello\n2\tworld\n")), Checksum(ctx))
+}
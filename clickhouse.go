@@ -1,8 +1,10 @@
 package clickhouse
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
+	"net/http"
 )
 
 func init() {
@@ -19,5 +21,86 @@ func (d *chDriver) Open(dsn string) (driver.Conn, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newConn(cfg), nil
+	if err = cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return connect(context.Background(), cfg, nil)
+}
+
+// Connection is a new connection passed to Config.OnConnect, e.g. to execute
+// SET statements in the session of the connection or to check the role of
+// the server. With the auto session the hook is also called with a
+// connection reused from the pool in its new session.
+type Connection interface {
+	driver.ExecerContext
+	driver.QueryerContext
+}
+
+// connect returns a new connection with the client if it is set, the
+// connection is closed if Config.OnConnect fails
+func connect(ctx context.Context, cfg *Config, client *http.Client) (driver.Conn, error) {
+	cn := newConn(cfg)
+	if client != nil {
+		cn.transport = clientTransport{client}
+	}
+	if cn.onConnect != nil {
+		if err := cn.onConnect(ctx, cn); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+// OpenConnector parses the DSN, so sql.Open fails on invalid options and
+// settings of it rather than the first query
+func (d *chDriver) OpenConnector(dsn string) (driver.Connector, error) {
+	cfg, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return NewConnector(cfg), nil
+}
+
+// NewConnector returns a connector for sql.OpenDB. It allows to use the
+// options of Config which can not be set in a DSN, such as QueryInterceptor.
+func NewConnector(cfg *Config) driver.Connector {
+	return &connector{cfg: cfg}
+}
+
+// Connector returns a connector for sql.OpenDB which sends requests with the
+// client, e.g. with a proxy, instrumentation or limits of connections. The
+// timeouts, TLS and idle connections options of cfg are not applied to the
+// client, they are the options of its transport.
+func Connector(cfg *Config, client *http.Client) driver.Connector {
+	return &connector{cfg: cfg, client: client}
+}
+
+// connector implements the driver.Connector
+type connector struct {
+	cfg    *Config
+	client *http.Client
+}
+
+// Connect returns new db connection
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	if err := c.cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return connect(ctx, c.cfg, c.client)
+}
+
+// clientTransport sends requests of a connection with a custom client
+type clientTransport struct {
+	client *http.Client
+}
+
+// RoundTrip implements the http.RoundTripper
+func (t clientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.client.Do(req)
+}
+
+// Driver returns the underlying driver
+func (c *connector) Driver() driver.Driver {
+	return new(chDriver)
 }
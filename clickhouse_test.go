@@ -3,6 +3,9 @@ package clickhouse
 import (
 	"database/sql"
 	"database/sql/driver"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"sync"
@@ -141,3 +144,17 @@ func parseDate(s string) time.Time {
 func parseDateTime(s string) time.Time {
 	return parseTime(timeFormat, s)
 }
+
+// newTestServer starts a fake ClickHouse HTTP server which passes
+// requests with their queries to handler and returns DSN for it
+func newTestServer(handler func(w http.ResponseWriter, r *http.Request, query string)) (*httptest.Server, string) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		handler(w, r, string(body))
+	}))
+	return ts, "http://" + ts.Listener.Addr().String() + "/default"
+}
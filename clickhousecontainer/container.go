@@ -0,0 +1,90 @@
+// Package clickhousecontainer starts ClickHouse servers in Docker containers
+// with testcontainers-go for integration tests of the code which uses the
+// clickhouse driver:
+//
+//	c, err := clickhousecontainer.Start(ctx, "24.8")
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer c.Terminate(ctx)
+//	db, err := sql.Open("clickhouse", c.DSN())
+//
+// It is a separate module, so the driver does not depend on testcontainers-go.
+package clickhousecontainer
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	image          = "clickhouse/clickhouse-server"
+	httpPort       = "8123/tcp"
+	startupTimeout = time.Minute
+)
+
+// Container is a running ClickHouse server
+type Container struct {
+	testcontainers.Container
+	dsn string
+}
+
+// Start starts a ClickHouse server of the given version (an image tag,
+// "latest" if it is empty) and waits until the server is ready. The container
+// is removed by Terminate.
+func Start(ctx context.Context, version string) (*Container, error) {
+	if len(version) == 0 {
+		version = "latest"
+	}
+	req := testcontainers.ContainerRequest{
+		Image:        image + ":" + version,
+		ExposedPorts: []string{httpPort},
+		Env:          map[string]string{"CLICKHOUSE_SKIP_USER_SETUP": "1"},
+		WaitingFor: wait.ForHTTP("/ping").
+			WithPort(httpPort).
+			WithResponseMatcher(isPong).
+			WithStartupTimeout(startupTimeout),
+	}
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		if c != nil {
+			c.Terminate(context.Background())
+		}
+		return nil, err
+	}
+	host, err := c.Host(ctx)
+	if err != nil {
+		c.Terminate(context.Background())
+		return nil, err
+	}
+	port, err := c.MappedPort(ctx, httpPort)
+	if err != nil {
+		c.Terminate(context.Background())
+		return nil, err
+	}
+	return &Container{
+		Container: c,
+		dsn:       "http://" + net.JoinHostPort(host, port.Port()) + "/default",
+	}, nil
+}
+
+// DSN returns the DSN of the default database of the server
+func (c *Container) DSN() string {
+	return c.dsn
+}
+
+// isPong reports whether the body is the response of the ping handler
+func isPong(body io.Reader) bool {
+	data, err := ioutil.ReadAll(body)
+	return err == nil && strings.HasPrefix(string(data), "Ok.")
+}
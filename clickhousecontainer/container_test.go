@@ -0,0 +1,41 @@
+package clickhousecontainer
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStart(t *testing.T) {
+	version := os.Getenv("TEST_CLICKHOUSE_VERSION")
+	if len(version) == 0 {
+		t.Skip("TEST_CLICKHOUSE_VERSION is not set")
+	}
+	ctx := context.Background()
+	c, err := Start(ctx, version)
+	require.NoError(t, err)
+	defer c.Terminate(ctx)
+
+	u, err := url.Parse(c.DSN())
+	require.NoError(t, err)
+	assert.Equal(t, "/default", u.Path)
+	resp, err := http.Get("http://" + u.Host + "/?query=" + url.QueryEscape("SELECT 1"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "1", strings.TrimSpace(string(body)))
+}
+
+func TestIsPong(t *testing.T) {
+	assert.True(t, isPong(strings.NewReader("Ok.\n")))
+	assert.False(t, isPong(strings.NewReader("Service Unavailable")))
+}
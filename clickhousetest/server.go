@@ -0,0 +1,290 @@
+// Package clickhousetest provides an in-memory ClickHouse HTTP server for unit
+// tests of the code which uses the clickhouse driver without a real server.
+//
+// The server records the queries it receives and answers them with the
+// responses of the handlers registered with On:
+//
+//	srv := clickhousetest.NewServer()
+//	defer srv.Close()
+//	srv.On(`^SELECT id, name FROM users`).Return(clickhousetest.Result{
+//		Columns: []string{"id", "name"},
+//		Types:   []string{"UInt64", "String"},
+//		Rows:    [][]interface{}{{1, "alice"}, {2, "bob"}},
+//	})
+//	srv.On(`^INSERT INTO users`).Fail(60, "Table default.users does not exist.")
+//	db, err := sql.Open("clickhouse", srv.DSN())
+//
+// Results are sent in the TabSeparatedWithNamesAndTypes format, so the
+// driver must not be configured with format=RowBinaryWithNamesAndTypes.
+package clickhousetest
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	clickhouse "github.com/mailru/go-clickhouse"
+)
+
+// Query is a query received by the server
+type Query struct {
+	SQL    string
+	Method string
+	// Params are the URL parameters of the request, e.g. the settings and
+	// the query_id of the query
+	Params url.Values
+}
+
+// Result is a result set of a query, the values of the rows are formatted
+// like the arguments of the driver, strings and time.Time values as they are
+// and nil values as NULL
+type Result struct {
+	Columns []string
+	Types   []string
+	Rows    [][]interface{}
+}
+
+// Server is a fake ClickHouse server
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	queries  []Query
+	handlers []*Handler
+}
+
+// NewServer starts a server which answers the queries without a matching
+// handler with an empty successful response, e.g. the INSERTs and the
+// SELECT 1 of db.Ping
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// DSN returns the DSN of the default database of the server
+func (s *Server) DSN() string {
+	return s.URL + "/default"
+}
+
+// Queries returns the queries received by the server in order
+func (s *Server) Queries() []Query {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Query(nil), s.queries...)
+}
+
+// Reset forgets the received queries and the registered handlers
+func (s *Server) Reset() {
+	s.mu.Lock()
+	s.queries, s.handlers = nil, nil
+	s.mu.Unlock()
+}
+
+// On registers a handler of the queries matching the regular expression
+// pattern, the handlers are tried in the order of registration. It panics if
+// the pattern is not valid.
+func (s *Server) On(pattern string) *Handler {
+	h := &Handler{re: regexp.MustCompile(pattern)}
+	s.mu.Lock()
+	s.handlers = append(s.handlers, h)
+	s.mu.Unlock()
+	return h
+}
+
+// Handler is the response to the queries matching its pattern. The methods
+// of the handler must be called before it gets queries.
+type Handler struct {
+	re          *regexp.Regexp
+	result      *Result
+	code        int
+	message     string
+	delay       time.Duration
+	writtenRows int64
+	times       int
+	limited     bool
+}
+
+// Return makes the handler respond with the result
+func (h *Handler) Return(result Result) *Handler {
+	h.result = &result
+	return h
+}
+
+// Fail makes the handler respond with an exception of the server
+func (h *Handler) Fail(code int, message string) *Handler {
+	h.code, h.message = code, message
+	return h
+}
+
+// Delay makes the handler wait before it responds, the wait stops if the
+// client cancels the request, e.g. on the timeout of its context
+func (h *Handler) Delay(d time.Duration) *Handler {
+	h.delay = d
+	return h
+}
+
+// WrittenRows makes the handler report the number of written rows in the
+// summary of the response, it is RowsAffected of the result of Exec
+func (h *Handler) WrittenRows(n int64) *Handler {
+	h.writtenRows = n
+	return h
+}
+
+// Times limits the number of queries the handler answers, the next queries
+// go to the handlers registered after it, e.g. to simulate a failure which
+// is gone on a retry
+func (h *Handler) Times(n int) *Handler {
+	h.times, h.limited = n, true
+	return h
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	query, err := readQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.queries = append(s.queries, Query{SQL: query, Method: r.Method, Params: r.URL.Query()})
+	var h *Handler
+	for _, handler := range s.handlers {
+		if handler.limited && handler.times <= 0 || !handler.re.MatchString(query) {
+			continue
+		}
+		if handler.limited {
+			handler.times--
+		}
+		h = handler
+		break
+	}
+	s.mu.Unlock()
+	if h == nil {
+		return
+	}
+	if h.delay > 0 {
+		select {
+		case <-time.After(h.delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if h.writtenRows > 0 {
+		w.Header().Set("X-ClickHouse-Summary", fmt.Sprintf(`{"written_rows":"%d"}`, h.writtenRows))
+	}
+	if h.code != 0 {
+		w.Header().Set("X-ClickHouse-Exception-Code", fmt.Sprint(h.code))
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Code: %d. DB::Exception: %s (version 0.0.0.0)\n", h.code, h.message)
+		return
+	}
+	if h.result != nil {
+		body, err := formatResult(h.result)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		io.WriteString(w, body)
+	}
+}
+
+// readQuery returns the query of the request: the query URL parameter
+// followed by the body unless it holds external tables
+func readQuery(r *http.Request) (string, error) {
+	query := r.URL.Query().Get("query")
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		return query, nil
+	}
+	var body io.Reader = r.Body
+	switch r.Header.Get("Content-Encoding") {
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return "", err
+		}
+		body = gz
+	case "deflate":
+		zr, err := zlib.NewReader(r.Body)
+		if err != nil {
+			return "", err
+		}
+		body = zr
+	}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	if len(query) > 0 && len(b) > 0 {
+		query += "\n"
+	}
+	return query + string(b), nil
+}
+
+// tsvEscaper escapes strings as ClickHouse does in the TabSeparated formats
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\t", `\t`, "\n", `\n`)
+
+// formatResult formats the result in the TabSeparatedWithNamesAndTypes format
+func formatResult(result *Result) (string, error) {
+	if len(result.Columns) != len(result.Types) {
+		return "", fmt.Errorf("clickhousetest: %d columns with %d types", len(result.Columns), len(result.Types))
+	}
+	var b strings.Builder
+	writeRow := func(values []string) {
+		for i, v := range values {
+			if i > 0 {
+				b.WriteByte('\t')
+			}
+			b.WriteString(v)
+		}
+		b.WriteByte('\n')
+	}
+	names := make([]string, len(result.Columns))
+	types := make([]string, len(result.Types))
+	for i, name := range result.Columns {
+		names[i] = tsvEscaper.Replace(name)
+		// e.g. the timezones of DateTime('Asia/Tokyo')
+		types[i] = tsvEscaper.Replace(result.Types[i])
+	}
+	writeRow(names)
+	writeRow(types)
+	for _, row := range result.Rows {
+		if len(row) != len(result.Columns) {
+			return "", fmt.Errorf("clickhousetest: row of %d values for %d columns", len(row), len(result.Columns))
+		}
+		values := make([]string, len(row))
+		for i, v := range row {
+			s, err := formatValue(v)
+			if err != nil {
+				return "", err
+			}
+			values[i] = s
+		}
+		writeRow(values)
+	}
+	return b.String(), nil
+}
+
+// formatValue formats the value of a column, arrays, maps and tuples are
+// formatted like in queries, as ClickHouse does in the text formats
+func formatValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return `\N`, nil
+	case string:
+		return tsvEscaper.Replace(v), nil
+	case []byte:
+		return tsvEscaper.Replace(string(v)), nil
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05"), nil
+	}
+	return clickhouse.FormatValue(v)
+}
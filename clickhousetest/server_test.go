@@ -0,0 +1,115 @@
+package clickhousetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	clickhouse "github.com/mailru/go-clickhouse"
+)
+
+func TestServer(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.On(`^SELECT id, name, tags, created FROM users`).Return(Result{
+		Columns: []string{"id", "name", "tags", "created"},
+		Types:   []string{"UInt64", "Nullable(String)", "Array(String)", "DateTime"},
+		Rows: [][]interface{}{
+			{1, "it's\ta", []string{"x", "y'"}, time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)},
+			{2, nil, []string{}, time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)},
+		},
+	})
+	srv.On(`^INSERT INTO missing`).Fail(60, "Table default.missing does not exist")
+	srv.On(`^INSERT INTO users`).WrittenRows(2)
+
+	db, err := sql.Open("clickhouse", srv.DSN()+"?compress=gzip")
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.Ping())
+
+	rows, err := db.Query("SELECT id, name, tags, created FROM users WHERE id > ?", 0)
+	require.NoError(t, err)
+	type user struct {
+		id      uint64
+		name    sql.NullString
+		tags    []string
+		created time.Time
+	}
+	var users []user
+	for rows.Next() {
+		var u user
+		require.NoError(t, rows.Scan(&u.id, &u.name, &u.tags, &u.created))
+		users = append(users, u)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []user{
+		{1, sql.NullString{String: "it's\ta", Valid: true}, []string{"x", "y'"}, time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{2, sql.NullString{}, []string{}, time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}, users)
+
+	result, err := db.Exec("INSERT INTO users VALUES (?, ?)", 3, "carol")
+	require.NoError(t, err)
+	affected, err := result.RowsAffected()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, affected)
+
+	_, err = db.Exec("INSERT INTO missing VALUES (1)")
+	var chErr *clickhouse.Error
+	require.True(t, errors.As(err, &chErr))
+	assert.Equal(t, 60, chErr.Code)
+	assert.Equal(t, "Table default.missing does not exist", chErr.Message)
+
+	queries := srv.Queries()
+	require.Len(t, queries, 4)
+	assert.Equal(t, "SELECT 1", queries[0].SQL)
+	assert.Equal(t, "SELECT id, name, tags, created FROM users WHERE id > 0", queries[1].SQL)
+	assert.Equal(t, "GET", queries[1].Method)
+	assert.Equal(t, "INSERT INTO users VALUES (3, 'carol')", queries[2].SQL)
+	assert.Equal(t, "POST", queries[2].Method)
+
+	srv.Reset()
+	assert.Empty(t, srv.Queries())
+}
+
+func TestServerDelayAndTimes(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.On(`^INSERT`).Times(1).Fail(202, "Too many simultaneous queries")
+	srv.On(`^SELECT sleep`).Delay(time.Second)
+
+	db, err := sql.Open("clickhouse", srv.DSN())
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("INSERT INTO t VALUES (1)")
+	assert.Error(t, err)
+	// the failure is gone once the handler is exhausted
+	_, err = db.Exec("INSERT INTO t VALUES (1)")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = db.ExecContext(ctx, "SELECT sleep(1)")
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestFormatResult(t *testing.T) {
+	_, err := formatResult(&Result{Columns: []string{"a"}, Types: []string{"UInt8", "String"}})
+	assert.Error(t, err)
+	_, err = formatResult(&Result{Columns: []string{"a"}, Types: []string{"UInt8"}, Rows: [][]interface{}{{1, 2}}})
+	assert.Error(t, err)
+	body, err := formatResult(&Result{
+		Columns: []string{"a", "m", "t"},
+		Types:   []string{"String", "Map(String, UInt8)", "DateTime('UTC')"},
+		Rows:    [][]interface{}{{"x\\y\n", map[string]int{"k": 1}, time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "a\tm\tt\nString\tMap(String, UInt8)\tDateTime(\\'UTC\\')\nx\\\\y\\n\t{'k':1}\t2021-01-02 03:04:05\n", body)
+}
@@ -0,0 +1,191 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// DiscoverCluster returns addresses of all hosts of the clusters known to the
+// node specified by seedDSN. ClickHouse does not expose HTTP ports of the
+// cluster nodes, so every address is the host name joined with the port of
+// the seed node.
+func DiscoverCluster(ctx context.Context, seedDSN string) ([]string, error) {
+	cfg, err := ParseDSN(seedDSN)
+	if err != nil {
+		return nil, err
+	}
+	_, port, err := net.SplitHostPort(cfg.hosts()[0])
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("clickhouse", seedDSN)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT DISTINCT host_name FROM system.clusters ORDER BY host_name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var hosts []string
+	for rows.Next() {
+		var host string
+		if err := rows.Scan(&host); err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, net.JoinHostPort(host, port))
+	}
+	return hosts, rows.Err()
+}
+
+// ddlPollInterval is the interval of polling system.distributed_ddl_queue
+// for the status of a distributed DDL query
+var ddlPollInterval = time.Second
+
+// DDLHostResult is the result of a distributed DDL query on a host of the
+// cluster, Err is a *Error with the exception of the host if it failed
+type DDLHostResult struct {
+	Host string
+	Port int
+	Err  error
+}
+
+// ExecOnCluster executes the DDL query with the ON CLUSTER clause added to
+// it, e.g. "CREATE TABLE db.t (...) ENGINE = ..." becomes
+// "CREATE TABLE db.t ON CLUSTER `cluster` (...) ENGINE = ...", and waits
+// until all hosts of the cluster have executed it. The query is sent
+// without waiting for the hosts on the server, which is limited by
+// distributed_ddl_task_timeout, the status of its entry is polled from
+// system.distributed_ddl_queue until it is finished on all hosts or ctx is
+// done. The results of the hosts are returned with an error if the query
+// failed on any of them.
+//
+// The entry is the first one of the cluster created by the initiator node
+// after the query was sent, concurrent DDL queries of the same cluster sent
+// to the same node may be confused.
+func ExecOnCluster(ctx context.Context, db *sql.DB, cluster, ddl string) ([]DDLHostResult, error) {
+	query, err := addOnCluster(ddl, cluster)
+	if err != nil {
+		return nil, err
+	}
+	sqlConn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer sqlConn.Close()
+	// the initiator of the query, also with the read hosts
+	ctx = WithStickyHost(ctx)
+	var (
+		initiator string
+		start     int64
+	)
+	if err = sqlConn.QueryRowContext(ctx, "SELECT FQDN(), toUnixTimestamp(now())").Scan(&initiator, &start); err != nil {
+		return nil, err
+	}
+	async := WithSettings(ctx, map[string]interface{}{"distributed_ddl_task_timeout": 0})
+	if _, err = sqlConn.ExecContext(async, query); err != nil {
+		return nil, err
+	}
+	for {
+		var entry string
+		err := sqlConn.QueryRowContext(ctx, "SELECT entry FROM system.distributed_ddl_queue "+
+			"WHERE cluster = ? AND initiator_host = ? AND query_create_time >= toDateTime(?) ORDER BY entry LIMIT 1",
+			cluster, initiator, start).Scan(&entry)
+		switch {
+		case err == nil:
+			if results, done, err := ddlStatus(ctx, sqlConn, entry); err != nil || done {
+				return results, err
+			}
+		case err != sql.ErrNoRows:
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(ddlPollInterval):
+		}
+	}
+}
+
+// ddlStatus returns the results of the hosts of the entry of the
+// distributed DDL queue, done is false until all hosts have finished it
+func ddlStatus(ctx context.Context, conn *sql.Conn, entry string) (results []DDLHostResult, done bool, err error) {
+	rows, err := conn.QueryContext(ctx, "SELECT host, port, status, exception_code, exception_text "+
+		"FROM system.distributed_ddl_queue WHERE entry = ? ORDER BY host, port", entry)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+	done = true
+	var failed []string
+	for rows.Next() {
+		var (
+			r      DDLHostResult
+			status sql.NullString
+			code   sql.NullInt64
+			text   sql.NullString
+		)
+		if err = rows.Scan(&r.Host, &r.Port, &status, &code, &text); err != nil {
+			return nil, false, err
+		}
+		if status.String != "Finished" {
+			done = false
+		}
+		if code.Int64 != 0 {
+			r.Err = &Error{Code: int(code.Int64), Message: text.String}
+			failed = append(failed, fmt.Sprintf("%s:%d: %v", r.Host, r.Port, r.Err))
+		}
+		results = append(results, r)
+	}
+	if err = rows.Err(); err != nil || !done {
+		return nil, false, err
+	}
+	if len(failed) > 0 {
+		err = fmt.Errorf("clickhouse: DDL failed on %d of %d hosts: %s", len(failed), len(results), strings.Join(failed, "; "))
+	}
+	return results, true, err
+}
+
+// addOnCluster adds the ON CLUSTER clause to the DDL query after the name of
+// its object, or to the end of RENAME and EXCHANGE queries
+func addOnCluster(ddl, cluster string) (string, error) {
+	words, err := splitSQL(ddl)
+	if err != nil {
+		return "", err
+	}
+	if len(words) == 0 || !words[0].is("CREATE", "ATTACH", "DETACH", "DROP", "ALTER", "TRUNCATE", "OPTIMIZE", "RENAME", "EXCHANGE") {
+		return "", fmt.Errorf("clickhouse: ExecOnCluster expects a DDL query")
+	}
+	for i := 0; i+1 < len(words); i++ {
+		if words[i].is("ON") && words[i+1].is("CLUSTER") {
+			return "", fmt.Errorf("clickhouse: ExecOnCluster expects a query without ON CLUSTER")
+		}
+	}
+	clause := " ON CLUSTER " + QuoteIdentifier(cluster)
+	if words[0].is("RENAME", "EXCHANGE") {
+		end := words[len(words)-1]
+		if end.text == ";" && len(words) > 1 {
+			end = words[len(words)-2]
+		}
+		return ddl[:end.end] + clause + ddl[end.end:], nil
+	}
+	i := 1
+	for i < len(words) && words[i].is("OR", "REPLACE", "TEMPORARY", "TABLE", "DATABASE", "VIEW", "MATERIALIZED",
+		"DICTIONARY", "FUNCTION", "IF", "NOT", "EXISTS") {
+		i++
+	}
+	if i >= len(words) || words[i].text == "(" {
+		return "", fmt.Errorf("clickhouse: name expected in %q", ddl)
+	}
+	// the name may be qualified with the database
+	for i+2 < len(words) && words[i+1].text == "." && words[i+1].end == words[i+2].start && words[i+1].start == words[i].end {
+		i += 2
+	}
+	return ddl[:words[i].end] + clause + ddl[words[i].end:], nil
+}
@@ -0,0 +1,119 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoverCluster(t *testing.T) {
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		assert.Equal(t, "SELECT DISTINCT host_name FROM system.clusters ORDER BY host_name", query)
+		io.WriteString(w, "host_name\nString\nch1.local\nch2.local\n")
+	})
+	defer ts.Close()
+
+	hosts, err := DiscoverCluster(context.Background(), dsn)
+	require.NoError(t, err)
+	cfg, err := ParseDSN(dsn)
+	require.NoError(t, err)
+	port := cfg.Host[len("127.0.0.1"):]
+	assert.Equal(t, []string{"ch1.local" + port, "ch2.local" + port}, hosts)
+
+	_, err = DiscoverCluster(context.Background(), "://wrong")
+	assert.Error(t, err)
+}
+
+func TestExecOnCluster(t *testing.T) {
+	defer func(interval time.Duration) { ddlPollInterval = interval }(ddlPollInterval)
+	ddlPollInterval = time.Millisecond
+
+	var (
+		mu      sync.Mutex
+		ddl     string
+		polls   int
+		timeout string
+	)
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasPrefix(query, "SELECT FQDN()"):
+			io.WriteString(w, "FQDN()\ttoUnixTimestamp(now())\nString\tUInt32\nnode1\t1609459200\n")
+		case strings.HasPrefix(query, "SELECT entry"):
+			assert.Contains(t, query, "cluster = 'c' AND initiator_host = 'node1' AND query_create_time >= toDateTime(1609459200)")
+			polls++
+			io.WriteString(w, "entry\nString\n")
+			if polls > 1 {
+				io.WriteString(w, "query-0000000001\n")
+			}
+		case strings.HasPrefix(query, "SELECT host"):
+			assert.Contains(t, query, "entry = 'query-0000000001'")
+			io.WriteString(w, "host\tport\tstatus\texception_code\texception_text\n"+
+				"String\tUInt16\tNullable(String)\tNullable(UInt16)\tNullable(String)\n")
+			if polls < 3 {
+				io.WriteString(w, "node1\t9000\tFinished\t0\t\nnode2\t9000\tActive\t\\N\t\\N\n")
+			} else {
+				io.WriteString(w, "node1\t9000\tFinished\t0\t\nnode2\t9000\tFinished\t57\tTable db.t already exists\n")
+			}
+		default:
+			ddl, timeout = query, r.URL.Query().Get("distributed_ddl_task_timeout")
+		}
+	})
+	defer ts.Close()
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	results, err := ExecOnCluster(context.Background(), db, "c", "CREATE TABLE db.t (a UInt8) ENGINE = Memory")
+	require.Error(t, err)
+	assert.Equal(t, "clickhouse: DDL failed on 1 of 2 hosts: node2:9000: Code: 57, Message: Table db.t already exists", err.Error())
+	assert.Equal(t, "CREATE TABLE db.t ON CLUSTER `c` (a UInt8) ENGINE = Memory", ddl)
+	assert.Equal(t, "0", timeout)
+	assert.Equal(t, 3, polls)
+	require.Len(t, results, 2)
+	assert.Equal(t, DDLHostResult{Host: "node1", Port: 9000}, results[0])
+	assert.Equal(t, "node2", results[1].Host)
+	var chErr *Error
+	require.True(t, errors.As(results[1].Err, &chErr))
+	assert.Equal(t, 57, chErr.Code)
+
+	_, err = ExecOnCluster(context.Background(), db, "c", "SELECT 1")
+	assert.Error(t, err)
+}
+
+func TestAddOnCluster(t *testing.T) {
+	testCases := []struct {
+		ddl      string
+		expected string
+	}{
+		{"CREATE TABLE IF NOT EXISTS db.t (a UInt8) ENGINE = Memory", "CREATE TABLE IF NOT EXISTS db.t ON CLUSTER `c` (a UInt8) ENGINE = Memory"},
+		{"CREATE OR REPLACE VIEW v AS SELECT 1", "CREATE OR REPLACE VIEW v ON CLUSTER `c` AS SELECT 1"},
+		{"CREATE MATERIALIZED VIEW `db`.`v` TO t AS SELECT 1", "CREATE MATERIALIZED VIEW `db`.`v` ON CLUSTER `c` TO t AS SELECT 1"},
+		{"CREATE DATABASE db", "CREATE DATABASE db ON CLUSTER `c`"},
+		{"ALTER TABLE t ADD COLUMN b String", "ALTER TABLE t ON CLUSTER `c` ADD COLUMN b String"},
+		{"DROP TABLE IF EXISTS db.t SYNC", "DROP TABLE IF EXISTS db.t ON CLUSTER `c` SYNC"},
+		{"TRUNCATE TABLE t", "TRUNCATE TABLE t ON CLUSTER `c`"},
+		{"RENAME TABLE a TO b, c TO d;", "RENAME TABLE a TO b, c TO d ON CLUSTER `c`;"},
+		{"EXCHANGE TABLES a AND b", "EXCHANGE TABLES a AND b ON CLUSTER `c`"},
+	}
+	for _, tc := range testCases {
+		query, err := addOnCluster(tc.ddl, "c")
+		if assert.NoError(t, err, tc.ddl) {
+			assert.Equal(t, tc.expected, query)
+		}
+	}
+	for _, ddl := range []string{"", "SELECT 1", "INSERT INTO t VALUES (1)", "DROP TABLE t ON CLUSTER c", "CREATE TABLE (a UInt8)", "DROP TABLE"} {
+		_, err := addOnCluster(ddl, "c")
+		assert.Error(t, err, ddl)
+	}
+}
@@ -0,0 +1,249 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCoalesceMaxRows = 10000
+	defaultCoalesceMaxWait = time.Second
+)
+
+// ErrWriterClosed is returned by CoalescingWriter.Write after Close
+var ErrWriterClosed = errors.New("clickhouse: writer is closed")
+
+// CoalesceOptions describes CoalescingWriter options.
+type CoalesceOptions struct {
+	// Columns to insert, if empty values of all columns of the table are expected.
+	Columns []string
+	// MaxRows is a number of buffered rows that triggers a flush, 10000 by default.
+	MaxRows int
+	// MaxWait is a maximum time a row can be buffered before a flush, 1s by default.
+	MaxWait time.Duration
+	// ErrChan receives errors of the flushes triggered by MaxWait if it is not
+	// full. The first of the errors is also returned by the next Write, Flush
+	// or Close.
+	ErrChan chan<- error
+}
+
+// CoalescingWriter buffers rows written from multiple goroutines and sends
+// them to the table as a single INSERT request.
+type CoalescingWriter struct {
+	db      *sql.DB
+	query   string
+	columns int
+	opts    CoalesceOptions
+
+	mu     sync.Mutex
+	rows   [][]interface{}
+	timer  *time.Timer
+	closed bool
+	// err is the error of a flush triggered by MaxWait
+	err error
+	// flushes are the pending and running flushes triggered by MaxWait
+	flushes sync.WaitGroup
+}
+
+// NewCoalescingWriter creates a new CoalescingWriter inserting rows into table
+func NewCoalescingWriter(db *sql.DB, table string, opts CoalesceOptions) *CoalescingWriter {
+	if opts.MaxRows <= 0 {
+		opts.MaxRows = defaultCoalesceMaxRows
+	}
+	if opts.MaxWait <= 0 {
+		opts.MaxWait = defaultCoalesceMaxWait
+	}
+	query := "INSERT INTO " + table
+	if len(opts.Columns) > 0 {
+		query += " (" + strings.Join(opts.Columns, ", ") + ")"
+	}
+	return &CoalescingWriter{
+		db:      db,
+		query:   query + " VALUES ",
+		columns: len(opts.Columns),
+		opts:    opts,
+	}
+}
+
+// InserterOption is an option of NewBufferedInserter
+type InserterOption func(*CoalesceOptions)
+
+// FlushEvery sets the maximum time a row is buffered before a flush
+func FlushEvery(d time.Duration) InserterOption {
+	return func(opts *CoalesceOptions) {
+		opts.MaxWait = d
+	}
+}
+
+// MaxRows sets the number of buffered rows which triggers a flush
+func MaxRows(n int) InserterOption {
+	return func(opts *CoalesceOptions) {
+		opts.MaxRows = n
+	}
+}
+
+// FlushErrors sets the channel which receives the errors of the flushes
+// triggered by time, see CoalesceOptions.ErrChan
+func FlushErrors(ch chan<- error) InserterOption {
+	return func(opts *CoalesceOptions) {
+		opts.ErrChan = ch
+	}
+}
+
+// NewBufferedInserter creates a CoalescingWriter for the INSERT query, which
+// must have no VALUES or FORMAT clause, e.g. "INSERT INTO t (a, b)". Rows
+// written from many goroutines are flushed as a single insert when MaxRows
+// rows are buffered or FlushEvery passes since the first buffered row, e.g.
+//
+//	w, err := clickhouse.NewBufferedInserter(db, "INSERT INTO events (ts, name)",
+//	    clickhouse.FlushEvery(time.Second), clickhouse.MaxRows(10000))
+func NewBufferedInserter(db *sql.DB, query string, opts ...InserterOption) (*CoalescingWriter, error) {
+	if err := checkInsertPrefix(query, "NewBufferedInserter"); err != nil {
+		return nil, err
+	}
+	var options CoalesceOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	w := NewCoalescingWriter(db, "", options)
+	w.query = strings.TrimSpace(query) + " VALUES "
+	return w, nil
+}
+
+// Write buffers a copy of the row, so the caller can reuse it. If the buffer
+// is full, the rows are flushed synchronously and the error of the flush is
+// returned. If a flush triggered by MaxWait failed since the previous call,
+// its error is returned and the row is not buffered.
+func (w *CoalescingWriter) Write(ctx context.Context, row []interface{}) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrWriterClosed
+	}
+	if err := w.takeErr(); err != nil {
+		w.mu.Unlock()
+		return err
+	}
+	w.rows = append(w.rows, append([]interface{}(nil), row...))
+	if len(w.rows) < w.opts.MaxRows {
+		if w.timer == nil {
+			w.flushes.Add(1)
+			w.timer = time.AfterFunc(w.opts.MaxWait, w.flushByTimer)
+		}
+		w.mu.Unlock()
+		return nil
+	}
+	rows := w.takeRows()
+	w.mu.Unlock()
+	return w.insert(ctx, rows)
+}
+
+// Flush sends all buffered rows. It returns the error of the flush or of a
+// failed flush triggered by MaxWait since the previous call.
+func (w *CoalescingWriter) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	rows := w.takeRows()
+	prevErr := w.takeErr()
+	w.mu.Unlock()
+	if err := w.insert(ctx, rows); err != nil {
+		return err
+	}
+	return prevErr
+}
+
+// Close flushes all buffered rows and waits for the running flushes triggered
+// by MaxWait, the writer can not be used after Close.
+func (w *CoalescingWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	w.closed = true
+	rows := w.takeRows()
+	w.mu.Unlock()
+	err := w.insert(ctx, rows)
+	w.flushes.Wait()
+	w.mu.Lock()
+	prevErr := w.takeErr()
+	w.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return prevErr
+}
+
+// takeRows returns buffered rows and resets the buffer, w.mu must be held
+func (w *CoalescingWriter) takeRows() [][]interface{} {
+	if w.timer != nil {
+		if w.timer.Stop() {
+			// the flush of the timer will not run
+			w.flushes.Done()
+		}
+		w.timer = nil
+	}
+	rows := w.rows
+	w.rows = nil
+	return rows
+}
+
+// takeErr returns the error of a flush triggered by MaxWait and resets it,
+// w.mu must be held
+func (w *CoalescingWriter) takeErr() error {
+	err := w.err
+	w.err = nil
+	return err
+}
+
+func (w *CoalescingWriter) flushByTimer() {
+	defer w.flushes.Done()
+	w.mu.Lock()
+	rows := w.takeRows()
+	w.mu.Unlock()
+	err := w.insert(context.Background(), rows)
+	if err == nil {
+		return
+	}
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.mu.Unlock()
+	if w.opts.ErrChan != nil {
+		select {
+		case w.opts.ErrChan <- err:
+		default:
+		}
+	}
+}
+
+// insert sends rows in a single request using the batch mode of transactions
+func (w *CoalescingWriter) insert(ctx context.Context, rows [][]interface{}) (err error) {
+	if len(rows) == 0 {
+		return nil
+	}
+	columns := w.columns
+	if columns == 0 {
+		columns = len(rows[0])
+	}
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	stmt, err := tx.PrepareContext(ctx, w.query+"("+strings.TrimSuffix(strings.Repeat("?, ", columns), ", ")+")")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, row := range rows {
+		if _, err = stmt.ExecContext(ctx, row...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,165 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalescingWriter(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		queries []string
+	)
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		mu.Lock()
+		queries = append(queries, query)
+		mu.Unlock()
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	w := NewCoalescingWriter(db, "events", CoalesceOptions{
+		Columns: []string{"id", "name"},
+		MaxRows: 3,
+		MaxWait: time.Hour,
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, w.Write(ctx, []interface{}{i, "name"}))
+		}(i)
+	}
+	wg.Wait()
+	mu.Lock()
+	assert.Empty(t, queries)
+	mu.Unlock()
+
+	require.NoError(t, w.Write(ctx, []interface{}{2, "name"}))
+	mu.Lock()
+	if assert.Len(t, queries, 1) {
+		assert.Contains(t, queries[0], "INSERT INTO events (id, name) VALUES(")
+		assert.Contains(t, queries[0], "(2, 'name')")
+	}
+	mu.Unlock()
+
+	// the row buffer of the caller is reused
+	row := []interface{}{3, "last"}
+	require.NoError(t, w.Write(ctx, row))
+	row[0], row[1] = 4, "reused"
+	require.NoError(t, w.Close(ctx))
+	assert.Equal(t, ErrWriterClosed, w.Write(ctx, []interface{}{4, "closed"}))
+	mu.Lock()
+	if assert.Len(t, queries, 2) {
+		assert.Equal(t, "INSERT INTO events (id, name) VALUES(3, 'last')", queries[1])
+	}
+	mu.Unlock()
+}
+
+func TestCoalescingWriterMaxWait(t *testing.T) {
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		http.Error(w, "Code: 60, e.displayText() = DB::Exception: Table default.events doesn't exist., e.what() = DB::Exception", http.StatusNotFound)
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	errs := make(chan error, 1)
+	w := NewCoalescingWriter(db, "events", CoalesceOptions{
+		MaxWait: 10 * time.Millisecond,
+		ErrChan: errs,
+	})
+	require.NoError(t, w.Write(context.Background(), []interface{}{1}))
+	select {
+	case err := <-errs:
+		if assert.IsType(t, &Error{}, err) {
+			assert.Equal(t, 60, err.(*Error).Code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("rows are not flushed")
+	}
+	// the error is also returned by the next call
+	assert.IsType(t, &Error{}, w.Write(context.Background(), []interface{}{2}))
+	assert.NoError(t, w.Flush(context.Background()))
+
+	// without ErrChan
+	w = NewCoalescingWriter(db, "events", CoalesceOptions{MaxWait: time.Millisecond})
+	require.NoError(t, w.Write(context.Background(), []interface{}{1}))
+	time.Sleep(50 * time.Millisecond)
+	assert.IsType(t, &Error{}, w.Close(context.Background()))
+}
+
+func TestCoalescingWriterCloseWaits(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var inserted int32
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		close(started)
+		<-release
+		atomic.StoreInt32(&inserted, 1)
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	w := NewCoalescingWriter(db, "events", CoalesceOptions{MaxWait: time.Millisecond})
+	require.NoError(t, w.Write(context.Background(), []interface{}{1}))
+	<-started
+	closed := make(chan error)
+	go func() {
+		closed <- w.Close(context.Background())
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned before the flush of the timer")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	assert.NoError(t, <-closed)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&inserted))
+}
+
+func TestBufferedInserter(t *testing.T) {
+	queries := make(chan string, 1)
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries <- query
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	w, err := NewBufferedInserter(db, "INSERT INTO events (id, name)", FlushEvery(10*time.Millisecond), MaxRows(100))
+	require.NoError(t, err)
+	require.NoError(t, w.Write(context.Background(), []interface{}{1, "a"}))
+	require.NoError(t, w.Write(context.Background(), []interface{}{2, "b"}))
+	select {
+	case query := <-queries:
+		assert.Equal(t, "INSERT INTO events (id, name) VALUES(1, 'a'), (2, 'b')", query)
+	case <-time.After(time.Second):
+		t.Fatal("rows are not flushed")
+	}
+	require.NoError(t, w.Close(context.Background()))
+
+	_, err = NewBufferedInserter(db, "INSERT INTO events VALUES (?)")
+	assert.Error(t, err)
+	_, err = NewBufferedInserter(db, "SELECT 1")
+	assert.Error(t, err)
+}
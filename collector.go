@@ -0,0 +1,73 @@
+package clickhouse
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Collector receives metrics of the queries, e.g. to export query latency,
+// insert throughput and error rates to Prometheus. Its methods are called
+// concurrently by the connections of the pool and must not block.
+type Collector interface {
+	// QueryStarted is called before the query is sent
+	QueryStarted(query string)
+	// QueryFinished is called when the response is read, for queries
+	// returning rows when the rows are closed
+	QueryFinished(query string, duration time.Duration, err error)
+	// BytesWritten is called with the size of a sent request body, after
+	// compression if it is enabled
+	BytesWritten(n int64)
+	// BytesRead is called with the size of a read response body after
+	// decompression
+	BytesRead(n int64)
+}
+
+// startQuery reports the start of the query to the collector of the
+// connection, the returned function reports its end
+func (c *conn) startQuery(query string) func(err error) {
+	if c.collector == nil {
+		return func(error) {}
+	}
+	c.collector.QueryStarted(query)
+	start := time.Now()
+	return func(err error) {
+		c.collector.QueryFinished(query, time.Since(start), err)
+	}
+}
+
+// reportedBody reports the number of bytes read from it once it is closed
+type reportedBody struct {
+	io.ReadCloser
+	n      int64
+	report func(n int64)
+	once   sync.Once
+}
+
+func (b *reportedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *reportedBody) Close() error {
+	b.once.Do(func() {
+		b.report(b.n)
+	})
+	return b.ReadCloser.Close()
+}
+
+// finishingBody calls finish once it is closed
+type finishingBody struct {
+	io.ReadCloser
+	finish func(error)
+	once   sync.Once
+}
+
+func (b *finishingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.finish(nil)
+	})
+	return err
+}
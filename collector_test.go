@@ -0,0 +1,87 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCollector struct {
+	mu       sync.Mutex
+	started  []string
+	finished []string
+	errs     []error
+	written  int64
+	read     int64
+}
+
+func (c *testCollector) QueryStarted(query string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started = append(c.started, query)
+}
+
+func (c *testCollector) QueryFinished(query string, duration time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.finished = append(c.finished, query)
+	c.errs = append(c.errs, err)
+}
+
+func (c *testCollector) BytesWritten(n int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written += n
+}
+
+func (c *testCollector) BytesRead(n int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.read += n
+}
+
+func TestCollector(t *testing.T) {
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		switch query {
+		case "SELECT a":
+			w.Write([]byte("a\nUInt8\n1\n2\n"))
+		case "DROP TABLE t":
+			http.Error(w, "Code: 60. DB::Exception: Table default.t doesn't exist. (UNKNOWN_TABLE)", http.StatusNotFound)
+		}
+	})
+	defer ts.Close()
+
+	cfg, err := ParseDSN(dsn)
+	require.NoError(t, err)
+	collector := &testCollector{}
+	cfg.Collector = collector
+	db := sql.OpenDB(NewConnector(cfg))
+	defer db.Close()
+
+	rows, err := db.Query("SELECT a")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SELECT a"}, collector.started)
+	assert.Empty(t, collector.finished)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Close())
+	assert.Equal(t, []string{"SELECT a"}, collector.finished)
+	assert.Equal(t, int64(len("a\nUInt8\n1\n2\n")), collector.read)
+
+	_, err = db.Exec("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("SELECT a")+len("INSERT INTO t VALUES (1)")), collector.written)
+
+	_, err = db.Exec("DROP TABLE t")
+	require.Error(t, err)
+	assert.Equal(t, []string{"SELECT a", "INSERT INTO t VALUES (1)", "DROP TABLE t"}, collector.finished)
+	require.Len(t, collector.errs, 3)
+	assert.NoError(t, collector.errs[0])
+	assert.NoError(t, collector.errs[1])
+	assert.Error(t, collector.errs[2])
+}
@@ -0,0 +1,366 @@
+package clickhouse
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Columns is a result read by columns with QueryColumns
+type Columns struct {
+	Names []string
+	Types []string
+	// Data are the values of the columns: []int64 for signed integers and
+	// enums scanned as numbers, []uint64 for unsigned integers, []float64
+	// for floats, []string for strings, enums, UUIDs and FixedString,
+	// []time.Time for dates and []interface{} with the values of Scan for
+	// the other types, e.g. Nullable, Array or Decimal
+	Data []interface{}
+	// Len is the number of rows
+	Len int
+}
+
+// Column returns the values of the column with the name, nil if the result
+// has no such column
+func (c *Columns) Column(name string) interface{} {
+	for i, n := range c.Names {
+		if n == name {
+			return c.Data[i]
+		}
+	}
+	return nil
+}
+
+func (c *Columns) column(name string) (interface{}, error) {
+	data := c.Column(name)
+	if data == nil {
+		return nil, fmt.Errorf("clickhouse: no column %s in the result", name)
+	}
+	return data, nil
+}
+
+func mismatchedColumn(name string, data interface{}, typ string) error {
+	return fmt.Errorf("clickhouse: column %s is %T, not %s", name, data, typ)
+}
+
+// Int64 returns the values of the signed integer column with the name
+func (c *Columns) Int64(name string) ([]int64, error) {
+	data, err := c.column(name)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := data.([]int64)
+	if !ok {
+		return nil, mismatchedColumn(name, data, "[]int64")
+	}
+	return values, nil
+}
+
+// Uint64 returns the values of the unsigned integer column with the name
+func (c *Columns) Uint64(name string) ([]uint64, error) {
+	data, err := c.column(name)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := data.([]uint64)
+	if !ok {
+		return nil, mismatchedColumn(name, data, "[]uint64")
+	}
+	return values, nil
+}
+
+// Float64 returns the values of the float column with the name
+func (c *Columns) Float64(name string) ([]float64, error) {
+	data, err := c.column(name)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := data.([]float64)
+	if !ok {
+		return nil, mismatchedColumn(name, data, "[]float64")
+	}
+	return values, nil
+}
+
+// String returns the values of the string column with the name
+func (c *Columns) String(name string) ([]string, error) {
+	data, err := c.column(name)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := data.([]string)
+	if !ok {
+		return nil, mismatchedColumn(name, data, "[]string")
+	}
+	return values, nil
+}
+
+// Time returns the values of the date column with the name
+func (c *Columns) Time(name string) ([]time.Time, error) {
+	data, err := c.column(name)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := data.([]time.Time)
+	if !ok {
+		return nil, mismatchedColumn(name, data, "[]time.Time")
+	}
+	return values, nil
+}
+
+// QueryColumns executes the query and reads the whole result into slices of
+// the values of its columns. The result is requested in
+// RowBinaryWithNamesAndTypes format and the values of the columns of the
+// common types are decoded into typed slices without being boxed into
+// interface{} values, e.g. to load large results for further processing.
+func QueryColumns(ctx context.Context, db *sql.DB, query string, args ...interface{}) (*Columns, error) {
+	values, err := convertArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	sqlConn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer sqlConn.Close()
+	var columns *Columns
+	err = sqlConn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*conn)
+		if !ok {
+			return errNotClickHouseConn
+		}
+		body, err := c.queryFormat(ctx, query, FormatRowBinaryWithNamesAndTypes, values)
+		if err != nil {
+			return err
+		}
+		body = &formatBody{ReadCloser: body, c: c}
+		defer body.Close()
+		columns, err = readColumns(newBodyReader(c, body), c.parserOptions(ctx))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// readColumns reads the columns of a result in RowBinaryWithNamesAndTypes
+// format
+func readColumns(br *bufio.Reader, opt *DataParserOptions) (*Columns, error) {
+	r := &binaryReader{r: br}
+	names, types, descs, err := readBinaryHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	decoders := make([]columnDecoder, len(descs))
+	for i, desc := range descs {
+		d, err := newBinaryDecoder(desc, false, opt)
+		if err != nil {
+			return nil, err
+		}
+		decoders[i] = newColumnDecoder(d)
+	}
+	columns := &Columns{Names: names, Types: types}
+	for len(decoders) > 0 {
+		if err := decodeColumnsRow(r, decoders); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		columns.Len++
+	}
+	columns.Data = make([]interface{}, len(decoders))
+	for i, d := range decoders {
+		columns.Data[i] = d.values()
+	}
+	return columns, nil
+}
+
+func decodeColumnsRow(r *binaryReader, decoders []columnDecoder) error {
+	for i, d := range decoders {
+		if err := d.decode(r); err != nil {
+			if i > 0 || err != io.EOF {
+				// the end of the body is only expected between the rows
+				return noEOF(err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// columnDecoder appends the values of a column decoded from the RowBinary
+// format to a slice
+type columnDecoder interface {
+	decode(r *binaryReader) error
+	values() interface{}
+}
+
+// binaryStringValueDecoder is a decoder of values returned as strings
+type binaryStringValueDecoder interface {
+	decodeString(r *binaryReader) (string, error)
+}
+
+// newColumnDecoder returns the column decoder of the values of d
+func newColumnDecoder(d binaryDecoder) columnDecoder {
+	switch d := d.(type) {
+	case *binaryIntDecoder:
+		if d.signed {
+			return &intColumn{bitSize: d.bitSize}
+		}
+		return &uintColumn{bitSize: d.bitSize}
+	case *binaryFloatDecoder:
+		return &floatColumn{bitSize: d.bitSize}
+	case *binaryEnumDecoder:
+		if d.asNumber {
+			// the numbers of the elements are signed integers
+			return &intColumn{bitSize: d.bitSize}
+		}
+		return &stringColumn{d: d}
+	case binaryStringValueDecoder:
+		return &stringColumn{d: d}
+	case binaryTimeDecoder:
+		return &timeColumn{d: d}
+	}
+	return &valueColumn{d: d}
+}
+
+type intColumn struct {
+	bitSize int
+	data    []int64
+}
+
+func (c *intColumn) decode(r *binaryReader) error {
+	b, err := r.read(c.bitSize / 8)
+	if err != nil {
+		return err
+	}
+	var v int64
+	switch c.bitSize {
+	case 8:
+		v = int64(int8(b[0]))
+	case 16:
+		v = int64(int16(binary.LittleEndian.Uint16(b)))
+	case 32:
+		v = int64(int32(binary.LittleEndian.Uint32(b)))
+	default:
+		v = int64(binary.LittleEndian.Uint64(b))
+	}
+	c.data = append(c.data, v)
+	return nil
+}
+
+func (c *intColumn) values() interface{} {
+	return c.data
+}
+
+type uintColumn struct {
+	bitSize int
+	data    []uint64
+}
+
+func (c *uintColumn) decode(r *binaryReader) error {
+	b, err := r.read(c.bitSize / 8)
+	if err != nil {
+		return err
+	}
+	var v uint64
+	switch c.bitSize {
+	case 8:
+		v = uint64(b[0])
+	case 16:
+		v = uint64(binary.LittleEndian.Uint16(b))
+	case 32:
+		v = uint64(binary.LittleEndian.Uint32(b))
+	default:
+		v = binary.LittleEndian.Uint64(b)
+	}
+	c.data = append(c.data, v)
+	return nil
+}
+
+func (c *uintColumn) values() interface{} {
+	return c.data
+}
+
+type floatColumn struct {
+	bitSize int
+	data    []float64
+}
+
+func (c *floatColumn) decode(r *binaryReader) error {
+	b, err := r.read(c.bitSize / 8)
+	if err != nil {
+		return err
+	}
+	if c.bitSize == 32 {
+		c.data = append(c.data, float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+	} else {
+		c.data = append(c.data, math.Float64frombits(binary.LittleEndian.Uint64(b)))
+	}
+	return nil
+}
+
+func (c *floatColumn) values() interface{} {
+	return c.data
+}
+
+type stringColumn struct {
+	d    binaryStringValueDecoder
+	data []string
+}
+
+func (c *stringColumn) decode(r *binaryReader) error {
+	s, err := c.d.decodeString(r)
+	if err != nil {
+		return err
+	}
+	c.data = append(c.data, s)
+	return nil
+}
+
+func (c *stringColumn) values() interface{} {
+	return c.data
+}
+
+type timeColumn struct {
+	d    binaryTimeDecoder
+	data []time.Time
+}
+
+func (c *timeColumn) decode(r *binaryReader) error {
+	t, err := c.d.decodeTime(r)
+	if err != nil {
+		return err
+	}
+	c.data = append(c.data, t)
+	return nil
+}
+
+func (c *timeColumn) values() interface{} {
+	return c.data
+}
+
+// valueColumn keeps the values of the types without a typed column
+type valueColumn struct {
+	d    binaryDecoder
+	data []interface{}
+}
+
+func (c *valueColumn) decode(r *binaryReader) error {
+	v, err := c.d.decode(r)
+	if err != nil {
+		return err
+	}
+	c.data = append(c.data, v)
+	return nil
+}
+
+func (c *valueColumn) values() interface{} {
+	return c.data
+}
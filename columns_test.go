@@ -0,0 +1,106 @@
+package clickhouse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryColumns(t *testing.T) {
+	b := &rowBinary{}
+	b.le(uint8(6)).str("id").str("n").str("f").str("s").str("ts").str("x")
+	b.str("Int32").str("UInt16").str("Float32").str("LowCardinality(String)").str("DateTime('UTC')").str("Nullable(Int8)")
+	b.le(int32(-1)).le(uint16(7)).le(float32(0.5)).str("a").le(uint32(1600000000)).le(uint8(1))
+	b.le(int32(2)).le(uint16(8)).le(float32(1.5)).str("b").le(uint32(0)).le(uint8(0)).le(int8(-3))
+
+	var params string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		params = r.URL.Query().Get("default_format")
+		assert.Equal(t, "SELECT * FROM t WHERE id > 0", query)
+		w.Write(b.Bytes())
+	})
+	defer ts.Close()
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	columns, err := QueryColumns(context.Background(), db, "SELECT * FROM t WHERE id > ?", 0)
+	require.NoError(t, err)
+	assert.Equal(t, FormatRowBinaryWithNamesAndTypes, params)
+	assert.Equal(t, 2, columns.Len)
+	assert.Equal(t, []string{"id", "n", "f", "s", "ts", "x"}, columns.Names)
+	assert.Equal(t, "Nullable(Int8)", columns.Types[5])
+
+	ids, err := columns.Int64("id")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{-1, 2}, ids)
+	n, err := columns.Uint64("n")
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{7, 8}, n)
+	f, err := columns.Float64("f")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.5, 1.5}, f)
+	s, err := columns.String("s")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, s)
+	times, err := columns.Time("ts")
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{time.Unix(1600000000, 0).UTC(), time.Unix(0, 0).UTC()}, times)
+	assert.Equal(t, []interface{}{nil, int8(-3)}, columns.Column("x"))
+
+	_, err = columns.Int64("s")
+	assert.EqualError(t, err, "clickhouse: column s is []string, not []int64")
+	_, err = columns.String("missing")
+	assert.Error(t, err)
+	assert.Nil(t, columns.Column("missing"))
+}
+
+func TestReadColumns(t *testing.T) {
+	b := &rowBinary{}
+	b.le(uint8(3)).str("e").str("u").str("d")
+	b.str("Enum8('a' = 1, 'b' = -2)").str("FixedString(2)").str("Date")
+	b.le(int8(-2))
+	b.WriteString("xy")
+	b.le(uint16(1))
+
+	columns, err := readColumns(bufio.NewReader(bytes.NewReader(b.Bytes())), &DataParserOptions{Location: time.UTC})
+	require.NoError(t, err)
+	assert.Equal(t, 1, columns.Len)
+	assert.Equal(t, []string{"b"}, columns.Column("e"))
+	assert.Equal(t, []string{"xy"}, columns.Column("u"))
+	assert.Equal(t, []time.Time{time.Date(1970, 1, 2, 0, 0, 0, 0, time.UTC)}, columns.Column("d"))
+
+	columns, err = readColumns(bufio.NewReader(bytes.NewReader(b.Bytes())), &DataParserOptions{Location: time.UTC, EnumAsNumber: true})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{-2}, columns.Column("e"))
+
+	// the body ends in the middle of a row
+	truncated := b.Bytes()[:b.Len()-1]
+	_, err = readColumns(bufio.NewReader(bytes.NewReader(truncated)), nil)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func BenchmarkReadColumns(b *testing.B) {
+	data := &rowBinary{}
+	data.le(uint8(4)).str("a").str("b").str("c").str("d")
+	data.str("Int32").str("String").str("Float64").str("DateTime")
+	for i := 0; i < 1000; i++ {
+		data.le(int32(123)).str("hello world").le(1.5).le(uint32(1546398245))
+	}
+	raw := data.Bytes()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := readColumns(bufio.NewReader(bytes.NewReader(raw)), &DataParserOptions{Location: time.UTC}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
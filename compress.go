@@ -0,0 +1,111 @@
+package clickhouse
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Compressor compresses bodies of requests and decompresses bodies of
+// responses with an HTTP content encoding supported by ClickHouse.
+type Compressor interface {
+	// NewWriter returns a writer compressing the data written to w
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	// NewReader returns a reader decompressing the data read from r
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	compressorsLock sync.RWMutex
+	compressors     = map[string]Compressor{
+		"gzip":    gzipCompressor{},
+		"deflate": zlibCompressor{},
+	}
+)
+
+// RegisterCompressor registers a compressor of the content encoding to be
+// used with the compress=<encoding> DSN parameter. The gzip and deflate
+// encodings are built in, other encodings supported by ClickHouse
+// (e.g. zstd, lz4, br, xz) can be registered with compressors of third
+// party packages.
+func RegisterCompressor(encoding string, c Compressor) {
+	compressorsLock.Lock()
+	compressors[encoding] = c
+	compressorsLock.Unlock()
+}
+
+func getCompressor(encoding string) Compressor {
+	compressorsLock.RLock()
+	defer compressorsLock.RUnlock()
+	return compressors[encoding]
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// zlibCompressor implements the deflate encoding, which is the zlib format
+type zlibCompressor struct{}
+
+func (zlibCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriter(w), nil
+}
+
+func (zlibCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+// compressedBody returns a function which streams the query compressed with c,
+// so the compressed body is never kept in memory as a whole
+func compressedBody(c Compressor, query string) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return compressReader(c, strings.NewReader(query)), nil
+	}
+}
+
+// compressReader returns a reader of the data of r compressed with c
+func compressReader(c Compressor, r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		w, err := c.NewWriter(pw)
+		if err == nil {
+			if _, err = io.Copy(w, r); err == nil {
+				err = w.Close()
+			} else {
+				w.Close()
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// decompressedBody closes both the decompressor and the response body
+type decompressedBody struct {
+	io.ReadCloser
+	body io.Closer
+}
+
+func (b *decompressedBody) Close() error {
+	b.ReadCloser.Close()
+	return b.body.Close()
+}
+
+// parseCompressParam parses the compress parameter, which is the content
+// encoding of requests and responses
+func parseCompressParam(cfg *Config, encoding string) error {
+	if getCompressor(encoding) == nil {
+		return fmt.Errorf("clickhouse: unsupported compression '%s'", encoding)
+	}
+	cfg.Compression = encoding
+	return nil
+}
@@ -0,0 +1,62 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompression(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate"} {
+		t.Run(encoding, func(t *testing.T) {
+			var queries []string
+			c := getCompressor(encoding)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Content-Encoding") != encoding || r.URL.Query().Get("enable_http_compression") != "1" {
+					http.Error(w, "not compressed", http.StatusBadRequest)
+					return
+				}
+				body, err := c.NewReader(r.Body)
+				require.NoError(t, err)
+				query, err := ioutil.ReadAll(body)
+				require.NoError(t, err)
+				queries = append(queries, string(query))
+
+				if !strings.Contains(r.Header.Get("Accept-Encoding"), encoding) {
+					http.Error(w, "compressed response is not accepted", http.StatusBadRequest)
+					return
+				}
+				w.Header().Set("Content-Encoding", encoding)
+				cw, err := c.NewWriter(w)
+				require.NoError(t, err)
+				cw.Write([]byte("1\nUInt8\n1\n"))
+				cw.Close()
+			}))
+			defer ts.Close()
+
+			cfg, err := ParseDSN("http://" + ts.Listener.Addr().String() + "/default?compress=" + encoding)
+			require.NoError(t, err)
+			assert.Equal(t, encoding, cfg.Compression)
+			assert.Contains(t, cfg.FormatDSN(), "compress="+encoding)
+
+			db, err := sql.Open("clickhouse", cfg.FormatDSN())
+			require.NoError(t, err)
+			defer db.Close()
+			var n int
+			require.NoError(t, db.QueryRow("SELECT 1").Scan(&n))
+			assert.Equal(t, 1, n)
+			_, err = db.Exec("INSERT INTO t VALUES (?)", 2)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"SELECT 1", "INSERT INTO t VALUES (2)"}, queries)
+		})
+	}
+
+	_, err := ParseDSN("http://localhost:8123/default?compress=unknown")
+	assert.Error(t, err)
+}
@@ -1,30 +1,83 @@
 package clickhouse
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/url"
+	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // Config is a configuration parsed from a DSN string
 type Config struct {
-	User            string
-	Password        string
-	Scheme          string
-	Host            string
-	Database        string
-	Timeout         time.Duration
-	IdleTimeout     time.Duration
-	ReadTimeout     time.Duration
-	WriteTimeout    time.Duration
-	Location        *time.Location
-	Debug           bool
-	UseDBLocation   bool
-	GzipCompression bool
-	Params          map[string]string
-	TLSConfig       string
+	User                  string
+	Password              string
+	AuthMode              string
+	Token                 string
+	TokenFunc             TokenFunc
+	Credentials           CredentialsProvider
+	UserFile              string
+	PasswordFile          string
+	UserEnv               string
+	PasswordEnv           string
+	Scheme                string
+	Host                  string
+	Socket                string
+	DialContext           DialFunc
+	Database              string
+	Timeout               time.Duration
+	IdleTimeout           time.Duration
+	ReadTimeout           time.Duration
+	WriteTimeout          time.Duration
+	RequestTimeout        time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IgnoreDeadline        bool
+	Location              *time.Location
+	Debug                 bool
+	UseDBLocation         bool
+	ScanLocation          *time.Location
+	TimeParams            string
+	GzipCompression       bool
+	Params                map[string]string
+	Headers               map[string]string
+	QuotaKey              string
+	TLSConfig             string
+	MaxRequestBodySize    int64
+	MaxBodyDrainBytes     int64
+	BufferSize            int
+	StmtCacheSize         int
+	InsecureHTTP          bool
+	MaxIdleConnsPerHost   int
+	MaxIdleConns          int
+	MaxConnsPerHost       int
+	IdleConnTimeout       time.Duration
+	DisableKeepAlives     bool
+	KeepAlive             time.Duration
+	QueryInterceptor      func(query string, args []interface{}) (string, []interface{}, error)
+	ETagCache             bool
+	ResponseCache         ResponseCache
+	OnError               func(err error, query string)
+	OnConnect             func(ctx context.Context, conn Connection) error
+	ReadHosts             string
+	HostStrategy          string
+	HostCooldown          time.Duration
+	HealthCheckInterval   time.Duration
+	Compression           string
+	EnumAsNumber          bool
+	ZeroDateAsNil         bool
+	MaxRetries            int
+	RetryBackoff          time.Duration
+	SessionID             string
+	SessionTimeout        time.Duration
+	Tracer                Tracer
+	Collector             Collector
+	Logger                Logger
+	Format                string
 }
 
 // NewConfig creates a new config with default values
@@ -43,6 +96,12 @@ func NewConfig() *Config {
 func (cfg *Config) FormatDSN() string {
 	u := cfg.url(nil, true)
 	query := u.Query()
+	if len(cfg.Socket) > 0 {
+		u.Scheme, u.Host, u.Path = "unix", "", cfg.Socket
+		if len(cfg.Database) > 0 {
+			query.Set("database", cfg.Database)
+		}
+	}
 	if cfg.Timeout != 0 {
 		query.Set("timeout", cfg.Timeout.String())
 	}
@@ -55,23 +114,203 @@ func (cfg *Config) FormatDSN() string {
 	if cfg.WriteTimeout != 0 {
 		query.Set("write_timeout", cfg.WriteTimeout.String())
 	}
+	if cfg.RequestTimeout != 0 {
+		query.Set("request_timeout", cfg.RequestTimeout.String())
+	}
+	if cfg.TLSHandshakeTimeout != 0 {
+		query.Set("tls_handshake_timeout", cfg.TLSHandshakeTimeout.String())
+	}
+	if cfg.ResponseHeaderTimeout != 0 {
+		query.Set("response_header_timeout", cfg.ResponseHeaderTimeout.String())
+	}
+	if cfg.IgnoreDeadline {
+		query.Set("ignore_deadline", "1")
+	}
 	if cfg.Location != time.UTC && cfg.Location != nil {
 		query.Set("location", cfg.Location.String())
 	}
+	if cfg.UseDBLocation {
+		query.Set("use_db_location", "1")
+	}
+	if cfg.ScanLocation != nil {
+		query.Set("scan_location", cfg.ScanLocation.String())
+	}
+	if len(cfg.TimeParams) > 0 {
+		query.Set("time_params", cfg.TimeParams)
+	}
 	if cfg.GzipCompression {
 		query.Set("enable_http_compression", "1")
 	}
+	if len(cfg.Compression) > 0 {
+		query.Set("compress", cfg.Compression)
+	}
 	if cfg.Debug {
 		query.Set("debug", "1")
 	}
+	if cfg.InsecureHTTP {
+		query.Set("insecure", "1")
+	}
+	if cfg.MaxRequestBodySize != 0 {
+		query.Set("max_body_size", strconv.FormatInt(cfg.MaxRequestBodySize, 10))
+	}
+	if cfg.MaxBodyDrainBytes != 0 {
+		query.Set("max_body_drain_bytes", strconv.FormatInt(cfg.MaxBodyDrainBytes, 10))
+	}
+	if cfg.StmtCacheSize != 0 {
+		query.Set("stmt_cache_size", strconv.Itoa(cfg.StmtCacheSize))
+	}
+	if cfg.BufferSize != 0 {
+		query.Set("buffer_size", strconv.Itoa(cfg.BufferSize))
+	}
+	if len(cfg.TLSConfig) > 0 {
+		query.Set("tls_config", cfg.TLSConfig)
+	}
+	if cfg.ETagCache {
+		query.Set("etag_cache", "1")
+	}
+	if len(cfg.ReadHosts) > 0 {
+		query.Set("read_hosts", cfg.ReadHosts)
+	}
+	if len(cfg.HostStrategy) > 0 {
+		query.Set("host_strategy", cfg.HostStrategy)
+	}
+	if cfg.HostCooldown != 0 {
+		query.Set("host_cooldown", cfg.HostCooldown.String())
+	}
+	if cfg.HealthCheckInterval != 0 {
+		query.Set("health_check_interval", cfg.HealthCheckInterval.String())
+	}
+	if cfg.MaxRetries != 0 {
+		query.Set("max_retries", strconv.Itoa(cfg.MaxRetries))
+	}
+	if cfg.RetryBackoff != 0 {
+		query.Set("retry_backoff", cfg.RetryBackoff.String())
+	}
+	if len(cfg.SessionID) > 0 {
+		query.Set("session_id", cfg.SessionID)
+	}
+	if cfg.SessionTimeout != 0 {
+		query.Set("session_timeout", cfg.SessionTimeout.String())
+	}
+	if cfg.EnumAsNumber {
+		query.Set("enum_as_number", "1")
+	}
+	if cfg.ZeroDateAsNil {
+		query.Set("zero_date_as_nil", "1")
+	}
+	if len(cfg.Format) > 0 {
+		query.Set("format", cfg.Format)
+	}
+	if len(cfg.QuotaKey) > 0 {
+		query.Set("quota_key", cfg.QuotaKey)
+	}
+	if len(cfg.AuthMode) > 0 {
+		query.Set("auth_mode", cfg.AuthMode)
+	}
+	if len(cfg.Token) > 0 {
+		query.Set("token", cfg.Token)
+	}
+	if len(cfg.UserFile) > 0 {
+		query.Set("user_file", cfg.UserFile)
+	}
+	if len(cfg.PasswordFile) > 0 {
+		query.Set("password_file", cfg.PasswordFile)
+	}
+	if len(cfg.UserEnv) > 0 {
+		query.Set("user_env", cfg.UserEnv)
+	}
+	if len(cfg.PasswordEnv) > 0 {
+		query.Set("password_env", cfg.PasswordEnv)
+	}
+	if cfg.MaxIdleConnsPerHost != 0 {
+		query.Set("max_idle_per_host", strconv.Itoa(cfg.MaxIdleConnsPerHost))
+	}
+	if cfg.MaxIdleConns != 0 {
+		query.Set("max_idle_conns", strconv.Itoa(cfg.MaxIdleConns))
+	}
+	if cfg.MaxConnsPerHost != 0 {
+		query.Set("max_conns_per_host", strconv.Itoa(cfg.MaxConnsPerHost))
+	}
+	if cfg.IdleConnTimeout != 0 {
+		query.Set("idle_conn_timeout", cfg.IdleConnTimeout.String())
+	}
+	if cfg.DisableKeepAlives {
+		query.Set("disable_keep_alives", "1")
+	}
+	if cfg.KeepAlive != 0 {
+		query.Set("tcp_keep_alive", cfg.KeepAlive.String())
+	}
 
 	u.RawQuery = query.Encode()
 	return u.String()
 }
 
+// Validate checks that the config is consistent. Credentials are not allowed
+// to be sent over plain HTTP unless InsecureHTTP is set or the connection is
+// made through a unix socket.
+func (cfg *Config) Validate() error {
+	hasSecret := len(cfg.Password) > 0 || len(cfg.Token) > 0 || cfg.TokenFunc != nil || cfg.credentials() != nil
+	if cfg.Scheme == "http" && len(cfg.Socket) == 0 && hasSecret && !cfg.InsecureHTTP {
+		return ErrInsecureWithCredentials
+	}
+	if cfg.AuthMode == AuthModeJWT && len(cfg.Token) == 0 && cfg.TokenFunc == nil {
+		return ErrNoToken
+	}
+	return nil
+}
+
+// ProfileEnv is the environment variable which selects a profile for ConfigFromProfile
+const ProfileEnv = "CLICKHOUSE_PROFILE"
+
+// ConfigFromProfile returns the config of the profile named by the
+// CLICKHOUSE_PROFILE environment variable, e.g. "prod" or "staging".
+// The "default" profile is returned if the variable is not set.
+func ConfigFromProfile(profiles map[string]*Config) (*Config, error) {
+	name := os.Getenv(ProfileEnv)
+	if len(name) == 0 {
+		name = "default"
+	}
+	if cfg, ok := profiles[name]; ok && cfg != nil {
+		return cfg, nil
+	}
+	names := make([]string, 0, len(profiles))
+	for n := range profiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("clickhouse: unknown profile %q, available profiles: %s", name, strings.Join(names, ", "))
+}
+
+// hosts returns the comma separated hosts of the config with ports
+func (cfg *Config) hosts() []string {
+	hosts := strings.Split(cfg.Host, ",")
+	for i, host := range hosts {
+		hosts[i] = ensureHavePort(strings.TrimSpace(host))
+	}
+	return hosts
+}
+
+// readHosts returns the comma separated hosts of the config which serve the
+// read-only queries with ports, it is nil if the reads are not split
+func (cfg *Config) readHosts() []string {
+	if len(strings.TrimSpace(cfg.ReadHosts)) == 0 {
+		return nil
+	}
+	hosts := strings.Split(cfg.ReadHosts, ",")
+	for i, host := range hosts {
+		hosts[i] = ensureHavePort(strings.TrimSpace(host))
+	}
+	return hosts
+}
+
+// url returns the URL of the first host, or of all hosts for a DSN
 func (cfg *Config) url(extra map[string]string, dsn bool) *url.URL {
+	hosts := cfg.hosts()
+	if !dsn {
+		hosts = hosts[:1]
+	}
 	u := &url.URL{
-		Host:   ensureHavePort(cfg.Host),
+		Host:   strings.Join(hosts, ","),
 		Scheme: cfg.Scheme,
 		Path:   "/",
 	}
@@ -105,6 +344,7 @@ func (cfg *Config) url(extra map[string]string, dsn bool) *url.URL {
 
 // ParseDSN parses the DSN string to a Config
 func ParseDSN(dsn string) (*Config, error) {
+	dsn, hosts := cutHosts(dsn)
 	u, err := url.Parse(dsn)
 	if err != nil {
 		return nil, err
@@ -112,7 +352,18 @@ func ParseDSN(dsn string) (*Config, error) {
 	cfg := NewConfig()
 
 	cfg.Scheme, cfg.Host = u.Scheme, u.Host
-	if len(u.Path) > 1 {
+	if len(hosts) > 0 {
+		cfg.Host = hosts
+	}
+	params := map[string][]string(u.Query())
+	if u.Scheme == "unix" {
+		// unix:///path/to/socket?database=db, requests are sent over HTTP
+		cfg.Scheme, cfg.Host, cfg.Socket = "http", "localhost", u.Path
+		if db, ok := params["database"]; ok && len(db) > 0 {
+			cfg.Database = db[0]
+			delete(params, "database")
+		}
+	} else if len(u.Path) > 1 {
 		// skip '/'
 		cfg.Database = u.Path[1:]
 	}
@@ -123,19 +374,95 @@ func ParseDSN(dsn string) (*Config, error) {
 			cfg.Password = passwd
 		}
 	}
-	if err = parseDSNParams(cfg, map[string][]string(u.Query())); err != nil {
+	if err = parseDSNParams(cfg, params); err != nil {
 		return nil, err
 	}
 	return cfg, nil
 }
 
+// cutHosts replaces the comma separated hosts of the DSN with a single one,
+// since url.Parse does not accept them, and returns the hosts
+func cutHosts(dsn string) (string, string) {
+	i := strings.Index(dsn, "://")
+	if i < 0 {
+		return dsn, ""
+	}
+	start := i + 3
+	end := len(dsn)
+	if j := strings.IndexAny(dsn[start:], "/?#"); j >= 0 {
+		end = start + j
+	}
+	if j := strings.LastIndexByte(dsn[start:end], '@'); j >= 0 {
+		start += j + 1
+	}
+	if !strings.Contains(dsn[start:end], ",") {
+		return dsn, ""
+	}
+	return dsn[:start] + "localhost" + dsn[end:], dsn[start:end]
+}
+
+// DSNParamAliases maps names of DSN parameters used by other ClickHouse
+// drivers to the names used by this driver. The "user", "host" and "database"
+// names set the corresponding parts of the DSN. The aliases are deprecated,
+// their use is logged if debug is enabled or a logger is set.
+var DSNParamAliases = map[string]string{
+	"username":     "user",
+	"addr":         "host",
+	"db":           "database",
+	"dial_timeout": "timeout",
+	"compress":     "enable_http_compression",
+}
+
+// isCompressParam reports whether the parameter is compress=<encoding>
+// rather than the boolean alias of enable_http_compression
+func isCompressParam(k, v string) bool {
+	if k != "compress" {
+		return false
+	}
+	_, err := strconv.ParseBool(v)
+	return err != nil
+}
+
 // parseDSNParams parses the DSN "query string"
 // Values must be url.QueryEscape'ed
 func parseDSNParams(cfg *Config, params map[string][]string) (err error) {
+	var aliases []string
+	defer func() {
+		if logger := cfg.logger(); err == nil && logger != nil {
+			for _, alias := range aliases {
+				logger.Warnf("DSN parameter '%s' is deprecated, use '%s' instead", alias, DSNParamAliases[alias])
+			}
+		}
+	}()
 	for k, v := range params {
 		if len(v) == 0 {
 			continue
 		}
+		// the last value of a repeated parameter wins, so a DSN can be
+		// extended with overrides
+		v = v[len(v)-1:]
+		if name := strings.TrimPrefix(k, settingsParamPrefix); name != k {
+			if err = validateSetting(name, v[0], true); err != nil {
+				return err
+			}
+			cfg.Params[name] = v[0]
+			continue
+		}
+		if canonical, ok := DSNParamAliases[k]; ok && !isCompressParam(k, v[0]) {
+			aliases = append(aliases, k)
+			switch canonical {
+			case "user":
+				cfg.User = v[0]
+				continue
+			case "host":
+				cfg.Host = v[0]
+				continue
+			case "database":
+				cfg.Database = v[0]
+				continue
+			}
+			k = canonical
+		}
 
 		switch k {
 		case "timeout":
@@ -146,8 +473,27 @@ func parseDSNParams(cfg *Config, params map[string][]string) (err error) {
 			cfg.ReadTimeout, err = time.ParseDuration(v[0])
 		case "write_timeout":
 			cfg.WriteTimeout, err = time.ParseDuration(v[0])
+		case "request_timeout":
+			cfg.RequestTimeout, err = time.ParseDuration(v[0])
+		case "tls_handshake_timeout":
+			cfg.TLSHandshakeTimeout, err = time.ParseDuration(v[0])
+		case "response_header_timeout":
+			cfg.ResponseHeaderTimeout, err = time.ParseDuration(v[0])
+		case "ignore_deadline":
+			cfg.IgnoreDeadline, err = strconv.ParseBool(v[0])
 		case "location":
 			cfg.Location, err = time.LoadLocation(v[0])
+		case "use_db_location":
+			cfg.UseDBLocation, err = strconv.ParseBool(v[0])
+		case "scan_location":
+			cfg.ScanLocation, err = time.LoadLocation(v[0])
+		case "time_params":
+			switch v[0] {
+			case TimeParamsServer, TimeParamsUTC:
+				cfg.TimeParams = v[0]
+			default:
+				err = fmt.Errorf("clickhouse: unknown time params mode '%s'", v[0])
+			}
 		case "debug":
 			cfg.Debug, err = strconv.ParseBool(v[0])
 		case "default_format", "query", "database":
@@ -157,8 +503,91 @@ func parseDSNParams(cfg *Config, params map[string][]string) (err error) {
 			cfg.Params[k] = v[0]
 		case "tls_config":
 			cfg.TLSConfig = v[0]
+		case "compress":
+			err = parseCompressParam(cfg, v[0])
+		case "tls":
+			err = parseTLSParam(cfg, v[0])
+		case "insecure":
+			cfg.InsecureHTTP, err = strconv.ParseBool(v[0])
+		case "max_body_size":
+			cfg.MaxRequestBodySize, err = strconv.ParseInt(v[0], 10, 64)
+		case "max_body_drain_bytes":
+			cfg.MaxBodyDrainBytes, err = strconv.ParseInt(v[0], 10, 64)
+		case "stmt_cache_size":
+			cfg.StmtCacheSize, err = strconv.Atoi(v[0])
+		case "buffer_size":
+			cfg.BufferSize, err = strconv.Atoi(v[0])
+		case "etag_cache":
+			cfg.ETagCache, err = strconv.ParseBool(v[0])
+		case "max_idle_per_host":
+			cfg.MaxIdleConnsPerHost, err = strconv.Atoi(v[0])
+		case "max_idle_conns":
+			cfg.MaxIdleConns, err = strconv.Atoi(v[0])
+		case "max_conns_per_host":
+			cfg.MaxConnsPerHost, err = strconv.Atoi(v[0])
+		case "idle_conn_timeout":
+			cfg.IdleConnTimeout, err = time.ParseDuration(v[0])
+		case "disable_keep_alives":
+			cfg.DisableKeepAlives, err = strconv.ParseBool(v[0])
+		case "tcp_keep_alive":
+			cfg.KeepAlive, err = time.ParseDuration(v[0])
+		case "max_retries":
+			cfg.MaxRetries, err = strconv.Atoi(v[0])
+		case "retry_backoff":
+			cfg.RetryBackoff, err = time.ParseDuration(v[0])
+		case "session_id":
+			cfg.SessionID = v[0]
+		case "session_timeout":
+			cfg.SessionTimeout, err = parseSessionTimeout(v[0])
+		case "enum_as_number":
+			cfg.EnumAsNumber, err = strconv.ParseBool(v[0])
+		case "zero_date_as_nil":
+			cfg.ZeroDateAsNil, err = strconv.ParseBool(v[0])
+		case "host_strategy":
+			switch v[0] {
+			case HostStrategyInOrder, HostStrategyRoundRobin, HostStrategyRandom:
+				cfg.HostStrategy = v[0]
+			default:
+				err = fmt.Errorf("clickhouse: unknown host strategy '%s'", v[0])
+			}
+		case "read_hosts":
+			cfg.ReadHosts = v[0]
+		case "host_cooldown":
+			cfg.HostCooldown, err = time.ParseDuration(v[0])
+		case "health_check_interval":
+			cfg.HealthCheckInterval, err = time.ParseDuration(v[0])
+		case "auth_mode":
+			switch v[0] {
+			case AuthModeBasic, AuthModeHeaders, AuthModeJWT:
+				cfg.AuthMode = v[0]
+			default:
+				err = fmt.Errorf("clickhouse: unknown auth mode '%s'", v[0])
+			}
+		case "token":
+			cfg.Token = v[0]
+		case "user_file":
+			cfg.UserFile = v[0]
+		case "password_file":
+			cfg.PasswordFile = v[0]
+		case "user_env":
+			cfg.UserEnv = v[0]
+		case "password_env":
+			cfg.PasswordEnv = v[0]
+		case "quota_key":
+			cfg.QuotaKey = v[0]
+		case "format":
+			switch v[0] {
+			case FormatTabSeparatedWithNamesAndTypes, FormatRowBinaryWithNamesAndTypes:
+				cfg.Format = v[0]
+			default:
+				err = fmt.Errorf("clickhouse: format '%s' is not supported", v[0])
+			}
 		default:
-			cfg.Params[k] = v[0]
+			if _, ok := params[settingsParamPrefix+k]; ok {
+				err = fmt.Errorf("clickhouse: setting '%s' is set twice", k)
+			} else if err = validateSetting(k, v[0], false); err == nil {
+				cfg.Params[k] = v[0]
+			}
 		}
 		if err != nil {
 			return err
@@ -168,6 +597,36 @@ func parseDSNParams(cfg *Config, params map[string][]string) (err error) {
 	return
 }
 
+// parseTLSParam parses the tls parameter: "true" enables HTTPS with the
+// default config, "skip-verify" enables HTTPS without verification of
+// certificates, other values are keys of configs registered with
+// RegisterTLSConfig.
+func parseTLSParam(cfg *Config, value string) error {
+	switch value {
+	case "false":
+		return nil
+	case "true":
+	case tlsSkipVerify:
+		cfg.TLSConfig = value
+	default:
+		if !isTLSConfigRegistered(value) {
+			return fmt.Errorf("clickhouse: TLS config %q is not registered", value)
+		}
+		cfg.TLSConfig = value
+	}
+	cfg.Scheme = "https"
+	return nil
+}
+
+// parseSessionTimeout parses a duration or a number of seconds like the
+// session_timeout setting of ClickHouse
+func parseSessionTimeout(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
+
 func ensureHavePort(addr string) string {
 	if _, _, err := net.SplitHostPort(addr); err != nil {
 		return net.JoinHostPort(addr, "8123")
@@ -1,15 +1,14 @@
 package clickhouse
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"database/sql/driver"
 	"io"
-	"log"
-	"net"
+	"io/ioutil"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -21,8 +20,31 @@ const (
 	// QueryID uses for setting query_id request param for request to Clickhouse
 	QueryID key = iota
 	// QuotaKey uses for setting quota_key request param for request to Clickhouse
+	//
+	// Deprecated: use WithQuotaKey, which does not expose the key in the URL
 	QuotaKey
 
+	checksumKey
+	stickyHostKey
+	finalKey
+	sampleKey
+	settingsKey
+	rowFilterKey
+	queryIDCallbackKey
+	externalTablesKey
+	settingsErrKey
+	progressKey
+	summaryKey
+	headersKey
+	quotaHeaderKey
+	totalsKey
+	cacheTTLKey
+	scanLocationKey
+	enumAsNumberKey
+	// contextKeys is the number of the keys above, the keys of new options
+	// are added before it
+	contextKeys
+
 	quotaKeyParamName = "quota_key"
 	queryIDParamName  = "query_id"
 )
@@ -31,50 +53,150 @@ const (
 type conn struct {
 	url                *url.URL
 	user               *url.Userinfo
+	authMode           string
+	token              string
+	tokenFunc          TokenFunc
+	credentials        CredentialsProvider
+	headers            map[string]string
+	quotaKey           string
+	version            *Version
 	location           *time.Location
 	useDBLocation      bool
+	scanLocation       *time.Location
+	utcTimeParams      bool
+	enumAsNumber       bool
+	zeroDateAsNil      bool
+	rowBinary          bool
 	useGzipCompression bool
-	transport          *http.Transport
+	maxBodySize        int64
+	maxBodyDrain       int64
+	bufferSize         int
+	requestTimeout     time.Duration
+	ignoreDeadline     bool
+	maxRetries         int
+	retryBackoff       time.Duration
+	interceptor        func(string, []interface{}) (string, []interface{}, error)
+	responseCache      ResponseCache
+	onError            func(error, string)
+	onConnect          func(context.Context, Connection) error
+	hosts              *hostPool
+	readHosts          *hostPool
+	readHost           string
+	autoSession        bool
+	tracer             Tracer
+	collector          Collector
+	encoding           string
+	compressor         Compressor
+	transport          http.RoundTripper
 	cancel             context.CancelFunc
 	txCtx              context.Context
 	stmts              []*stmt
-	logger             *log.Logger
+	stmtCache          *stmtCache
+	logger             Logger
 	closed             int32
+	broken             int32
 }
 
 func newConn(cfg *Config) *conn {
-	var logger *log.Logger
-	if cfg.Debug {
-		logger = log.New(os.Stderr, "clickhouse: ", log.LstdFlags)
+	extra := map[string]string{"default_format": "TabSeparatedWithNamesAndTypes"}
+	for k, v := range cfg.sessionParams() {
+		extra[k] = v
 	}
 	c := &conn{
-		url:                cfg.url(map[string]string{"default_format": "TabSeparatedWithNamesAndTypes"}, false),
+		url:                cfg.url(extra, false),
+		authMode:           cfg.AuthMode,
+		token:              cfg.Token,
+		tokenFunc:          cfg.TokenFunc,
+		credentials:        cfg.credentials(),
+		headers:            cfg.Headers,
+		quotaKey:           cfg.QuotaKey,
 		location:           cfg.Location,
 		useDBLocation:      cfg.UseDBLocation,
+		scanLocation:       cfg.ScanLocation,
+		utcTimeParams:      cfg.TimeParams == TimeParamsUTC,
+		enumAsNumber:       cfg.EnumAsNumber,
+		zeroDateAsNil:      cfg.ZeroDateAsNil,
+		rowBinary:          cfg.Format == FormatRowBinaryWithNamesAndTypes,
 		useGzipCompression: cfg.GzipCompression,
+		maxBodySize:        cfg.MaxRequestBodySize,
+		maxBodyDrain:       maxBodyDrain(cfg.MaxBodyDrainBytes),
+		bufferSize:         cfg.BufferSize,
+		requestTimeout:     cfg.RequestTimeout,
+		ignoreDeadline:     cfg.IgnoreDeadline,
+		maxRetries:         cfg.MaxRetries,
+		retryBackoff:       cfg.RetryBackoff,
+		interceptor:        cfg.QueryInterceptor,
+		onError:            cfg.OnError,
+		onConnect:          cfg.OnConnect,
+		hosts:              getHostPool(cfg.hosts(), cfg),
+		autoSession:        cfg.SessionID == SessionIDAuto,
+		tracer:             cfg.Tracer,
+		collector:          cfg.Collector,
+		encoding:           cfg.Compression,
+		compressor:         getCompressor(cfg.Compression),
+		stmtCache:          newStmtCache(cfg.StmtCacheSize),
 		transport: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout:   cfg.Timeout,
-				KeepAlive: cfg.IdleTimeout,
-				DualStack: true,
-			}).DialContext,
-			MaxIdleConns:          1,
-			IdleConnTimeout:       cfg.IdleTimeout,
-			ResponseHeaderTimeout: cfg.ReadTimeout,
+			DialContext:           cfg.dialer(),
+			MaxIdleConns:          maxIdleConns(cfg.MaxIdleConns, cfg.MaxIdleConnsPerHost),
+			MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+			MaxConnsPerHost:       cfg.MaxConnsPerHost,
+			IdleConnTimeout:       orDuration(cfg.IdleConnTimeout, cfg.IdleTimeout),
+			DisableKeepAlives:     cfg.DisableKeepAlives,
+			TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+			ResponseHeaderTimeout: orDuration(cfg.ResponseHeaderTimeout, cfg.ReadTimeout),
 			TLSClientConfig:       getTLSConfigClone(cfg.TLSConfig),
 		},
-		logger: logger,
+		logger: cfg.logger(),
+	}
+	if cfg.ETagCache {
+		c.responseCache = cfg.ResponseCache
+		if c.responseCache == nil {
+			c.responseCache = defaultResponseCache
+		}
+	}
+	if c.hosts != nil {
+		c.url.Host = c.hosts.order()[0]
+		c.hosts.acquire()
+	}
+	// the sessions live on a single server, so they keep the reads on it
+	if hosts := cfg.readHosts(); len(hosts) > 0 && len(cfg.SessionID) == 0 {
+		c.readHost = hosts[0]
+		if c.readHosts = getHostPool(hosts, cfg); c.readHosts != nil {
+			c.readHost = c.readHosts.order()[0]
+			c.readHosts.acquire()
+		}
 	}
 	// store userinfo in separate member, we will handle it manually
 	c.user = c.url.User
 	c.url.User = nil
-	c.log("new connection", c.url.Scheme, c.url.Host, c.url.Path)
+	c.logf(Logger.Debugf, "new connection %s://%s%s", c.url.Scheme, c.url.Host, c.url.Path)
 	return c
 }
 
-func (c *conn) log(msg ...interface{}) {
+// maxIdleConns returns the limit of idle connections of the transport,
+// it is not limited if only the number of idle connections per host is set
+func maxIdleConns(total, perHost int) int {
+	if total > 0 {
+		return total
+	}
+	if perHost > 0 {
+		return 0
+	}
+	return 1
+}
+
+// orDuration returns d if it is set, otherwise the default
+func orDuration(d, def time.Duration) time.Duration {
+	if d != 0 {
+		return d
+	}
+	return def
+}
+
+// logf logs the message with the method of the logger of the connection
+func (c *conn) logf(method func(Logger, string, ...interface{}), format string, args ...interface{}) {
 	if c.logger != nil {
-		c.logger.Println(msg...)
+		method(c.logger, format, args...)
 	}
 }
 
@@ -88,7 +210,7 @@ func (c *conn) Prepare(query string) (driver.Stmt, error) {
 // connection as no longer in use.
 func (c *conn) Close() error {
 	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
-		c.log("close connection", c.url.Scheme, c.url.Host, c.url.Path)
+		c.logf(Logger.Debugf, "close connection %s://%s%s", c.url.Scheme, c.url.Host, c.url.Path)
 		cancel := c.cancel
 		transport := c.transport
 		c.transport = nil
@@ -97,9 +219,11 @@ func (c *conn) Close() error {
 		if cancel != nil {
 			cancel()
 		}
-		if transport != nil {
-			transport.CloseIdleConnections()
+		if t, ok := transport.(*http.Transport); ok {
+			t.CloseIdleConnections()
 		}
+		c.hosts.release()
+		c.readHosts.release()
 	}
 	return nil
 }
@@ -126,7 +250,7 @@ func (c *conn) Commit() (err error) {
 		return nil
 	}
 	for _, stmt := range stmts {
-		c.log("commit statement: ", stmt.prefix, stmt.pattern)
+		c.logf(Logger.Debugf, "commit statement: %s %s", stmt.prefix, stmt.pattern)
 		if err = stmt.commit(ctx); err != nil {
 			break
 		}
@@ -156,14 +280,59 @@ func (c *conn) Rollback() error {
 
 // Exec implements the driver.Execer
 func (c *conn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	query, args, err := c.intercept(query, args)
+	if err != nil {
+		return nil, err
+	}
 	return c.exec(context.Background(), query, args)
 }
 
 // Query implements the driver.Queryer
 func (c *conn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	query, args, err := c.intercept(query, args)
+	if err != nil {
+		return nil, err
+	}
 	return c.query(context.Background(), query, args)
 }
 
+// intercept applies Config.QueryInterceptor to the query and its arguments
+func (c *conn) intercept(query string, args []driver.Value) (string, []driver.Value, error) {
+	if c.interceptor == nil {
+		return query, args, nil
+	}
+	var iargs []interface{}
+	if args != nil {
+		iargs = make([]interface{}, len(args))
+		for i, arg := range args {
+			iargs[i] = arg
+		}
+	}
+	query, iargs, err := c.interceptor(query, iargs)
+	if err != nil {
+		c.reportError(err, query)
+		return "", nil, err
+	}
+	if iargs == nil {
+		return query, nil, nil
+	}
+	args = make([]driver.Value, len(iargs))
+	for i, arg := range iargs {
+		if args[i], err = (converter{}).ConvertValue(arg); err != nil {
+			return "", nil, err
+		}
+	}
+	return query, args, nil
+}
+
+// reportError passes the error to Config.OnError. driver.ErrBadConn is not
+// reported, since database/sql retries the query on another connection.
+func (c *conn) reportError(err error, query string) {
+	if err != nil && err != driver.ErrBadConn && c.onError != nil {
+		c.onError(err, query)
+	}
+}
+
 func (c *conn) beginTx(ctx context.Context) (driver.Tx, error) {
 	if atomic.LoadInt32(&c.closed) != 0 {
 		return nil, driver.ErrBadConn
@@ -172,7 +341,16 @@ func (c *conn) beginTx(ctx context.Context) (driver.Tx, error) {
 	return c, nil
 }
 
-func (c *conn) query(ctx context.Context, query string, args []driver.Value) (driver.Rows, error) {
+func (c *conn) query(ctx context.Context, query string, args []driver.Value) (_ driver.Rows, err error) {
+	ctx, span := c.startSpan(ctx, "clickhouse.query", query)
+	finish := c.startQuery(query)
+	defer func() {
+		c.reportError(err, query)
+		if err != nil {
+			endSpan(span, err)
+			finish(err)
+		}
+	}()
 	if atomic.LoadInt32(&c.closed) != 0 {
 		return nil, driver.ErrBadConn
 	}
@@ -180,31 +358,167 @@ func (c *conn) query(ctx context.Context, query string, args []driver.Value) (dr
 	if err != nil {
 		return nil, err
 	}
-	body, err := c.doRequest(ctx, req)
+	checksum, _ := ctx.Value(checksumKey).(*rowsChecksum)
+	sections := hasResultSections(c.stmtCache.get(query), req)
+	// the checksums are computed over the text rows, RowBinary has no totals
+	binaryResult := c.rowBinary && checksum == nil && !sections
+	if binaryResult {
+		reqQuery := req.URL.Query()
+		reqQuery.Set("default_format", FormatRowBinaryWithNamesAndTypes)
+		req.URL.RawQuery = reqQuery.Encode()
+	}
+	traceRequest(span, req)
+	body, err := c.doCachedRequest(ctx, req, query, args)
+	if err != nil {
+		return nil, err
+	}
+	var counted *countingBody
+	if span != nil {
+		counted = &countingBody{ReadCloser: body}
+		body = counted
+	}
+
+	var (
+		rows   driver.Rows
+		result *resultRows
+	)
+	if binaryResult {
+		var binRows *binaryRows
+		if binRows, err = newBinaryRows(c, body, c.parserOptions(ctx)); err == nil {
+			rows, result = binRows, &binRows.resultRows
+		}
+	} else {
+		var txtRows *textRows
+		if txtRows, err = newTextRows(c, body, c.parserOptions(ctx)); err == nil {
+			txtRows.checksum = checksum
+			txtRows.sections = sections
+			rows, result = txtRows, &txtRows.resultRows
+		}
+	}
 	if err != nil {
+		body.Close()
 		return nil, err
 	}
+	result.onClose = func() {
+		if span != nil {
+			span.SetAttribute("db.rows_returned", result.rowsRead)
+			span.SetAttribute("clickhouse.bytes_received", counted.n)
+			endSpan(span, nil)
+		}
+		finish(nil)
+		keepTotals(ctx, result)
+	}
+	return rows, nil
+}
 
-	return newTextRows(c, body, c.location, c.useDBLocation)
+func (c *conn) queryFormat(ctx context.Context, query, format string, args []driver.Value) (_ io.ReadCloser, err error) {
+	if atomic.LoadInt32(&c.closed) != 0 {
+		return nil, driver.ErrBadConn
+	}
+	query, args, err = c.intercept(query, args)
+	if err != nil {
+		return nil, err
+	}
+	finish := c.startQuery(query)
+	defer func() {
+		c.reportError(err, query)
+		if err != nil {
+			finish(err)
+		}
+	}()
+	req, err := c.buildRequest(ctx, query, args, true)
+	if err != nil {
+		return nil, err
+	}
+	reqQuery := req.URL.Query()
+	reqQuery.Set("default_format", format)
+	req.URL.RawQuery = reqQuery.Encode()
+	body, err := c.doRequestRetrying(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	return &finishingBody{ReadCloser: body, finish: finish}, nil
 }
 
-func (c *conn) exec(ctx context.Context, query string, args []driver.Value) (driver.Result, error) {
+func (c *conn) exec(ctx context.Context, query string, args []driver.Value) (_ driver.Result, err error) {
+	ctx, span := c.startSpan(ctx, "clickhouse.exec", query)
+	finish := c.startQuery(query)
+	defer func() {
+		c.reportError(err, query)
+		endSpan(span, err)
+		finish(err)
+	}()
 	if atomic.LoadInt32(&c.closed) != 0 {
 		return nil, driver.ErrBadConn
 	}
+	if err = c.checkFeatures(ctx); err != nil {
+		return nil, err
+	}
+	// the summary of the response is the number of affected rows
+	summary, ok := ctx.Value(summaryKey).(*querySummary)
+	if !ok {
+		summary = new(querySummary)
+		ctx = context.WithValue(ctx, summaryKey, summary)
+	}
 	req, err := c.buildRequest(ctx, query, args, false)
 	if err != nil {
 		return nil, err
 	}
-	body, err := c.doRequest(ctx, req)
+	traceRequest(span, req)
+	body, err := c.doRequestRetrying(ctx, req, isIdempotent(query, req))
 	if body != nil {
 		body.Close()
 	}
-	return emptyResult, err
+	if err = asyncInsertError(query, req, err); err != nil {
+		return nil, err
+	}
+	return summaryResult(summary), nil
+}
+
+// execStream executes the query with the data streamed from r appended to it
+func (c *conn) execStream(ctx context.Context, query string, r io.Reader) (err error) {
+	defer func() {
+		c.reportError(err, query)
+	}()
+	if atomic.LoadInt32(&c.closed) != 0 {
+		return driver.ErrBadConn
+	}
+	if query, _, err = c.intercept(query, nil); err != nil {
+		return err
+	}
+	finish := c.startQuery(query)
+	defer func() {
+		finish(err)
+	}()
+	if err = c.checkFeatures(ctx); err != nil {
+		return err
+	}
+	req, err := c.buildRequest(ctx, query, nil, false)
+	if err != nil {
+		return err
+	}
+	body := io.MultiReader(strings.NewReader(query), r)
+	if c.compressor != nil {
+		req.Body = compressReader(c.compressor, body)
+	} else {
+		req.Body = ioutil.NopCloser(body)
+	}
+	req.GetBody, req.ContentLength = nil, -1
+	respBody, err := c.doRequest(ctx, req)
+	if respBody != nil {
+		respBody.Close()
+	}
+	return asyncInsertError(query, req, err)
 }
 
 func (c *conn) doRequest(ctx context.Context, req *http.Request) (io.ReadCloser, error) {
-	ctx, cancel := context.WithCancel(ctx)
+	var cancel context.CancelFunc
+	if c.requestTimeout > 0 {
+		// the deadline covers the whole round-trip including reading of the body
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
 	transport := c.transport
 	c.cancel = cancel
 
@@ -213,17 +527,87 @@ func (c *conn) doRequest(ctx context.Context, req *http.Request) (io.ReadCloser,
 		return nil, driver.ErrBadConn
 	}
 
+	hosts := c.hosts
+	if len(c.readHost) > 0 && req.URL.Host == c.readHost {
+		hosts = c.readHosts
+	}
+	sticky, _ := ctx.Value(stickyHostKey).(*stickyHost)
+	pinned := sticky.get()
+	if len(pinned) > 0 {
+		req.URL.Host = pinned
+	}
+	var (
+		cacheKey   string
+		cachedBody []byte
+	)
+	if c.responseCache != nil {
+		if cacheKey = responseCacheKey(req); len(cacheKey) > 0 {
+			if etag, body, ok := c.responseCache.Get(cacheKey); ok {
+				req.Header.Set("If-None-Match", etag)
+				cachedBody = body
+			}
+		}
+	}
+	if !c.ignoreDeadline {
+		limitExecutionTime(ctx, req)
+	}
 	req = req.WithContext(ctx)
+	if c.collector != nil && req.Body != nil && req.Body != http.NoBody {
+		req.Body = &reportedBody{ReadCloser: req.Body, report: c.collector.BytesWritten}
+	}
+	stop := c.watchCancel(ctx, req)
+	start := time.Now()
 	resp, err := transport.RoundTrip(req)
+	if err != nil && hosts != nil && len(pinned) == 0 && isDialError(err) {
+		resp, err = c.failover(hosts, transport, req, err)
+	}
+	if err == nil && resp.Body != http.NoBody {
+		resp.Body = &drainingBody{ReadCloser: resp.Body, ctx: ctx, limit: c.maxBodyDrain}
+	}
+	if err == nil {
+		err = c.decompress(resp)
+	}
+	if err == nil && c.collector != nil {
+		resp.Body = &reportedBody{ReadCloser: resp.Body, report: c.collector.BytesRead}
+	}
 	if err != nil {
+		c.logf(Logger.Errorf, "request %s query_id=%s failed in %s: %v",
+			redactURL(req.URL), req.URL.Query().Get(queryIDParamName), time.Since(start), err)
+		c.markBroken(ctx, err)
+		if ctx.Err() == nil {
+			// otherwise the query is killed
+			stop()
+		}
 		c.cancel = nil
 		return nil, err
 	}
+	logMethod := Logger.Debugf
+	if resp.StatusCode != 200 && resp.StatusCode != http.StatusNotModified {
+		logMethod = Logger.Errorf
+	}
+	c.logf(logMethod, "request %s query_id=%s status %d in %s",
+		redactURL(req.URL), req.URL.Query().Get(queryIDParamName), resp.StatusCode, time.Since(start))
+	if resp.StatusCode == 200 {
+		sticky.pin(req.URL.Host)
+	}
+	reportProgress(ctx, resp.Header)
+	if resp.StatusCode != 200 || resp.Body == http.NoBody {
+		stop()
+	} else {
+		resp.Body = &watchedBody{ReadCloser: resp.Body, stop: stop}
+	}
+	if resp.StatusCode == http.StatusNotModified && cachedBody != nil {
+		resp.Body.Close()
+		return ioutil.NopCloser(bytes.NewReader(cachedBody)), nil
+	}
+	if etag := resp.Header.Get("ETag"); resp.StatusCode == 200 && len(cacheKey) > 0 && len(etag) > 0 {
+		return &cachingBody{ReadCloser: resp.Body, cache: c.responseCache, key: cacheKey, etag: etag}, nil
+	}
 	if resp.StatusCode != 200 {
 		msg, err := readResponse(resp)
 		c.cancel = nil
 		if err == nil {
-			err = newError(string(msg))
+			err = newResponseError(string(msg), resp.Header.Get(exceptionCodeHeader))
 		}
 		return nil, err
 	}
@@ -231,32 +615,124 @@ func (c *conn) doRequest(ctx context.Context, req *http.Request) (io.ReadCloser,
 	return resp.Body, nil
 }
 
-func (c *conn) buildRequest(ctx context.Context, query string, params []driver.Value, readonly bool) (*http.Request, error) {
-	var (
-		method string
-		err    error
-	)
-	if params != nil {
-		if query, err = interpolateParams(query, params); err != nil {
+// failover retries the request, which failed to connect to the host of the
+// connection, with the other hosts of the pool. The connection switches to
+// the first host which accepts the request.
+func (c *conn) failover(hosts *hostPool, transport http.RoundTripper, req *http.Request, err error) (*http.Response, error) {
+	failed := req.URL.Host
+	hosts.markDown(failed, err)
+	if req.GetBody == nil && req.Body != nil {
+		// the body is streamed and can not be sent again
+		return nil, err
+	}
+	for _, host := range hosts.order() {
+		if host == failed {
+			continue
+		}
+		if req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		req.URL.Host = host
+		var resp *http.Response
+		if resp, err = transport.RoundTrip(req); err == nil {
+			c.logf(Logger.Warnf, "failover from %s to %s", failed, host)
+			hosts.markUp(host)
+			if hosts == c.readHosts {
+				c.readHost = host
+			} else {
+				c.url.Host = host
+			}
+			return resp, nil
+		}
+		if !isDialError(err) {
 			return nil, err
 		}
+		hosts.markDown(host, err)
+	}
+	return nil, err
+}
+
+// rewriteQuery interpolates the parameters of the query and applies the
+// modifiers of the context to it
+func (c *conn) rewriteQuery(ctx context.Context, query string, params []driver.Value) (string, error) {
+	var err error
+	if params != nil {
+		if query, err = interpolateParams2(query, params, c.stmtCache.get(query).index); err != nil {
+			return "", err
+		}
+	}
+	if ctx != nil {
+		if err, ok := ctx.Value(settingsErrKey).(error); ok {
+			return "", err
+		}
+		// row filters go first, so FINAL and SAMPLE are added to the filtered tables
+		if filters, ok := ctx.Value(rowFilterKey).([]rowFilter); ok {
+			if query, err = addRowFilters(query, filters); err != nil {
+				return "", err
+			}
+		}
+		if final, _ := ctx.Value(finalKey).(bool); final {
+			if query, err = addFinal(query); err != nil {
+				return "", err
+			}
+		}
+		if sample, ok := ctx.Value(sampleKey).(sampleOption); ok {
+			if query, err = addSample(query, sample); err != nil {
+				return "", err
+			}
+		}
+	}
+	return query, nil
+}
+
+func (c *conn) buildRequest(ctx context.Context, query string, params []driver.Value, readonly bool) (*http.Request, error) {
+	var method string
+	query, err := c.rewriteQuery(ctx, query, params)
+	if err != nil {
+		return nil, err
 	}
 	if readonly {
 		method = http.MethodGet
 	} else {
 		method = http.MethodPost
 	}
-	c.log("query: ", query)
+	if c.maxBodySize > 0 && int64(len(query)) > c.maxBodySize {
+		return nil, ErrPayloadTooLarge{Actual: int64(len(query)), Limit: c.maxBodySize}
+	}
+	c.logf(Logger.Debugf, "query: %s", query)
 	req, err := http.NewRequest(method, c.url.String(), strings.NewReader(query))
-	// http.Transport ignores url.User argument, handle it here
-	if err == nil && c.user != nil {
-		p, _ := c.user.Password()
-		req.SetBasicAuth(c.user.Username(), p)
+	if err == nil && readonly && len(c.readHost) > 0 {
+		// the read-only queries go to the read replicas
+		req.URL.Host = c.readHost
+	}
+	if err == nil {
+		c.setHeaders(ctx, req)
+		c.setQuotaKey(ctx, req)
+		err = c.authenticate(ctx, req)
+	}
+	if tables := externalTables(ctx); err == nil && len(tables) > 0 {
+		err = c.attachExternalTables(req, query, tables)
+	} else if err == nil && c.compressor != nil {
+		c.compress(req, query)
 	}
 	if ctx != nil {
 		quotaKey, quotaOk := ctx.Value(QuotaKey).(string)
 		queryID, queryOk := ctx.Value(QueryID).(string)
-		if quotaOk || queryOk {
+		callback, callbackOk := ctx.Value(queryIDCallbackKey).(func(string))
+		if !queryOk && (ctx.Done() != nil || c.tracer != nil || callbackOk) {
+			// generated to kill the query if the context is cancelled
+			// and to find the query of a span or a callback in system.query_log
+			queryID, queryOk = newQueryID(), true
+		}
+		if callbackOk && queryOk && len(queryID) > 0 {
+			callback(queryID)
+		}
+		settings, settingsOk := ctx.Value(settingsKey).(map[string]string)
+		if quotaOk || queryOk || settingsOk {
 			reqQuery := req.URL.Query()
 			if quotaOk {
 				reqQuery.Add(quotaKeyParamName, quotaKey)
@@ -264,17 +740,82 @@ func (c *conn) buildRequest(ctx context.Context, query string, params []driver.V
 			if queryOk && len(queryID) > 0 {
 				reqQuery.Add(queryIDParamName, queryID)
 			}
+			for k, v := range settings {
+				reqQuery.Set(k, v)
+			}
 			req.URL.RawQuery = reqQuery.Encode()
 		}
 	}
 	return req, err
 }
 
+// compress makes the request to send the query compressed and to accept
+// compressed responses
+func (c *conn) compress(req *http.Request, query string) {
+	req.GetBody = compressedBody(c.compressor, query)
+	req.Body, _ = req.GetBody()
+	req.ContentLength = -1
+	req.Header.Set("Content-Encoding", c.encoding)
+	if c.encoding != "gzip" {
+		// gzip responses are decompressed by the transport
+		req.Header.Set("Accept-Encoding", c.encoding)
+	}
+	reqQuery := req.URL.Query()
+	reqQuery.Set("enable_http_compression", "1")
+	req.URL.RawQuery = reqQuery.Encode()
+}
+
+// decompress decompresses the body of the response if it is encoded
+// with the encoding of the connection
+func (c *conn) decompress(resp *http.Response) error {
+	if c.compressor == nil || resp.Header.Get("Content-Encoding") != c.encoding {
+		return nil
+	}
+	r, err := c.compressor.NewReader(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return err
+	}
+	resp.Body = &decompressedBody{ReadCloser: r, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	return nil
+}
+
+// deferredStmt returns the statement of the transaction which sends the rows
+// of the INSERT ... VALUES query on commit, or nil if the connection has no
+// transaction or the query can not be batched
+func (c *conn) deferredStmt(query string, args []driver.Value) *stmt {
+	if c.txCtx == nil || len(args) == 0 {
+		return nil
+	}
+	s := newStmt(query)
+	if !s.batchMode || s.params || len(args) != len(s.index) {
+		return nil
+	}
+	for _, prev := range c.stmts {
+		if prev.prefix == s.prefix && prev.pattern == s.pattern && atomic.LoadInt32(&prev.closed) == 0 {
+			return prev
+		}
+	}
+	c.logf(Logger.Debugf, "new statement: %s", query)
+	s.c = c
+	c.stmts = append(c.stmts, s)
+	return s
+}
+
 func (c *conn) prepare(query string) (*stmt, error) {
 	if atomic.LoadInt32(&c.closed) != 0 {
 		return nil, driver.ErrBadConn
 	}
-	c.log("new statement: ", query)
+	query, args, err := c.intercept(query, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 0 {
+		c.reportError(ErrInterceptorArgs, query)
+		return nil, ErrInterceptorArgs
+	}
+	c.logf(Logger.Debugf, "new statement: %s", query)
 	s := newStmt(query)
 	s.c = c
 	if c.txCtx == nil {
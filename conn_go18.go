@@ -4,21 +4,22 @@ package clickhouse
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
-	"net/http"
-	"net/url"
-	"strings"
 )
 
-// Ping implements the driver.Pinger
+// Ping implements the driver.Pinger. It executes SELECT 1 with the
+// credentials and the database of the connection, so the errors of the
+// server, e.g. a wrong password or a missing database, are returned as is.
 func (c *conn) Ping(ctx context.Context) error {
 	if c.transport == nil {
 		return driver.ErrBadConn
 	}
-	// make request with empty body, response should be "Ok"
-	u := &url.URL{Scheme: c.url.Scheme, User: c.url.User, Host: c.url.Host, Path: "/"}
-	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	req, err := c.buildRequest(ctx, "SELECT 1", nil, true)
 	if err != nil {
 		return err
 	}
@@ -27,17 +28,28 @@ func (c *conn) Ping(ctx context.Context) error {
 		c.cancel = nil
 	}()
 	if err != nil {
+		var chErr *Error
+		if errors.As(err, &chErr) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		return driver.ErrBadConn
 	}
-	resp, err := ioutil.ReadAll(respBody)
-	if err != nil || len(resp) != 4 || !strings.HasPrefix(string(resp), "Ok.") {
+	defer respBody.Close()
+	if _, err = io.Copy(ioutil.Discard, respBody); err != nil {
 		return driver.ErrBadConn
 	}
 	return nil
 }
 
-// BeginTx implements the driver.ConnBeginTx
+// BeginTx implements the driver.ConnBeginTx. ClickHouse has no isolation
+// levels, so only the default one is accepted.
 func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if level := sql.IsolationLevel(opts.Isolation); level != sql.LevelDefault {
+		return nil, fmt.Errorf("clickhouse: isolation level %s is not supported", level)
+	}
 	return c.beginTx(ctx)
 }
 
@@ -48,30 +60,66 @@ func (c *conn) PrepareContext(_ context.Context, query string) (driver.Stmt, err
 
 // ExecContext implements the driver.ExecerContext
 func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
-	values, err := namedValueToValue(args)
+	ctx, values, err := namedValueToValue(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	if query, values, err = c.intercept(query, values); err != nil {
+		return nil, err
+	}
+	statements, err := c.scriptStatements(query, values)
 	if err != nil {
 		return nil, err
 	}
+	if len(statements) > 1 {
+		return c.execScript(ctx, statements)
+	}
+	if s := c.deferredStmt(query, values); s != nil {
+		return s.exec(ctx, values)
+	}
 	return c.exec(ctx, query, values)
 }
 
 // QueryContext implements the driver.QueryerContext
 func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
-	values, err := namedValueToValue(args)
+	ctx, values, err := namedValueToValue(ctx, args)
 	if err != nil {
 		return nil, err
 	}
+	if query, values, err = c.intercept(query, values); err != nil {
+		return nil, err
+	}
+	statements, err := c.scriptStatements(query, values)
+	if err != nil {
+		return nil, err
+	}
+	if len(statements) > 1 {
+		return c.queryScript(ctx, statements)
+	}
 	return c.query(ctx, query, values)
 }
 
-func namedValueToValue(named []driver.NamedValue) ([]driver.Value, error) {
-	dargs := make([]driver.Value, len(named))
-	for n, param := range named {
-		if len(param.Name) > 0 {
-			// TODO: support the use of Named Parameters #561
-			return nil, ErrNameParams
+// namedValueToValue returns the positional values, named values are added
+// to the context as server-side query parameters
+func namedValueToValue(ctx context.Context, named []driver.NamedValue) (context.Context, []driver.Value, error) {
+	dargs := make([]driver.Value, 0, len(named))
+	var params map[string]string
+	for _, param := range named {
+		if len(param.Name) == 0 {
+			dargs = append(dargs, param.Value)
+			continue
+		}
+		v, err := formatQueryParam(param.Value)
+		if err != nil {
+			return nil, nil, err
 		}
-		dargs[n] = param.Value
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[queryParamPrefix+param.Name] = v
+	}
+	if params != nil {
+		ctx = withSettings(ctx, params)
 	}
-	return dargs, nil
+	return ctx, dargs, nil
 }
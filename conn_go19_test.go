@@ -28,7 +28,7 @@ func (s *connSuite) TestExecBuild19() {
 		_, err = result.LastInsertId()
 		s.Equal(ErrNoLastInsertID, err)
 		_, err = result.RowsAffected()
-		s.Equal(ErrNoRowsAffected, err)
+		s.NoError(err)
 		if len(tc.query2) == 0 {
 			continue
 		}
@@ -4,10 +4,16 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -130,7 +136,7 @@ func (s *connSuite) TestExec() {
 		_, err = result.LastInsertId()
 		s.Equal(ErrNoLastInsertID, err)
 		_, err = result.RowsAffected()
-		s.Equal(ErrNoRowsAffected, err)
+		s.NoError(err)
 		if len(tc.query2) == 0 {
 			continue
 		}
@@ -334,6 +340,338 @@ func (s *connSuite) TestBuildRequestWithQueryIdAndQuotaKey() {
 		}
 	}
 }
+func TestBuildRequestMaxBodySize(t *testing.T) {
+	cfg := NewConfig()
+	cfg.MaxRequestBodySize = 10
+	cn := newConn(cfg)
+	_, err := cn.buildRequest(context.Background(), "SELECT 1", nil, false)
+	assert.NoError(t, err)
+	_, err = cn.buildRequest(context.Background(), "INSERT INTO t VALUES (?)", []driver.Value{"value"}, false)
+	assert.Equal(t, ErrPayloadTooLarge{Actual: 30, Limit: 10}, err)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		w.Write([]byte("1\n"))
+		w.(http.Flusher).Flush()
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("2\n"))
+	})
+	defer ts.Close()
+
+	cfg, err := ParseDSN(dsn + "?request_timeout=50ms")
+	require.NoError(t, err)
+	cn := newConn(cfg)
+	req, err := cn.buildRequest(context.Background(), "SELECT 1", nil, true)
+	require.NoError(t, err)
+	body, err := cn.doRequest(context.Background(), req)
+	require.NoError(t, err)
+	defer body.Close()
+	_, err = ioutil.ReadAll(body)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestExecReplace(t *testing.T) {
+	var methods, queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		methods = append(methods, r.Method)
+		queries = append(queries, query)
+		// a row is written for every tuple of values after the list of columns
+		w.Header().Set(summaryHeader, fmt.Sprintf(`{"written_rows":"%d"}`, strings.Count(query, "(")-1))
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	result, err := db.ExecContext(context.Background(), "REPLACE INTO data (i64) VALUES (?)", 1)
+	require.NoError(t, err)
+	affected, err := result.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	st, err := tx.Prepare("REPLACE INTO data (i64) VALUES (?)")
+	require.NoError(t, err)
+	for _, v := range []int{2, 3} {
+		_, err = st.Exec(v)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tx.Commit())
+
+	assert.Equal(t, []string{http.MethodPost, http.MethodPost}, methods)
+	assert.Equal(t, []string{
+		"REPLACE INTO data (i64) VALUES (1)",
+		"REPLACE INTO data (i64) VALUES(2), (3)",
+	}, queries)
+}
+
+func TestTxDeferredInserts(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	st, err := tx.Prepare("INSERT INTO t (a, b) VALUES (?, ?)")
+	require.NoError(t, err)
+	_, err = st.Exec(1, "x")
+	require.NoError(t, err)
+	_, err = tx.Exec("INSERT INTO t (a, b) VALUES (?, ?)", 2, "y")
+	require.NoError(t, err)
+	_, err = tx.Exec("INSERT INTO s VALUES (?)", 3)
+	require.NoError(t, err)
+	// the other statements are executed immediately
+	_, err = tx.Exec("ALTER TABLE t DELETE WHERE a = ?", 0)
+	require.NoError(t, err)
+	_, err = tx.Exec("INSERT INTO s VALUES (4)")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ALTER TABLE t DELETE WHERE a = 0", "INSERT INTO s VALUES (4)"}, queries)
+	require.NoError(t, tx.Commit())
+	assert.Equal(t, []string{
+		"ALTER TABLE t DELETE WHERE a = 0",
+		"INSERT INTO s VALUES (4)",
+		"INSERT INTO t (a, b) VALUES(1, 'x'), (2, 'y')",
+		"INSERT INTO s VALUES(3)",
+	}, queries)
+
+	queries = nil
+	tx, err = db.Begin()
+	require.NoError(t, err)
+	_, err = tx.Exec("INSERT INTO t (a, b) VALUES (?, ?)", 5, "z")
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+	assert.Empty(t, queries)
+	_, err = db.Exec("INSERT INTO t (a, b) VALUES (?, ?)", 6, "w")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"INSERT INTO t (a, b) VALUES (6, 'w')"}, queries)
+
+	_, err = db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+	assert.EqualError(t, err, "clickhouse: isolation level Serializable is not supported")
+}
+
+func TestExecContentLength(t *testing.T) {
+	var lengths []int64
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		assert.Empty(t, r.TransferEncoding)
+		assert.EqualValues(t, len(query), r.ContentLength)
+		lengths = append(lengths, r.ContentLength)
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("INSERT INTO data (i64) VALUES (?)", 1)
+	require.NoError(t, err)
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	st, err := tx.Prepare("INSERT INTO data (i64) VALUES (?)")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		_, err = st.Exec(i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tx.Commit())
+	assert.Len(t, lengths, 2)
+}
+
+func TestQueryInterceptor(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		w.Write([]byte("a\nInt8\n1\n"))
+	})
+	defer ts.Close()
+
+	cfg, err := ParseDSN(dsn)
+	require.NoError(t, err)
+	cfg.QueryInterceptor = func(query string, args []interface{}) (string, []interface{}, error) {
+		if strings.HasPrefix(query, "DROP") {
+			return "", nil, ErrPermission
+		}
+		if strings.HasPrefix(query, "SELECT") {
+			return query + " AND tenant_id = ?", append(args, "tenant"), nil
+		}
+		return query, args, nil
+	}
+	db := sql.OpenDB(NewConnector(cfg))
+	defer db.Close()
+
+	var a int8
+	require.NoError(t, db.QueryRow("SELECT a FROM t WHERE b = ?", 1).Scan(&a))
+	_, err = db.Exec("INSERT INTO t VALUES (?)", 2)
+	require.NoError(t, err)
+	_, err = db.Exec("DROP TABLE t")
+	assert.Equal(t, ErrPermission, err)
+
+	st, err := db.Prepare("INSERT INTO t VALUES (?)")
+	require.NoError(t, err)
+	_, err = st.Exec(3)
+	require.NoError(t, err)
+	require.NoError(t, st.Close())
+	_, err = db.Prepare("SELECT a FROM t WHERE b = 1")
+	assert.Equal(t, ErrInterceptorArgs, err)
+
+	assert.Equal(t, []string{
+		"SELECT a FROM t WHERE b = 1 AND tenant_id = 'tenant'",
+		"INSERT INTO t VALUES (2)",
+		"INSERT INTO t VALUES(3)",
+	}, queries)
+}
+
+func TestOnError(t *testing.T) {
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		if strings.Contains(query, "missing") {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("Code: 60, e.displayText() = DB::Exception: Table default.missing doesn't exist., e.what() = DB::Exception\n"))
+			return
+		}
+		w.Write([]byte("a\nInt8\n1\n"))
+	})
+	defer ts.Close()
+
+	type report struct {
+		err   error
+		query string
+	}
+	var reports []report
+	cfg, err := ParseDSN(dsn)
+	require.NoError(t, err)
+	cfg.OnError = func(err error, query string) {
+		reports = append(reports, report{err, query})
+	}
+	db := sql.OpenDB(NewConnector(cfg))
+	defer db.Close()
+
+	var a int8
+	require.NoError(t, db.QueryRow("SELECT a FROM t").Scan(&a))
+	err = db.QueryRow("SELECT a FROM missing").Scan(&a)
+	assert.True(t, errors.Is(err, ErrTableNotFound))
+	_, err = db.Exec("INSERT INTO t VALUES (?)")
+	assert.Equal(t, ErrPlaceholderCount, err)
+
+	if assert.Len(t, reports, 2) {
+		assert.True(t, errors.Is(reports[0].err, ErrTableNotFound))
+		assert.Equal(t, "SELECT a FROM missing", reports[0].query)
+		assert.Equal(t, report{ErrPlaceholderCount, "INSERT INTO t VALUES (?)"}, reports[1])
+	}
+}
+
 func TestConn(t *testing.T) {
 	suite.Run(t, new(connSuite))
 }
+
+type countingTransport struct {
+	requests int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	req.Header.Set("X-Test", "instrumented")
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestConnectorClient(t *testing.T) {
+	var headers []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		headers = append(headers, r.Header.Get("X-Test"))
+		if len(query) == 0 {
+			w.Write([]byte("Ok.\n"))
+			return
+		}
+		w.Write([]byte("1\nUInt8\n1\n"))
+	})
+	defer ts.Close()
+
+	cfg, err := ParseDSN(dsn)
+	require.NoError(t, err)
+	transport := &countingTransport{}
+	db := sql.OpenDB(Connector(cfg, &http.Client{Transport: transport}))
+	defer db.Close()
+
+	var v int
+	require.NoError(t, db.QueryRow("SELECT 1").Scan(&v))
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	require.NoError(t, db.Ping())
+	assert.Equal(t, 3, transport.requests)
+	assert.Equal(t, []string{"instrumented", "instrumented", "instrumented"}, headers)
+}
+
+func TestPingServerError(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		if user, _, _ := r.BasicAuth(); user != "default" {
+			http.Error(w, "Code: 516. DB::Exception: "+user+": Authentication failed: password is incorrect or there is no user with such name. (AUTHENTICATION_FAILED)", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("1\nUInt8\n1\n"))
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", strings.Replace(dsn, "http://", "http://default@", 1))
+	require.NoError(t, err)
+	require.NoError(t, db.Ping())
+	db.Close()
+	assert.Equal(t, []string{"SELECT 1"}, queries)
+
+	db, err = sql.Open("clickhouse", strings.Replace(dsn, "http://", "http://unknown@", 1))
+	require.NoError(t, err)
+	defer db.Close()
+	err = db.Ping()
+	if assert.IsType(t, &Error{}, err) {
+		assert.Equal(t, 516, err.(*Error).Code)
+	}
+
+	ts.Close()
+	assert.Equal(t, driver.ErrBadConn, db.Ping())
+}
+
+func TestStreamingRows(t *testing.T) {
+	read := make(chan struct{})
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		w.Write([]byte("n\nUInt64\n1\n"))
+		w.(http.Flusher).Flush()
+		// the rest of the result is sent only after the first row is read
+		select {
+		case <-read:
+		case <-time.After(5 * time.Second):
+		}
+		w.Write([]byte("2\n"))
+	})
+	defer ts.Close()
+
+	cfg, err := ParseDSN(dsn + "?buffer_size=65536")
+	require.NoError(t, err)
+	assert.Equal(t, 65536, cfg.BufferSize)
+	assert.Contains(t, cfg.FormatDSN(), "buffer_size=65536")
+	db := sql.OpenDB(NewConnector(cfg))
+	defer db.Close()
+
+	rows, err := db.Query("SELECT n")
+	require.NoError(t, err)
+	defer rows.Close()
+	var values []uint64
+	for rows.Next() {
+		var v uint64
+		require.NoError(t, rows.Scan(&v))
+		if len(values) == 0 {
+			close(read)
+		}
+		values = append(values, v)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []uint64{1, 2}, values)
+}
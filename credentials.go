@@ -0,0 +1,119 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CredentialsProvider returns the user and the password of the requests. It
+// is called for every request, so the credentials can rotate without
+// recreating the connection pool. Empty values keep the user and the
+// password of the DSN.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (user, password string, err error)
+}
+
+// credentials returns the provider of the config, the files and the
+// environment variables of the DSN are read by the default one
+func (cfg *Config) credentials() CredentialsProvider {
+	if cfg.Credentials != nil {
+		return cfg.Credentials
+	}
+	if len(cfg.UserFile) == 0 && len(cfg.PasswordFile) == 0 && len(cfg.UserEnv) == 0 && len(cfg.PasswordEnv) == 0 {
+		return nil
+	}
+	return &secretCredentials{
+		userFile:     cfg.UserFile,
+		passwordFile: cfg.PasswordFile,
+		userEnv:      cfg.UserEnv,
+		passwordEnv:  cfg.PasswordEnv,
+		files:        make(map[string]*secretFile),
+	}
+}
+
+// secretCredentials reads the credentials from files, e.g. Kubernetes
+// secrets, and from environment variables
+type secretCredentials struct {
+	userFile     string
+	passwordFile string
+	userEnv      string
+	passwordEnv  string
+
+	mu    sync.Mutex
+	files map[string]*secretFile
+}
+
+// secretFile is the content of a file, it is read again once the file is
+// modified
+type secretFile struct {
+	modTime time.Time
+	size    int64
+	value   string
+}
+
+// Credentials implements the CredentialsProvider
+func (s *secretCredentials) Credentials(context.Context) (user, password string, err error) {
+	if user, err = s.secret(s.userFile, s.userEnv); err != nil {
+		return "", "", err
+	}
+	if password, err = s.secret(s.passwordFile, s.passwordEnv); err != nil {
+		return "", "", err
+	}
+	return user, password, nil
+}
+
+// secret returns the content of the file or the value of the environment
+// variable, the file goes first
+func (s *secretCredentials) secret(path, env string) (string, error) {
+	if len(path) == 0 {
+		if len(env) == 0 {
+			return "", nil
+		}
+		return os.Getenv(env), nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.files[path]; ok && f.modTime.Equal(info.ModTime()) && f.size == info.Size() {
+		return f.value, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	// secrets are often written with a trailing newline
+	value := strings.TrimRight(string(data), "\r\n")
+	s.files[path] = &secretFile{modTime: info.ModTime(), size: info.Size(), value: value}
+	return value, nil
+}
+
+// providedCredentials returns the user and the password of the provider,
+// the user and the password of the DSN are returned if there is no provider
+// or it returns empty values
+func (c *conn) providedCredentials(ctx context.Context, user, password string) (string, string, error) {
+	if c.credentials == nil {
+		return user, password, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	u, p, err := c.credentials.Credentials(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("clickhouse: failed to get credentials: %v", err)
+	}
+	if len(u) > 0 {
+		user = u
+	}
+	if len(p) > 0 {
+		password = p
+	}
+	return user, password, nil
+}
@@ -0,0 +1,84 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticCredentials struct {
+	user, password string
+	err            error
+}
+
+func (s staticCredentials) Credentials(context.Context) (string, string, error) {
+	return s.user, s.password, s.err
+}
+
+func TestCredentials(t *testing.T) {
+	var user, password string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		user, password, _ = r.BasicAuth()
+	})
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "credentials")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	passwordFile := filepath.Join(dir, "password")
+	require.NoError(t, ioutil.WriteFile(passwordFile, []byte("secret1\n"), 0600))
+	require.NoError(t, os.Setenv("CH_TEST_USER", "env_user"))
+	defer os.Unsetenv("CH_TEST_USER")
+
+	cfg, err := ParseDSN(dsn + "?user_env=CH_TEST_USER&insecure=1&password_file=" + passwordFile)
+	require.NoError(t, err)
+	assert.Equal(t, passwordFile, cfg.PasswordFile)
+	assert.Contains(t, cfg.FormatDSN(), "user_env=CH_TEST_USER")
+	db := sql.OpenDB(NewConnector(cfg))
+	defer db.Close()
+
+	_, err = db.Exec("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, "env_user", user)
+	assert.Equal(t, "secret1", password)
+
+	// the rotated secret is used without reopening the pool
+	require.NoError(t, ioutil.WriteFile(passwordFile, []byte("secret22"), 0600))
+	_, err = db.Exec("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, "secret22", password)
+
+	require.NoError(t, os.Remove(passwordFile))
+	_, err = db.Exec("INSERT INTO t VALUES (1)")
+	assert.Error(t, err)
+
+	// plain HTTP requires insecure=1 with the secrets of the files
+	cfg, err = ParseDSN(dsn + "?password_file=" + passwordFile)
+	require.NoError(t, err)
+	assert.Equal(t, ErrInsecureWithCredentials, cfg.Validate())
+
+	cfg, err = ParseDSN(dsn)
+	require.NoError(t, err)
+	cfg.User, cfg.InsecureHTTP = "dsn_user", true
+	cfg.Credentials = staticCredentials{password: "provided"}
+	db2 := sql.OpenDB(NewConnector(cfg))
+	defer db2.Close()
+	_, err = db2.Exec("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, "dsn_user", user)
+	assert.Equal(t, "provided", password)
+
+	cfg.Credentials = staticCredentials{err: errors.New("vault is sealed")}
+	db3 := sql.OpenDB(NewConnector(cfg))
+	defer db3.Close()
+	_, err = db3.Exec("INSERT INTO t VALUES (1)")
+	assert.EqualError(t, err, "clickhouse: failed to get credentials: vault is sealed")
+}
@@ -5,9 +5,14 @@ import (
 	"database/sql/driver"
 	"fmt"
 	"io"
+	"math"
+	"net"
 	"reflect"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
 var (
@@ -24,6 +29,8 @@ var (
 	reflectTypeUInt64      = reflect.TypeOf(uint64(0))
 	reflectTypeFloat32     = reflect.TypeOf(float32(0))
 	reflectTypeFloat64     = reflect.TypeOf(float64(0))
+	reflectTypeIP          = reflect.TypeOf(net.IP{})
+	reflectTypeDuration    = reflect.TypeOf(time.Duration(0))
 )
 
 // DataParser implements parsing of a driver value and reporting its type.
@@ -37,14 +44,60 @@ type stringParser struct {
 	length  int
 }
 
+// fixedStringParser reads FixedString values byte by byte, so the values
+// which are not valid UTF-8, e.g. binary hashes, keep their bytes
+type fixedStringParser struct {
+	unquote bool
+	length  int
+}
+
+// intervalParser parses Interval values into time.Duration, the intervals of
+// months, quarters and years have no fixed duration and are parsed into the
+// int64 numbers of the units
+type intervalParser struct {
+	unit time.Duration
+}
+
+type enumParser struct {
+	unquote bool
+	values  map[string]int16
+	bitSize int
+}
+
+type ipParser struct {
+	unquote bool
+	v4      bool
+}
+
 type dateTimeParser struct {
-	unquote  bool
-	format   string
-	location *time.Location
+	unquote   bool
+	format    string
+	location  *time.Location
+	scan      *time.Location
+	zeroAsNil bool
+}
+
+// bufferPool holds buffers reused for reading values to reduce allocations
+// when many rows are parsed
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	bufferPool.Put(buf)
 }
 
 func readNumber(s io.RuneScanner) (string, error) {
-	var builder bytes.Buffer
+	builder := getBuffer()
+	defer putBuffer(builder)
 
 loop:
 	for {
@@ -53,7 +106,7 @@ loop:
 		switch r {
 		case eof:
 			break loop
-		case ',', ']', ')':
+		case ',', ']', ')', '}', ':':
 			s.UnreadRune()
 			break loop
 		}
@@ -65,7 +118,8 @@ loop:
 }
 
 func readUnquoted(s io.RuneScanner, length int) (string, error) {
-	var builder bytes.Buffer
+	builder := getBuffer()
+	defer putBuffer(builder)
 
 	runesRead := 0
 loop:
@@ -126,17 +180,177 @@ func (p *stringParser) Type() reflect.Type {
 	return reflectTypeString
 }
 
+func (p *fixedStringParser) Parse(s io.RuneScanner) (driver.Value, error) {
+	bs, ok := s.(io.ByteScanner)
+	if !ok {
+		return readString(s, p.length, p.unquote)
+	}
+	if p.unquote {
+		if b, err := bs.ReadByte(); err != nil || b != '\'' {
+			return nil, fmt.Errorf("unexpected character instead of a quote")
+		}
+	}
+	value := make([]byte, 0, p.length)
+	for len(value) < p.length {
+		b, err := bs.ReadByte()
+		if err != nil {
+			break
+		}
+		if b == '\'' {
+			bs.UnreadByte()
+			break
+		}
+		if b == '\\' {
+			if b, err = bs.ReadByte(); err != nil {
+				return nil, fmt.Errorf("incorrect escaping in string: unexpected eof in escaped char")
+			}
+			b = unescapeByte(b)
+		}
+		value = append(value, b)
+	}
+	if len(value) != p.length {
+		return nil, fmt.Errorf("unexpected string length %d, expected %d", len(value), p.length)
+	}
+	if p.unquote {
+		if b, err := bs.ReadByte(); err != nil || b != '\'' {
+			return nil, fmt.Errorf("unexpected character instead of a quote")
+		}
+	}
+	return string(value), nil
+}
+
+func (p *fixedStringParser) Type() reflect.Type {
+	return reflectTypeString
+}
+
+// unescapeByte returns the byte escaped with b like readEscaped
+func unescapeByte(b byte) byte {
+	switch b {
+	case 'b':
+		return '\b'
+	case 'f':
+		return '\f'
+	case 'r':
+		return '\r'
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case '0':
+		return 0
+	}
+	return b
+}
+
+func (p *intervalParser) Parse(s io.RuneScanner) (driver.Value, error) {
+	str, err := readNumber(s)
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return intervalValue(n, p.unit)
+}
+
+func (p *intervalParser) Type() reflect.Type {
+	if p.unit == 0 {
+		return reflectTypeInt64
+	}
+	return reflectTypeDuration
+}
+
+// intervalUnits are the durations of the units of Interval types, zero for
+// the units without a fixed duration
+var intervalUnits = map[string]time.Duration{
+	"IntervalNanosecond":  time.Nanosecond,
+	"IntervalMicrosecond": time.Microsecond,
+	"IntervalMillisecond": time.Millisecond,
+	"IntervalSecond":      time.Second,
+	"IntervalMinute":      time.Minute,
+	"IntervalHour":        time.Hour,
+	"IntervalDay":         24 * time.Hour,
+	"IntervalWeek":        7 * 24 * time.Hour,
+	"IntervalMonth":       0,
+	"IntervalQuarter":     0,
+	"IntervalYear":        0,
+}
+
+// intervalValue returns the interval of n units as time.Duration, or n if
+// the unit has no fixed duration
+func intervalValue(n int64, unit time.Duration) (driver.Value, error) {
+	if unit == 0 {
+		return n, nil
+	}
+	if n > math.MaxInt64/int64(unit) || n < math.MinInt64/int64(unit) {
+		return nil, fmt.Errorf("interval of %d units of %v overflows time.Duration", n, unit)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+func (p *enumParser) Parse(s io.RuneScanner) (driver.Value, error) {
+	str, err := readString(s, 0, p.unquote)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := p.values[str]
+	if !ok {
+		return nil, fmt.Errorf("unknown enum element %q", str)
+	}
+	if p.bitSize == 8 {
+		return int8(v), nil
+	}
+	return v, nil
+}
+
+func (p *enumParser) Type() reflect.Type {
+	if p.bitSize == 8 {
+		return reflectTypeInt8
+	}
+	return reflectTypeInt16
+}
+
+func (p *ipParser) Parse(s io.RuneScanner) (driver.Value, error) {
+	str, err := readString(s, 0, p.unquote)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the string representation of IP address: %v", err)
+	}
+	ip := net.ParseIP(str)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address %q", str)
+	}
+	if p.v4 {
+		if ip = ip.To4(); ip == nil {
+			return nil, fmt.Errorf("invalid IPv4 address %q", str)
+		}
+	}
+	return ip, nil
+}
+
+func (p *ipParser) Type() reflect.Type {
+	return reflectTypeIP
+}
+
 func (p *dateTimeParser) Parse(s io.RuneScanner) (driver.Value, error) {
 	str, err := readString(s, len(p.format), p.unquote)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read the string representation of date or datetime: %v", err)
 	}
 
-	if str == "0000-00-00" || str == "0000-00-00 00:00:00" {
+	if str == zeroDate || str == zeroTime {
+		if p.zeroAsNil && !p.unquote {
+			// nested values can not be nil, e.g. the elements of arrays
+			return nil, nil
+		}
 		return time.Time{}, nil
 	}
 
-	return time.ParseInLocation(p.format, str, p.location)
+	t, err := time.ParseInLocation(p.format, str, p.location)
+	if err == nil && p.scan != nil {
+		t = t.In(p.scan)
+	}
+	return t, err
 }
 
 func (p *dateTimeParser) Type() reflect.Type {
@@ -152,18 +366,65 @@ func (p *arrayParser) Type() reflect.Type {
 }
 
 type tupleParser struct {
-	args []DataParser
+	args  []DataParser
+	names []string
 }
 
 func (p *tupleParser) Type() reflect.Type {
-	fields := make([]reflect.StructField, len(p.args), len(p.args))
+	types := make([]reflect.Type, len(p.args), len(p.args))
 	for i, arg := range p.args {
+		types[i] = arg.Type()
+	}
+	return tupleType(types, p.names)
+}
+
+// tupleType returns the struct type of the tuple values with the elements
+// of the given types and names
+func tupleType(types []reflect.Type, names []string) reflect.Type {
+	fields := make([]reflect.StructField, len(types), len(types))
+	exported := make(map[string]struct{}, len(types))
+	for i, typ := range types {
 		fields[i].Name = "Field" + strconv.Itoa(i)
-		fields[i].Type = arg.Type()
+		fields[i].Type = typ
+		if len(names) > i && len(names[i]) > 0 {
+			// keep the original name of the element in the tag
+			name := exportedFieldName(names[i])
+			if _, ok := exported[name]; !ok && len(name) > 0 {
+				fields[i].Name = name
+				exported[name] = struct{}{}
+			}
+			fields[i].Tag = reflect.StructTag(`ch:` + strconv.Quote(names[i]))
+		}
 	}
 	return reflect.StructOf(fields)
 }
 
+// exportedFieldName converts a name of a tuple element into an exported
+// struct field name in camel case (user_name -> UserName), empty string is
+// returned if it is not possible.
+func exportedFieldName(name string) string {
+	var (
+		runes []rune
+		upper = true
+	)
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) && len(runes) > 0:
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+			runes = append(runes, r)
+		default:
+			upper = true
+		}
+	}
+	if len(runes) == 0 || !unicode.IsUpper(runes[0]) {
+		return ""
+	}
+	return string(runes)
+}
+
 func (p *tupleParser) Parse(s io.RuneScanner) (driver.Value, error) {
 	r := read(s)
 	if r != '(' {
@@ -230,6 +491,58 @@ func (p *arrayParser) Parse(s io.RuneScanner) (driver.Value, error) {
 	return slice.Interface(), nil
 }
 
+type mapParser struct {
+	key   DataParser
+	value DataParser
+}
+
+func (p *mapParser) Type() reflect.Type {
+	return reflect.MapOf(p.key.Type(), p.value.Type())
+}
+
+func (p *mapParser) Parse(s io.RuneScanner) (driver.Value, error) {
+	r := read(s)
+	if r != '{' {
+		return nil, fmt.Errorf("unexpected character '%c', expected '{' at the beginning of map", r)
+	}
+
+	m := reflect.MakeMap(p.Type())
+	for {
+		r := read(s)
+		s.UnreadRune()
+		if r == '}' {
+			break
+		}
+
+		k, err := p.key.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse map key: %v", err)
+		}
+		r = read(s)
+		if r != ':' {
+			return nil, fmt.Errorf("unexpected character '%c', expected ':' after map key", r)
+		}
+		v, err := p.value.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse map value: %v", err)
+		}
+
+		m.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(v))
+
+		r = read(s)
+		if r != ',' {
+			s.UnreadRune()
+		}
+	}
+
+	r = read(s)
+	if r != '}' {
+		return nil, fmt.Errorf("unexpected character '%c', expected '}' at the end of map", r)
+	}
+
+	return m.Interface(), nil
+}
+
 type lowCardinalityParser struct {
 	arg DataParser
 }
@@ -242,12 +555,100 @@ func (p *lowCardinalityParser) Parse(s io.RuneScanner) (driver.Value, error) {
 	return p.arg.Parse(s)
 }
 
-func newDateTimeParser(format string, loc *time.Location, unquote bool) (DataParser, error) {
-	return &dateTimeParser{
-		unquote:  unquote,
-		format:   format,
-		location: loc,
-	}, nil
+// nullableParser parses Nullable values, NULL is \N in TSV and NULL inside
+// arrays, tuples and maps. The nested values are returned as pointers, so
+// they can be elements of slices like []*int32.
+type nullableParser struct {
+	arg    DataParser
+	nested bool
+}
+
+func (p *nullableParser) Type() reflect.Type {
+	return reflect.PtrTo(p.arg.Type())
+}
+
+func (p *nullableParser) Parse(s io.RuneScanner) (driver.Value, error) {
+	if !p.nested {
+		if r := read(s); r != '\\' {
+			s.UnreadRune()
+			return p.arg.Parse(s)
+		}
+		rest, err := readRaw(s)
+		if err != nil {
+			return nil, err
+		}
+		if rest == "N" {
+			return nil, nil
+		}
+		return p.arg.Parse(strings.NewReader("\\" + rest))
+	}
+
+	if r := read(s); r == 'N' {
+		for _, c := range "ULL" {
+			if read(s) != c {
+				return nil, fmt.Errorf("unexpected character, expected NULL")
+			}
+		}
+		return reflect.Zero(p.Type()).Interface(), nil
+	}
+	s.UnreadRune()
+	v, err := p.arg.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	ptr := reflect.New(p.arg.Type())
+	if v != nil {
+		ptr.Elem().Set(reflect.ValueOf(v))
+	}
+	return ptr.Interface(), nil
+}
+
+// readRaw reads the rest of the value as is
+func readRaw(s io.RuneScanner) (string, error) {
+	builder := getBuffer()
+	defer putBuffer(builder)
+	for r := read(s); r != eof; r = read(s) {
+		builder.WriteRune(r)
+	}
+	return builder.String(), nil
+}
+
+// decimalParser parses decimals into their string representation, inside
+// arrays and tuples they are not quoted unlike strings
+type decimalParser struct{}
+
+func (p *decimalParser) Parse(s io.RuneScanner) (driver.Value, error) {
+	return readNumber(s)
+}
+
+func (p *decimalParser) Type() reflect.Type {
+	return reflectTypeString
+}
+
+func newDateTimeParser(format string, loc *time.Location, unquote bool, opt *DataParserOptions) (DataParser, error) {
+	p := &dateTimeParser{
+		unquote:   unquote,
+		format:    format,
+		location:  loc,
+		zeroAsNil: opt != nil && opt.ZeroDateAsNil,
+	}
+	if opt != nil && format != dateFormat {
+		// dates have no time to convert
+		p.scan = opt.ScanLocation
+	}
+	return p, nil
+}
+
+// columnLocation returns the location of DateTime values, tz holds the
+// timezone argument of the column type if it is specified
+func columnLocation(tz []*TypeDesc, opt *DataParserOptions) (*time.Location, error) {
+	if (opt == nil || opt.Location == nil || opt.UseDBLocation) && len(tz) > 0 {
+		return time.LoadLocation(tz[0].Name)
+	}
+	if opt != nil && opt.Location != nil {
+		return opt.Location, nil
+	}
+	return time.UTC, nil
 }
 
 type intParser struct {
@@ -371,6 +772,12 @@ type DataParserOptions struct {
 	Location *time.Location
 	// UseDBLocation if false: always use Location, ignore DateTime argument.
 	UseDBLocation bool
+	// ScanLocation if set: convert DateTime and DateTime64 values to the location after parsing.
+	ScanLocation *time.Location
+	// EnumAsNumber if true: parse Enum8 and Enum16 values into int8 and int16 numbers of the elements.
+	EnumAsNumber bool
+	// ZeroDateAsNil if true: parse zero Date and DateTime values (0000-00-00) into nil instead of zero time.Time.
+	ZeroDateAsNil bool
 }
 
 // NewDataParser creates a new DataParser based on the
@@ -384,25 +791,39 @@ func newDataParser(t *TypeDesc, unquote bool, opt *DataParserOptions) (DataParse
 	case "Nothing":
 		return &nothingParser{}, nil
 	case "Nullable":
-		return nil, fmt.Errorf("Nullable types are not supported")
-	case "Date":
+		if len(t.Args) != 1 {
+			return nil, fmt.Errorf("element type not specified for Nullable")
+		}
+		subParser, err := newDataParser(t.Args[0], unquote, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create parser for Nullable elements: %v", err)
+		}
+		return &nullableParser{arg: subParser, nested: unquote}, nil
+	case "Date", "Date32":
 		loc := time.UTC
 		if opt != nil && opt.Location != nil {
 			loc = opt.Location
 		}
-		return newDateTimeParser(dateFormat, loc, unquote)
+		return newDateTimeParser(dateFormat, loc, unquote, opt)
 	case "DateTime":
-		loc := time.UTC
-		if (opt == nil || opt.Location == nil || opt.UseDBLocation) && len(t.Args) > 0 {
-			var err error
-			loc, err = time.LoadLocation(t.Args[0].Name)
-			if err != nil {
-				return nil, err
-			}
-		} else if opt != nil && opt.Location != nil {
-			loc = opt.Location
+		loc, err := columnLocation(t.Args, opt)
+		if err != nil {
+			return nil, err
+		}
+		return newDateTimeParser(timeFormat, loc, unquote, opt)
+	case "DateTime64":
+		if len(t.Args) < 1 {
+			return nil, fmt.Errorf("precision not specified for DateTime64")
+		}
+		precision, err := strconv.Atoi(t.Args[0].Name)
+		if err != nil || precision < 0 || precision > maxDateTime64Precision {
+			return nil, fmt.Errorf("malformed precision specified for DateTime64: %s", t.Args[0].Name)
+		}
+		loc, err := columnLocation(t.Args[1:], opt)
+		if err != nil {
+			return nil, err
 		}
-		return newDateTimeParser(timeFormat, loc, unquote)
+		return newDateTimeParser(dateTime64Format(precision), loc, unquote, opt)
 	case "UInt8":
 		return &intParser{false, 8}, nil
 	case "UInt16":
@@ -411,6 +832,14 @@ func newDataParser(t *TypeDesc, unquote bool, opt *DataParserOptions) (DataParse
 		return &intParser{false, 32}, nil
 	case "UInt64":
 		return &intParser{false, 64}, nil
+	case "UInt128":
+		return &bigIntParser{false, 128}, nil
+	case "UInt256":
+		return &bigIntParser{false, 256}, nil
+	case "Int128":
+		return &bigIntParser{true, 128}, nil
+	case "Int256":
+		return &bigIntParser{true, 256}, nil
 	case "Int8":
 		return &intParser{true, 8}, nil
 	case "Int16":
@@ -423,8 +852,24 @@ func newDataParser(t *TypeDesc, unquote bool, opt *DataParserOptions) (DataParse
 		return &floatParser{32}, nil
 	case "Float64":
 		return &floatParser{64}, nil
-	case "Decimal", "String", "Enum8", "Enum16", "UUID":
+	case "Enum8", "Enum16":
+		if opt != nil && opt.EnumAsNumber {
+			if t.EnumValues == nil {
+				return nil, fmt.Errorf("element values not specified for %s", t.Name)
+			}
+			bitSize := 8
+			if t.Name == "Enum16" {
+				bitSize = 16
+			}
+			return &enumParser{unquote: unquote, values: t.EnumValues, bitSize: bitSize}, nil
+		}
+		return &stringParser{unquote: unquote}, nil
+	case "Decimal", "Decimal32", "Decimal64", "Decimal128", "Decimal256":
+		return &decimalParser{}, nil
+	case "String", "UUID":
 		return &stringParser{unquote: unquote}, nil
+	case "IPv4", "IPv6":
+		return &ipParser{unquote: unquote, v4: t.Name == "IPv4"}, nil
 	case "FixedString":
 		if len(t.Args) != 1 {
 			return nil, fmt.Errorf("length not specified for FixedString")
@@ -433,7 +878,10 @@ func newDataParser(t *TypeDesc, unquote bool, opt *DataParserOptions) (DataParse
 		if err != nil {
 			return nil, fmt.Errorf("malformed length specified for FixedString: %v", err)
 		}
-		return &stringParser{unquote: unquote, length: length}, nil
+		return &fixedStringParser{unquote: unquote, length: length}, nil
+	case "IntervalNanosecond", "IntervalMicrosecond", "IntervalMillisecond", "IntervalSecond", "IntervalMinute",
+		"IntervalHour", "IntervalDay", "IntervalWeek", "IntervalMonth", "IntervalQuarter", "IntervalYear":
+		return &intervalParser{intervalUnits[t.Name]}, nil
 	case "Array":
 		if len(t.Args) != 1 {
 			return nil, fmt.Errorf("element type not specified for Array")
@@ -455,7 +903,52 @@ func newDataParser(t *TypeDesc, unquote bool, opt *DataParserOptions) (DataParse
 			}
 			subParsers[i] = subParser
 		}
-		return &tupleParser{subParsers}, nil
+		return &tupleParser{args: subParsers, names: t.ArgNames}, nil
+	case "Nested":
+		if len(t.Args) < 1 || len(t.ArgNames) != len(t.Args) {
+			return nil, fmt.Errorf("element names and types not specified for Nested")
+		}
+		subParsers := make([]DataParser, len(t.Args), len(t.Args))
+		for i, arg := range t.Args {
+			subParser, err := newDataParser(arg, true, opt)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create parser for nested element: %v", err)
+			}
+			subParsers[i] = subParser
+		}
+		return &arrayParser{&tupleParser{args: subParsers, names: t.ArgNames}}, nil
+	case "Map":
+		if len(t.Args) != 2 {
+			return nil, fmt.Errorf("key and value types not specified for Map")
+		}
+		keyParser, err := newDataParser(t.Args[0], true, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create parser for map keys: %v", err)
+		}
+		if !keyParser.Type().Comparable() {
+			return nil, fmt.Errorf("map keys of type %s are not supported", t.Args[0].Name)
+		}
+		valueParser, err := newDataParser(t.Args[1], true, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create parser for map values: %v", err)
+		}
+		return &mapParser{key: keyParser, value: valueParser}, nil
+	case "Point", "Ring", "Polygon", "MultiPolygon":
+		return newGeoParser(t.Name), nil
+	case "SimpleAggregateFunction":
+		if len(t.Args) != 2 {
+			return nil, fmt.Errorf("function and type not specified for SimpleAggregateFunction")
+		}
+		// the values are of the type of the column
+		return newDataParser(t.Args[1], unquote, opt)
+	case "AggregateFunction":
+		return &aggregateStateParser{unquote: unquote}, nil
+	case "JSON", "Object":
+		// JSON documents are returned as is
+		return &stringParser{unquote: unquote}, nil
+	case "Variant", "Dynamic":
+		// the values are returned as the text, they are NULL if not set
+		return &nullableParser{arg: &stringParser{unquote: unquote}, nested: unquote}, nil
 	case "LowCardinality":
 		if len(t.Args) != 1 {
 			return nil, fmt.Errorf("element type not specified for LowCardinality")
@@ -2,6 +2,8 @@ package clickhouse
 
 import (
 	"math"
+	"math/big"
+	"net"
 	"strings"
 	"testing"
 	"time"
@@ -32,10 +34,22 @@ func TestParseData(t *testing.T) {
 
 	testCases := []*testCase{
 		{
-			name:          "nullable not supported",
-			inputtype:     "Nullable(String)",
-			inputdata:     "NULL",
-			failNewParser: true,
+			name:      "nullable string",
+			inputtype: "Nullable(String)",
+			inputdata: "NULL",
+			output:    "NULL",
+		},
+		{
+			name:      "nullable null",
+			inputtype: "Nullable(String)",
+			inputdata: `\N`,
+			output:    nil,
+		},
+		{
+			name:      "nullable escaped string",
+			inputtype: "Nullable(String)",
+			inputdata: `\ta`,
+			output:    "\ta",
 		},
 		{
 			name:      "string",
@@ -49,6 +63,54 @@ func TestParseData(t *testing.T) {
 			inputdata: `hello\0\0\0\0\0`,
 			output:    "hello\x00\x00\x00\x00\x00",
 		},
+		{
+			name:      "fixed string of multibyte characters",
+			inputtype: "FixedString(4)",
+			inputdata: "пр",
+			output:    "пр",
+		},
+		{
+			name:      "fixed string of bytes",
+			inputtype: "FixedString(4)",
+			inputdata: "\xff\xfe\\0\\t",
+			output:    "\xff\xfe\x00\t",
+		},
+		{
+			name:      "array of fixed strings",
+			inputtype: "Array(FixedString(2))",
+			inputdata: "['a\\0','\\'\xe2']",
+			output:    []string{"a\x00", "'\xe2"},
+		},
+		{
+			name:          "fixed string too short",
+			inputtype:     "FixedString(4)",
+			inputdata:     "abc",
+			failParseData: true,
+		},
+		{
+			name:      "interval second",
+			inputtype: "IntervalSecond",
+			inputdata: "-30",
+			output:    -30 * time.Second,
+		},
+		{
+			name:      "array of interval week",
+			inputtype: "Array(IntervalWeek)",
+			inputdata: "[1,2]",
+			output:    []time.Duration{7 * 24 * time.Hour, 14 * 24 * time.Hour},
+		},
+		{
+			name:      "interval month",
+			inputtype: "IntervalMonth",
+			inputdata: "3",
+			output:    int64(3),
+		},
+		{
+			name:          "interval overflow",
+			inputtype:     "IntervalDay",
+			inputdata:     "1000000",
+			failParseData: true,
+		},
 		{
 			name:      "string with escaping",
 			inputtype: "String",
@@ -91,6 +153,19 @@ func TestParseData(t *testing.T) {
 			inputdata: "0000-00-00",
 			output:    time.Time{},
 		},
+		{
+			name:      "zero date as nil",
+			inputtype: "Date",
+			inputdata: "0000-00-00",
+			inputopt:  &DataParserOptions{ZeroDateAsNil: true},
+			output:    nil,
+		},
+		{
+			name:      "date32",
+			inputtype: "Date32",
+			inputdata: "1900-01-01",
+			output:    time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
 		{
 			name:      "with special timezone",
 			inputtype: "Date",
@@ -106,12 +181,82 @@ func TestParseData(t *testing.T) {
 			inputdata: "hello",
 			output:    "hello",
 		},
+		{
+			name:      "uint256",
+			inputtype: "UInt256",
+			inputdata: "340282366920938463463374607431768211456",
+			output:    new(big.Int).Lsh(big.NewInt(1), 128),
+		},
+		{
+			name:          "uint128 out of range",
+			inputtype:     "UInt128",
+			inputdata:     "340282366920938463463374607431768211456",
+			failParseData: true,
+		},
+		{
+			name:          "int128 malformed",
+			inputtype:     "Int128",
+			inputdata:     "1e10",
+			failParseData: true,
+		},
+		{
+			name:      "enum as number",
+			inputtype: "Enum8('hello' = 1, 'world' = -2)",
+			inputdata: "world",
+			inputopt:  &DataParserOptions{EnumAsNumber: true},
+			output:    int8(-2),
+		},
+		{
+			name:      "array of enum16 as number",
+			inputtype: "Array(Enum16('hello' = 1000, 'world' = 2))",
+			inputdata: "['hello','world']",
+			inputopt:  &DataParserOptions{EnumAsNumber: true},
+			output:    []int16{1000, 2},
+		},
+		{
+			name:      "low cardinality enum as number",
+			inputtype: "LowCardinality(Enum8('a' = 1))",
+			inputdata: "a",
+			inputopt:  &DataParserOptions{EnumAsNumber: true},
+			output:    int8(1),
+		},
+		{
+			name:          "unknown enum element",
+			inputtype:     "Enum8('hello' = 1)",
+			inputdata:     "world",
+			inputopt:      &DataParserOptions{EnumAsNumber: true},
+			failParseData: true,
+		},
 		{
 			name:      "uuid",
 			inputtype: "UUID",
 			inputdata: "c79a9747-7cef-4b11-8177-380f7ed462a4",
 			output:    "c79a9747-7cef-4b11-8177-380f7ed462a4",
 		},
+		{
+			name:      "ipv4",
+			inputtype: "IPv4",
+			inputdata: "192.168.0.1",
+			output:    net.IPv4(192, 168, 0, 1).To4(),
+		},
+		{
+			name:      "ipv6",
+			inputtype: "IPv6",
+			inputdata: "2001:db8::1",
+			output:    net.ParseIP("2001:db8::1"),
+		},
+		{
+			name:      "array of ipv4",
+			inputtype: "Array(IPv4)",
+			inputdata: "['10.0.0.1','10.0.0.2']",
+			output:    []net.IP{net.IPv4(10, 0, 0, 1).To4(), net.IPv4(10, 0, 0, 2).To4()},
+		},
+		{
+			name:          "invalid ipv4",
+			inputtype:     "IPv4",
+			inputdata:     "2001:db8::1",
+			failParseData: true,
+		},
 		{
 			name:      "datetime, without options and argument",
 			inputtype: "DateTime",
@@ -124,6 +269,44 @@ func TestParseData(t *testing.T) {
 			inputdata: "2018-01-02 12:34:56",
 			output:    time.Date(2018, 1, 2, 12, 34, 56, 0, losAngeles),
 		},
+		{
+			name:      "datetime64",
+			inputtype: "DateTime64(3)",
+			inputdata: "2018-01-02 12:34:56.789",
+			output:    time.Date(2018, 1, 2, 12, 34, 56, 789000000, time.UTC),
+		},
+		{
+			name:      "datetime64 with nanoseconds and argument",
+			inputtype: "DateTime64(9, 'America/Los_Angeles')",
+			inputdata: "2018-01-02 12:34:56.000000001",
+			output:    time.Date(2018, 1, 2, 12, 34, 56, 1, losAngeles),
+		},
+		{
+			name:      "datetime64 with argument and location, use argument",
+			inputtype: "DateTime64(6, 'America/Los_Angeles')",
+			inputdata: "2018-01-02 12:34:56.123456",
+			inputopt: &DataParserOptions{
+				Location:      moscow,
+				UseDBLocation: true,
+			},
+			output: time.Date(2018, 1, 2, 12, 34, 56, 123456000, losAngeles),
+		},
+		{
+			name:      "array of datetime64 without fractional part",
+			inputtype: "Array(DateTime64(0))",
+			inputdata: "['2018-01-02 12:34:56']",
+			output:    []time.Time{time.Date(2018, 1, 2, 12, 34, 56, 0, time.UTC)},
+		},
+		{
+			name:          "datetime64 without precision",
+			inputtype:     "DateTime64",
+			failNewParser: true,
+		},
+		{
+			name:          "datetime64 with malformed precision",
+			inputtype:     "DateTime64(10)",
+			failNewParser: true,
+		},
 		{
 			name:      "datetime with argument, but location nil",
 			inputtype: "DateTime('America/Los_Angeles')",
@@ -232,6 +415,58 @@ func TestParseData(t *testing.T) {
 				{},
 			},
 		},
+		{
+			name:      "array of zero dates",
+			inputtype: "Array(Date)",
+			inputdata: "['0000-00-00']",
+			inputopt:  &DataParserOptions{ZeroDateAsNil: true},
+			output:    []time.Time{{}},
+		},
+		{
+			name:      "array of datetimes",
+			inputtype: "Array(DateTime)",
+			inputdata: "['2018-01-02 03:04:05','2018-01-02 00:00:00']",
+			output: []time.Time{
+				time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+				time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:      "array of decimals",
+			inputtype: "Array(Decimal(9, 2))",
+			inputdata: "[1.50,-2.00]",
+			output:    []string{"1.50", "-2.00"},
+		},
+		{
+			name:      "array of nullable ints",
+			inputtype: "Array(Nullable(Int32))",
+			inputdata: "[1,NULL,-3]",
+			output:    []*int32{int32Ptr(1), nil, int32Ptr(-3)},
+		},
+		{
+			name:      "array of nullable strings",
+			inputtype: "Array(LowCardinality(Nullable(String)))",
+			inputdata: `['NULL',NULL,'a\'b']`,
+			output:    []*string{stringPtr("NULL"), nil, stringPtr("a'b")},
+		},
+		{
+			name:          "array of nullable with malformed null",
+			inputtype:     "Array(Nullable(Int32))",
+			inputdata:     "[NUL]",
+			failParseData: true,
+		},
+		{
+			name:      "array of arrays",
+			inputtype: "Array(Array(Int32))",
+			inputdata: "[[1,2],[],[3]]",
+			output:    [][]int32{{1, 2}, {}, {3}},
+		},
+		{
+			name:      "array of arrays of strings",
+			inputtype: "Array(Array(String))",
+			inputdata: `[['a\'b','c,d'],[]]`,
+			output:    [][]string{{"a'b", "c,d"}, {}},
+		},
 		{
 			name:      "empty array of ints",
 			inputtype: "Array(Int8)",
@@ -262,6 +497,56 @@ func TestParseData(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "nested",
+			inputtype: "Nested(id UInt64, user_name String, _x UInt8)",
+			inputdata: "[(1,'hello',3),(2,'world',4)]",
+			output: []struct {
+				Id       uint64 `ch:"id"`
+				UserName string `ch:"user_name"`
+				X        uint8  `ch:"_x"`
+			}{
+				{1, "hello", 3},
+				{2, "world", 4},
+			},
+		},
+		{
+			name:      "named tuple",
+			inputtype: "Tuple(id UInt64, user_name String)",
+			inputdata: "(1,'hello')",
+			output: struct {
+				Id       uint64 `ch:"id"`
+				UserName string `ch:"user_name"`
+			}{1, "hello"},
+		},
+		{
+			name:      "map",
+			inputtype: "Map(String, Array(UInt8))",
+			inputdata: "{'a':[1,2],'b\\'c':[]}",
+			output:    map[string][]uint8{"a": {1, 2}, "b'c": {}},
+		},
+		{
+			name:      "empty map",
+			inputtype: "Map(UInt16, String)",
+			inputdata: "{}",
+			output:    map[uint16]string{},
+		},
+		{
+			name:          "map with array keys",
+			inputtype:     "Map(Array(UInt8), String)",
+			failNewParser: true,
+		},
+		{
+			name:          "map without colon",
+			inputtype:     "Map(String, UInt8)",
+			inputdata:     "{'a',1}",
+			failParseData: true,
+		},
+		{
+			name:          "nested without names",
+			inputtype:     "Nested(UInt64, String)",
+			failNewParser: true,
+		},
 		{
 			name:          "malformed array element",
 			inputtype:     "Array(UInt8)",
@@ -363,3 +648,15 @@ func TestParseData(t *testing.T) {
 		})
 	}
 }
+
+func int32Ptr(v int32) *int32 {
+	return &v
+}
+
+func stringPtr(v string) *string {
+	return &v
+}
+
+func uint8Ptr(v uint8) *uint8 {
+	return &v
+}
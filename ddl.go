@@ -0,0 +1,66 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// IdempotentDDL rewrites CREATE TABLE to CREATE TABLE IF NOT EXISTS and
+// DROP TABLE to DROP TABLE IF EXISTS, so the statement can be safely
+// executed more than once. Statements which already have the clause and
+// other statements are returned unchanged.
+func IdempotentDDL(query string) (string, error) {
+	words, err := splitSQL(query)
+	if err != nil {
+		return "", err
+	}
+	if len(words) == 0 {
+		return query, nil
+	}
+	var clause []string
+	switch {
+	case words[0].is("CREATE"):
+		clause = []string{"IF", "NOT", "EXISTS"}
+	case words[0].is("DROP"):
+		clause = []string{"IF", "EXISTS"}
+	default:
+		return query, nil
+	}
+	i := 1
+	if i < len(words) && words[i].is("TEMPORARY") {
+		i++
+	}
+	if i >= len(words) || !words[i].is("TABLE") {
+		return query, nil
+	}
+	pos := words[i].end
+	if i+1 >= len(words) {
+		return "", fmt.Errorf("clickhouse: table name expected in %q", query)
+	}
+	for j, keyword := range clause {
+		if i+1+j >= len(words) || !words[i+1+j].is(keyword) {
+			return query[:pos] + " " + strings.Join(clause, " ") + query[pos:], nil
+		}
+	}
+	return query, nil
+}
+
+// AtomicSwap atomically exchanges the names of the source and the target
+// tables of the database with EXCHANGE TABLES, e.g. to replace a table with
+// a freshly loaded copy without downtime. The database must use the Atomic
+// engine. If the database is empty, the current database is used.
+func AtomicSwap(ctx context.Context, db *sql.DB, database, sourceTable, targetTable string) error {
+	_, err := db.ExecContext(ctx, "EXCHANGE TABLES "+qualifiedName(database, sourceTable)+
+		" AND "+qualifiedName(database, targetTable))
+	return err
+}
+
+// qualifiedName returns the quoted name of the table of the database
+func qualifiedName(database, table string) string {
+	if len(database) == 0 {
+		return QuoteIdentifier(table)
+	}
+	return QuoteIdentifier(database) + "." + QuoteIdentifier(table)
+}
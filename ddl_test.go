@@ -0,0 +1,64 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotentDDL(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{"CREATE TABLE t (a Int8) ENGINE = Memory", "CREATE TABLE IF NOT EXISTS t (a Int8) ENGINE = Memory"},
+		{"create temporary table t (a Int8)", "create temporary table IF NOT EXISTS t (a Int8)"},
+		{"CREATE TABLE IF NOT EXISTS t (a Int8)", "CREATE TABLE IF NOT EXISTS t (a Int8)"},
+		{"CREATE TABLE if_not_exists (a Int8)", "CREATE TABLE IF NOT EXISTS if_not_exists (a Int8)"},
+		{"CREATE TABLE `if` (a Int8)", "CREATE TABLE IF NOT EXISTS `if` (a Int8)"},
+		{"CREATE TABLE if (a Int8)", "CREATE TABLE IF NOT EXISTS if (a Int8)"},
+		{"CREATE TABLE \"IF NOT EXISTS\" (a Int8)", "CREATE TABLE IF NOT EXISTS \"IF NOT EXISTS\" (a Int8)"},
+		{"-- comment\nCREATE /* TABLE */ TABLE db.t AS db.s", "-- comment\nCREATE /* TABLE */ TABLE IF NOT EXISTS db.t AS db.s"},
+		{"DROP TABLE t", "DROP TABLE IF EXISTS t"},
+		{"DROP TABLE if", "DROP TABLE IF EXISTS if"},
+		{"drop table if exists t", "drop table if exists t"},
+		{"DROP TABLE exists", "DROP TABLE IF EXISTS exists"},
+		{"CREATE DATABASE db", "CREATE DATABASE db"},
+		{"SELECT 'CREATE TABLE t'", "SELECT 'CREATE TABLE t'"},
+	}
+	for _, tc := range testCases {
+		actual, err := IdempotentDDL(tc.query)
+		if assert.NoError(t, err, tc.query) {
+			assert.Equal(t, tc.expected, actual)
+		}
+	}
+
+	for _, query := range []string{"CREATE TABLE", "DROP TABLE `t", "CREATE /* TABLE"} {
+		_, err := IdempotentDDL(query)
+		assert.Error(t, err, query)
+	}
+}
+
+func TestAtomicSwap(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	require.NoError(t, AtomicSwap(ctx, db, "db", "events_new", "events"))
+	require.NoError(t, AtomicSwap(ctx, db, "", "a`b", "c"))
+	assert.Equal(t, []string{
+		"EXCHANGE TABLES `db`.`events_new` AND `db`.`events`",
+		"EXCHANGE TABLES `a\\`b` AND `c`",
+	}, queries)
+}
@@ -0,0 +1,181 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact decimal number, unscaled * 10^-scale. It can be scanned
+// from Decimal columns and passed as an argument, the scale is preserved in
+// both directions, e.g. 1.50 stays 1.50.
+type Decimal struct {
+	unscaled *big.Int
+	scale    int32
+}
+
+// NewDecimal returns the decimal unscaled * 10^-scale
+func NewDecimal(unscaled *big.Int, scale int32) Decimal {
+	return Decimal{unscaled: new(big.Int).Set(unscaled), scale: scale}
+}
+
+// ParseDecimal parses a decimal number like -123.4500, the number of digits
+// after the point is the scale
+func ParseDecimal(s string) (Decimal, error) {
+	digits := s
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		digits = digits[1:]
+	}
+	var scale int32
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		scale = int32(len(digits) - i - 1)
+		digits = digits[:i] + digits[i+1:]
+	}
+	if len(digits) == 0 || strings.Trim(digits, "0123456789") != "" {
+		return Decimal{}, fmt.Errorf("clickhouse: invalid decimal %q", s)
+	}
+	unscaled, _ := new(big.Int).SetString(digits, 10)
+	if s[0] == '-' {
+		unscaled.Neg(unscaled)
+	}
+	return Decimal{unscaled: unscaled, scale: scale}, nil
+}
+
+// Scale returns the number of digits after the decimal point
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Unscaled returns the decimal without the point
+func (d Decimal) Unscaled() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(d.unscaled)
+}
+
+// Rat returns the decimal as a rational number
+func (d Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.Unscaled(), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.scale)), nil))
+}
+
+// Float returns the decimal as a floating-point number, which may be inexact
+func (d Decimal) Float() *big.Float {
+	f, _ := new(big.Float).SetPrec(256).SetString(d.String())
+	return f
+}
+
+// String returns the decimal with exactly Scale digits after the point
+func (d Decimal) String() string {
+	u := d.Unscaled()
+	neg := u.Sign() < 0
+	digits := u.Abs(u).String()
+	if d.scale > 0 {
+		if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		digits = digits[:len(digits)-int(d.scale)] + "." + digits[len(digits)-int(d.scale):]
+	}
+	if neg {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Scan implements the sql.Scanner
+func (d *Decimal) Scan(src interface{}) (err error) {
+	switch v := src.(type) {
+	case string:
+		*d, err = ParseDecimal(v)
+	case []byte:
+		*d, err = ParseDecimal(string(v))
+	case int64:
+		*d = Decimal{unscaled: big.NewInt(v)}
+	case float64:
+		*d, err = ParseDecimal(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		err = fmt.Errorf("clickhouse: can not scan %T into Decimal", src)
+	}
+	return err
+}
+
+// Value implements the driver.Valuer
+func (d Decimal) Value() (driver.Value, error) {
+	return []byte(d.String()), nil
+}
+
+// BigRat returns a scanner of Decimal columns into r
+func BigRat(r *big.Rat) sql.Scanner {
+	return &ratScanner{r}
+}
+
+type ratScanner struct {
+	r *big.Rat
+}
+
+// Scan implements the sql.Scanner
+func (s *ratScanner) Scan(src interface{}) error {
+	var d Decimal
+	if err := d.Scan(src); err != nil {
+		return err
+	}
+	s.r.Set(d.Rat())
+	return nil
+}
+
+// BigFloat returns a scanner of Decimal columns into f, the precision of f
+// is kept if it is set
+func BigFloat(f *big.Float) sql.Scanner {
+	return &floatScanner{f}
+}
+
+type floatScanner struct {
+	f *big.Float
+}
+
+// Scan implements the sql.Scanner
+func (s *floatScanner) Scan(src interface{}) error {
+	var d Decimal
+	if err := d.Scan(src); err != nil {
+		return err
+	}
+	if s.f.Prec() == 0 {
+		s.f.SetPrec(256)
+	}
+	if _, ok := s.f.SetString(d.String()); !ok {
+		return fmt.Errorf("clickhouse: can not scan %v into big.Float", src)
+	}
+	return nil
+}
+
+// formatBigRat formats r as an exact decimal literal
+func formatBigRat(r *big.Rat) (string, error) {
+	// the fraction is a finite decimal if the denominator is 2^a * 5^b
+	denom := new(big.Int).Set(r.Denom())
+	var twos, fives int
+	for _, f := range []struct {
+		factor int64
+		n      *int
+	}{{2, &twos}, {5, &fives}} {
+		q, m, factor := new(big.Int), new(big.Int), big.NewInt(f.factor)
+		for {
+			q.QuoRem(denom, factor, m)
+			if m.Sign() != 0 {
+				break
+			}
+			denom.Set(q)
+			*f.n++
+		}
+	}
+	if denom.Cmp(big.NewInt(1)) != 0 {
+		return "", fmt.Errorf("clickhouse: %s is not a finite decimal", r.String())
+	}
+	prec := twos
+	if fives > prec {
+		prec = fives
+	}
+	return r.FloatString(prec), nil
+}
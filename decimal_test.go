@@ -0,0 +1,70 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"math/big"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecimalType(t *testing.T) {
+	for _, s := range []string{"0", "1.50", "-0.0012", "123456789012345678901234567890.123456789", "+7"} {
+		d, err := ParseDecimal(s)
+		if assert.NoError(t, err, s) && s[0] != '+' {
+			assert.Equal(t, s, d.String())
+		}
+	}
+	for _, s := range []string{"", "-", "1.2.3", "1e5", "abc"} {
+		_, err := ParseDecimal(s)
+		assert.Error(t, err, s)
+	}
+
+	d, err := ParseDecimal("-0.0012")
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), d.Scale())
+	assert.Equal(t, big.NewInt(-12), d.Unscaled())
+	assert.Equal(t, big.NewRat(-3, 2500), d.Rat())
+	assert.Equal(t, NewDecimal(big.NewInt(-12), 4), d)
+	assert.Equal(t, "-0.0012", d.Float().Text('f', 4))
+	assert.Equal(t, "0.00", NewDecimal(big.NewInt(0), 2).String())
+	assert.Equal(t, "0", Decimal{}.String())
+
+	v, err := converter{}.ConvertValue(d)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("-0.0012"), v)
+	v, err = converter{}.ConvertValue(big.NewRat(3, 8))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("0.375"), v)
+	_, err = converter{}.ConvertValue(big.NewRat(1, 3))
+	assert.Error(t, err)
+	v, err = converter{}.ConvertValue(big.NewFloat(2.5))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("2.5"), v)
+	encoded, err := textEncode.Encode([]*big.Rat{big.NewRat(1, 2), nil})
+	require.NoError(t, err)
+	assert.Equal(t, "[0.5,NULL]", string(encoded))
+}
+
+func TestScanDecimal(t *testing.T) {
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		w.Write([]byte("a\tb\tc\nDecimal(10, 2)\tDecimal(38, 20)\tDecimal(18, 4)\n1.50\t0.12345678901234567890\t-2.0000\n"))
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var (
+		d Decimal
+		r big.Rat
+		f big.Float
+	)
+	require.NoError(t, db.QueryRow("SELECT a, b, c").Scan(&d, BigRat(&r), BigFloat(&f)))
+	assert.Equal(t, "1.50", d.String())
+	assert.Equal(t, "0.12345678901234567890", r.FloatString(20))
+	assert.Equal(t, "-2", f.Text('f', -1))
+}
@@ -0,0 +1,29 @@
+package clickhouse
+
+import (
+	"context"
+	"net"
+)
+
+// DialFunc dials a connection to the address of a host of the DSN, e.g.
+// through a sidecar proxy or to an in-memory listener in tests
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dialer returns the dial function of the transport: Config.DialContext if it
+// is set, a dialer of the unix socket of the DSN or a TCP dialer
+func (cfg *Config) dialer() DialFunc {
+	if cfg.DialContext != nil {
+		return cfg.DialContext
+	}
+	dialer := &net.Dialer{
+		Timeout:   cfg.Timeout,
+		KeepAlive: orDuration(cfg.KeepAlive, cfg.IdleTimeout),
+		DualStack: true,
+	}
+	if socket := cfg.Socket; len(socket) > 0 {
+		return func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	}
+	return dialer.DialContext
+}
@@ -0,0 +1,70 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clickhouse")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "chproxy.sock")
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	var database string
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		database = r.URL.Query().Get("database")
+		w.Write([]byte("1\nUInt8\n1\n"))
+	}))
+	ts.Listener = l
+	ts.Start()
+	defer ts.Close()
+
+	dsn := "unix://" + socket + "?database=test&max_retries=1"
+	cfg, err := ParseDSN(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, socket, cfg.Socket)
+	assert.Equal(t, "test", cfg.Database)
+	assert.Equal(t, "http", cfg.Scheme)
+	assert.Equal(t, "unix://"+socket+"?database=test&idle_timeout=1h0m0s&max_retries=1", cfg.FormatDSN())
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	var v uint8
+	require.NoError(t, db.QueryRow("SELECT 1").Scan(&v))
+	assert.EqualValues(t, 1, v)
+	assert.Equal(t, "test", database)
+}
+
+func TestDialContext(t *testing.T) {
+	ts, _ := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		w.Write([]byte("1\nUInt8\n1\n"))
+	})
+	defer ts.Close()
+
+	var addrs []string
+	cfg := NewConfig()
+	cfg.Host = "clickhouse.invalid:8123"
+	cfg.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		addrs = append(addrs, addr)
+		return (&net.Dialer{}).DialContext(ctx, network, ts.Listener.Addr().String())
+	}
+	db := sql.OpenDB(NewConnector(cfg))
+	defer db.Close()
+	var v uint8
+	require.NoError(t, db.QueryRow("SELECT 1").Scan(&v))
+	assert.EqualValues(t, 1, v)
+	assert.Equal(t, []string{"clickhouse.invalid:8123"}, addrs)
+}
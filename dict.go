@@ -0,0 +1,43 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// DictGet returns the value of the attribute attr of the dictionary dict for
+// the key. The dictionary may be qualified with a database as "db.dict". The
+// type of the attribute is looked up in system.dictionaries, so the value has
+// the Go type the driver uses for the attribute type (e.g. uint64 for UInt64).
+// ErrDictionaryNotFound or ErrAttributeNotFound is returned if the dictionary
+// or the attribute does not exist.
+func DictGet(ctx context.Context, db *sql.DB, dict, attr string, key interface{}) (interface{}, error) {
+	query := "SELECT indexOf(attribute.names, ?) AS i, attribute.types[i] FROM system.dictionaries WHERE name = ?"
+	args := []interface{}{attr, dict}
+	if i := strings.IndexByte(dict, '.'); i >= 0 {
+		query += " AND database = ?"
+		args = []interface{}{attr, dict[i+1:], dict[:i]}
+	}
+	var (
+		index    uint64
+		attrType string
+	)
+	err := db.QueryRowContext(ctx, query, args...).Scan(&index, &attrType)
+	if err == sql.ErrNoRows {
+		return nil, ErrDictionaryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if index == 0 {
+		return nil, ErrAttributeNotFound
+	}
+
+	var value interface{}
+	err = db.QueryRowContext(ctx, "SELECT CAST(dictGet(?, ?, ?) AS "+attrType+")", dict, attr, key).Scan(&value)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,49 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDictGet(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		switch {
+		case strings.Contains(query, "name = 'missing'"):
+			io.WriteString(w, "i\tt\nUInt64\tString\n")
+		case strings.Contains(query, "system.dictionaries") && strings.Contains(query, "'nope'"):
+			io.WriteString(w, "i\tt\nUInt64\tString\n0\t\n")
+		case strings.Contains(query, "system.dictionaries"):
+			io.WriteString(w, "i\tt\nUInt64\tString\n2\tUInt64\n")
+		default:
+			io.WriteString(w, "v\nUInt64\n42\n")
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	v, err := DictGet(ctx, db, "db.users", "age", uint64(7))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), v)
+	assert.Equal(t, []string{
+		"SELECT indexOf(attribute.names, 'age') AS i, attribute.types[i] FROM system.dictionaries WHERE name = 'users' AND database = 'db'",
+		"SELECT CAST(dictGet('db.users', 'age', 7) AS UInt64)",
+	}, queries)
+
+	_, err = DictGet(ctx, db, "missing", "age", 1)
+	assert.Equal(t, ErrDictionaryNotFound, err)
+	_, err = DictGet(ctx, db, "users", "nope", 1)
+	assert.Equal(t, ErrAttributeNotFound, err)
+}
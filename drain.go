@@ -0,0 +1,51 @@
+package clickhouse
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// defaultMaxBodyDrain is the number of unread bytes of a response which are
+// drained on close by default, it is the limit of the transport of the
+// recent Go versions, which drains the rest of small bodies for up to 50ms
+const defaultMaxBodyDrain = 256 << 10
+
+// drainTimeout limits the time of draining a body, the rest of a body which
+// is still being streamed, e.g. of a slow query, is not waited for
+var drainTimeout = 50 * time.Millisecond
+
+// drainingBody reads the rest of the response body on close, up to limit
+// bytes, so the keep-alive connection returns to the idle pool instead of
+// being closed. A larger rest of a body, e.g. of rows closed early, the rest
+// which is not received within drainTimeout or the body of a cancelled
+// request is not read and its connection is closed, so the server cancels
+// the query.
+type drainingBody struct {
+	io.ReadCloser
+	ctx   context.Context
+	limit int64
+}
+
+func (b *drainingBody) Close() error {
+	if b.limit > 0 && b.ctx.Err() == nil {
+		// closing the body interrupts the drain
+		timer := time.AfterFunc(drainTimeout, func() { b.ReadCloser.Close() })
+		io.CopyN(ioutil.Discard, b.ReadCloser, b.limit)
+		timer.Stop()
+	}
+	return b.ReadCloser.Close()
+}
+
+// maxBodyDrain returns the drain limit of the config, negative values
+// disable draining
+func maxBodyDrain(n int64) int64 {
+	switch {
+	case n < 0:
+		return 0
+	case n == 0:
+		return defaultMaxBodyDrain
+	}
+	return n
+}
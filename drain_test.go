@@ -0,0 +1,185 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newConnCountingServer starts a server which responds with rows rows of a
+// single column and counts the accepted connections
+func newConnCountingServer(t *testing.T, rows int) (*httptest.Server, *int32) {
+	var conns int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "n\nUInt32\n")
+		for i := 0; i < rows; i++ {
+			fmt.Fprintf(w, "%d\n", i)
+			if i == 0 {
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	ts.Start()
+	return ts, &conns
+}
+
+func TestDrainOnEarlyClose(t *testing.T) {
+	// the limit of the bytes is tested, not of the time
+	defer func(timeout time.Duration) { drainTimeout = timeout }(drainTimeout)
+	drainTimeout = 10 * time.Second
+	for _, tc := range []struct {
+		name  string
+		rows  int
+		param string
+		conns int32
+	}{
+		{"small rest is drained", 10000, "", 1},
+		{"large rest is closed", 100000, "", 3},
+		{"limit is raised", 100000, "?max_body_drain_bytes=10000000", 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ts, conns := newConnCountingServer(t, tc.rows)
+			defer ts.Close()
+			db, err := sql.Open("clickhouse", ts.URL+"/default"+tc.param)
+			require.NoError(t, err)
+			defer db.Close()
+			db.SetMaxOpenConns(1)
+
+			for i := 0; i < 3; i++ {
+				rows, err := db.Query("SELECT number FROM numbers")
+				require.NoError(t, err)
+				require.True(t, rows.Next())
+				require.NoError(t, rows.Close())
+			}
+			assert.Equal(t, tc.conns, atomic.LoadInt32(conns))
+		})
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestDrainingBody(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, tc := range []struct {
+		ctx    context.Context
+		limit  int64
+		unread int
+	}{
+		{context.Background(), 4, 6},
+		{context.Background(), 100, 0},
+		{context.Background(), 0, 10},
+		{cancelled, 100, 10},
+	} {
+		r := strings.NewReader("0123456789")
+		rec := &closeRecorder{Reader: r}
+		body := &drainingBody{ReadCloser: rec, ctx: tc.ctx, limit: tc.limit}
+		require.NoError(t, body.Close())
+		assert.True(t, rec.closed)
+		assert.Equal(t, tc.unread, r.Len(), "limit %d", tc.limit)
+	}
+}
+
+func TestDrainOnCancel(t *testing.T) {
+	var conns int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprint(w, "n\nUInt32\n0\n")
+		w.(http.Flusher).Flush()
+		if strings.HasPrefix(string(query), "SELECT sleep") {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}))
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+	db, err := sql.Open("clickhouse", ts.URL+"/default")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rows, err := db.QueryContext(ctx, "SELECT sleep(3)")
+	require.NoError(t, err)
+	require.True(t, rows.Next())
+	cancel()
+	start := time.Now()
+	rows.Close()
+	assert.True(t, time.Since(start) < time.Second, "the body of a cancelled query is not drained")
+
+	// the connection of the cancelled request is not reused
+	var n int
+	require.NoError(t, db.QueryRow("SELECT 1").Scan(&n))
+	assert.True(t, atomic.LoadInt32(&conns) >= 2)
+}
+
+func TestDrainSlowStream(t *testing.T) {
+	disconnected := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "n\nUInt32\n0\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+			close(disconnected)
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer ts.Close()
+	db, err := sql.Open("clickhouse", ts.URL+"/default")
+	require.NoError(t, err)
+	defer db.Close()
+
+	rows, err := db.Query("SELECT sleep(2)")
+	require.NoError(t, err)
+	require.True(t, rows.Next())
+	start := time.Now()
+	require.NoError(t, rows.Close())
+	assert.True(t, time.Since(start) < 500*time.Millisecond, "the rest of a slow stream is not waited for")
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("the connection of the slow stream is not closed")
+	}
+}
+
+func TestMaxBodyDrainDSN(t *testing.T) {
+	cfg, err := ParseDSN("http://localhost:8123/default?max_body_drain_bytes=1024")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1024, cfg.MaxBodyDrainBytes)
+	assert.Empty(t, cfg.Params)
+	assert.True(t, strings.Contains(cfg.FormatDSN(), "max_body_drain_bytes=1024"))
+	assert.EqualValues(t, defaultMaxBodyDrain, maxBodyDrain(0))
+	assert.EqualValues(t, 0, maxBodyDrain(-1))
+}
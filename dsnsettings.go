@@ -0,0 +1,219 @@
+package clickhouse
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// settingsParamPrefix is the prefix of DSN parameters which are always
+// forwarded to the server as settings, even if the name of the setting is
+// the name of an option of the driver
+const settingsParamPrefix = "settings."
+
+// settingValidator checks the value of a setting forwarded from the DSN
+type settingValidator func(value string) error
+
+func uintSetting(value string) error {
+	if _, err := strconv.ParseUint(value, 10, 64); err != nil {
+		return fmt.Errorf("'%s' is not an unsigned integer", value)
+	}
+	return nil
+}
+
+func intSetting(value string) error {
+	if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+		return fmt.Errorf("'%s' is not an integer", value)
+	}
+	return nil
+}
+
+func floatSetting(value string) error {
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		return fmt.Errorf("'%s' is not a number", value)
+	}
+	return nil
+}
+
+func boolSetting(value string) error {
+	switch strings.ToLower(value) {
+	case "0", "1", "true", "false":
+		return nil
+	}
+	return fmt.Errorf("'%s' is not a boolean, expected 0, 1, true or false", value)
+}
+
+// enumSetting returns a validator of a setting with one of the values
+func enumSetting(values ...string) settingValidator {
+	return func(value string) error {
+		for _, v := range values {
+			if value == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("'%s' is not one of %s", value, strings.Join(values, ", "))
+	}
+}
+
+// threadsSetting validates the settings of the number of threads which are
+// unsigned integers or auto
+func threadsSetting(value string) error {
+	if value == "auto" || strings.HasPrefix(value, "auto(") && strings.HasSuffix(value, ")") {
+		return nil
+	}
+	if uintSetting(value) != nil {
+		return fmt.Errorf("'%s' is not an unsigned integer or auto", value)
+	}
+	return nil
+}
+
+var overflowMode = enumSetting("throw", "break")
+
+// knownSettings are the validators of the commonly used settings, the DSN
+// parameters which are not known settings are forwarded as they are unless
+// they look like a misspelled known setting
+var knownSettings = map[string]settingValidator{
+	"max_memory_usage":                         uintSetting,
+	"max_memory_usage_for_user":                uintSetting,
+	"max_threads":                              threadsSetting,
+	"max_insert_threads":                       uintSetting,
+	"max_final_threads":                        threadsSetting,
+	"max_block_size":                           uintSetting,
+	"max_insert_block_size":                    uintSetting,
+	"min_insert_block_size_rows":               uintSetting,
+	"min_insert_block_size_bytes":              uintSetting,
+	"max_query_size":                           uintSetting,
+	"max_ast_depth":                            uintSetting,
+	"max_ast_elements":                         uintSetting,
+	"max_parser_depth":                         uintSetting,
+	"max_rows_to_read":                         uintSetting,
+	"max_bytes_to_read":                        uintSetting,
+	"max_rows_to_group_by":                     uintSetting,
+	"max_bytes_before_external_group_by":       uintSetting,
+	"max_bytes_before_external_sort":           uintSetting,
+	"max_result_rows":                          uintSetting,
+	"max_result_bytes":                         uintSetting,
+	"max_partitions_per_insert_block":          uintSetting,
+	"max_network_bandwidth":                    uintSetting,
+	"max_concurrent_queries_for_user":          uintSetting,
+	"max_execution_time":                       floatSetting,
+	"timeout_before_checking_execution_speed":  floatSetting,
+	"connect_timeout":                          floatSetting,
+	"receive_timeout":                          floatSetting,
+	"send_timeout":                             floatSetting,
+	"http_headers_progress_interval_ms":        uintSetting,
+	"async_insert_busy_timeout_ms":             uintSetting,
+	"input_format_allow_errors_num":            uintSetting,
+	"input_format_allow_errors_ratio":          floatSetting,
+	"http_zlib_compression_level":              intSetting,
+	"priority":                                 uintSetting,
+	"readonly":                                 enumSetting("0", "1", "2"),
+	"send_progress_in_http_headers":            boolSetting,
+	"wait_end_of_query":                        boolSetting,
+	"insert_deduplicate":                       boolSetting,
+	"async_insert":                             boolSetting,
+	"wait_for_async_insert":                    boolSetting,
+	"distributed_aggregation_memory_efficient": boolSetting,
+	"use_uncompressed_cache":                   boolSetting,
+	"log_queries":                              boolSetting,
+	"join_use_nulls":                           boolSetting,
+	"extremes":                                 boolSetting,
+	"select_sequential_consistency":            boolSetting,
+	"input_format_skip_unknown_fields":         boolSetting,
+	"output_format_json_quote_64bit_integers":  boolSetting,
+	"read_overflow_mode":                       overflowMode,
+	"result_overflow_mode":                     overflowMode,
+	"timeout_overflow_mode":                    overflowMode,
+	"group_by_overflow_mode":                   enumSetting("throw", "break", "any"),
+	"distributed_product_mode":                 enumSetting("deny", "local", "global", "allow"),
+	"load_balancing": enumSetting("random", "nearest_hostname", "hostname_levenshtein_distance",
+		"in_order", "first_or_random", "round_robin"),
+	"totals_mode": enumSetting("before_having", "after_having_exclusive", "after_having_inclusive",
+		"after_having_auto"),
+	"date_time_input_format":  enumSetting("basic", "best_effort", "best_effort_us"),
+	"date_time_output_format": enumSetting("simple", "iso", "unix_timestamp"),
+}
+
+// validateSetting checks the value of a known setting forwarded from the
+// DSN. An unknown setting which differs from a known one by a couple of
+// characters is considered a typo unless explicit is set, i.e. the setting
+// is given with the settings. prefix.
+func validateSetting(name, value string, explicit bool) error {
+	if validate, ok := knownSettings[name]; ok {
+		if err := validate(value); err != nil {
+			return fmt.Errorf("clickhouse: setting %s: %v", name, err)
+		}
+		return nil
+	}
+	if explicit || strings.HasPrefix(name, queryParamPrefix) {
+		return nil
+	}
+	if suggestion := similarSetting(name); len(suggestion) > 0 {
+		return fmt.Errorf("clickhouse: unknown setting '%s', did you mean '%s'? "+
+			"Use '%s%s' to send it as it is", name, suggestion, settingsParamPrefix, name)
+	}
+	return nil
+}
+
+// maxSettingTypos is the largest edit distance between an unknown setting
+// and a known one which is reported as a typo
+const maxSettingTypos = 2
+
+// similarSetting returns the known setting closest to the name if it is
+// within maxSettingTypos edits
+func similarSetting(name string) string {
+	if len(name) <= 2*maxSettingTypos {
+		return ""
+	}
+	best, bestDistance := "", maxSettingTypos+1
+	for known := range knownSettings {
+		if d := editDistance(name, known); d < bestDistance || d == bestDistance && known < best {
+			best, bestDistance = known, d
+		}
+	}
+	if bestDistance > maxSettingTypos {
+		return ""
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance of the strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// ForwardedParams returns the sorted names of the parameters which are sent
+// to the server with every query, i.e. the settings of the DSN which are not
+// options of the driver
+func (cfg *Config) ForwardedParams() []string {
+	names := make([]string, 0, len(cfg.Params))
+	for k := range cfg.Params {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
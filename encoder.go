@@ -1,9 +1,13 @@
 package clickhouse
 
 import (
+	"bytes"
 	"database/sql/driver"
 	"fmt"
+	"math/big"
+	"net"
 	"reflect"
+	"sort"
 	"strconv"
 	"time"
 )
@@ -29,6 +33,38 @@ func (e *textEncoder) Encode(value driver.Value) ([]byte, error) {
 		return e.encodeArray(reflect.ValueOf(v.v))
 	case []byte:
 		return v, nil
+	case Point:
+		return encodePoint(v), nil
+	case net.IP:
+		if v == nil {
+			return []byte("NULL"), nil
+		}
+		return []byte(quote(v.String())), nil
+	case *big.Rat:
+		if v != nil {
+			s, err := formatBigRat(v)
+			return []byte(s), err
+		}
+	case *big.Float:
+		if v != nil {
+			return []byte(v.Text('f', -1)), nil
+		}
+	case *big.Int:
+		if v != nil {
+			return []byte(v.String()), nil
+		}
+	case time.Duration:
+		return []byte(formatInterval(v)), nil
+	}
+	// e.g. Date values or UUIDs in slices
+	if v, ok, err := callValuer(value); ok {
+		if err != nil {
+			return nil, err
+		}
+		if v, err = (converter{}).ConvertValue(v); err != nil {
+			return nil, err
+		}
+		return e.Encode(v)
 	}
 
 	vv := reflect.ValueOf(value)
@@ -40,6 +76,12 @@ func (e *textEncoder) Encode(value driver.Value) ([]byte, error) {
 		return e.Encode(vv.Elem().Interface())
 	case reflect.Slice, reflect.Array:
 		return e.encodeArray(vv)
+	case reflect.Map:
+		return e.encodeMap(vv)
+	case reflect.Struct:
+		if isTupleStruct(value) {
+			return e.encodeTuple(vv)
+		}
 	}
 	return []byte(e.encode(value)), nil
 }
@@ -107,3 +149,52 @@ func (e *textEncoder) encodeArray(value reflect.Value) ([]byte, error) {
 	}
 	return append(res, ']'), nil
 }
+
+// encodeMap encodes a go map as Clickhouse Map, entries are sorted by keys
+func (e *textEncoder) encodeMap(value reflect.Value) ([]byte, error) {
+	if value.IsNil() {
+		return []byte("{}"), nil
+	}
+	entries := make([][]byte, 0, value.Len())
+	iter := value.MapRange()
+	for iter.Next() {
+		k, err := e.Encode(iter.Key().Interface())
+		if err != nil {
+			return nil, err
+		}
+		v, err := e.Encode(iter.Value().Interface())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, append(append(k, ':'), v...))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i], entries[j]) < 0
+	})
+	res := []byte{'{'}
+	res = append(res, bytes.Join(entries, []byte{','})...)
+	return append(res, '}'), nil
+}
+
+// encodeTuple encodes exported fields of a go struct as Clickhouse Tuple
+func (e *textEncoder) encodeTuple(value reflect.Value) ([]byte, error) {
+	res := make([]byte, 0)
+	res = append(res, '(')
+	n := 0
+	for i := 0; i < value.NumField(); i++ {
+		if f := value.Type().Field(i); f.PkgPath != "" || f.Tag.Get("ch") == "-" {
+			// unexported or skipped field
+			continue
+		}
+		if n > 0 {
+			res = append(res, ',')
+		}
+		tmp, err := e.Encode(value.Field(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, tmp...)
+		n++
+	}
+	return append(res, ')'), nil
+}
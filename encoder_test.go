@@ -1,6 +1,7 @@
 package clickhouse
 
 import (
+	"net"
 	"testing"
 	"time"
 
@@ -29,10 +30,15 @@ func TestTextEncoder(t *testing.T) {
 		{float64(1), "1"},
 		{dt, "'2011-03-06 06:20:00'"},
 		{d, "'2012-05-31 00:00:00'"},
+		{time.Time{}, "'0000-00-00 00:00:00'"},
 		{"hello", "'hello'"},
 		{[]byte("hello"), "hello"},
 		{`\\'hello`, `'\\\\\'hello'`},
 		{[]byte(`\\'hello`), `\\'hello`},
+		{net.IPv4(10, 0, 0, 1), "'10.0.0.1'"},
+		{net.ParseIP("2001:db8::1"), "'2001:db8::1'"},
+		{[]net.IP{net.IPv4(10, 0, 0, 1)}, "['10.0.0.1']"},
+		{net.IP(nil), "NULL"},
 		{[]int32{1, 2}, "[1,2]"},
 		{[]int32{}, "[]"},
 		{Array([]int8{1}), "[1]"},
@@ -40,6 +46,15 @@ func TestTextEncoder(t *testing.T) {
 		{[][]int16{{1}}, "[[1]]"},
 		{[]int16(nil), "[]"},
 		{(*int16)(nil), "NULL"},
+		{struct {
+			A uint8
+			B string
+			c int
+		}{1, "b", 2}, "(1,'b')"},
+		{Array([]struct {
+			A uint8
+			B []int8
+		}{{1, []int8{1}}, {2, nil}}), "[(1,[1]),(2,[])]"},
 	}
 
 	enc := new(textEncoder)
@@ -5,35 +5,154 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 )
 
 // Various errors the driver might return. Can change between driver versions.
 var (
 	ErrPlaceholderCount = errors.New("clickhouse: wrong placeholder count")
-	ErrNameParams       = errors.New("clickhouse: driver does not support the use of Named Parameters")
+	ErrNameParams       = errors.New("clickhouse: named parameters are not supported in batch inserts")
 	ErrMalformed        = errors.New("clickhouse: response is malformed")
 	ErrNoLastInsertID   = errors.New("no LastInsertId available")
 	ErrNoRowsAffected   = errors.New("no RowsAffected available")
+
+	ErrInsecureWithCredentials = errors.New("clickhouse: credentials are sent over plain HTTP, set insecure=1 to allow it")
+	ErrInterceptorArgs         = errors.New("clickhouse: query interceptor can not add arguments to a prepared statement")
+	ErrDictionaryNotFound      = errors.New("clickhouse: dictionary does not exist")
+	ErrAttributeNotFound       = errors.New("clickhouse: dictionary attribute does not exist")
+	ErrSampleRatio             = errors.New("clickhouse: sample ratio must be in (0, 1]")
+	ErrUnsafeRowFilter         = errors.New("clickhouse: row filter condition must be a plain expression")
+	ErrNoToken                 = errors.New("clickhouse: auth mode jwt requires a token")
+)
+
+// ErrorClass is a class of server errors. An *Error matches a class using
+// errors.Is if the error code belongs to the class:
+//
+//	if errors.Is(err, clickhouse.ErrTableNotFound) {
+//	    ...
+//	}
+type ErrorClass struct {
+	name  string
+	codes []int
+}
+
+// Classes of server errors.
+var (
+	ErrNetwork        = &ErrorClass{"network error", []int{209, 210, 279}}
+	ErrSyntax         = &ErrorClass{"syntax error", []int{62}}
+	ErrPermission     = &ErrorClass{"permission denied", []int{164, 192, 193, 194, 195, 497, 516}}
+	ErrTableNotFound  = &ErrorClass{"table not found", []int{60}}
+	ErrColumnNotFound = &ErrorClass{"column not found", []int{10, 16, 47}}
+	ErrTypeConversion = &ErrorClass{"type conversion error", []int{6, 27, 38, 41, 53, 70, 72}}
+)
+
+// Error implements the interface error
+func (c *ErrorClass) Error() string {
+	return "clickhouse: " + c.name
+}
+
+// Contains reports whether the code belongs to the class
+func (c *ErrorClass) Contains(code int) bool {
+	for _, v := range c.codes {
+		if v == code {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	errorRe = regexp.MustCompile(`(?s)Code: (\d+),.+DB::Exception: (.+),.*`)
+	// format of the servers since 21.x:
+	// Code: 241. DB::Exception: Memory limit exceeded: ... (MEMORY_LIMIT_EXCEEDED) (version 22.1.3.7 (official build))
+	exceptionRe = regexp.MustCompile(`(?s)^Code: (\d+)\. DB::Exception: (.+?)(?: \(([A-Z0-9_]+)\))?(?: \(version .*\))?,?$`)
 )
 
-var errorRe = regexp.MustCompile(`(?s)Code: (\d+),.+DB::Exception: (.+),.*`)
+// exceptionCodeHeader is the header of responses with the code of the server error
+const exceptionCodeHeader = "X-ClickHouse-Exception-Code"
 
-// Error contains parsed information about server error
+// Error contains parsed information about server error. Name is the name of
+// the error code, e.g. MEMORY_LIMIT_EXCEEDED, it is reported by the servers
+// since 21.x. StackTrace is sent by the server if the setting stacktrace=1
+// is enabled for the user.
 type Error struct {
-	Code    int
-	Message string
+	Code       int
+	Name       string
+	Message    string
+	StackTrace string
 }
 
+// Exception is the error of the server, use errors.As to get it:
+//
+//	var ex *clickhouse.Exception
+//	if errors.As(err, &ex) && ex.Code == 241 {
+//	    ...
+//	}
+type Exception = Error
+
 // Error implements the interface error
 func (e *Error) Error() string {
 	return fmt.Sprintf("Code: %d, Message: %s", e.Code, e.Message)
 }
 
+// Is reports whether the error belongs to the target *ErrorClass
+func (e *Error) Is(target error) bool {
+	c, ok := target.(*ErrorClass)
+	return ok && c.Contains(e.Code)
+}
+
+// ErrPayloadTooLarge is returned when a request body exceeds Config.MaxRequestBodySize
+type ErrPayloadTooLarge struct {
+	Actual int64
+	Limit  int64
+}
+
+// Error implements the interface error
+func (e ErrPayloadTooLarge) Error() string {
+	return fmt.Sprintf("clickhouse: request body of %d bytes exceeds the limit of %d bytes", e.Actual, e.Limit)
+}
+
 func newError(resp string) error {
-	tokens := errorRe.FindStringSubmatch(resp)
+	msg, stackTrace := splitStackTrace(resp)
+	if tokens := exceptionRe.FindStringSubmatch(msg); len(tokens) == 4 {
+		code, _ := strconv.ParseInt(tokens[1], 10, 64)
+		return &Error{Code: int(code), Name: tokens[3], Message: tokens[2], StackTrace: stackTrace}
+	}
+	tokens := errorRe.FindStringSubmatch(msg)
 	if len(tokens) < 3 {
 		return fmt.Errorf("clickhouse: %s", resp)
 	}
 	code, _ := strconv.ParseInt(tokens[1], 10, 64)
-	return &Error{Code: int(code), Message: tokens[2]}
+	return &Error{Code: int(code), Message: tokens[2], StackTrace: stackTrace}
+}
+
+// newResponseError parses the body of the failed response, the code of the
+// error is taken from the header if the body is not recognized
+func newResponseError(resp string, codeHeader string) error {
+	err := newError(resp)
+	if _, ok := err.(*Error); ok || len(codeHeader) == 0 {
+		return err
+	}
+	code, perr := strconv.Atoi(codeHeader)
+	if perr != nil {
+		return err
+	}
+	msg, stackTrace := splitStackTrace(resp)
+	return &Error{Code: code, Message: msg, StackTrace: stackTrace}
+}
+
+// splitStackTrace cuts the stack trace of the server from the error message
+func splitStackTrace(resp string) (msg, stackTrace string) {
+	msg = strings.TrimSpace(resp)
+	i := strings.Index(msg, "Stack trace")
+	if i < 0 {
+		return msg, ""
+	}
+	stackTrace = msg[i:]
+	if j := strings.IndexByte(stackTrace, '\n'); j >= 0 {
+		// skip the title, e.g. "Stack trace (when copying this message, always include the lines below):"
+		stackTrace = strings.TrimSpace(stackTrace[j:])
+	}
+	// the old servers separate the stack trace with a comma, which errorRe expects
+	return strings.TrimSpace(msg[:i]), stackTrace
 }
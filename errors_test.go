@@ -0,0 +1,72 @@
+package clickhouse
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewError(t *testing.T) {
+	err := newError("Code: 60, e.displayText() = DB::Exception: Table default.data2 doesn't exist., e.what() = DB::Exception\n")
+	assert.Equal(t, &Error{Code: 60, Message: "Table default.data2 doesn't exist."}, err)
+
+	err = newError("unexpected")
+	assert.EqualError(t, err, "clickhouse: unexpected")
+
+	err = newError("Code: 241. DB::Exception: Memory limit (for query) exceeded: would use 9.32 GiB, maximum: 9.31 GiB. (MEMORY_LIMIT_EXCEEDED) (version 22.1.3.7 (official build))\n")
+	assert.Equal(t, &Error{
+		Code:    241,
+		Name:    "MEMORY_LIMIT_EXCEEDED",
+		Message: "Memory limit (for query) exceeded: would use 9.32 GiB, maximum: 9.31 GiB.",
+	}, err)
+
+	err = newError("Code: 60. DB::Exception: Table default.t doesn't exist. (UNKNOWN_TABLE), Stack trace (when copying this message, always include the lines below):\n\n0. DB::Exception::Exception() @ 0xa82d07a in /usr/bin/clickhouse\n1. DB::Context::getTable() @ 0x1234 in /usr/bin/clickhouse\n")
+	assert.Equal(t, &Error{
+		Code:       60,
+		Name:       "UNKNOWN_TABLE",
+		Message:    "Table default.t doesn't exist.",
+		StackTrace: "0. DB::Exception::Exception() @ 0xa82d07a in /usr/bin/clickhouse\n1. DB::Context::getTable() @ 0x1234 in /usr/bin/clickhouse",
+	}, err)
+
+	err = newError("Code: 62, e.displayText() = DB::Exception: Syntax error, Stack trace:\n\n0. DB::parseQuery()\n")
+	assert.Equal(t, &Error{Code: 62, Message: "Syntax error", StackTrace: "0. DB::parseQuery()"}, err)
+
+	err = newResponseError("Memory limit exceeded\n", "241")
+	assert.Equal(t, &Error{Code: 241, Message: "Memory limit exceeded"}, err)
+	err = newResponseError("unexpected", "")
+	assert.EqualError(t, err, "clickhouse: unexpected")
+
+	var ex *Exception
+	assert.False(t, errors.As(err, &ex))
+	err = fmt.Errorf("wrapped: %w", newError("Code: 60. DB::Exception: Table default.t doesn't exist. (UNKNOWN_TABLE)"))
+	if assert.True(t, errors.As(err, &ex)) {
+		assert.Equal(t, 60, ex.Code)
+		assert.Equal(t, "UNKNOWN_TABLE", ex.Name)
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	testCases := []struct {
+		code  int
+		class *ErrorClass
+	}{
+		{209, ErrNetwork},
+		{62, ErrSyntax},
+		{164, ErrPermission},
+		{516, ErrPermission},
+		{60, ErrTableNotFound},
+		{47, ErrColumnNotFound},
+		{53, ErrTypeConversion},
+	}
+	classes := []*ErrorClass{ErrNetwork, ErrSyntax, ErrPermission, ErrTableNotFound, ErrColumnNotFound, ErrTypeConversion}
+	for _, tc := range testCases {
+		err := fmt.Errorf("wrapped: %w", &Error{Code: tc.code})
+		for _, class := range classes {
+			assert.Equal(t, class == tc.class, errors.Is(err, class), "code %d, class %s", tc.code, class)
+		}
+	}
+	assert.False(t, errors.Is(errors.New("other"), ErrSyntax))
+	assert.EqualError(t, ErrSyntax, "clickhouse: syntax error")
+}
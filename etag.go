@@ -0,0 +1,133 @@
+package clickhouse
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+const (
+	defaultResponseCacheSize = 1000
+	// maxCachedResponseSize limits the size of a response stored in the cache
+	maxCachedResponseSize = 8 << 20
+)
+
+// ResponseCache stores responses of read-only queries with their ETags.
+// Implementations must be safe for concurrent use.
+type ResponseCache interface {
+	// Get returns the ETag and the body of the cached response for the key
+	Get(key string) (etag string, body []byte, ok bool)
+	// Set stores the response for the key
+	Set(key, etag string, body []byte)
+}
+
+// defaultResponseCache is used if Config.ETagCache is set without Config.ResponseCache
+var defaultResponseCache = NewMemoryResponseCache(defaultResponseCacheSize)
+
+// NewMemoryResponseCache returns an in-memory ResponseCache which keeps up to
+// maxEntries least recently used responses.
+func NewMemoryResponseCache(maxEntries int) ResponseCache {
+	return &memoryResponseCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+type memoryResponseCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+}
+
+type cachedResponse struct {
+	key  string
+	etag string
+	body []byte
+}
+
+// Get implements the ResponseCache
+func (c *memoryResponseCache) Get(key string) (string, []byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return "", nil, false
+	}
+	c.lru.MoveToFront(e)
+	r := e.Value.(*cachedResponse)
+	return r.etag, r.body, true
+}
+
+// Set implements the ResponseCache
+func (c *memoryResponseCache) Set(key, etag string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		r := e.Value.(*cachedResponse)
+		r.etag, r.body = etag, body
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&cachedResponse{key: key, etag: etag, body: body})
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.entries, e.Value.(*cachedResponse).key)
+	}
+}
+
+// responseCacheKey returns the key of the read-only request, or an empty
+// string if the request can not be cached. The parameters which differ
+// between the requests of the same query, query_id and the
+// max_execution_time of the deadline of the context, are left out.
+func responseCacheKey(req *http.Request) string {
+	if req.Method != http.MethodGet || req.GetBody == nil {
+		return ""
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+	query, err := ioutil.ReadAll(body)
+	if err != nil || len(query) == 0 {
+		return ""
+	}
+	params := req.URL.Query()
+	params.Del(queryIDParamName)
+	params.Del("max_execution_time")
+	u := *req.URL
+	u.RawQuery = params.Encode()
+	return u.String() + "\n" + string(query)
+}
+
+// cachingBody stores the response in the cache once it is fully read
+type cachingBody struct {
+	io.ReadCloser
+	cache ResponseCache
+	key   string
+	etag  string
+	buf   bytes.Buffer
+	skip  bool
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if !b.skip {
+		b.buf.Write(p[:n])
+		if b.buf.Len() > maxCachedResponseSize {
+			b.skip = true
+			b.buf = bytes.Buffer{}
+		}
+		if err == io.EOF {
+			b.skip = true
+			b.cache.Set(b.key, b.etag, b.buf.Bytes())
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,69 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestETagCache(t *testing.T) {
+	var notModified int
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, "a\nInt8\n1\n")
+	})
+	defer ts.Close()
+
+	cfg, err := ParseDSN(dsn + "?etag_cache=1")
+	require.NoError(t, err)
+	assert.True(t, cfg.ETagCache)
+	assert.Contains(t, cfg.FormatDSN(), "etag_cache=1")
+	cfg.ResponseCache = NewMemoryResponseCache(10)
+	db := sql.OpenDB(NewConnector(cfg))
+	defer db.Close()
+
+	for i := 0; i < 3; i++ {
+		var a int8
+		require.NoError(t, db.QueryRow("SELECT a FROM t").Scan(&a))
+		assert.Equal(t, int8(1), a)
+	}
+	assert.Equal(t, 2, notModified)
+
+	// cancellable contexts get a query_id and deadlines max_execution_time
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(10+i)*time.Minute)
+		var a int8
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT a FROM t").Scan(&a))
+		cancel()
+		ctx, cancel = context.WithCancel(context.Background())
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT a FROM t").Scan(&a))
+		cancel()
+	}
+	assert.Equal(t, 6, notModified)
+}
+
+func TestMemoryResponseCache(t *testing.T) {
+	cache := NewMemoryResponseCache(2)
+	cache.Set("a", "1", []byte("a"))
+	cache.Set("b", "1", []byte("b"))
+	_, _, ok := cache.Get("a")
+	assert.True(t, ok)
+	cache.Set("c", "1", []byte("c"))
+	_, _, ok = cache.Get("b")
+	assert.False(t, ok)
+	etag, body, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", etag)
+	assert.Equal(t, []byte("a"), body)
+}
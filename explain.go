@@ -0,0 +1,81 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// ValidateQuery checks the syntax of the query with EXPLAIN AST, the query
+// itself is not executed. A server error, e.g. one matching ErrSyntax, is
+// returned if the query is invalid.
+func ValidateQuery(ctx context.Context, db *sql.DB, query string, args ...interface{}) error {
+	rows, err := db.QueryContext(ctx, "EXPLAIN AST "+query, args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+	}
+	if err = rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	return rows.Close()
+}
+
+// PruningReport describes how many data of a table a query reads
+type PruningReport struct {
+	// PartitionsScanned is the number of data parts of the table the query reads
+	PartitionsScanned int
+	// PartitionsTotal is the number of active data parts of the table
+	PartitionsTotal int
+	// EstimatedRows is the estimated number of rows the query reads from the table
+	EstimatedRows int64
+}
+
+// AnalyzePartitionPruning estimates with EXPLAIN ESTIMATE how many data parts
+// and rows of the table (optionally qualified as "db.table") the query reads.
+// EXPLAIN ESTIMATE reports data parts rather than partitions, so partitions
+// are counted in data parts: a query that filters by the partition key reads
+// a small share of PartitionsTotal.
+func AnalyzePartitionPruning(ctx context.Context, db *sql.DB, table, query string) (*PruningReport, error) {
+	database := ""
+	if i := strings.IndexByte(table, '.'); i >= 0 {
+		database, table = table[:i], table[i+1:]
+	}
+	rows, err := db.QueryContext(ctx, "EXPLAIN ESTIMATE "+query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	report := new(PruningReport)
+	for rows.Next() {
+		var (
+			estDatabase, estTable string
+			parts, estRows, marks uint64
+		)
+		if err := rows.Scan(&estDatabase, &estTable, &parts, &estRows, &marks); err != nil {
+			return nil, err
+		}
+		if estTable == table && (len(database) == 0 || estDatabase == database) {
+			report.PartitionsScanned += int(parts)
+			report.EstimatedRows += int64(estRows)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	partsQuery := "SELECT count() FROM system.parts WHERE active AND table = ? AND database = currentDatabase()"
+	args := []interface{}{table}
+	if len(database) > 0 {
+		partsQuery = "SELECT count() FROM system.parts WHERE active AND table = ? AND database = ?"
+		args = append(args, database)
+	}
+	var total uint64
+	if err := db.QueryRowContext(ctx, partsQuery, args...).Scan(&total); err != nil {
+		return nil, err
+	}
+	report.PartitionsTotal = int(total)
+	return report, nil
+}
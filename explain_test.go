@@ -0,0 +1,64 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateQuery(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		if query == "EXPLAIN AST SELECT FROM" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, "Code: 62, e.displayText() = DB::Exception: Syntax error: failed at position 19, e.what() = DB::Exception\n")
+			return
+		}
+		io.WriteString(w, "explain\nString\nSelectWithUnionQuery (children 1)\n ExpressionList (children 1)\n")
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	assert.NoError(t, ValidateQuery(ctx, db, "SELECT a FROM t WHERE b = ?", "x"))
+	err = ValidateQuery(ctx, db, "SELECT FROM")
+	assert.True(t, errors.Is(err, ErrSyntax))
+	assert.Equal(t, []string{"EXPLAIN AST SELECT a FROM t WHERE b = 'x'", "EXPLAIN AST SELECT FROM"}, queries)
+}
+
+func TestAnalyzePartitionPruning(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		if strings.HasPrefix(query, "EXPLAIN ESTIMATE") {
+			io.WriteString(w, "database\ttable\tparts\trows\tmarks\nString\tString\tUInt64\tUInt64\tUInt64\n"+
+				"db\tevents\t3\t24576\t3\ndb\tusers\t1\t100\t1\n")
+			return
+		}
+		io.WriteString(w, "count()\nUInt64\n12\n")
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	report, err := AnalyzePartitionPruning(context.Background(), db, "db.events", "SELECT * FROM db.events e JOIN db.users USING (id) WHERE date = today()")
+	require.NoError(t, err)
+	assert.Equal(t, &PruningReport{PartitionsScanned: 3, PartitionsTotal: 12, EstimatedRows: 24576}, report)
+	assert.Equal(t, []string{
+		"EXPLAIN ESTIMATE SELECT * FROM db.events e JOIN db.users USING (id) WHERE date = today()",
+		"SELECT count() FROM system.parts WHERE active AND table = 'events' AND database = 'db'",
+	}, queries)
+}
@@ -0,0 +1,131 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// Column is a column of an external table
+type Column struct {
+	Name string
+	Type string
+}
+
+// External is a table sent to the server with a query, which can use it like
+// a temporary table, e.g. in "WHERE id IN ids" with a large list of ids.
+// See https://clickhouse.com/docs/en/engines/table-engines/special/external-data
+type External struct {
+	name    string
+	columns []Column
+	rows    [][]interface{}
+}
+
+// ExternalTable returns the external table with the columns and the rows,
+// more rows can be added with Append
+func ExternalTable(name string, columns []Column, rows ...[]interface{}) *External {
+	return &External{name: name, columns: columns, rows: rows}
+}
+
+// Append adds the row to the table
+func (e *External) Append(row ...interface{}) {
+	e.rows = append(e.rows, row)
+}
+
+// WithExternalTables returns a copy of ctx which sends the tables with
+// queries executed with it. Such queries are sent as multipart/form-data
+// POST requests, which are not compressed.
+func WithExternalTables(ctx context.Context, tables ...*External) context.Context {
+	parent, _ := ctx.Value(externalTablesKey).([]*External)
+	return context.WithValue(ctx, externalTablesKey, append(append([]*External(nil), parent...), tables...))
+}
+
+func externalTables(ctx context.Context) []*External {
+	if ctx == nil {
+		return nil
+	}
+	tables, _ := ctx.Value(externalTablesKey).([]*External)
+	return tables
+}
+
+// structure returns the columns in the format of the <name>_structure parameter
+func (e *External) structure() string {
+	columns := make([]string, len(e.columns))
+	for i, col := range e.columns {
+		columns[i] = col.Name + " " + col.Type
+	}
+	return strings.Join(columns, ", ")
+}
+
+// writeTo writes the rows in the TabSeparated format
+func (e *External) writeTo(w io.Writer) error {
+	var buf bytes.Buffer
+	for _, row := range e.rows {
+		if len(row) != len(e.columns) {
+			return fmt.Errorf("clickhouse: external table %s has %d columns, got a row of %d values", e.name, len(e.columns), len(row))
+		}
+		buf.Reset()
+		for i, v := range row {
+			if i > 0 {
+				buf.WriteByte('\t')
+			}
+			dv, err := converter{}.ConvertValue(v)
+			if err != nil {
+				return err
+			}
+			s, err := formatQueryParam(dv)
+			if err != nil {
+				return err
+			}
+			buf.WriteString(s)
+		}
+		buf.WriteByte('\n')
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachExternalTables makes the request send the tables as files of the
+// multipart form, the query is moved to the URL
+func (c *conn) attachExternalTables(req *http.Request, query string, tables []*External) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	reqQuery := req.URL.Query()
+	reqQuery.Set("query", query)
+	for _, table := range tables {
+		if len(table.name) == 0 || len(table.columns) == 0 {
+			return fmt.Errorf("clickhouse: external table must have a name and columns")
+		}
+		reqQuery.Set(table.name+"_structure", table.structure())
+		part, err := mw.CreateFormFile(table.name, table.name)
+		if err != nil {
+			return err
+		}
+		if err := table.writeTo(part); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	if c.maxBodySize > 0 && int64(body.Len()) > c.maxBodySize {
+		return ErrPayloadTooLarge{Actual: int64(body.Len()), Limit: c.maxBodySize}
+	}
+	data := body.Bytes()
+	req.Method = http.MethodPost
+	req.URL.RawQuery = reqQuery.Encode()
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.ContentLength = int64(len(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return nil
+}
@@ -0,0 +1,67 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternalTables(t *testing.T) {
+	var (
+		method, query string
+		structures    []string
+		files         = make(map[string]string)
+	)
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, body string) {
+		method = r.Method
+		query = r.URL.Query().Get("query")
+		structures = []string{r.URL.Query().Get("ids_structure"), r.URL.Query().Get("names_structure")}
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mr := multipart.NewReader(strings.NewReader(body), params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			data, _ := ioutil.ReadAll(part)
+			files[part.FormName()] = string(data)
+		}
+		w.Write([]byte("count()\nUInt64\n2\n"))
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ids := ExternalTable("ids", []Column{{"id", "UInt64"}}, []interface{}{1}, []interface{}{2})
+	names := ExternalTable("names", []Column{{"id", "UInt64"}, {"name", "Nullable(String)"}})
+	names.Append(1, "it's\ta")
+	names.Append(2, nil)
+	ctx := WithExternalTables(context.Background(), ids)
+	ctx = WithExternalTables(ctx, names)
+
+	var count uint64
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count() FROM t WHERE id IN ids").Scan(&count))
+	assert.EqualValues(t, 2, count)
+	assert.Equal(t, http.MethodPost, method)
+	assert.Equal(t, "SELECT count() FROM t WHERE id IN ids", query)
+	assert.Equal(t, []string{"id UInt64", "id UInt64, name Nullable(String)"}, structures)
+	assert.Equal(t, map[string]string{"ids": "1\n2\n", "names": "1\tit's\\ta\n2\t\\N\n"}, files)
+
+	names.Append(3)
+	_, err = db.ExecContext(WithExternalTables(context.Background(), names), "INSERT INTO t SELECT * FROM names")
+	assert.Error(t, err)
+}
@@ -0,0 +1,153 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+var errNotClickHouseConn = errors.New("clickhouse: database connection is not a clickhouse connection")
+
+// QueryArrowStream executes the query and returns the raw response body with
+// the result in ArrowStream format (Apache Arrow IPC streaming format). The
+// driver does not decode the stream into Arrow records, the body can be
+// passed to ipc.NewReader of github.com/apache/arrow/go to read them. The
+// returned body holds a connection of db until it is closed.
+func QueryArrowStream(ctx context.Context, db *sql.DB, query string, args ...interface{}) (io.ReadCloser, error) {
+	return QueryFormat(ctx, db, query, "ArrowStream", args...)
+}
+
+// QueryFormat executes the query and returns the response body with the result
+// in the given ClickHouse output format. The returned body holds a connection
+// of db until it is closed.
+func QueryFormat(ctx context.Context, db *sql.DB, query, format string, args ...interface{}) (io.ReadCloser, error) {
+	values, err := convertArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	sqlConn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var body io.ReadCloser
+	err = sqlConn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*conn)
+		if !ok {
+			return errNotClickHouseConn
+		}
+		var err error
+		if body, err = c.queryFormat(ctx, query, format, values); err == nil {
+			body = &formatBody{ReadCloser: body, c: c}
+		}
+		return err
+	})
+	if err != nil {
+		sqlConn.Close()
+		return nil, err
+	}
+	return &connBody{ReadCloser: body, conn: sqlConn}, nil
+}
+
+// convertArgs converts the arguments of the queries executed on the driver
+// connection directly
+func convertArgs(args []interface{}) ([]driver.Value, error) {
+	var values []driver.Value
+	for _, arg := range args {
+		v, err := converter{}.ConvertValue(arg)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// formatBody resets the cancel function of the connection on Close like rows do
+type formatBody struct {
+	io.ReadCloser
+	c *conn
+}
+
+func (b *formatBody) Close() error {
+	b.c.cancel = nil
+	return b.ReadCloser.Close()
+}
+
+// connBody releases the connection to the pool on Close
+type connBody struct {
+	io.ReadCloser
+	conn *sql.Conn
+}
+
+func (b *connBody) Close() error {
+	err := b.ReadCloser.Close()
+	if cerr := b.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// QueryParquet executes the query and writes the result in Parquet format to
+// the file at outputPath. The file is removed if the query fails.
+func QueryParquet(ctx context.Context, db *sql.DB, query, outputPath string, args ...interface{}) (err error) {
+	body, err := QueryFormat(ctx, db, query, "Parquet", args...)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(outputPath)
+		}
+	}()
+	_, err = io.Copy(f, body)
+	return err
+}
+
+// QueryParquetBuffered executes the query and returns the Parquet file of the
+// result buffered in memory. It is not a Parquet reader: the whole response
+// is read into the returned bytes.Reader, because Parquet readers need
+// random access to the file, and the body is closed before
+// QueryParquetBuffered returns, so the memory used is the size of the file.
+// Use QueryParquet to write large results to a file instead. The buffer can
+// be opened with parquet.OpenFile(r, r.Size()) of
+// github.com/parquet-go/parquet-go.
+func QueryParquetBuffered(ctx context.Context, db *sql.DB, query string, args ...interface{}) (*bytes.Reader, error) {
+	body, err := QueryFormat(ctx, db, query, "Parquet", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// QueryMarkdown executes the query and returns the result as a Markdown table
+// (Markdown output format is supported by ClickHouse since 22.11).
+func QueryMarkdown(ctx context.Context, db *sql.DB, query string, args ...interface{}) (string, error) {
+	body, err := QueryFormat(ctx, db, query, "Markdown", args...)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
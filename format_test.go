@@ -0,0 +1,106 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryArrowStream(t *testing.T) {
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		if query == "SELECT 1" {
+			// ping
+			io.WriteString(w, "1\nUInt8\n1\n")
+			return
+		}
+		assert.Equal(t, "SELECT * FROM t WHERE id = 1", query)
+		assert.Equal(t, "ArrowStream", r.URL.Query().Get("default_format"))
+		io.WriteString(w, "arrow stream")
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	body, err := QueryArrowStream(ctx, db, "SELECT * FROM t WHERE id = ?", 1)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "arrow stream", string(data))
+	require.NoError(t, body.Close())
+
+	// the connection is released
+	assert.NoError(t, db.PingContext(ctx))
+	_, err = QueryArrowStream(ctx, db, "SELECT * FROM t WHERE id = ?", 1, 2)
+	assert.Equal(t, ErrPlaceholderCount, err)
+	assert.NoError(t, db.PingContext(ctx))
+
+	_, err = QueryArrowStream(ctx, NewSingleflightDB(db), "SELECT 1")
+	assert.Equal(t, errNotClickHouseConn, err)
+}
+
+func TestQueryParquet(t *testing.T) {
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		if query == "SELECT broken" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, "Code: 62, e.displayText() = DB::Exception: Syntax error, e.what() = DB::Exception\n")
+			return
+		}
+		assert.Equal(t, "Parquet", r.URL.Query().Get("default_format"))
+		io.WriteString(w, "PAR1 data PAR1")
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "result.parquet")
+	require.NoError(t, QueryParquet(ctx, db, "SELECT * FROM t WHERE id = ?", path, 1))
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "PAR1 data PAR1", string(data))
+
+	r, err := QueryParquetBuffered(ctx, db, "SELECT * FROM t")
+	require.NoError(t, err)
+	assert.EqualValues(t, len("PAR1 data PAR1"), r.Size())
+
+	_, err = QueryParquetBuffered(ctx, db, "SELECT broken")
+	assert.True(t, errors.Is(err, ErrSyntax))
+	assert.Equal(t, 0, db.Stats().InUse)
+}
+
+func TestQueryMarkdown(t *testing.T) {
+	table := "| a | b |\n|-:|:-|\n| 1 | x |\n"
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		if query == "" {
+			io.WriteString(w, "Ok.\n")
+			return
+		}
+		assert.Equal(t, "SELECT 1 AS a, 'x' AS b", query)
+		assert.Equal(t, "Markdown", r.URL.Query().Get("default_format"))
+		io.WriteString(w, table)
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	md, err := QueryMarkdown(context.Background(), db, "SELECT ? AS a, ? AS b", 1, "x")
+	require.NoError(t, err)
+	assert.Equal(t, table, md)
+	assert.Equal(t, 0, db.Stats().InUse)
+}
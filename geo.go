@@ -0,0 +1,115 @@
+package clickhouse
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// Point is a value of Point column, the X and Y coordinates. It has the
+// same layout as orb.Point, a Point column can be scanned into *[2]float64
+// or *orb.Point directly.
+type Point [2]float64
+
+// Ring is a value of Ring column, a polygon without holes. Ring, Polygon and
+// MultiPolygon columns can also be scanned into nested slices of compatible
+// types with ScanArray, e.g. a Ring column into *[][2]float64 or *orb.Ring.
+type Ring []Point
+
+// Polygon is a value of Polygon column, the outer ring and then the holes
+type Polygon []Ring
+
+// MultiPolygon is a value of MultiPolygon column
+type MultiPolygon []Polygon
+
+var (
+	geoTypes = map[string]reflect.Type{
+		"Point":        reflect.TypeOf(Point{}),
+		"Ring":         reflect.TypeOf(Ring{}),
+		"Polygon":      reflect.TypeOf(Polygon{}),
+		"MultiPolygon": reflect.TypeOf(MultiPolygon{}),
+	}
+	// geoElems are the element types of the geo types which are arrays
+	geoElems = map[string]string{
+		"Ring":         "Point",
+		"Polygon":      "Ring",
+		"MultiPolygon": "Polygon",
+	}
+)
+
+func encodePoint(p Point) []byte {
+	res := []byte{'('}
+	res = strconv.AppendFloat(res, p[0], 'g', -1, 64)
+	res = append(res, ',')
+	res = strconv.AppendFloat(res, p[1], 'g', -1, 64)
+	return append(res, ')')
+}
+
+type pointParser struct {
+	tuple tupleParser
+}
+
+func (p *pointParser) Parse(s io.RuneScanner) (driver.Value, error) {
+	v, err := p.tuple.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	rv := reflect.ValueOf(v)
+	return Point{rv.Field(0).Float(), rv.Field(1).Float()}, nil
+}
+
+func (p *pointParser) Type() reflect.Type {
+	return geoTypes["Point"]
+}
+
+// geoArrayParser parses Ring, Polygon and MultiPolygon values
+type geoArrayParser struct {
+	array arrayParser
+	typ   reflect.Type
+}
+
+func (p *geoArrayParser) Parse(s io.RuneScanner) (driver.Value, error) {
+	v, err := p.array.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return reflect.ValueOf(v).Convert(p.typ).Interface(), nil
+}
+
+func (p *geoArrayParser) Type() reflect.Type {
+	return p.typ
+}
+
+func newGeoParser(name string) DataParser {
+	if name == "Point" {
+		return &pointParser{tupleParser{args: []DataParser{&floatParser{64}, &floatParser{64}}}}
+	}
+	return &geoArrayParser{array: arrayParser{newGeoParser(geoElems[name])}, typ: geoTypes[name]}
+}
+
+type binaryPointDecoder struct{}
+
+func (d *binaryPointDecoder) decode(r *binaryReader) (driver.Value, error) {
+	var p Point
+	for i := range p {
+		v, err := (&binaryFloatDecoder{64}).decode(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode point: %v", noEOF(err))
+		}
+		p[i] = v.(float64)
+	}
+	return p, nil
+}
+
+func (d *binaryPointDecoder) Type() reflect.Type {
+	return geoTypes["Point"]
+}
+
+func newGeoDecoder(name string) binaryDecoder {
+	if name == "Point" {
+		return &binaryPointDecoder{}
+	}
+	return &binaryArrayDecoder{arg: newGeoDecoder(geoElems[name]), typ: geoTypes[name]}
+}
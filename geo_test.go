@@ -0,0 +1,71 @@
+package clickhouse
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// orbPoint and orbRing have the layout of the types of github.com/paulmach/orb
+type orbPoint [2]float64
+
+type orbRing []orbPoint
+
+func TestGeo(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		if r.Method == http.MethodGet {
+			w.Write([]byte("p\tr\tpg\tmp\nPoint\tRing\tPolygon\tMultiPolygon\n" +
+				"(1,2)\t[(0,0),(1,0.5)]\t[[(0,0),(1,1)],[]]\t[[[(-1.5,2)]]]\n"))
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var (
+		p  [2]float64
+		r  Ring
+		pg Polygon
+		mp MultiPolygon
+	)
+	require.NoError(t, db.QueryRow("SELECT p, r, pg, mp").Scan(&p, &r, &pg, &mp))
+	assert.Equal(t, [2]float64{1, 2}, p)
+	assert.Equal(t, Ring{{0, 0}, {1, 0.5}}, r)
+	assert.Equal(t, Polygon{{{0, 0}, {1, 1}}, {}}, pg)
+	assert.Equal(t, MultiPolygon{{{{-1.5, 2}}}}, mp)
+
+	var (
+		orb   orbRing
+		plain [][][2]float64
+	)
+	require.NoError(t, db.QueryRow("SELECT p, r, pg, mp").Scan(new(orbPoint), ScanArray(&orb), ScanArray(&plain), new(interface{})))
+	assert.Equal(t, orbRing{{0, 0}, {1, 0.5}}, orb)
+	assert.Equal(t, [][][2]float64{{{0, 0}, {1, 1}}, {}}, plain)
+
+	_, err = db.Exec("INSERT INTO t VALUES (?, ?, ?)", Point{1, -2.5}, Ring{{0, 0}, {1, 1}}, Polygon{{{0, 0}}})
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO t VALUES ((1,-2.5), [(0,0),(1,1)], [[(0,0)]])", queries[len(queries)-1])
+}
+
+func TestBinaryGeo(t *testing.T) {
+	b := &rowBinary{}
+	b.le(uint8(2)).str("p").str("r").str("Point").str("Ring")
+	b.le(1.0).le(2.0).le(uint8(1)).le(-1.0).le(0.5)
+
+	rows, err := newBinaryRows(&conn{}, &bufReadCloser{bytes.NewReader(b.Bytes())}, &DataParserOptions{Location: time.UTC, UseDBLocation: true})
+	require.NoError(t, err)
+	assert.Equal(t, geoTypes["Ring"], rows.ColumnTypeScanType(1))
+	dest := make([]driver.Value, 2)
+	require.NoError(t, rows.Next(dest))
+	assert.Equal(t, []driver.Value{Point{1, 2}, Ring{{-1, 0.5}}}, dest)
+}
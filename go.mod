@@ -1,3 +1,10 @@
 module github.com/mailru/go-clickhouse
 
+go 1.17
+
 require github.com/stretchr/testify v1.3.0
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+)
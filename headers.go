@@ -0,0 +1,62 @@
+package clickhouse
+
+import (
+	"context"
+	"net/http"
+)
+
+// WithHeaders returns a copy of ctx which adds the HTTP headers to the
+// requests of the queries executed with it, e.g. a tenant ID or a token
+// required by a gateway in front of ClickHouse. The headers override the
+// headers of Config.Headers and of the parent context, the headers set by the
+// driver itself (authentication, compression) can not be overridden.
+func WithHeaders(ctx context.Context, headers map[string]string) context.Context {
+	if parent, ok := ctx.Value(headersKey).(map[string]string); ok {
+		merged := make(map[string]string, len(parent)+len(headers))
+		for k, v := range parent {
+			merged[k] = v
+		}
+		for k, v := range headers {
+			merged[k] = v
+		}
+		headers = merged
+	}
+	return context.WithValue(ctx, headersKey, headers)
+}
+
+const quotaHeader = "X-ClickHouse-Quota"
+
+// WithQuotaKey returns a copy of ctx which sets the quota key of the queries
+// executed with it, so quotas KEYED BY client_key account the resources used
+// by the queries to the key, e.g. to a customer of a multi-tenant service.
+// It overrides the quota_key of the DSN.
+func WithQuotaKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, quotaHeaderKey, key)
+}
+
+// setQuotaKey sets the X-ClickHouse-Quota header of the request
+func (c *conn) setQuotaKey(ctx context.Context, req *http.Request) {
+	key := c.quotaKey
+	if ctx != nil {
+		if k, ok := ctx.Value(quotaHeaderKey).(string); ok {
+			key = k
+		}
+	}
+	if len(key) > 0 {
+		req.Header.Set(quotaHeader, key)
+	}
+}
+
+// setHeaders sets the headers of the connection and of the context
+func (c *conn) setHeaders(ctx context.Context, req *http.Request) {
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	if ctx == nil {
+		return
+	}
+	headers, _ := ctx.Value(headersKey).(map[string]string)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+}
@@ -0,0 +1,67 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaders(t *testing.T) {
+	var headers http.Header
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		headers = r.Header
+	})
+	defer ts.Close()
+
+	cfg, err := ParseDSN(dsn)
+	require.NoError(t, err)
+	cfg.Headers = map[string]string{"X-Tenant": "default", "X-Gateway-Key": "key"}
+	cfg.Compression = "gzip"
+	db := sql.OpenDB(NewConnector(cfg))
+	defer db.Close()
+
+	require.NoError(t, db.Ping())
+	assert.Equal(t, "key", headers.Get("X-Gateway-Key"))
+
+	_, err = db.Exec("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, "default", headers.Get("X-Tenant"))
+	assert.Equal(t, "key", headers.Get("X-Gateway-Key"))
+
+	ctx := WithHeaders(context.Background(), map[string]string{"X-Tenant": "acme", "X-Request-ID": "1"})
+	ctx = WithHeaders(ctx, map[string]string{"X-Request-ID": "2", "Content-Encoding": "identity"})
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", headers.Get("X-Tenant"))
+	assert.Equal(t, "2", headers.Get("X-Request-ID"))
+	assert.Equal(t, "key", headers.Get("X-Gateway-Key"))
+	// headers of the driver are not overridden
+	assert.Equal(t, "gzip", headers.Get("Content-Encoding"))
+}
+
+func TestQuotaKey(t *testing.T) {
+	var quotaKeys []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		quotaKeys = append(quotaKeys, r.Header.Get("X-ClickHouse-Quota"))
+		assert.Empty(t, r.URL.Query().Get("quota_key"))
+	})
+	defer ts.Close()
+
+	cfg, err := ParseDSN(dsn + "?quota_key=service")
+	require.NoError(t, err)
+	assert.Equal(t, "service", cfg.QuotaKey)
+	assert.Empty(t, cfg.Params)
+	assert.Contains(t, cfg.FormatDSN(), "quota_key=service")
+
+	db := sql.OpenDB(NewConnector(cfg))
+	defer db.Close()
+	_, err = db.Exec("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	_, err = db.ExecContext(WithQuotaKey(context.Background(), "customer"), "INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"service", "customer"}, quotaKeys)
+}
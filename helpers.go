@@ -11,8 +11,13 @@ var (
 	escaper    = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
 	dateFormat = "2006-01-02"
 	timeFormat = "2006-01-02 15:04:05"
+	zeroDate   = "0000-00-00"
+	zeroTime   = "0000-00-00 00:00:00"
 )
 
+// maxDateTime64Precision is the maximum precision of DateTime64, nanoseconds
+const maxDateTime64Precision = 9
+
 func escape(s string) string {
 	return escaper.Replace(s)
 }
@@ -21,11 +26,60 @@ func quote(s string) string {
 	return "'" + s + "'"
 }
 
+var identifierEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+
+// QuoteIdentifier quotes the name of a database, a table or a column with
+// backticks, e.g. for building DDL, quoting the backslashes and backticks
+// of the name
+func QuoteIdentifier(name string) string {
+	return "`" + identifierEscaper.Replace(name) + "`"
+}
+
+// EscapeString escapes the backslashes and quotes of s for a string literal
+// in single quotes
+func EscapeString(s string) string {
+	return escape(s)
+}
+
+// FormatValue formats v as a SQL literal exactly as the driver interpolates
+// arguments into queries, e.g. strings are quoted, slices are arrays and
+// []byte values are raw SQL
+func FormatValue(v interface{}) (string, error) {
+	dv, err := converter{}.ConvertValue(v)
+	if err != nil {
+		return "", err
+	}
+	b, err := textEncode.Encode(dv)
+	return string(b), err
+}
+
 func formatTime(value time.Time) string {
+	if value.IsZero() {
+		return quote(zeroTime)
+	}
 	return quote(value.Format(timeFormat))
 }
 
+// dateTime64Format returns the format of DateTime64 values with precision
+// digits of the fractional part
+func dateTime64Format(precision int) string {
+	if precision == 0 {
+		return timeFormat
+	}
+	return timeFormat + "." + strings.Repeat("0", precision)
+}
+
+func formatDateTime64(value time.Time, precision int) string {
+	if value.IsZero() {
+		return quote(zeroTime + dateTime64Format(precision)[len(timeFormat):])
+	}
+	return quote(value.Format(dateTime64Format(precision)))
+}
+
 func formatDate(value time.Time) string {
+	if value.IsZero() {
+		return quote(zeroDate)
+	}
 	return quote(value.Format(dateFormat))
 }
 
@@ -0,0 +1,40 @@
+package clickhouse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuoteIdentifier(t *testing.T) {
+	assert.Equal(t, "`events`", QuoteIdentifier("events"))
+	assert.Equal(t, "`my table`", QuoteIdentifier("my table"))
+	assert.Equal(t, "`a\\`b\\\\c`", QuoteIdentifier("a`b\\c"))
+	assert.Equal(t, `it\'s a \\ test`, EscapeString(`it's a \ test`))
+}
+
+func TestFormatValue(t *testing.T) {
+	testCases := []struct {
+		value    interface{}
+		expected string
+	}{
+		{"it's", `'it\'s'`},
+		{42, "42"},
+		{nil, "NULL"},
+		{[]string{"a", "b'"}, `['a','b\'']`},
+		{map[string]int{"k": 1}, "{'k':1}"},
+		{time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC), "'2021-02-03 04:05:06'"},
+		{Date(time.Date(2021, 2, 3, 0, 0, 0, 0, time.UTC)), "'2021-02-03'"},
+		{[]byte("now()"), "now()"},
+		{uint64(1 << 63), "9223372036854775808"},
+	}
+	for _, tc := range testCases {
+		s, err := FormatValue(tc.value)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, s)
+	}
+	_, err := FormatValue(Date(time.Date(1800, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Error(t, err)
+}
@@ -0,0 +1,321 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Strategies of choosing a host of a multi-host DSN for a new connection
+const (
+	// HostStrategyInOrder chooses the first healthy host, it is the default
+	HostStrategyInOrder = "in_order"
+	// HostStrategyRoundRobin chooses the healthy hosts in turn
+	HostStrategyRoundRobin = "round_robin"
+	// HostStrategyRandom chooses a random healthy host
+	HostStrategyRandom = "random"
+)
+
+// hostDownTimeout is how long a host which failed to accept a connection is
+// tried only after the healthy hosts if Config.HostCooldown is not set
+const hostDownTimeout = 30 * time.Second
+
+// HostStatus is the state of a host of a multi-host DSN
+type HostStatus struct {
+	Host string
+	// Read is true for the hosts of Config.ReadHosts
+	Read bool
+	// Healthy is false while the host is cooling down after a failure, such
+	// hosts are tried only after the healthy ones
+	Healthy   bool
+	DownUntil time.Time
+	// LastCheck is the time of the last health check, it is zero if the
+	// health checks are disabled
+	LastCheck time.Time
+	// LastError is the error of the last failed health check or connection,
+	// it is nil once the host is healthy again
+	LastError error
+}
+
+// Hosts returns the states of the hosts used by the connections of db in
+// the order of the DSN followed by the read hosts, a single host is always
+// healthy
+func Hosts(ctx context.Context, db *sql.DB) ([]HostStatus, error) {
+	sqlConn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer sqlConn.Close()
+	var statuses []HostStatus
+	err = sqlConn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*conn)
+		if !ok {
+			return errNotClickHouseConn
+		}
+		if c.hosts == nil {
+			statuses = []HostStatus{{Host: c.url.Host, Healthy: true}}
+		} else {
+			statuses = c.hosts.statuses()
+		}
+		if c.readHosts == nil && len(c.readHost) > 0 {
+			statuses = append(statuses, HostStatus{Host: c.readHost, Read: true, Healthy: true})
+		} else if c.readHosts != nil {
+			for _, status := range c.readHosts.statuses() {
+				status.Read = true
+				statuses = append(statuses, status)
+			}
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// WithStickyHost returns a copy of ctx which pins all queries executed with
+// it to the host of the first successful query, so reads see the writes of
+// the previous queries made with the same context in multi-host setups.
+func WithStickyHost(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stickyHostKey, new(stickyHost))
+}
+
+type stickyHost struct {
+	mu   sync.Mutex
+	host string
+}
+
+// get returns the pinned host, h may be nil
+func (h *stickyHost) get() string {
+	if h == nil {
+		return ""
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.host
+}
+
+// pin pins the host unless another host has been pinned already, h may be nil
+func (h *stickyHost) pin(host string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	if len(h.host) == 0 {
+		h.host = host
+	}
+	h.mu.Unlock()
+}
+
+// hostPools shares the state of the hosts between connections of the same DSN
+var hostPools sync.Map
+
+// hostPool chooses hosts of a multi-host DSN and tracks the failed ones
+type hostPool struct {
+	strategy string
+	hosts    []string
+	cooldown time.Duration
+	interval time.Duration
+	scheme   string
+	client   *http.Client
+
+	mu     sync.Mutex
+	next   int
+	down   map[string]time.Time
+	errs   map[string]error
+	checks map[string]time.Time
+	refs   int
+	stop   chan struct{}
+}
+
+// getHostPool returns the pool of the hosts, it is nil for a single host
+func getHostPool(hosts []string, cfg *Config) *hostPool {
+	if len(hosts) < 2 {
+		return nil
+	}
+	cooldown := orDuration(cfg.HostCooldown, hostDownTimeout)
+	key := fmt.Sprintf("%s|%s|%s|%s|%s", cfg.HostStrategy, cooldown, cfg.HealthCheckInterval, cfg.Scheme, strings.Join(hosts, ","))
+	p, _ := hostPools.LoadOrStore(key, &hostPool{
+		strategy: cfg.HostStrategy,
+		hosts:    hosts,
+		cooldown: cooldown,
+		interval: cfg.HealthCheckInterval,
+		scheme:   cfg.Scheme,
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext:     cfg.dialer(),
+				TLSClientConfig: getTLSConfigClone(cfg.TLSConfig),
+			},
+		},
+		down:   make(map[string]time.Time),
+		errs:   make(map[string]error),
+		checks: make(map[string]time.Time),
+	})
+	return p.(*hostPool)
+}
+
+// acquire registers a connection which uses the pool, the health checks run
+// while the pool has connections
+func (p *hostPool) acquire() {
+	if p == nil || p.interval <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refs++
+	if p.refs == 1 {
+		p.stop = make(chan struct{})
+		go p.checkHealth(p.stop)
+	}
+}
+
+// release unregisters a closed connection
+func (p *hostPool) release() {
+	if p == nil || p.interval <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refs--
+	if p.refs == 0 {
+		close(p.stop)
+	}
+}
+
+// checkHealth pings the hosts every interval until stop is closed, the hosts
+// which fail are marked down and the hosts which respond are marked up
+func (p *hostPool) checkHealth(stop <-chan struct{}) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for _, host := range p.hosts {
+				p.check(host)
+			}
+		}
+	}
+}
+
+// check sends GET /ping to the host
+func (p *hostPool) check(host string) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.interval)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, p.scheme+"://"+host+"/ping", nil)
+	if err != nil {
+		return
+	}
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err == nil {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("clickhouse: ping of %s failed with status %s", host, resp.Status)
+		}
+	}
+	p.mu.Lock()
+	p.checks[host] = time.Now()
+	p.mu.Unlock()
+	if err != nil {
+		p.markDown(host, err)
+	} else {
+		p.markUp(host)
+	}
+}
+
+// statuses returns the states of the hosts
+func (p *hostPool) statuses() []HostStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	statuses := make([]HostStatus, len(p.hosts))
+	for i, host := range p.hosts {
+		until, down := p.down[host]
+		statuses[i] = HostStatus{
+			Host:      host,
+			Healthy:   !down || !now.Before(until),
+			LastCheck: p.checks[host],
+		}
+		if !statuses[i].Healthy {
+			statuses[i].DownUntil = until
+			statuses[i].LastError = p.errs[host]
+		}
+	}
+	return statuses
+}
+
+// order returns the hosts in the order they should be tried: the healthy
+// hosts ordered by the strategy, then the failed ones
+func (p *hostPool) order() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var start int
+	switch p.strategy {
+	case HostStrategyRoundRobin:
+		start = p.next
+		p.next = (p.next + 1) % len(p.hosts)
+	case HostStrategyRandom:
+		start = rand.Intn(len(p.hosts))
+	}
+	now := time.Now()
+	healthy := make([]string, 0, len(p.hosts))
+	var failed []string
+	for i := range p.hosts {
+		host := p.hosts[(start+i)%len(p.hosts)]
+		if until, ok := p.down[host]; ok && now.Before(until) {
+			failed = append(failed, host)
+		} else {
+			healthy = append(healthy, host)
+		}
+	}
+	return append(healthy, failed...)
+}
+
+// markDown makes the host to be tried after the healthy ones for the
+// cooldown
+func (p *hostPool) markDown(host string, err error) {
+	p.mu.Lock()
+	p.down[host] = time.Now().Add(p.cooldown)
+	p.errs[host] = err
+	p.mu.Unlock()
+}
+
+// markUp marks the host as healthy
+func (p *hostPool) markUp(host string) {
+	p.mu.Lock()
+	delete(p.down, host)
+	delete(p.errs, host)
+	p.mu.Unlock()
+}
+
+// shouldLeave reports whether the host is down while another host of the
+// pool is healthy
+func (p *hostPool) shouldLeave(host string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if until, ok := p.down[host]; !ok || !now.Before(until) {
+		return false
+	}
+	for _, h := range p.hosts {
+		if until, ok := p.down[h]; !ok || !now.Before(until) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDialError reports whether the request failed to connect to the host,
+// so it has not been sent and can be retried with another host
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
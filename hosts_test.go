@@ -0,0 +1,237 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStickyHost(t *testing.T) {
+	var hits1, hits2 int
+	ts1, dsn1 := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		hits1++
+	})
+	defer ts1.Close()
+	ts2, _ := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		hits2++
+	})
+	defer ts2.Close()
+
+	cfg, err := ParseDSN(dsn1)
+	require.NoError(t, err)
+	cn := newConn(cfg)
+	ctx := WithStickyHost(context.Background())
+	_, err = cn.exec(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, ts1.Listener.Addr().String(), ctx.Value(stickyHostKey).(*stickyHost).get())
+
+	// the first pinned host is kept
+	ctx.Value(stickyHostKey).(*stickyHost).pin(ts2.Listener.Addr().String())
+	_, err = cn.exec(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, hits1)
+	assert.Equal(t, 0, hits2)
+
+	ctx = context.WithValue(context.Background(), stickyHostKey, &stickyHost{host: ts2.Listener.Addr().String()})
+	_, err = cn.exec(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, hits2)
+}
+
+func TestHostFailover(t *testing.T) {
+	var hits int
+	ts, _ := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		hits++
+	})
+	defer ts.Close()
+	down, _ := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {})
+	downAddr := down.Listener.Addr().String()
+	down.Close()
+
+	hosts := downAddr + "," + ts.Listener.Addr().String()
+	cfg, err := ParseDSN("http://user@" + hosts + "/default?host_strategy=in_order")
+	require.NoError(t, err)
+	assert.Equal(t, hosts, cfg.Host)
+	assert.Equal(t, "user", cfg.User)
+	assert.Contains(t, cfg.FormatDSN(), "http://user@"+hosts+"/default?")
+
+	cn := newConn(cfg)
+	assert.Equal(t, downAddr, cn.url.Host)
+	_, err = cn.exec(context.Background(), "INSERT INTO t VALUES (1)", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, hits)
+	assert.Equal(t, ts.Listener.Addr().String(), cn.url.Host)
+
+	// new connections skip the failed host
+	assert.Equal(t, ts.Listener.Addr().String(), newConn(cfg).url.Host)
+}
+
+func TestReadHosts(t *testing.T) {
+	var writes, reads []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		writes = append(writes, query)
+		w.Write([]byte("a\nUInt8\n1\n"))
+	})
+	defer ts.Close()
+	read, _ := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		reads = append(reads, query)
+		w.Write([]byte("a\nUInt8\n2\n"))
+	})
+	defer read.Close()
+	down, _ := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {})
+	downAddr := down.Listener.Addr().String()
+	down.Close()
+
+	readHosts := downAddr + "," + read.Listener.Addr().String()
+	cfg, err := ParseDSN(dsn + "?read_hosts=" + readHosts)
+	require.NoError(t, err)
+	assert.Equal(t, readHosts, cfg.ReadHosts)
+	assert.Contains(t, cfg.FormatDSN(), "read_hosts="+url.QueryEscape(readHosts))
+	db := sql.OpenDB(NewConnector(cfg))
+	defer db.Close()
+
+	_, err = db.Exec("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	var v int
+	// the read fails over to the other read host
+	require.NoError(t, db.QueryRow("SELECT a").Scan(&v))
+	assert.Equal(t, 2, v)
+	assert.Equal(t, []string{"INSERT INTO t VALUES (1)"}, writes)
+	assert.Equal(t, []string{"SELECT a"}, reads)
+
+	statuses, err := Hosts(context.Background(), db)
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	assert.Equal(t, HostStatus{Host: ts.Listener.Addr().String(), Healthy: true}, statuses[0])
+	assert.True(t, statuses[1].Read)
+	assert.False(t, statuses[1].Healthy)
+	assert.Equal(t, HostStatus{Host: read.Listener.Addr().String(), Read: true, Healthy: true}, statuses[2])
+
+	// the reads of a session stay on its server
+	cfg.SessionID = "s"
+	db2 := sql.OpenDB(NewConnector(cfg))
+	defer db2.Close()
+	require.NoError(t, db2.QueryRow("SELECT a").Scan(&v))
+	assert.Equal(t, 1, v)
+}
+
+func TestHostPoolOrder(t *testing.T) {
+	_, err := ParseDSN("http://a,b/default?host_strategy=unknown")
+	assert.Error(t, err)
+
+	p := getHostPool([]string{"a:8123", "b:8123", "c:8123"}, &Config{HostStrategy: HostStrategyRoundRobin})
+	assert.Equal(t, []string{"a:8123", "b:8123", "c:8123"}, p.order())
+	assert.Equal(t, []string{"b:8123", "c:8123", "a:8123"}, p.order())
+	p.markDown("c:8123", nil)
+	assert.Equal(t, []string{"a:8123", "b:8123", "c:8123"}, p.order())
+	assert.Equal(t, []string{"a:8123", "b:8123", "c:8123"}, p.order())
+	p.markUp("c:8123")
+	assert.Equal(t, []string{"b:8123", "c:8123", "a:8123"}, p.order())
+
+	assert.Nil(t, getHostPool([]string{"a:8123"}, &Config{HostStrategy: HostStrategyRoundRobin}))
+	assert.ElementsMatch(t, []string{"a:8123", "b:8123"}, getHostPool([]string{"a:8123", "b:8123"}, &Config{HostStrategy: HostStrategyRandom}).order())
+}
+
+func TestHealthCheck(t *testing.T) {
+	ok, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		w.Write([]byte("Ok.\n"))
+	})
+	defer ok.Close()
+	failing, _ := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		if r.URL.Path == "/ping" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	defer failing.Close()
+	okAddr, failingAddr := ok.Listener.Addr().String(), failing.Listener.Addr().String()
+
+	cfg, err := ParseDSN(dsn + "?health_check_interval=10ms&host_cooldown=1m")
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Millisecond, cfg.HealthCheckInterval)
+	assert.Contains(t, cfg.FormatDSN(), "host_cooldown=1m0s")
+	cfg.Host = failingAddr + "," + okAddr
+	db := sql.OpenDB(NewConnector(cfg))
+
+	var statuses []HostStatus
+	for i := 0; i < 200; i++ {
+		statuses, err = Hosts(context.Background(), db)
+		require.NoError(t, err)
+		if !statuses[0].Healthy {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Len(t, statuses, 2)
+	assert.Equal(t, failingAddr, statuses[0].Host)
+	assert.False(t, statuses[0].Healthy)
+	assert.EqualError(t, statuses[0].LastError, "clickhouse: ping of "+failingAddr+" failed with status 503 Service Unavailable")
+	assert.True(t, statuses[0].DownUntil.After(time.Now().Add(50*time.Second)))
+	assert.Equal(t, okAddr, statuses[1].Host)
+	assert.True(t, statuses[1].Healthy)
+	assert.False(t, statuses[1].LastCheck.IsZero())
+
+	// new connections use the healthy host
+	p := getHostPool(cfg.hosts(), cfg)
+	assert.Equal(t, []string{okAddr, failingAddr}, p.order())
+
+	// the checks stop with the last connection
+	require.NoError(t, db.Close())
+	p.mu.Lock()
+	assert.Equal(t, 0, p.refs)
+	p.mu.Unlock()
+
+	single, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer single.Close()
+	statuses, err = Hosts(context.Background(), single)
+	require.NoError(t, err)
+	assert.Equal(t, []HostStatus{{Host: okAddr, Healthy: true}}, statuses)
+}
+
+func TestHealthCheckRecovery(t *testing.T) {
+	var pings int32
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		if r.URL.Path == "/ping" && atomic.AddInt32(&pings, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("Ok.\n"))
+	})
+	defer ts.Close()
+	other, _ := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		w.Write([]byte("Ok.\n"))
+	})
+	defer other.Close()
+	addr := ts.Listener.Addr().String()
+
+	cfg, err := ParseDSN(dsn + "?health_check_interval=10ms&host_cooldown=1m")
+	require.NoError(t, err)
+	cfg.Host = addr + "," + other.Listener.Addr().String()
+	db := sql.OpenDB(NewConnector(cfg))
+	defer db.Close()
+
+	// the host fails the first ping and passes the next ones
+	var statuses []HostStatus
+	for i := 0; i < 200; i++ {
+		statuses, err = Hosts(context.Background(), db)
+		require.NoError(t, err)
+		if atomic.LoadInt32(&pings) > 1 && statuses[0].Healthy {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, atomic.LoadInt32(&pings) > 1)
+	assert.Equal(t, addr, statuses[0].Host)
+	assert.True(t, statuses[0].Healthy)
+	assert.NoError(t, statuses[0].LastError)
+	assert.True(t, statuses[0].DownUntil.IsZero())
+	p := getHostPool(cfg.hosts(), cfg)
+	assert.False(t, p.shouldLeave(addr))
+}
@@ -0,0 +1,59 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// JSON wraps v to pass it as a JSON document to JSON and Object('json')
+// columns, v is marshaled with encoding/json. Values implementing
+// json.Marshaler are passed as JSON documents without the wrapper.
+func JSON(v interface{}) driver.Valuer {
+	return jsonValue{v}
+}
+
+type jsonValue struct {
+	v interface{}
+}
+
+// Value implements driver.Valuer
+func (j jsonValue) Value() (driver.Value, error) {
+	b, err := json.Marshal(j.v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// ScanJSON returns a sql.Scanner which unmarshals a JSON or Object('json')
+// column into dest with encoding/json, e.g. into a *map[string]interface{},
+// a pointer to a struct or a *json.RawMessage. JSON columns can also be
+// scanned into strings directly.
+func ScanJSON(dest interface{}) sql.Scanner {
+	return &jsonScanner{dest: dest}
+}
+
+type jsonScanner struct {
+	dest interface{}
+}
+
+// Scan implements the sql.Scanner
+func (s *jsonScanner) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		data = []byte("null")
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("clickhouse: can not scan %T as JSON", src)
+	}
+	if err := json.Unmarshal(data, s.dest); err != nil {
+		return fmt.Errorf("clickhouse: can not scan JSON: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSON(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		if r.Method == http.MethodGet {
+			w.Write([]byte("j\to\tv\td\nJSON\tObject(\\'json\\')\tVariant(String, UInt64)\tDynamic\n" +
+				"{\"a\":1,\"b\":\"it\\'s\"}\t{}\t42\t\\N\n"))
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var (
+		m   map[string]interface{}
+		raw json.RawMessage
+		v   string
+		d   sql.NullString
+	)
+	require.NoError(t, db.QueryRow("SELECT j, o, v, d").Scan(ScanJSON(&m), ScanJSON(&raw), &v, &d))
+	assert.Equal(t, map[string]interface{}{"a": 1.0, "b": "it's"}, m)
+	assert.Equal(t, json.RawMessage("{}"), raw)
+	assert.Equal(t, "42", v)
+	assert.False(t, d.Valid)
+
+	var doc struct {
+		A int `json:"a"`
+	}
+	require.NoError(t, db.QueryRow("SELECT j, o, v, d").Scan(ScanJSON(&doc), new(string), new(int), new(interface{})))
+	assert.Equal(t, 1, doc.A)
+	assert.Error(t, db.QueryRow("SELECT j, o, v, d").Scan(ScanJSON(&doc), new(int), new(int), new(interface{})))
+
+	_, err = db.Exec("INSERT INTO t VALUES (?, ?)", JSON(map[string]interface{}{"k": "it's"}), json.RawMessage(`{"n":1}`))
+	require.NoError(t, err)
+	assert.Equal(t, `INSERT INTO t VALUES ('{"k":"it\'s"}', '{"n":1}')`, queries[len(queries)-1])
+}
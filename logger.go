@@ -0,0 +1,66 @@
+package clickhouse
+
+import (
+	"log"
+	"net/url"
+	"os"
+)
+
+// Logger receives the log messages of the driver, e.g. an adapter of a
+// structured logging library. Its methods are called concurrently by the
+// connections of the pool.
+type Logger interface {
+	// Debugf logs connections, statements and requests
+	Debugf(format string, args ...interface{})
+	// Infof logs cancellations of the queries
+	Infof(format string, args ...interface{})
+	// Warnf logs retries and failovers of the requests
+	Warnf(format string, args ...interface{})
+	// Errorf logs failed requests
+	Errorf(format string, args ...interface{})
+}
+
+// stdLogger is the logger used if Config.Debug is set and Config.Logger is not
+type stdLogger struct {
+	*log.Logger
+}
+
+func newStdLogger() Logger {
+	return stdLogger{log.New(os.Stderr, "clickhouse: ", log.LstdFlags)}
+}
+
+func (l stdLogger) Debugf(format string, args ...interface{}) { l.Printf(format, args...) }
+func (l stdLogger) Infof(format string, args ...interface{})  { l.Printf(format, args...) }
+func (l stdLogger) Warnf(format string, args ...interface{})  { l.Printf(format, args...) }
+func (l stdLogger) Errorf(format string, args ...interface{}) { l.Printf(format, args...) }
+
+// logger returns the logger of the config, nil if nothing is logged
+func (cfg *Config) logger() Logger {
+	if cfg.Logger != nil {
+		return cfg.Logger
+	}
+	if cfg.Debug {
+		return newStdLogger()
+	}
+	return nil
+}
+
+// redactedParams are the parameters of the URL which are not logged
+var redactedParams = []string{"user", "password", "quota_key"}
+
+// redactURL returns the URL with the credentials replaced by "xxxxx"
+func redactURL(u *url.URL) string {
+	redacted := *u
+	query := redacted.Query()
+	changed := false
+	for _, name := range redactedParams {
+		if _, ok := query[name]; ok {
+			query.Set(name, "xxxxx")
+			changed = true
+		}
+	}
+	if changed {
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.Redacted()
+}
@@ -0,0 +1,64 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+)
+
+// ScanMap returns a sql.Scanner which scans a Map column into dest, which
+// must be a pointer to a map. Keys and values are converted to the types of
+// dest, e.g. Map(String, UInt8) can be scanned into map[string]interface{}
+// or map[string]int. A Map column can also be scanned directly into a map of
+// the exact type, like map[string]uint8.
+func ScanMap(dest interface{}) sql.Scanner {
+	return &mapScanner{dest: dest}
+}
+
+type mapScanner struct {
+	dest interface{}
+}
+
+// Scan implements the sql.Scanner
+func (s *mapScanner) Scan(src interface{}) error {
+	dv := reflect.ValueOf(s.dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() || dv.Elem().Kind() != reflect.Map {
+		return fmt.Errorf("clickhouse: expected pointer to map, got %T", s.dest)
+	}
+	dv = dv.Elem()
+	if src == nil {
+		dv.Set(reflect.Zero(dv.Type()))
+		return nil
+	}
+	sv := reflect.ValueOf(src)
+	if sv.Kind() != reflect.Map {
+		return fmt.Errorf("clickhouse: can not scan %T as Map", src)
+	}
+	m := reflect.MakeMapWithSize(dv.Type(), sv.Len())
+	iter := sv.MapRange()
+	for iter.Next() {
+		k, err := convertMapElem(iter.Key(), dv.Type().Key())
+		if err != nil {
+			return err
+		}
+		v, err := convertMapElem(iter.Value(), dv.Type().Elem())
+		if err != nil {
+			return err
+		}
+		m.SetMapIndex(k, v)
+	}
+	dv.Set(m)
+	return nil
+}
+
+func convertMapElem(v reflect.Value, t reflect.Type) (reflect.Value, error) {
+	switch {
+	case v.Type().AssignableTo(t):
+		r := reflect.New(t).Elem()
+		r.Set(v)
+		return r, nil
+	case v.Type().ConvertibleTo(t) && (t.Kind() != reflect.String || v.Kind() == reflect.String):
+		return v.Convert(t), nil
+	}
+	return reflect.Value{}, fmt.Errorf("clickhouse: can not convert %s to %s", v.Type(), t)
+}
@@ -0,0 +1,40 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMap(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		if r.Method == http.MethodGet {
+			w.Write([]byte("m\nMap(String, UInt8)\n{'a':1,'it\\'s':2}\n"))
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var exact map[string]uint8
+	require.NoError(t, db.QueryRow("SELECT m").Scan(&exact))
+	assert.Equal(t, map[string]uint8{"a": 1, "it's": 2}, exact)
+	var generic map[string]interface{}
+	require.NoError(t, db.QueryRow("SELECT m").Scan(ScanMap(&generic)))
+	assert.Equal(t, map[string]interface{}{"a": uint8(1), "it's": uint8(2)}, generic)
+	var converted map[string]int
+	require.NoError(t, db.QueryRow("SELECT m").Scan(ScanMap(&converted)))
+	assert.Equal(t, map[string]int{"a": 1, "it's": 2}, converted)
+	assert.Error(t, db.QueryRow("SELECT m").Scan(ScanMap(&queries)))
+
+	_, err = db.Exec("INSERT INTO t VALUES (?, ?)", map[string]int{"b": 2, "a's": 1}, map[int][]string{1: {"x"}})
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO t VALUES ({'a\\'s':1,'b':2}, {1:['x']})", queries[len(queries)-1])
+}
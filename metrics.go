@@ -0,0 +1,48 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SystemMetrics returns the current values of system.metrics by metric names
+func SystemMetrics(ctx context.Context, db *sql.DB) (map[string]int64, error) {
+	rows, err := db.QueryContext(ctx, "SELECT metric, toInt64(value) FROM system.metrics")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	metrics := make(map[string]int64)
+	for rows.Next() {
+		var (
+			name  string
+			value int64
+		)
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		metrics[name] = value
+	}
+	return metrics, rows.Err()
+}
+
+// AsyncMetrics returns the values of system.asynchronous_metrics by metric names
+func AsyncMetrics(ctx context.Context, db *sql.DB) (map[string]float64, error) {
+	rows, err := db.QueryContext(ctx, "SELECT metric, toFloat64(value) FROM system.asynchronous_metrics")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	metrics := make(map[string]float64)
+	for rows.Next() {
+		var (
+			name  string
+			value float64
+		)
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		metrics[name] = value
+	}
+	return metrics, rows.Err()
+}
@@ -0,0 +1,40 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystemMetrics(t *testing.T) {
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		switch {
+		case strings.Contains(query, "system.metrics"):
+			io.WriteString(w, "metric\tvalue\nString\tInt64\nQuery\t3\nMerge\t0\n")
+		case strings.Contains(query, "system.asynchronous_metrics"):
+			io.WriteString(w, "metric\tvalue\nString\tFloat64\nUptime\t120.5\n")
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	metrics, err := SystemMetrics(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"Query": 3, "Merge": 0}, metrics)
+
+	asyncMetrics, err := AsyncMetrics(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"Uptime": 120.5}, asyncMetrics)
+}
@@ -0,0 +1,381 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WithFinal returns a context which makes the driver add the FINAL modifier
+// to every table of the FROM and JOIN clauses of SELECT queries, so rows of
+// ReplacingMergeTree (and other collapsing engines) tables are deduplicated.
+// Tables which already have FINAL, subqueries and table functions are
+// left intact, queries other than SELECT are sent unchanged.
+func WithFinal(ctx context.Context) context.Context {
+	return context.WithValue(ctx, finalKey, true)
+}
+
+// WithSample returns a context which makes the driver add SAMPLE ratio to the
+// first table of the FROM clause of the main query of SELECT queries, which
+// may be in its subquery or common table expression. Tables of JOIN clauses
+// and subqueries of conditions, e.g. IN (SELECT ...), are not sampled. The ratio must be in (0, 1], otherwise queries fail with
+// ErrSampleRatio. Tables which already have SAMPLE are left intact.
+func WithSample(ctx context.Context, ratio float64) context.Context {
+	return context.WithValue(ctx, sampleKey, sampleOption{ratio: ratio})
+}
+
+// WithSampleAll is like WithSample, but samples every table of the FROM
+// and JOIN clauses.
+func WithSampleAll(ctx context.Context, ratio float64) context.Context {
+	return context.WithValue(ctx, sampleKey, sampleOption{ratio: ratio, all: true})
+}
+
+// WithRowFilter returns a context which makes the driver replace the table in
+// FROM and JOIN clauses of SELECT queries with a subquery which selects only
+// the rows matching the condition:
+//
+//	SELECT ... FROM (SELECT * FROM table WHERE condition) AS table
+//
+// A table name without a database matches the table in any database. The
+// condition must be a plain expression, queries fail with ErrUnsafeRowFilter
+// if it contains subqueries, comments, semicolons or unbalanced parentheses.
+func WithRowFilter(ctx context.Context, table, condition string) context.Context {
+	parent, _ := ctx.Value(rowFilterKey).([]rowFilter)
+	filters := make([]rowFilter, len(parent), len(parent)+1)
+	copy(filters, parent)
+	return context.WithValue(ctx, rowFilterKey, append(filters, rowFilter{table: table, condition: condition}))
+}
+
+type rowFilter struct {
+	table     string
+	condition string
+}
+
+// addRowFilters replaces the filtered tables of the SELECT query with subqueries
+func addRowFilters(query string, filters []rowFilter) (string, error) {
+	names := make([]string, len(filters))
+	for i, f := range filters {
+		if err := checkRowFilter(f.condition); err != nil {
+			return "", err
+		}
+		words, err := splitSQL(f.table)
+		if err != nil {
+			return "", err
+		}
+		names[i] = tableName(words)
+	}
+	words, err := splitSQL(query)
+	if err != nil {
+		return "", err
+	}
+	var (
+		b    strings.Builder
+		prev int
+	)
+	for _, t := range findTables(words, false) {
+		name := tableName(t.name)
+		var conditions []string
+		for i, f := range filters {
+			if name == names[i] || !strings.Contains(names[i], ".") && strings.HasSuffix(name, "."+names[i]) {
+				conditions = append(conditions, "("+f.condition+")")
+			}
+		}
+		if len(conditions) == 0 {
+			continue
+		}
+		if t.sample {
+			return "", fmt.Errorf("clickhouse: row filter can not be applied to the sampled table %s", name)
+		}
+		table := query[t.start:t.end]
+		if t.final {
+			table += " FINAL"
+		}
+		alias := strings.TrimSpace(query[t.end:t.aliasEnd])
+		if !t.alias {
+			last := t.name[len(t.name)-1].text
+			if i := strings.LastIndexByte(last, '.'); i >= 0 && !t.name[len(t.name)-1].quoted {
+				last = last[i+1:]
+			}
+			alias = "AS " + last
+		}
+		b.WriteString(query[prev:t.start])
+		b.WriteString("(SELECT * FROM " + table + " WHERE " + strings.Join(conditions, " AND ") + ") " + alias)
+		prev = t.finalEnd
+	}
+	if prev == 0 {
+		return query, nil
+	}
+	b.WriteString(query[prev:])
+	return b.String(), nil
+}
+
+// tableName returns the name of a table with unquoted parts
+func tableName(words []sqlWord) string {
+	var b strings.Builder
+	for _, w := range words {
+		if w.quoted {
+			b.WriteString(w.text[1 : len(w.text)-1])
+		} else {
+			b.WriteString(w.text)
+		}
+	}
+	return b.String()
+}
+
+// checkRowFilter checks that the condition of a row filter is a plain expression
+func checkRowFilter(condition string) error {
+	words, err := splitSQL(condition)
+	if err != nil || len(words) == 0 {
+		return ErrUnsafeRowFilter
+	}
+	depth, prev := 0, 0
+	for _, w := range words {
+		if strings.TrimSpace(condition[prev:w.start]) != "" {
+			// comment
+			return ErrUnsafeRowFilter
+		}
+		prev = w.end
+		switch {
+		case w.is("SELECT", "WITH"), w.text == ";" && !w.quoted:
+			return ErrUnsafeRowFilter
+		case w.text == "(" && !w.quoted:
+			depth++
+		case w.text == ")" && !w.quoted:
+			if depth--; depth < 0 {
+				return ErrUnsafeRowFilter
+			}
+		}
+	}
+	if depth != 0 || strings.TrimSpace(condition[prev:]) != "" {
+		return ErrUnsafeRowFilter
+	}
+	return nil
+}
+
+type sampleOption struct {
+	ratio float64
+	all   bool
+}
+
+// addSample adds SAMPLE after table names of the SELECT query
+func addSample(query string, opt sampleOption) (string, error) {
+	if !(opt.ratio > 0 && opt.ratio <= 1) {
+		return "", ErrSampleRatio
+	}
+	return addTableModifier(query, "SAMPLE "+strconv.FormatFloat(opt.ratio, 'f', -1, 64), !opt.all)
+}
+
+// notAliases are keywords which may follow a table name and can not be its alias
+var notAliases = []string{
+	"FINAL", "SAMPLE", "PREWHERE", "WHERE", "GROUP", "HAVING", "ORDER", "LIMIT",
+	"OFFSET", "SETTINGS", "FORMAT", "UNION", "INTERSECT", "EXCEPT", "WINDOW",
+	"QUALIFY", "ON", "USING", "JOIN", "ARRAY", "GLOBAL", "LOCAL", "ANY", "ALL",
+	"ASOF", "SEMI", "ANTI", "INNER", "OUTER", "LEFT", "RIGHT", "FULL", "CROSS",
+	"PASTE", "INTO",
+}
+
+// addFinal adds FINAL after table names of the SELECT query
+func addFinal(query string) (string, error) {
+	return addTableModifier(query, "FINAL", false)
+}
+
+// addTableModifier adds the FINAL or SAMPLE modifier after table names
+// of the SELECT query. If first is set, only the first tables of FROM
+// clauses are modified.
+func addTableModifier(query, modifier string, first bool) (string, error) {
+	words, err := splitSQL(query)
+	if err != nil {
+		return "", err
+	}
+	var positions []int
+	for _, t := range findTables(words, first) {
+		switch {
+		case modifier == "FINAL" && !t.final:
+			positions = append(positions, t.aliasEnd)
+		case modifier != "FINAL" && !t.sample:
+			positions = append(positions, t.finalEnd)
+		}
+	}
+	if len(positions) == 0 {
+		return query, nil
+	}
+	var b strings.Builder
+	prev := 0
+	for _, pos := range positions {
+		b.WriteString(query[prev:pos])
+		b.WriteString(" " + modifier)
+		prev = pos
+	}
+	b.WriteString(query[prev:])
+	return b.String(), nil
+}
+
+// tableRef is a table of a FROM or JOIN clause, positions are
+// offsets in the query
+type tableRef struct {
+	name     []sqlWord
+	start    int
+	end      int
+	alias    bool
+	aliasEnd int
+	final    bool
+	finalEnd int
+	sample   bool
+}
+
+// findTables returns the tables of FROM and JOIN clauses of the SELECT query
+// including tables of subqueries, the references to the common table
+// expressions of WITH clauses are not tables. If first is set, only the first
+// table of the FROM clause of the main query is returned, the main query of
+// a subquery or a common table expression in that clause is the subquery or
+// the expression, e.g. not the subqueries of IN conditions.
+func findTables(words []sqlWord, first bool) []tableRef {
+	if len(words) == 0 || !words[0].is("SELECT", "WITH") {
+		return nil
+	}
+	return scanTables(words, first, findCTEs(words))
+}
+
+func scanTables(words []sqlWord, first bool, ctes map[string][]sqlWord) []tableRef {
+	var (
+		tables []tableRef
+		// subquery tells whether the parentheses contain a query
+		// or an expression, the top level is a query
+		subquery = []bool{true}
+		// main tells whether the parentheses contain the main query, i.e.
+		// the subquery of the FROM clause of the main query
+		main = []bool{true}
+	)
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		switch {
+		case w.text == "(" && !w.quoted:
+			subquery = append(subquery, i+1 < len(words) && words[i+1].is("SELECT", "WITH"))
+			main = append(main, main[len(main)-1] && i > 0 && words[i-1].is("FROM"))
+			continue
+		case w.text == ")" && !w.quoted:
+			if len(subquery) > 1 {
+				subquery, main = subquery[:len(subquery)-1], main[:len(main)-1]
+			}
+			continue
+		case !subquery[len(subquery)-1]:
+			// e.g. extract(YEAR FROM d)
+			continue
+		case first && !main[len(main)-1]:
+			continue
+		case w.is("FROM"):
+		case w.is("JOIN") && !(i > 0 && words[i-1].is("ARRAY")) && !first:
+		default:
+			continue
+		}
+		for {
+			end, t, ok := skipTable(words, i+1)
+			if body, cte := ctes[tableName(t.name)]; cte && len(t.name) == 1 {
+				// a reference to a common table expression
+				ok = false
+				if first {
+					tables = append(tables, scanTables(body, first, withoutCTE(ctes, tableName(t.name)))...)
+				}
+			}
+			if ok {
+				tables = append(tables, t)
+			}
+			if first || end >= len(words) || words[end].text != "," || words[end].quoted {
+				i = end - 1
+				break
+			}
+			i = end
+		}
+	}
+	return tables
+}
+
+// findCTEs returns the bodies of the common table expressions of the WITH
+// clauses of the query, name AS (SELECT ...), by their names
+func findCTEs(words []sqlWord) map[string][]sqlWord {
+	ctes := make(map[string][]sqlWord)
+	for i := 1; i+3 < len(words); i++ {
+		if !(words[i-1].is("WITH") || words[i-1].text == "," && !words[i-1].quoted) ||
+			!words[i+1].is("AS") || words[i+2].text != "(" || words[i+2].quoted || !words[i+3].is("SELECT", "WITH") {
+			continue
+		}
+		depth, end := 0, i+2
+		for ; end < len(words); end++ {
+			if words[end].quoted {
+				continue
+			}
+			if words[end].text == "(" {
+				depth++
+			} else if words[end].text == ")" {
+				if depth--; depth == 0 {
+					break
+				}
+			}
+		}
+		ctes[tableName(words[i:i+1])] = words[i+3 : end]
+	}
+	return ctes
+}
+
+// withoutCTE returns the common table expressions except the named one, the
+// expressions may refer to themselves, e.g. in WITH RECURSIVE
+func withoutCTE(ctes map[string][]sqlWord, name string) map[string][]sqlWord {
+	rest := make(map[string][]sqlWord, len(ctes))
+	for n, body := range ctes {
+		if n != name {
+			rest[n] = body
+		}
+	}
+	return rest
+}
+
+// skipTable skips a table expression starting at words[i] and returns
+// the index of the next word and the table. ok is false if the expression
+// is a subquery or a table function.
+func skipTable(words []sqlWord, i int) (next int, t tableRef, ok bool) {
+	if i >= len(words) || words[i].text == "(" && !words[i].quoted {
+		return i, t, false
+	}
+	// [db.]table, the parts can be quoted separately
+	start := i
+	i++
+	for i+1 < len(words) && words[i].text == "." && !words[i].quoted {
+		i += 2
+	}
+	if i < len(words) && words[i].text == "(" && !words[i].quoted {
+		// table function
+		return i, t, false
+	}
+	t.name = words[start:i]
+	t.start, t.end = words[start].start, words[i-1].end
+	if i+1 < len(words) && words[i].is("AS") {
+		i += 2
+		t.alias = true
+	} else if i < len(words) && isAlias(words[i]) {
+		i++
+		t.alias = true
+	}
+	t.aliasEnd = words[i-1].end
+	// FINAL precedes SAMPLE
+	if i < len(words) && words[i].is("FINAL") {
+		t.final = true
+		i++
+	}
+	t.finalEnd = words[i-1].end
+	if i < len(words) && words[i].is("SAMPLE") {
+		t.sample = true
+		i++
+	}
+	return i, t, true
+}
+
+func isAlias(w sqlWord) bool {
+	if w.quoted {
+		return w.text[0] != '\''
+	}
+	if len(w.text) == 0 || !isIdentChar(w.text[0]) {
+		return false
+	}
+	return !w.is(notAliases...)
+}
@@ -0,0 +1,191 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddFinal(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT * FROM t", "SELECT * FROM t FINAL"},
+		{"SELECT * FROM db.t WHERE a = 1", "SELECT * FROM db.t FINAL WHERE a = 1"},
+		{"SELECT * FROM `db`.`t` AS x", "SELECT * FROM `db`.`t` AS x FINAL"},
+		{"SELECT * FROM t x PREWHERE a = 1", "SELECT * FROM t x FINAL PREWHERE a = 1"},
+		{"SELECT * FROM t FINAL", "SELECT * FROM t FINAL"},
+		{
+			"SELECT * FROM a LEFT JOIN b ON a.id = b.id INNER JOIN c AS cc USING (id)",
+			"SELECT * FROM a FINAL LEFT JOIN b FINAL ON a.id = b.id INNER JOIN c AS cc FINAL USING (id)",
+		},
+		{
+			"SELECT x FROM (SELECT x FROM t) JOIN (SELECT y FROM s) USING (x)",
+			"SELECT x FROM (SELECT x FROM t FINAL) JOIN (SELECT y FROM s FINAL) USING (x)",
+		},
+		{"SELECT * FROM t ARRAY JOIN arr", "SELECT * FROM t FINAL ARRAY JOIN arr"},
+		{"SELECT extract(YEAR FROM d) FROM t", "SELECT extract(YEAR FROM d) FROM t FINAL"},
+		{"SELECT * FROM numbers(10)", "SELECT * FROM numbers(10)"},
+		{"SELECT * FROM a, b WHERE a.id = b.id", "SELECT * FROM a FINAL, b FINAL WHERE a.id = b.id"},
+		{"WITH 1 AS x SELECT x FROM t", "WITH 1 AS x SELECT x FROM t FINAL"},
+		{"WITH c AS (SELECT * FROM t) SELECT * FROM c", "WITH c AS (SELECT * FROM t FINAL) SELECT * FROM c"},
+		{
+			"WITH a AS (SELECT 1), `b` AS (SELECT * FROM a JOIN t USING (x)) SELECT * FROM b JOIN db.a USING (x)",
+			"WITH a AS (SELECT 1), `b` AS (SELECT * FROM a JOIN t FINAL USING (x)) SELECT * FROM b JOIN db.a FINAL USING (x)",
+		},
+		{"SELECT 'FROM t' FROM t -- FROM x", "SELECT 'FROM t' FROM t FINAL -- FROM x"},
+		{"INSERT INTO t SELECT * FROM s", "INSERT INTO t SELECT * FROM s"},
+		{"CREATE TABLE t AS SELECT * FROM s", "CREATE TABLE t AS SELECT * FROM s"},
+	}
+	for _, tc := range testCases {
+		actual, err := addFinal(tc.query)
+		if assert.NoError(t, err, tc.query) {
+			assert.Equal(t, tc.expected, actual)
+		}
+	}
+}
+
+func TestAddSample(t *testing.T) {
+	testCases := []struct {
+		query    string
+		opt      sampleOption
+		expected string
+	}{
+		{"SELECT * FROM t", sampleOption{ratio: 0.1}, "SELECT * FROM t SAMPLE 0.1"},
+		{"SELECT * FROM t AS x FINAL WHERE a = 1", sampleOption{ratio: 1}, "SELECT * FROM t AS x FINAL SAMPLE 1 WHERE a = 1"},
+		{"SELECT * FROM t SAMPLE 0.5", sampleOption{ratio: 0.1}, "SELECT * FROM t SAMPLE 0.5"},
+		{
+			"SELECT * FROM a LEFT JOIN b USING (id), c",
+			sampleOption{ratio: 0.25},
+			"SELECT * FROM a SAMPLE 0.25 LEFT JOIN b USING (id), c",
+		},
+		{
+			"SELECT * FROM a, b JOIN c USING (id)",
+			sampleOption{ratio: 0.25},
+			"SELECT * FROM a SAMPLE 0.25, b JOIN c USING (id)",
+		},
+		{
+			"SELECT * FROM a, b JOIN c USING (id)",
+			sampleOption{ratio: 0.25, all: true},
+			"SELECT * FROM a SAMPLE 0.25, b SAMPLE 0.25 JOIN c SAMPLE 0.25 USING (id)",
+		},
+		{"INSERT INTO t SELECT * FROM s", sampleOption{ratio: 0.1}, "INSERT INTO t SELECT * FROM s"},
+		{
+			"WITH c AS (SELECT * FROM t WHERE x IN (SELECT x FROM s)) SELECT * FROM c",
+			sampleOption{ratio: 0.1},
+			"WITH c AS (SELECT * FROM t SAMPLE 0.1 WHERE x IN (SELECT x FROM s)) SELECT * FROM c",
+		},
+		{
+			"WITH c AS (SELECT * FROM t) SELECT * FROM u JOIN c USING (x)",
+			sampleOption{ratio: 0.1},
+			"WITH c AS (SELECT * FROM t) SELECT * FROM u SAMPLE 0.1 JOIN c USING (x)",
+		},
+		{
+			"SELECT * FROM t WHERE x IN (SELECT y FROM u)",
+			sampleOption{ratio: 0.1},
+			"SELECT * FROM t SAMPLE 0.1 WHERE x IN (SELECT y FROM u)",
+		},
+		{
+			"SELECT * FROM (SELECT * FROM t WHERE x IN (SELECT y FROM u)) WHERE z IN (SELECT z FROM v)",
+			sampleOption{ratio: 0.1},
+			"SELECT * FROM (SELECT * FROM t SAMPLE 0.1 WHERE x IN (SELECT y FROM u)) WHERE z IN (SELECT z FROM v)",
+		},
+		{
+			"SELECT * FROM t WHERE x IN (SELECT y FROM u)",
+			sampleOption{ratio: 0.1, all: true},
+			"SELECT * FROM t SAMPLE 0.1 WHERE x IN (SELECT y FROM u SAMPLE 0.1)",
+		},
+	}
+	for _, tc := range testCases {
+		actual, err := addSample(tc.query, tc.opt)
+		if assert.NoError(t, err, tc.query) {
+			assert.Equal(t, tc.expected, actual)
+		}
+	}
+	for _, ratio := range []float64{0, -1, 1.5} {
+		_, err := addSample("SELECT * FROM t", sampleOption{ratio: ratio})
+		assert.Equal(t, ErrSampleRatio, err)
+	}
+
+	actual, err := addFinal("SELECT * FROM t SAMPLE 0.1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "SELECT * FROM t FINAL SAMPLE 0.1", actual)
+	}
+}
+
+func TestAddRowFilters(t *testing.T) {
+	testCases := []struct {
+		query    string
+		filters  []rowFilter
+		expected string
+	}{
+		{
+			"SELECT * FROM orders WHERE amount > 10",
+			[]rowFilter{{"orders", "tenant_id = 1"}},
+			"SELECT * FROM (SELECT * FROM orders WHERE (tenant_id = 1)) AS orders WHERE amount > 10",
+		},
+		{
+			"SELECT o.id FROM db.orders o FINAL JOIN `db`.`users` AS u ON o.uid = u.id",
+			[]rowFilter{{"orders", "tenant_id = 1"}, {"db.users", "active"}, {"orders", "deleted = 0"}},
+			"SELECT o.id FROM (SELECT * FROM db.orders FINAL WHERE (tenant_id = 1) AND (deleted = 0)) o " +
+				"JOIN (SELECT * FROM `db`.`users` WHERE (active)) AS u ON o.uid = u.id",
+		},
+		{
+			"SELECT * FROM other.orders, users",
+			[]rowFilter{{"db.orders", "tenant_id = 1"}},
+			"SELECT * FROM other.orders, users",
+		},
+	}
+	for _, tc := range testCases {
+		actual, err := addRowFilters(tc.query, tc.filters)
+		if assert.NoError(t, err, tc.query) {
+			assert.Equal(t, tc.expected, actual)
+		}
+	}
+
+	for _, condition := range []string{
+		"",
+		"id IN (SELECT id FROM t)",
+		"1) UNION ALL (SELECT 1",
+		"1 = 1 -- ",
+		"1 /* */ = 1",
+		"1; DROP TABLE t",
+		"(a = 1",
+		"'unterminated",
+	} {
+		_, err := addRowFilters("SELECT * FROM t", []rowFilter{{"t", condition}})
+		assert.Equal(t, ErrUnsafeRowFilter, err, condition)
+	}
+	_, err := addRowFilters("SELECT * FROM t SAMPLE 0.1", []rowFilter{{"t", "a = 1"}})
+	assert.Error(t, err)
+}
+
+func TestWithFinal(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		w.Write([]byte("a\nInt8\n1\n"))
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var a int8
+	require.NoError(t, db.QueryRowContext(WithFinal(context.Background()), "SELECT a FROM t WHERE s = ?", "FROM x").Scan(&a))
+	require.NoError(t, db.QueryRowContext(context.Background(), "SELECT a FROM t").Scan(&a))
+	require.NoError(t, db.QueryRowContext(WithSample(WithFinal(context.Background()), 0.1), "SELECT a FROM t").Scan(&a))
+	require.NoError(t, db.QueryRowContext(WithFinal(WithRowFilter(context.Background(), "t", "b = 1")), "SELECT a FROM t").Scan(&a))
+	assert.Equal(t, []string{
+		"SELECT a FROM t FINAL WHERE s = 'FROM x'",
+		"SELECT a FROM t",
+		"SELECT a FROM t FINAL SAMPLE 0.1",
+		"SELECT a FROM (SELECT * FROM t FINAL WHERE (b = 1)) AS t",
+	}, queries)
+}
@@ -0,0 +1,236 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ScanNested returns a sql.Scanner which scans a Nested column into dest,
+// which must be a pointer to a slice of structs. Elements of the column are
+// matched to the struct fields by the "ch" tag or by the case insensitive
+// field name, elements without a matching field are skipped.
+//
+// Note: ClickHouse returns Nested columns as a single column only with the
+// setting flatten_nested=0, otherwise each element is a separate array column
+// scanned with ScanNestedColumns.
+func ScanNested(dest interface{}) sql.Scanner {
+	return &nestedScanner{dest: dest}
+}
+
+type nestedScanner struct {
+	dest interface{}
+}
+
+// nestedDest returns the slice of structs dest points to
+func nestedDest(dest interface{}) (reflect.Value, error) {
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() ||
+		dv.Elem().Kind() != reflect.Slice || dv.Elem().Type().Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("clickhouse: expected pointer to slice of structs, got %T", dest)
+	}
+	return dv.Elem(), nil
+}
+
+// Scan implements the sql.Scanner
+func (s *nestedScanner) Scan(src interface{}) error {
+	dv, err := nestedDest(s.dest)
+	if err != nil {
+		return err
+	}
+	if src == nil {
+		dv.Set(reflect.Zero(dv.Type()))
+		return nil
+	}
+	sv := reflect.ValueOf(src)
+	if sv.Kind() != reflect.Slice || sv.Type().Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("clickhouse: can not scan %T as Nested", src)
+	}
+	slice := reflect.MakeSlice(dv.Type(), sv.Len(), sv.Len())
+	for i := 0; i < sv.Len(); i++ {
+		if err := assignStruct(slice.Index(i), sv.Index(i)); err != nil {
+			return err
+		}
+	}
+	dv.Set(slice)
+	return nil
+}
+
+// ScanNestedColumns returns the sql.Scanners of the array columns of a Nested
+// column flattened by the default setting flatten_nested=1, e.g. n.id and
+// n.user_name, which zip the arrays into dest, a pointer to a slice of
+// structs. The elements are the names of the elements of the Nested column in
+// the order of their columns in the scanned row, they are matched to the
+// struct fields like ScanNested does. All the arrays must have the same
+// length, the first of them replaces the slice.
+//
+//	err := rows.Scan(clickhouse.ScanNestedColumns(&items, "id", "user_name")...)
+func ScanNestedColumns(dest interface{}, elements ...string) []interface{} {
+	scanners := make([]interface{}, len(elements))
+	for i, name := range elements {
+		scanners[i] = &nestedColumnScanner{dest: dest, name: name, first: i == 0}
+	}
+	return scanners
+}
+
+type nestedColumnScanner struct {
+	dest  interface{}
+	name  string
+	first bool
+}
+
+// Scan implements the sql.Scanner
+func (s *nestedColumnScanner) Scan(src interface{}) error {
+	dv, err := nestedDest(s.dest)
+	if err != nil {
+		return err
+	}
+	// nil is scanned as an empty array
+	sv := reflect.ValueOf([]interface{}{})
+	if src != nil {
+		sv = reflect.ValueOf(src)
+	}
+	if sv.Kind() != reflect.Slice {
+		return fmt.Errorf("clickhouse: can not scan %T as the array of Nested element %s", src, s.name)
+	}
+	if s.first {
+		dv.Set(reflect.MakeSlice(dv.Type(), sv.Len(), sv.Len()))
+	} else if dv.Len() != sv.Len() {
+		return fmt.Errorf("clickhouse: the array of Nested element %s has %d values, expected %d", s.name, sv.Len(), dv.Len())
+	}
+	idx := structFieldIndex(dv.Type().Elem(), s.name)
+	if idx < 0 {
+		return nil
+	}
+	for i := 0; i < sv.Len(); i++ {
+		f := dv.Index(i).Field(idx)
+		if err := assignValue(f, sv.Index(i), dv.Type().Elem().Field(idx).Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScanTuple returns a sql.Scanner which scans a Tuple column into dest, which
+// must be a pointer to a struct, a []interface{} or a map[string]interface{}.
+// Elements are matched to the struct fields like ScanNested does, the keys of
+// the map are the names of the elements of a named tuple, e.g.
+// Tuple(a UInt8, b String), or their 1-based positions "1", "2", ...
+func ScanTuple(dest interface{}) sql.Scanner {
+	return &tupleScanner{dest: dest}
+}
+
+type tupleScanner struct {
+	dest interface{}
+}
+
+// Scan implements the sql.Scanner
+func (s *tupleScanner) Scan(src interface{}) error {
+	dv := reflect.ValueOf(s.dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return fmt.Errorf("clickhouse: expected pointer, got %T", s.dest)
+	}
+	dv = dv.Elem()
+	if src == nil {
+		dv.Set(reflect.Zero(dv.Type()))
+		return nil
+	}
+	sv := reflect.ValueOf(src)
+	if sv.Kind() != reflect.Struct {
+		return fmt.Errorf("clickhouse: can not scan %T as Tuple", src)
+	}
+	switch d := s.dest.(type) {
+	case *[]interface{}:
+		values := make([]interface{}, sv.NumField())
+		for i := range values {
+			values[i] = sv.Field(i).Interface()
+		}
+		*d = values
+	case *map[string]interface{}:
+		values := make(map[string]interface{}, sv.NumField())
+		for i := 0; i < sv.NumField(); i++ {
+			name := sv.Type().Field(i).Tag.Get("ch")
+			if len(name) == 0 {
+				name = strconv.Itoa(i + 1)
+			}
+			values[name] = sv.Field(i).Interface()
+		}
+		*d = values
+	default:
+		if dv.Kind() != reflect.Struct {
+			return fmt.Errorf("clickhouse: expected pointer to struct, []interface{} or map[string]interface{}, got %T", s.dest)
+		}
+		return assignStruct(dv, sv)
+	}
+	return nil
+}
+
+func assignStruct(dst, src reflect.Value) error {
+	st := src.Type()
+	for i := 0; i < st.NumField(); i++ {
+		sf := st.Field(i)
+		name := sf.Tag.Get("ch")
+		if len(name) == 0 {
+			name = sf.Name
+		}
+		idx := structFieldIndex(dst.Type(), name)
+		if idx < 0 {
+			continue
+		}
+		if err := assignValue(dst.Field(idx), src.Field(i), dst.Type().Field(idx).Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assignValue assigns the value v to the struct field f with the name
+func assignValue(f, v reflect.Value, name string) error {
+	switch {
+	case v.Type().AssignableTo(f.Type()):
+		f.Set(v)
+	case v.Kind() == reflect.Struct && f.Kind() == reflect.Struct:
+		// nested tuples
+		return assignStruct(f, v)
+	case v.Kind() == reflect.Slice && f.Kind() == reflect.Slice &&
+		v.Type().Elem().Kind() == reflect.Struct && f.Type().Elem().Kind() == reflect.Struct:
+		// arrays of tuples
+		slice := reflect.MakeSlice(f.Type(), v.Len(), v.Len())
+		for j := 0; j < v.Len(); j++ {
+			if err := assignStruct(slice.Index(j), v.Index(j)); err != nil {
+				return err
+			}
+		}
+		f.Set(slice)
+	case v.Type().ConvertibleTo(f.Type()) && (f.Kind() != reflect.String || v.Kind() == reflect.String):
+		f.Set(v.Convert(f.Type()))
+	default:
+		return fmt.Errorf("clickhouse: can not assign %s to field %s of type %s", v.Type(), name, f.Type())
+	}
+	return nil
+}
+
+// structFieldIndex returns index of the exported field matching the name by
+// its "ch" tag or by the case insensitive field name, -1 if there is no one.
+func structFieldIndex(t reflect.Type, name string) int {
+	idx := -1
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := f.Tag.Get("ch")
+		if tag == "-" {
+			continue
+		}
+		if tag == name {
+			return i
+		}
+		if idx < 0 && len(tag) == 0 && strings.EqualFold(f.Name, name) {
+			idx = i
+		}
+	}
+	return idx
+}
@@ -0,0 +1,130 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanNested(t *testing.T) {
+	desc, err := ParseTypeDesc("Nested(id UInt64, user_name String, score Float32)")
+	require.NoError(t, err)
+	parser, err := NewDataParser(desc, nil)
+	require.NoError(t, err)
+	v, err := parser.Parse(strings.NewReader("[(1,'alice',1.5),(2,'bob',2)]"))
+	require.NoError(t, err)
+
+	var items []struct {
+		ID    uint64
+		Name  string `ch:"user_name"`
+		Score float64
+		extra int
+	}
+	require.NoError(t, ScanNested(&items).Scan(v))
+	if assert.Len(t, items, 2) {
+		assert.EqualValues(t, 1, items[0].ID)
+		assert.Equal(t, "alice", items[0].Name)
+		assert.Equal(t, 1.5, items[0].Score)
+		assert.EqualValues(t, 2, items[1].ID)
+		assert.Equal(t, "bob", items[1].Name)
+		assert.Equal(t, float64(2), items[1].Score)
+	}
+
+	require.NoError(t, ScanNested(&items).Scan(nil))
+	assert.Nil(t, items)
+
+	var wrongType []struct {
+		ID string
+	}
+	assert.Error(t, ScanNested(&wrongType).Scan(v))
+	assert.Error(t, ScanNested(items).Scan(v))
+	assert.Error(t, ScanNested(&items).Scan("value"))
+}
+
+func TestScanTuple(t *testing.T) {
+	desc, err := ParseTypeDesc("Tuple(id UInt64, user_name String)")
+	require.NoError(t, err)
+	parser, err := NewDataParser(desc, nil)
+	require.NoError(t, err)
+	v, err := parser.Parse(strings.NewReader("(1,'alice')"))
+	require.NoError(t, err)
+
+	var item struct {
+		ID   uint64
+		Name string `ch:"user_name"`
+	}
+	require.NoError(t, ScanTuple(&item).Scan(v))
+	assert.EqualValues(t, 1, item.ID)
+	assert.Equal(t, "alice", item.Name)
+
+	var values []interface{}
+	require.NoError(t, ScanTuple(&values).Scan(v))
+	assert.Equal(t, []interface{}{uint64(1), "alice"}, values)
+
+	var named map[string]interface{}
+	require.NoError(t, ScanTuple(&named).Scan(v))
+	assert.Equal(t, map[string]interface{}{"id": uint64(1), "user_name": "alice"}, named)
+
+	desc, err = ParseTypeDesc("Tuple(UInt8, String)")
+	require.NoError(t, err)
+	parser, err = NewDataParser(desc, nil)
+	require.NoError(t, err)
+	v, err = parser.Parse(strings.NewReader("(2,'bob')"))
+	require.NoError(t, err)
+	require.NoError(t, ScanTuple(&named).Scan(v))
+	assert.Equal(t, map[string]interface{}{"1": uint8(2), "2": "bob"}, named)
+
+	require.NoError(t, ScanTuple(&values).Scan(nil))
+	assert.Nil(t, values)
+	var str string
+	assert.Error(t, ScanTuple(&str).Scan(v))
+	assert.Error(t, ScanTuple(values).Scan(v))
+	assert.Error(t, ScanTuple(&values).Scan("value"))
+}
+
+func TestScanNestedColumns(t *testing.T) {
+	var items []struct {
+		ID    uint64
+		Name  string `ch:"user_name"`
+		Score *float32
+	}
+	score := float32(1.5)
+	scanners := ScanNestedColumns(&items, "id", "user_name", "score", "extra")
+	require.Len(t, scanners, 4)
+	for i, src := range []interface{}{[]uint64{1, 2}, []string{"alice", "bob"}, []*float32{&score, nil}, []uint8{7, 8}} {
+		require.NoError(t, scanners[i].(sql.Scanner).Scan(src))
+	}
+	if assert.Len(t, items, 2) {
+		assert.EqualValues(t, 1, items[0].ID)
+		assert.Equal(t, "alice", items[0].Name)
+		assert.Equal(t, &score, items[0].Score)
+		assert.EqualValues(t, 2, items[1].ID)
+		assert.Equal(t, "bob", items[1].Name)
+		assert.Nil(t, items[1].Score)
+	}
+
+	// the first array replaces the slice of the previous row
+	scanners = ScanNestedColumns(&items, "user_name", "id")
+	require.NoError(t, scanners[0].(sql.Scanner).Scan([]string{"carol"}))
+	require.NoError(t, scanners[1].(sql.Scanner).Scan([]uint64{3}))
+	if assert.Len(t, items, 1) {
+		assert.EqualValues(t, 3, items[0].ID)
+		assert.Equal(t, "carol", items[0].Name)
+		assert.Nil(t, items[0].Score)
+	}
+	require.NoError(t, scanners[0].(sql.Scanner).Scan(nil))
+	assert.Len(t, items, 0)
+
+	// the arrays of a row have different lengths
+	require.NoError(t, scanners[0].(sql.Scanner).Scan([]string{"alice", "bob"}))
+	assert.Error(t, scanners[1].(sql.Scanner).Scan([]uint64{1}))
+	assert.Error(t, scanners[0].(sql.Scanner).Scan("value"))
+	assert.Error(t, ScanNestedColumns(items, "id")[0].(sql.Scanner).Scan([]uint64{1}))
+	var wrongType []struct {
+		ID string
+	}
+	assert.Error(t, ScanNestedColumns(&wrongType, "id")[0].(sql.Scanner).Scan([]uint64{1}))
+}
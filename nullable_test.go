@@ -0,0 +1,88 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNullable(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		if r.Method != http.MethodGet {
+			return
+		}
+		if r.URL.Query().Get("default_format") == FormatRowBinaryWithNamesAndTypes {
+			b := &rowBinary{}
+			b.le(uint8(3)).str("s").str("i").str("t")
+			b.str("Nullable(String)").str("Nullable(Int64)").str("Tuple(Nullable(String), Int8)")
+			b.le(uint8(0)).str("a").le(uint8(1)).le(uint8(1)).le(int8(1))
+			b.le(uint8(1)).le(uint8(0)).le(int64(2)).le(uint8(0)).str("b").le(int8(2))
+			w.Write(b.Bytes())
+			return
+		}
+		w.Write([]byte("s\ti\tt\n" +
+			"Nullable(String)\tNullable(Int64)\tTuple(Nullable(String), Int8)\n" +
+			"a\t\\N\t(NULL,1)\n" +
+			"\\N\t2\t('b',2)\n"))
+	})
+	defer ts.Close()
+
+	for _, format := range []string{FormatTabSeparatedWithNamesAndTypes, FormatRowBinaryWithNamesAndTypes} {
+		db, err := sql.Open("clickhouse", dsn+"?format="+format)
+		require.NoError(t, err)
+
+		rows, err := db.Query("SELECT s, i, t")
+		require.NoError(t, err)
+		types, err := rows.ColumnTypes()
+		require.NoError(t, err)
+		nullable, ok := types[1].Nullable()
+		assert.True(t, nullable && ok, format)
+
+		var (
+			nullStrings []sql.NullString
+			nullInts    []sql.NullInt64
+			tuples      []map[string]interface{}
+		)
+		for rows.Next() {
+			var (
+				ns sql.NullString
+				ni sql.NullInt64
+				tu map[string]interface{}
+			)
+			require.NoError(t, rows.Scan(&ns, &ni, ScanTuple(&tu)))
+			nullStrings, nullInts, tuples = append(nullStrings, ns), append(nullInts, ni), append(tuples, tu)
+		}
+		require.NoError(t, rows.Err())
+		rows.Close()
+		assert.Equal(t, []sql.NullString{{String: "a", Valid: true}, {}}, nullStrings, format)
+		assert.Equal(t, []sql.NullInt64{{}, {Int64: 2, Valid: true}}, nullInts, format)
+		assert.Equal(t, []map[string]interface{}{{"1": (*string)(nil), "2": int8(1)}, {"1": stringPtr("b"), "2": int8(2)}}, tuples, format)
+
+		var (
+			ps *string
+			pi *int64
+		)
+		require.NoError(t, db.QueryRow("SELECT s, i, t").Scan(&ps, &pi, new(interface{})))
+		assert.Equal(t, "a", *ps, format)
+		assert.Nil(t, pi, format)
+		db.Close()
+	}
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	var (
+		nilInt *int
+		one    = 1
+	)
+	_, err = db.Exec("INSERT INTO t VALUES (?, ?, ?, ?, ?, ?)", nil, nilInt, &one,
+		sql.NullString{}, sql.NullInt64{Int64: 2, Valid: true}, sql.NullTime{Time: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Valid: true})
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO t VALUES (NULL, NULL, 1, NULL, 2, '2020-01-02 03:04:05')", queries[len(queries)-1])
+}
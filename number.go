@@ -0,0 +1,187 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+)
+
+// NumberPolicy controls the conversions of numeric columns scanned with
+// ScanNumber
+type NumberPolicy int
+
+const (
+	// NumberStrict fails if the value does not fit into the destination,
+	// e.g. a UInt64 value above math.MaxInt64 scanned into int64 or a float
+	// with the fractional part scanned into an integer. It is the behavior
+	// of database/sql.
+	NumberStrict NumberPolicy = iota
+	// NumberSaturate clamps the values to the range of the destination and
+	// truncates the fractional part of the values scanned into integers
+	NumberSaturate
+	// NumberLossless scans the values into *interface{} as int64 if they
+	// fit, as uint64 if they do not and as strings if they fit into neither
+	// of them, e.g. UInt256 values. Other destinations are scanned as with
+	// NumberStrict.
+	NumberLossless
+)
+
+// ScanNumber returns a sql.Scanner which scans a numeric column into dest
+// with the conversions of the policy. dest must be a pointer to an integer,
+// a float, a string or an interface{}. Integer, Float, Decimal and big
+// integer columns are converted exactly, NULL is scanned as the zero value.
+func ScanNumber(dest interface{}, policy NumberPolicy) sql.Scanner {
+	return &numberScanner{dest: dest, policy: policy}
+}
+
+type numberScanner struct {
+	dest   interface{}
+	policy NumberPolicy
+}
+
+// Scan implements the sql.Scanner
+func (s *numberScanner) Scan(src interface{}) error {
+	dv := reflect.ValueOf(s.dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return fmt.Errorf("clickhouse: expected pointer to number, got %T", s.dest)
+	}
+	dv = dv.Elem()
+	if src == nil {
+		dv.Set(reflect.Zero(dv.Type()))
+		return nil
+	}
+	r, err := numberRat(src)
+	if err != nil {
+		return err
+	}
+	switch dv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := s.integer(r, src, dv.Type(), true)
+		if err != nil {
+			return err
+		}
+		dv.SetInt(n.Int64())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := s.integer(r, src, dv.Type(), false)
+		if err != nil {
+			return err
+		}
+		dv.SetUint(n.Uint64())
+	case reflect.Float32, reflect.Float64:
+		if r == nil {
+			// infinity or NaN
+			dv.SetFloat(reflect.ValueOf(src).Float())
+			break
+		}
+		f, _ := r.Float64()
+		if dv.Kind() == reflect.Float32 && math.Abs(f) > math.MaxFloat32 {
+			if s.policy != NumberSaturate {
+				return fmt.Errorf("clickhouse: %v overflows %s", src, dv.Type())
+			}
+			f = math.Copysign(math.MaxFloat32, f)
+		}
+		dv.SetFloat(f)
+	case reflect.String:
+		dv.SetString(numberString(src, r))
+	case reflect.Interface:
+		if s.policy != NumberLossless {
+			dv.Set(reflect.ValueOf(src))
+			break
+		}
+		dv.Set(reflect.ValueOf(losslessNumber(src, r)))
+	default:
+		return fmt.Errorf("clickhouse: can not scan number into %T", s.dest)
+	}
+	return nil
+}
+
+// integer returns the integer value of r in the range of typ
+func (s *numberScanner) integer(r *big.Rat, src interface{}, typ reflect.Type, signed bool) (*big.Int, error) {
+	min, max := bigIntRange(signed, typ.Bits())
+	if r == nil {
+		// infinity or NaN
+		f := reflect.ValueOf(src).Float()
+		switch {
+		case s.policy != NumberSaturate || math.IsNaN(f):
+			return nil, fmt.Errorf("clickhouse: can not convert %v to %s", src, typ)
+		case f > 0:
+			return max, nil
+		}
+		return min, nil
+	}
+	if !r.IsInt() && s.policy != NumberSaturate {
+		return nil, fmt.Errorf("clickhouse: can not convert %v to %s without the fractional part", src, typ)
+	}
+	// the quotient is truncated toward zero
+	n := new(big.Int).Quo(r.Num(), r.Denom())
+	switch {
+	case n.Cmp(min) >= 0 && n.Cmp(max) <= 0:
+		return n, nil
+	case s.policy != NumberSaturate:
+		return nil, fmt.Errorf("clickhouse: %v overflows %s", src, typ)
+	case n.Sign() < 0:
+		return min, nil
+	}
+	return max, nil
+}
+
+// numberRat returns the exact value of a numeric column, it is nil for
+// infinities and NaN
+func numberRat(src interface{}) (*big.Rat, error) {
+	v := reflect.ValueOf(src)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Rat).SetInt64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return new(big.Rat).SetFloat64(v.Float()), nil
+	}
+	switch n := src.(type) {
+	case *big.Int:
+		return new(big.Rat).SetInt(n), nil
+	case string:
+		// Decimal values
+		if r, ok := new(big.Rat).SetString(n); ok {
+			return r, nil
+		}
+	case []byte:
+		if r, ok := new(big.Rat).SetString(string(n)); ok {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("clickhouse: can not scan %T as number", src)
+}
+
+func numberString(src interface{}, r *big.Rat) string {
+	switch n := src.(type) {
+	case string:
+		return n
+	case []byte:
+		return string(n)
+	}
+	if r != nil && r.IsInt() {
+		return r.Num().String()
+	}
+	return fmt.Sprint(src)
+}
+
+func losslessNumber(src interface{}, r *big.Rat) interface{} {
+	switch v := reflect.ValueOf(src); v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String, reflect.Slice:
+		// Decimal values keep their scale
+		return numberString(src, r)
+	}
+	n := r.Num()
+	if n.IsInt64() {
+		return n.Int64()
+	}
+	if n.IsUint64() {
+		return n.Uint64()
+	}
+	return n.String()
+}
@@ -0,0 +1,83 @@
+package clickhouse
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanNumber(t *testing.T) {
+	var (
+		i64 int64
+		i8  int8
+		u32 uint32
+		f32 float32
+		f64 float64
+		s   string
+		v   interface{}
+	)
+	big128, _ := new(big.Int).SetString("170141183460469231731687303715884105727", 10)
+
+	assert.Error(t, ScanNumber(&i64, NumberStrict).Scan(uint64(math.MaxUint64)))
+	assert.NoError(t, ScanNumber(&i64, NumberSaturate).Scan(uint64(math.MaxUint64)))
+	assert.Equal(t, int64(math.MaxInt64), i64)
+
+	assert.Error(t, ScanNumber(&i8, NumberStrict).Scan(1.5))
+	assert.NoError(t, ScanNumber(&i8, NumberStrict).Scan(2.0))
+	assert.Equal(t, int8(2), i8)
+	assert.NoError(t, ScanNumber(&i8, NumberSaturate).Scan(-1.5))
+	assert.Equal(t, int8(-1), i8)
+	assert.NoError(t, ScanNumber(&i8, NumberSaturate).Scan(int64(-1000)))
+	assert.Equal(t, int8(math.MinInt8), i8)
+	assert.NoError(t, ScanNumber(&i8, NumberSaturate).Scan(math.Inf(1)))
+	assert.Equal(t, int8(math.MaxInt8), i8)
+	assert.Error(t, ScanNumber(&i8, NumberSaturate).Scan(math.NaN()))
+
+	assert.Error(t, ScanNumber(&u32, NumberStrict).Scan(int8(-1)))
+	assert.NoError(t, ScanNumber(&u32, NumberSaturate).Scan(int8(-1)))
+	assert.Equal(t, uint32(0), u32)
+	assert.NoError(t, ScanNumber(&u32, NumberStrict).Scan("12.000"))
+	assert.Equal(t, uint32(12), u32)
+
+	assert.Error(t, ScanNumber(&f32, NumberStrict).Scan(math.MaxFloat64))
+	assert.NoError(t, ScanNumber(&f32, NumberSaturate).Scan(math.MaxFloat64))
+	assert.Equal(t, float32(math.MaxFloat32), f32)
+	assert.NoError(t, ScanNumber(&f32, NumberStrict).Scan(math.Inf(-1)))
+	assert.True(t, math.IsInf(float64(f32), -1))
+	assert.NoError(t, ScanNumber(&f32, NumberStrict).Scan(math.NaN()))
+	assert.True(t, math.IsNaN(float64(f32)))
+	assert.NoError(t, ScanNumber(&f64, NumberStrict).Scan(math.Inf(1)))
+	assert.True(t, math.IsInf(f64, 1))
+	assert.NoError(t, ScanNumber(&f64, NumberSaturate).Scan(float32(math.NaN())))
+	assert.True(t, math.IsNaN(f64))
+
+	assert.NoError(t, ScanNumber(&s, NumberStrict).Scan(uint64(math.MaxUint64)))
+	assert.Equal(t, "18446744073709551615", s)
+	assert.NoError(t, ScanNumber(&s, NumberStrict).Scan(big128))
+	assert.Equal(t, big128.String(), s)
+
+	assert.NoError(t, ScanNumber(&v, NumberStrict).Scan(uint8(1)))
+	assert.Equal(t, uint8(1), v)
+	for _, tc := range []struct {
+		src      interface{}
+		expected interface{}
+	}{
+		{uint8(1), int64(1)},
+		{uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{big128, big128.String()},
+		{float32(1.5), 1.5},
+		{"1.50", "1.50"},
+		{nil, nil},
+	} {
+		v = "dirty"
+		if assert.NoError(t, ScanNumber(&v, NumberLossless).Scan(tc.src)) {
+			assert.Equal(t, tc.expected, v)
+		}
+	}
+
+	assert.Error(t, ScanNumber(&s, NumberStrict).Scan("abc"))
+	assert.Error(t, ScanNumber(i64, NumberStrict).Scan(1))
+	assert.Error(t, ScanNumber(&[]int{}, NumberStrict).Scan(1))
+}
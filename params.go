@@ -0,0 +1,66 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"time"
+)
+
+// queryParamPrefix is the prefix of URL parameters holding server-side query parameters
+const queryParamPrefix = "param_"
+
+// Named returns an argument which is sent as the server-side query parameter
+// param_<name>, ClickHouse substitutes it for the {name:Type} placeholders of
+// the query and validates it against Type:
+//
+//	db.QueryContext(ctx, "SELECT * FROM t WHERE id = {id:UInt64}", clickhouse.Named("id", 42))
+//
+// Named arguments are never interpolated into the query text, they can be
+// mixed with positional arguments substituted for the ? placeholders.
+func Named(name string, value interface{}) sql.NamedArg {
+	return sql.Named(name, value)
+}
+
+var (
+	queryParamEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`)
+	unescaper         = strings.NewReplacer(`\\`, `\`, `\'`, `'`)
+)
+
+// formatQueryParam formats the value of a query parameter in the escaped
+// text format, which is how ClickHouse parses query parameters
+func formatQueryParam(value driver.Value) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return `\N`, nil
+	case string:
+		return queryParamEscaper.Replace(v), nil
+	case []byte:
+		// raw literals, e.g. values of Date and UInt64
+		s := string(v)
+		if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+			return queryParamEscaper.Replace(unescaper.Replace(s[1 : len(s)-1])), nil
+		}
+		return s, nil
+	case time.Time:
+		return v.Format(timeFormat), nil
+	}
+	// strings of arrays and tuples are quoted as in the text formats
+	b, err := textEncode.Encode(value)
+	return string(b), err
+}
+
+// hasQueryParams reports whether the query contains {name:Type} placeholders
+// of server-side query parameters
+func hasQueryParams(query string) bool {
+	words, err := splitSQL(query)
+	if err != nil {
+		return false
+	}
+	for i := 0; i+2 < len(words); i++ {
+		if words[i].text == "{" && !words[i].quoted && !words[i+1].quoted && isIdentChar(words[i+1].text[0]) && words[i+2].text == ":" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatQueryParam(t *testing.T) {
+	testCases := []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, `\N`},
+		{"it's\ta\\test\n", `it's\ta\\test\n`},
+		{int64(-1), "-1"},
+		{true, "1"},
+		{time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), "2020-01-02 03:04:05"},
+		{[]byte("'2020-01-02'"), "2020-01-02"},
+		{[]byte("18446744073709551615"), "18446744073709551615"},
+		{[]byte(`['a','b\'c']`), `['a','b\'c']`},
+		{[]string{"a", "b'c"}, `['a','b\'c']`},
+	}
+	for _, tc := range testCases {
+		actual, err := formatQueryParam(tc.value)
+		if assert.NoError(t, err) {
+			assert.Equal(t, tc.expected, actual, tc.value)
+		}
+	}
+}
+
+func TestNamed(t *testing.T) {
+	var (
+		queries []string
+		params  []url.Values
+	)
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		params = append(params, r.URL.Query())
+		if r.Method == http.MethodGet {
+			w.Write([]byte("1\nUInt8\n1\n"))
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	var n int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count() FROM t WHERE id = {id:UInt64} AND a = ?",
+		Named("id", 42), "it's").Scan(&n))
+	stmt, err := db.PrepareContext(ctx, "ALTER TABLE t DELETE WHERE name = {name:String}")
+	require.NoError(t, err)
+	_, err = stmt.ExecContext(ctx, sql.Named("name", "a\tb"))
+	require.NoError(t, err)
+	require.NoError(t, stmt.Close())
+
+	assert.Equal(t, []string{
+		"SELECT count() FROM t WHERE id = {id:UInt64} AND a = 'it\\'s'",
+		"ALTER TABLE t DELETE WHERE name = {name:String}",
+	}, queries)
+	assert.Equal(t, "42", params[0].Get("param_id"))
+	assert.Equal(t, `a\tb`, params[1].Get("param_name"))
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	stmt, err = tx.Prepare("INSERT INTO t VALUES (?)")
+	require.NoError(t, err)
+	_, err = stmt.Exec(sql.Named("a", 1))
+	assert.Equal(t, ErrNameParams, err)
+	require.NoError(t, tx.Rollback())
+}
@@ -0,0 +1,76 @@
+package clickhouse
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+const (
+	progressHeader = "X-ClickHouse-Progress"
+	summaryHeader  = "X-ClickHouse-Summary"
+)
+
+// Progress is the progress of a query reported by the server
+type Progress struct {
+	ReadRows        uint64 `json:"read_rows,string"`
+	ReadBytes       uint64 `json:"read_bytes,string"`
+	WrittenRows     uint64 `json:"written_rows,string"`
+	WrittenBytes    uint64 `json:"written_bytes,string"`
+	TotalRowsToRead uint64 `json:"total_rows_to_read,string"`
+}
+
+// WithProgress returns a copy of ctx which enables the setting
+// send_progress_in_http_headers for the queries executed with it and calls
+// fn with every progress update. The server sends the updates in the headers
+// of the response, so they are delivered once the response starts, e.g. when
+// the first block of the result is ready. Use wait_end_of_query=1 to get all
+// updates of an INSERT.
+func WithProgress(ctx context.Context, fn func(Progress)) context.Context {
+	ctx = withSettings(ctx, map[string]string{"send_progress_in_http_headers": "1"})
+	return context.WithValue(ctx, progressKey, fn)
+}
+
+// WithSummary returns a copy of ctx which keeps the summary of the last
+// query executed with it, the summary can be read with Summary
+func WithSummary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, summaryKey, new(querySummary))
+}
+
+// Summary returns the final progress of the last query executed with ctx,
+// e.g. the number of rows written by an INSERT. It returns false if ctx was
+// not created by WithSummary or the server did not send the summary.
+func Summary(ctx context.Context) (Progress, bool) {
+	if s, ok := ctx.Value(summaryKey).(*querySummary); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.progress, s.ok
+	}
+	return Progress{}, false
+}
+
+type querySummary struct {
+	mu       sync.Mutex
+	progress Progress
+	ok       bool
+}
+
+// reportProgress delivers the progress and summary headers of the response
+func reportProgress(ctx context.Context, header http.Header) {
+	if fn, ok := ctx.Value(progressKey).(func(Progress)); ok {
+		for _, v := range header.Values(progressHeader) {
+			var p Progress
+			if err := json.Unmarshal([]byte(v), &p); err == nil {
+				fn(p)
+			}
+		}
+	}
+	if s, ok := ctx.Value(summaryKey).(*querySummary); ok {
+		var p Progress
+		err := json.Unmarshal([]byte(header.Get(summaryHeader)), &p)
+		s.mu.Lock()
+		s.progress, s.ok = p, err == nil
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,77 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress(t *testing.T) {
+	var sendProgress string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		sendProgress = r.URL.Query().Get("send_progress_in_http_headers")
+		if sendProgress == "1" {
+			w.Header().Add(progressHeader, `{"read_rows":"10","read_bytes":"80","total_rows_to_read":"100"}`)
+			w.Header().Add(progressHeader, `{"read_rows":"100","read_bytes":"800","total_rows_to_read":"100"}`)
+		}
+		w.Header().Set(summaryHeader, `{"read_rows":"100","read_bytes":"800","written_rows":"3","written_bytes":"24","total_rows_to_read":"100"}`)
+		if r.Method == http.MethodGet {
+			w.Write([]byte("1\nUInt8\n1\n"))
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var updates []Progress
+	ctx := WithProgress(context.Background(), func(p Progress) {
+		updates = append(updates, p)
+	})
+	var v int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT 1").Scan(&v))
+	assert.Equal(t, "1", sendProgress)
+	assert.Equal(t, []Progress{
+		{ReadRows: 10, ReadBytes: 80, TotalRowsToRead: 100},
+		{ReadRows: 100, ReadBytes: 800, TotalRowsToRead: 100},
+	}, updates)
+
+	_, ok := Summary(ctx)
+	assert.False(t, ok)
+	ctx = WithSummary(context.Background())
+	result, err := db.ExecContext(ctx, "INSERT INTO t SELECT * FROM s")
+	require.NoError(t, err)
+	assert.Empty(t, sendProgress)
+	summary, ok := Summary(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, Progress{ReadRows: 100, ReadBytes: 800, WrittenRows: 3, WrittenBytes: 24, TotalRowsToRead: 100}, summary)
+	affected, err := result.RowsAffected()
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, affected)
+
+	// the affected rows do not need WithSummary and sum up over scripts
+	result, err = db.Exec("INSERT INTO t SELECT * FROM s; INSERT INTO t SELECT * FROM s")
+	require.NoError(t, err)
+	affected, err = result.RowsAffected()
+	require.NoError(t, err)
+	assert.EqualValues(t, 6, affected)
+}
+
+func TestRowsAffectedWithoutSummary(t *testing.T) {
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	result, err := db.Exec("INSERT INTO t SELECT * FROM s")
+	require.NoError(t, err)
+	_, err = result.RowsAffected()
+	assert.Equal(t, ErrNoRowsAffected, err)
+}
@@ -0,0 +1,36 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MergeAndComputeQuantiles merges the states of the column col of type
+// AggregateFunction(quantiles(...), T) stored in table and returns the values
+// of the given quantile levels. The table and column names are used as is in
+// the query, so they must be quoted by the caller if it is needed.
+func MergeAndComputeQuantiles(ctx context.Context, db *sql.DB, table, col string, quantiles []float64) ([]float64, error) {
+	if len(quantiles) == 0 {
+		return nil, fmt.Errorf("clickhouse: quantile levels are not specified")
+	}
+	levels := make([]string, len(quantiles))
+	for i, q := range quantiles {
+		if q < 0 || q > 1 {
+			return nil, fmt.Errorf("clickhouse: quantile level %v is out of range [0, 1]", q)
+		}
+		levels[i] = strconv.FormatFloat(q, 'f', -1, 64)
+	}
+	query := "SELECT quantilesMerge(" + strings.Join(levels, ", ") + ")(" + col + ") FROM " + table
+
+	var result []float64
+	if err := db.QueryRowContext(ctx, query).Scan(&result); err != nil {
+		return nil, err
+	}
+	if len(result) != len(quantiles) {
+		return nil, ErrMalformed
+	}
+	return result, nil
+}
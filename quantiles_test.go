@@ -0,0 +1,38 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeAndComputeQuantiles(t *testing.T) {
+	var last string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		last = query
+		io.WriteString(w, "q\nArray(Float64)\n[1.5,9,20.25]\n")
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	result, err := MergeAndComputeQuantiles(ctx, db, "stats", "latency", []float64{0.5, 0.9, 0.99})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT quantilesMerge(0.5, 0.9, 0.99)(latency) FROM stats", last)
+	assert.Equal(t, []float64{1.5, 9, 20.25}, result)
+
+	_, err = MergeAndComputeQuantiles(ctx, db, "stats", "latency", nil)
+	assert.Error(t, err)
+	_, err = MergeAndComputeQuantiles(ctx, db, "stats", "latency", []float64{1.5})
+	assert.Error(t, err)
+	_, err = MergeAndComputeQuantiles(ctx, db, "stats", "latency", []float64{0.5})
+	assert.Equal(t, ErrMalformed, err)
+}
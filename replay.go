@@ -0,0 +1,236 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// Formats of query results supported by ParseRows
+const (
+	FormatTabSeparatedWithNamesAndTypes = "TabSeparatedWithNamesAndTypes"
+	FormatJSON                          = "JSON"
+	FormatRowBinaryWithNamesAndTypes    = "RowBinaryWithNamesAndTypes"
+)
+
+var errReplayed = errors.New("clickhouse: rows have been already replayed")
+
+// ParseRows parses a query result in the given format read from r, e.g.
+// a captured response of ClickHouse or an exported file, and returns it as
+// *sql.Rows. Supported formats are TabSeparatedWithNamesAndTypes (TSVWithNamesAndTypes),
+// RowBinaryWithNamesAndTypes and JSON. Date and DateTime values are parsed in UTC.
+func ParseRows(r io.Reader, format string) (*sql.Rows, error) {
+	var (
+		rows driver.Rows
+		err  error
+	)
+	switch format {
+	case FormatTabSeparatedWithNamesAndTypes, "TSVWithNamesAndTypes":
+		rows, err = newTextRows(nil, ioutil.NopCloser(r), &DataParserOptions{Location: time.UTC})
+	case FormatRowBinaryWithNamesAndTypes:
+		rows, err = newBinaryRows(nil, ioutil.NopCloser(r), &DataParserOptions{Location: time.UTC})
+	case FormatJSON:
+		var res *bufferedResult
+		if res, err = readJSONResult(r); err == nil {
+			rows = &bufferedRows{res: res}
+		}
+	default:
+		return nil, fmt.Errorf("clickhouse: format %s is not supported", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	db := sql.OpenDB(&replayConnector{rows: rows})
+	// the rows hold the connection, it will be closed with them
+	defer db.Close()
+	return db.Query("")
+}
+
+type replayConnector struct {
+	rows driver.Rows
+}
+
+// Connect implements the driver.Connector
+func (c *replayConnector) Connect(context.Context) (driver.Conn, error) {
+	return &replayConn{c: c}, nil
+}
+
+// Driver implements the driver.Connector
+func (c *replayConnector) Driver() driver.Driver {
+	return new(chDriver)
+}
+
+// replayConn returns the rows of its connector on the first query
+type replayConn struct {
+	c *replayConnector
+}
+
+func (c *replayConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errReplayed
+}
+
+func (c *replayConn) Close() error {
+	return nil
+}
+
+func (c *replayConn) Begin() (driver.Tx, error) {
+	return nil, errReplayed
+}
+
+// QueryContext implements the driver.QueryerContext
+func (c *replayConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	rows := c.c.rows
+	if rows == nil {
+		return nil, errReplayed
+	}
+	c.c.rows = nil
+	return rows, nil
+}
+
+type jsonResult struct {
+	Meta []struct {
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"meta"`
+	Data []json.RawMessage `json:"data"`
+}
+
+// readJSONResult reads the result in JSON or JSONCompact format, values are
+// converted into the text representation and parsed by DataParser.
+func readJSONResult(r io.Reader) (*bufferedResult, error) {
+	var result jsonResult
+	if err := json.NewDecoder(r).Decode(&result); err != nil {
+		return nil, err
+	}
+	res := &bufferedResult{}
+	descs := make([]*TypeDesc, len(result.Meta))
+	parsers := make([]DataParser, len(result.Meta))
+	for i, m := range result.Meta {
+		desc, err := ParseTypeDesc(m.Type)
+		if err != nil {
+			return nil, err
+		}
+		parser, err := NewDataParser(desc, &DataParserOptions{Location: time.UTC})
+		if err != nil {
+			return nil, err
+		}
+		descs[i], parsers[i] = desc, parser
+		res.columns = append(res.columns, m.Name)
+		res.databaseTypes = append(res.databaseTypes, m.Type)
+		res.scanTypes = append(res.scanTypes, parser.Type())
+	}
+
+	for _, raw := range result.Data {
+		values, err := decodeJSONRow(raw, res.columns)
+		if err != nil {
+			return nil, err
+		}
+		row := make([]driver.Value, len(values))
+		for i, v := range values {
+			text, err := jsonToText(descs[i], v, true)
+			if err != nil {
+				return nil, fmt.Errorf("clickhouse: column %s: %v", res.columns[i], err)
+			}
+			if row[i], err = parsers[i].Parse(strings.NewReader(text)); err != nil {
+				return nil, fmt.Errorf("clickhouse: column %s: %v", res.columns[i], err)
+			}
+		}
+		res.values = append(res.values, row)
+	}
+	return res, nil
+}
+
+// decodeJSONRow decodes the row as an object (JSON) or an array (JSONCompact)
+func decodeJSONRow(raw json.RawMessage, columns []string) ([]interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	switch row := v.(type) {
+	case []interface{}:
+		if len(row) != len(columns) {
+			return nil, ErrMalformed
+		}
+		return row, nil
+	case map[string]interface{}:
+		values := make([]interface{}, len(columns))
+		for i, name := range columns {
+			values[i] = row[name]
+		}
+		return values, nil
+	}
+	return nil, ErrMalformed
+}
+
+// quotedJSONNumbers are the types of the numbers which JSON formats quote
+// by default, see output_format_json_quote_64bit_integers
+var quotedJSONNumbers = map[string]bool{
+	"Int64": true, "UInt64": true, "Int128": true, "UInt128": true, "Int256": true, "UInt256": true,
+}
+
+// jsonToText converts a JSON value into the text representation of ClickHouse,
+// top level strings are escaped, but not quoted
+func jsonToText(desc *TypeDesc, v interface{}, top bool) (string, error) {
+	if v == nil {
+		if top {
+			return `\N`, nil
+		}
+		return "NULL", nil
+	}
+	switch desc.Name {
+	case "LowCardinality", "Nullable":
+		if len(desc.Args) == 1 {
+			return jsonToText(desc.Args[0], v, top)
+		}
+	case "Array", "Tuple":
+		items, ok := v.([]interface{})
+		if !ok {
+			return "", fmt.Errorf("unexpected value %v of %s", v, desc.Name)
+		}
+		begin, end := "[", "]"
+		if desc.Name == "Tuple" {
+			begin, end = "(", ")"
+		}
+		parts := make([]string, len(items))
+		for i, item := range items {
+			arg := desc.Args[0]
+			if desc.Name == "Tuple" && i < len(desc.Args) {
+				arg = desc.Args[i]
+			}
+			part, err := jsonToText(arg, item, false)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = part
+		}
+		return begin + strings.Join(parts, ",") + end, nil
+	}
+	switch val := v.(type) {
+	case string:
+		if quotedJSONNumbers[desc.Name] {
+			return val, nil
+		}
+		if top {
+			return escape(val), nil
+		}
+		return quote(escape(val)), nil
+	case json.Number:
+		return val.String(), nil
+	case bool:
+		if val {
+			return "1", nil
+		}
+		return "0", nil
+	}
+	return "", fmt.Errorf("unexpected value %v of %s", v, desc.Name)
+}
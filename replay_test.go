@@ -0,0 +1,134 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRowsTSV(t *testing.T) {
+	rows, err := ParseRows(strings.NewReader("id\tname\nUInt8\tString\n1\thello\n2\tit\\'s\n"), FormatTabSeparatedWithNamesAndTypes)
+	require.NoError(t, err)
+	defer rows.Close()
+	columns, err := rows.Columns()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "name"}, columns)
+
+	var result [][]interface{}
+	for rows.Next() {
+		var (
+			id   uint8
+			name string
+		)
+		require.NoError(t, rows.Scan(&id, &name))
+		result = append(result, []interface{}{id, name})
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, [][]interface{}{{uint8(1), "hello"}, {uint8(2), "it's"}}, result)
+}
+
+func TestParseRowsJSON(t *testing.T) {
+	testCases := []string{
+		`{
+			"meta": [
+				{"name": "id", "type": "UInt64"},
+				{"name": "name", "type": "String"},
+				{"name": "tags", "type": "Array(String)"},
+				{"name": "t", "type": "DateTime"},
+				{"name": "p", "type": "Tuple(Int8, String)"}
+			],
+			"data": [
+				{"id": "1", "name": "it's", "tags": ["a", "b\\c"], "t": "2019-01-02 03:04:05", "p": [1, "x"]}
+			],
+			"rows": 1
+		}`,
+		`{
+			"meta": [
+				{"name": "id", "type": "UInt64"},
+				{"name": "name", "type": "String"},
+				{"name": "tags", "type": "Array(String)"},
+				{"name": "t", "type": "DateTime"},
+				{"name": "p", "type": "Tuple(Int8, String)"}
+			],
+			"data": [
+				["1", "it's", ["a", "b\\c"], "2019-01-02 03:04:05", [1, "x"]]
+			],
+			"rows": 1
+		}`,
+	}
+	for _, tc := range testCases {
+		rows, err := ParseRows(strings.NewReader(tc), FormatJSON)
+		require.NoError(t, err)
+		columns, err := rows.Columns()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"id", "name", "tags", "t", "p"}, columns)
+		types, err := rows.ColumnTypes()
+		require.NoError(t, err)
+		assert.Equal(t, "Array(String)", types[2].DatabaseTypeName())
+
+		require.True(t, rows.Next())
+		var (
+			id   uint64
+			name string
+			tags []string
+			ts   time.Time
+			p    interface{}
+		)
+		require.NoError(t, rows.Scan(&id, &name, &tags, &ts, &p))
+		assert.EqualValues(t, 1, id)
+		assert.Equal(t, "it's", name)
+		assert.Equal(t, []string{"a", `b\c`}, tags)
+		assert.Equal(t, time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC), ts)
+		assert.Equal(t, struct {
+			Field0 int8
+			Field1 string
+		}{1, "x"}, p)
+		assert.False(t, rows.Next())
+		assert.NoError(t, rows.Err())
+		assert.NoError(t, rows.Close())
+	}
+}
+
+func TestParseRowsJSONQuotedIntsAndNulls(t *testing.T) {
+	rows, err := ParseRows(strings.NewReader(`{
+		"meta": [
+			{"name": "ids", "type": "Array(UInt64)"},
+			{"name": "n", "type": "Nullable(String)"},
+			{"name": "m", "type": "Array(Nullable(Int64))"},
+			{"name": "p", "type": "Tuple(Int128, Nullable(UInt8))"}
+		],
+		"data": [
+			{"ids": ["1", "2"], "n": null, "m": ["-3", null], "p": ["4", null]},
+			{"ids": [], "n": "x", "m": [], "p": ["5", 6]}
+		]
+	}`), FormatJSON)
+	require.NoError(t, err)
+	var (
+		ids []uint64
+		n   sql.NullString
+		m   []*int64
+		p   interface{}
+	)
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&ids, &n, &m, &p))
+	assert.Equal(t, []uint64{1, 2}, ids)
+	assert.False(t, n.Valid)
+	minus3 := int64(-3)
+	assert.Equal(t, []*int64{&minus3, nil}, m)
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&ids, &n, &m, &p))
+	assert.Equal(t, sql.NullString{String: "x", Valid: true}, n)
+	assert.False(t, rows.Next())
+	assert.NoError(t, rows.Err())
+}
+
+func TestParseRowsWrongFormat(t *testing.T) {
+	_, err := ParseRows(strings.NewReader(""), "Native")
+	assert.Error(t, err)
+	_, err = ParseRows(strings.NewReader("{"), FormatJSON)
+	assert.Error(t, err)
+}
@@ -13,3 +13,28 @@ func (noResult) LastInsertId() (int64, error) {
 func (noResult) RowsAffected() (int64, error) {
 	return 0, ErrNoRowsAffected
 }
+
+// execResult is the result of a query with the number of written rows
+// reported by the server in the X-ClickHouse-Summary header
+type execResult struct {
+	rowsAffected int64
+}
+
+func (execResult) LastInsertId() (int64, error) {
+	return 0, ErrNoLastInsertID
+}
+
+func (r execResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// summaryResult returns the result of the query with the summary s, it has
+// no RowsAffected if the server did not send the summary
+func summaryResult(s *querySummary) driver.Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ok {
+		return emptyResult
+	}
+	return execResult{rowsAffected: int64(s.progress.WrittenRows)}
+}
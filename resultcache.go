@@ -0,0 +1,196 @@
+package clickhouse
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"database/sql/driver"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultResultCacheSize limits the number of results kept by the result cache
+const defaultResultCacheSize = 1000
+
+// WithCacheTTL returns a copy of ctx which caches the results of the queries
+// executed with it in memory for ttl. The results are shared by all
+// connections of the process and keyed by the normalized query with its
+// arguments, the settings and the user, so the queries which differ only in
+// whitespaces and comments share a result. Concurrent identical queries are
+// executed once.
+//
+// Results larger than 8MB and queries with external tables are not cached.
+// Note that a coalesced query is executed with the context of the first
+// caller, so its cancellation fails all waiters of the same query.
+func WithCacheTTL(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, cacheTTLKey, ttl)
+}
+
+var (
+	sharedResults = newResultCache(defaultResultCacheSize)
+	resultFlights flightGroup
+)
+
+// resultCache keeps up to maxEntries least recently used results until they expire
+type resultCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+}
+
+type cachedResult struct {
+	key     string
+	body    []byte
+	expires time.Time
+}
+
+func newResultCache(maxEntries int) *resultCache {
+	return &resultCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// get returns the body of the result for the key unless it has expired
+func (c *resultCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	r := e.Value.(*cachedResult)
+	if !time.Now().Before(r.expires) {
+		c.lru.Remove(e)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return r.body, true
+}
+
+// set stores the body of the result for the key until expires
+func (c *resultCache) set(key string, body []byte, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		r := e.Value.(*cachedResult)
+		r.body, r.expires = body, expires
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&cachedResult{key: key, body: body, expires: expires})
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.entries, e.Value.(*cachedResult).key)
+	}
+}
+
+// doCachedRequest executes the read-only request of the query, or returns
+// its cached result if the context has a cache TTL
+func (c *conn) doCachedRequest(ctx context.Context, req *http.Request, query string, args []driver.Value) (io.ReadCloser, error) {
+	ttl, _ := ctx.Value(cacheTTLKey).(time.Duration)
+	if ttl <= 0 || len(externalTables(ctx)) > 0 {
+		return c.doRequestRetrying(ctx, req, true)
+	}
+	key, err := c.resultCacheKey(ctx, req, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if body, ok := sharedResults.get(key); ok {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	v, err := resultFlights.do(key, func() (interface{}, error) {
+		body, err := c.doRequestRetrying(ctx, req, true)
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) <= maxCachedResponseSize {
+			sharedResults.set(key, b, time.Now().Add(ttl))
+		}
+		return b, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(v.([]byte))), nil
+}
+
+// resultCacheKey builds a key of the result of the request: the credentials,
+// the quota key, the custom headers, the host, the parameters of the request
+// except query_id and the query with its arguments and the modifiers of the
+// context split into words. The cache is shared by all connections of the
+// process, the host keeps apart the results of different servers with the
+// same credentials and the headers the results of different tenants of a
+// gateway.
+func (c *conn) resultCacheKey(ctx context.Context, req *http.Request, query string, args []driver.Value) (string, error) {
+	query, err := c.rewriteQuery(ctx, query, args)
+	if err != nil {
+		return "", err
+	}
+	words, err := splitSQL(query)
+	if err != nil {
+		return "", err
+	}
+	params := req.URL.Query()
+	params.Del(queryIDParamName)
+	var b strings.Builder
+	b.WriteString(req.Header.Get("Authorization"))
+	b.WriteByte('\n')
+	b.WriteString(req.Header.Get(userHeader))
+	b.WriteByte('\n')
+	b.WriteString(req.Header.Get(keyHeader))
+	b.WriteByte('\n')
+	b.WriteString(req.Header.Get(quotaHeader))
+	b.WriteByte('\n')
+	for _, name := range c.headerNames(ctx) {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(req.Header.Get(name))
+		b.WriteByte('\n')
+	}
+	b.WriteString(req.URL.Host)
+	b.WriteString(req.URL.Path)
+	b.WriteByte('?')
+	b.WriteString(params.Encode())
+	b.WriteByte('\n')
+	for i, w := range words {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(w.text)
+	}
+	return b.String(), nil
+}
+
+// headerNames returns the sorted canonical names of the headers set by
+// setHeaders
+func (c *conn) headerNames(ctx context.Context) []string {
+	set := make(map[string]struct{}, len(c.headers))
+	for k := range c.headers {
+		set[http.CanonicalHeaderKey(k)] = struct{}{}
+	}
+	headers, _ := ctx.Value(headersKey).(map[string]string)
+	for k := range headers {
+		set[http.CanonicalHeaderKey(k)] = struct{}{}
+	}
+	names := make([]string, 0, len(set))
+	for k := range set {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
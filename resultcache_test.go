@@ -0,0 +1,175 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultCache(t *testing.T) {
+	var hits int32
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		atomic.AddInt32(&hits, 1)
+		io.WriteString(w, "a\nInt32\n1\n")
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	query := func(ctx context.Context, query string, args ...interface{}) {
+		var v int32
+		require.NoError(t, db.QueryRowContext(ctx, query, args...).Scan(&v))
+		assert.Equal(t, int32(1), v)
+	}
+	ctx := WithCacheTTL(context.Background(), time.Minute)
+	query(ctx, "SELECT a FROM cached WHERE b = ?", 1)
+	query(ctx, "SELECT a\n  FROM cached -- comment\n  WHERE b = ?", 1)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&hits))
+
+	query(ctx, "SELECT a FROM cached WHERE b = ?", 2)
+	query(WithSettings(ctx, map[string]interface{}{"max_threads": 1}), "SELECT a FROM cached WHERE b = ?", 1)
+	query(context.Background(), "SELECT a FROM cached WHERE b = ?", 1)
+	assert.EqualValues(t, 4, atomic.LoadInt32(&hits))
+
+	short := WithCacheTTL(context.Background(), 10*time.Millisecond)
+	query(short, "SELECT a FROM expired")
+	query(short, "SELECT a FROM expired")
+	assert.EqualValues(t, 5, atomic.LoadInt32(&hits))
+	time.Sleep(20 * time.Millisecond)
+	query(short, "SELECT a FROM expired")
+	assert.EqualValues(t, 6, atomic.LoadInt32(&hits))
+}
+
+func TestResultCacheHosts(t *testing.T) {
+	var hits int32
+	handler := func(value string) func(w http.ResponseWriter, r *http.Request, query string) {
+		return func(w http.ResponseWriter, r *http.Request, query string) {
+			atomic.AddInt32(&hits, 1)
+			io.WriteString(w, "a\nString\n"+value+"\n")
+		}
+	}
+	ts1, dsn1 := newTestServer(handler("first"))
+	defer ts1.Close()
+	ts2, dsn2 := newTestServer(handler("second"))
+	defer ts2.Close()
+
+	ctx := WithCacheTTL(context.Background(), time.Minute)
+	for _, tc := range []struct{ dsn, expected string }{{dsn1, "first"}, {dsn2, "second"}, {dsn1, "first"}} {
+		db, err := sql.Open("clickhouse", tc.dsn)
+		require.NoError(t, err)
+		var v string
+		require.NoError(t, db.QueryRowContext(ctx, "SELECT a FROM hosts").Scan(&v))
+		assert.Equal(t, tc.expected, v)
+		db.Close()
+	}
+	assert.EqualValues(t, 2, atomic.LoadInt32(&hits))
+}
+
+func TestResultCacheHeaders(t *testing.T) {
+	var hits int32
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		atomic.AddInt32(&hits, 1)
+		io.WriteString(w, "a\nString\n"+r.Header.Get("X-Tenant-Id")+r.Header.Get(quotaHeader)+"\n")
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := WithCacheTTL(context.Background(), time.Minute)
+	for _, tenant := range []string{"a", "b", "a", "b"} {
+		var v string
+		tenantCtx := WithHeaders(ctx, map[string]string{"X-Tenant-Id": tenant})
+		require.NoError(t, db.QueryRowContext(tenantCtx, "SELECT a FROM tenants").Scan(&v))
+		assert.Equal(t, tenant, v)
+	}
+	assert.EqualValues(t, 2, atomic.LoadInt32(&hits))
+
+	// the quota key keeps apart the results too
+	var v string
+	require.NoError(t, db.QueryRowContext(WithQuotaKey(ctx, "c"), "SELECT a FROM tenants").Scan(&v))
+	assert.Equal(t, "c", v)
+	assert.EqualValues(t, 3, atomic.LoadInt32(&hits))
+}
+
+func TestResultCacheSingleflight(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		io.WriteString(w, "a\nInt32\n2\n3\n")
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	const n = 5
+	var wg sync.WaitGroup
+	results := make([][]int32, n)
+	ctx := WithCacheTTL(context.Background(), time.Minute)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rows, err := db.QueryContext(ctx, "SELECT a FROM coalesced")
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var v int32
+				assert.NoError(t, rows.Scan(&v))
+				results[i] = append(results[i], v)
+			}
+			assert.NoError(t, rows.Err())
+		}(i)
+	}
+	// wait for all queries to join the same flight
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		resultFlights.mu.Lock()
+		var dups int
+		for _, c := range resultFlights.calls {
+			dups = c.dups
+		}
+		resultFlights.mu.Unlock()
+		if dups == n-1 {
+			break
+		}
+	}
+	close(release)
+	wg.Wait()
+
+	assert.EqualValues(t, 1, atomic.LoadInt32(&hits))
+	for _, r := range results {
+		assert.Equal(t, []int32{2, 3}, r)
+	}
+}
+
+func TestResultCacheEviction(t *testing.T) {
+	c := newResultCache(2)
+	expires := time.Now().Add(time.Minute)
+	c.set("a", []byte("1"), expires)
+	c.set("b", []byte("2"), expires)
+	_, ok := c.get("a")
+	assert.True(t, ok)
+	c.set("c", []byte("3"), expires)
+	_, ok = c.get("b")
+	assert.False(t, ok)
+	body, ok := c.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), body)
+}
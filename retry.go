@@ -0,0 +1,84 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// defaultRetryBackoff is the delay before the first retry if Config.RetryBackoff is not set
+const defaultRetryBackoff = 100 * time.Millisecond
+
+// retryableCodes are the codes of server errors which are worth retrying:
+// TIMEOUT_EXCEEDED, READONLY, NO_FREE_CONNECTION, SOCKET_TIMEOUT and NETWORK_ERROR
+var retryableCodes = []int{159, 164, 203, 209, 210}
+
+// doRequestRetrying does the request and retries it up to Config.MaxRetries
+// times on transient errors if the query is idempotent. The delay between
+// the attempts starts with Config.RetryBackoff and doubles every time.
+func (c *conn) doRequestRetrying(ctx context.Context, req *http.Request, idempotent bool) (io.ReadCloser, error) {
+	backoff := c.retryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	for attempt := 0; ; attempt++ {
+		body, err := c.doRequest(ctx, req)
+		if err == nil || !idempotent || attempt >= c.maxRetries || ctx.Err() != nil || !isRetryable(err) {
+			return body, err
+		}
+		if req.Body != nil {
+			if req.GetBody == nil {
+				// the body is streamed and can not be sent again
+				return body, err
+			}
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		c.logf(Logger.Warnf, "retrying the request after error: %v", err)
+		timer := time.NewTimer(backoff << uint(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// isRetryable reports whether the error of the request is transient
+func isRetryable(err error) bool {
+	var ex *Exception
+	if errors.As(err, &ex) {
+		for _, code := range retryableCodes {
+			if ex.Code == code {
+				return true
+			}
+		}
+		return false
+	}
+	if isDialError(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isIdempotent reports whether the query can be sent again after a failure.
+// An INSERT is idempotent only if the server deduplicates it by the token.
+func isIdempotent(query string, req *http.Request) bool {
+	words, err := splitSQL(query)
+	if err != nil || len(words) == 0 {
+		return false
+	}
+	switch {
+	case returnsRows(query):
+		return true
+	case words[0].is("INSERT"):
+		return len(req.URL.Query().Get("insert_deduplication_token")) > 0
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package clickhouse
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *testing.T) {
+	var hits, failures int
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		hits++
+		if failures > 0 {
+			failures--
+			http.Error(w, "Code: 159. DB::Exception: Timeout exceeded: elapsed 5 seconds. (TIMEOUT_EXCEEDED)", http.StatusInternalServerError)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Write([]byte("1\nUInt8\n1\n"))
+		}
+	})
+	defer ts.Close()
+
+	cfg, err := ParseDSN(dsn + "?max_retries=2&retry_backoff=1ms")
+	require.NoError(t, err)
+	assert.Equal(t, 2, cfg.MaxRetries)
+	assert.Equal(t, time.Millisecond, cfg.RetryBackoff)
+	assert.Contains(t, cfg.FormatDSN(), "max_retries=2&retry_backoff=1ms")
+	cn := newConn(cfg)
+	// the version is not queried for deduplication tokens
+	cn.version = &Version{Major: 22, Minor: 8}
+	ctx := context.Background()
+
+	hits, failures = 0, 2
+	rows, err := cn.query(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	rows.Close()
+	assert.Equal(t, 3, hits)
+
+	hits, failures = 0, 3
+	_, err = cn.exec(ctx, "SELECT 1", nil)
+	if assert.Error(t, err) {
+		assert.Equal(t, 159, err.(*Exception).Code)
+	}
+	assert.Equal(t, 3, hits)
+
+	// inserts are retried only if they are deduplicated
+	hits, failures = 0, 1
+	_, err = cn.exec(ctx, "INSERT INTO t VALUES (1)", nil)
+	assert.Error(t, err)
+	assert.Equal(t, 1, hits)
+	hits, failures = 0, 1
+	_, err = cn.exec(withSettings(ctx, map[string]string{"insert_deduplication_token": "abc"}), "INSERT INTO t VALUES (1)", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, hits)
+	hits, failures = 0, 1
+	_, err = cn.exec(WithDeduplicationToken(ctx, "abc"), "INSERT INTO t VALUES (1)", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, hits)
+
+	// cancelled context stops retries
+	hits, failures = 0, 3
+	cn.retryBackoff = time.Hour
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = cn.exec(ctx, "SELECT 1", nil)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 1, hits)
+}
+
+func TestRetryConnectionRefused(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	addr := ts.Listener.Addr().String()
+	ts.Close()
+
+	cfg, err := ParseDSN("http://" + addr + "/?max_retries=2&retry_backoff=1ms")
+	require.NoError(t, err)
+	start := time.Now()
+	_, err = newConn(cfg).query(context.Background(), "SELECT 1", nil)
+	assert.True(t, isDialError(err), "%v", err)
+	assert.True(t, time.Since(start) >= 3*time.Millisecond)
+}
+
+func TestIsIdempotent(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:8123/", nil)
+	require.NoError(t, err)
+	assert.True(t, isIdempotent("select 1", req))
+	assert.True(t, isIdempotent("WITH 1 AS x SELECT x", req))
+	assert.True(t, isIdempotent("SHOW TABLES", req))
+	assert.False(t, isIdempotent("INSERT INTO t VALUES (1)", req))
+	assert.False(t, isIdempotent("ALTER TABLE t DELETE WHERE 1", req))
+	assert.False(t, isIdempotent("", req))
+	req.URL.RawQuery = "insert_deduplication_token=abc"
+	assert.True(t, isIdempotent("INSERT INTO t VALUES (1)", req))
+}
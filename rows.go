@@ -1,29 +1,59 @@
 package clickhouse
 
 import (
+	"bufio"
+	"context"
 	"database/sql/driver"
 	"encoding/csv"
 	"fmt"
 	"io"
+	"math"
 	"reflect"
+	"strconv"
 	"strings"
-	"time"
 )
 
-func newTextRows(c *conn, body io.ReadCloser, location *time.Location, useDBLocation bool) (*textRows, error) {
-	tsvReader := csv.NewReader(body)
+// defaultBufferSize is the size of the read buffer of results if
+// Config.BufferSize is not set
+const defaultBufferSize = 4096
+
+// newBodyReader returns the buffered reader of the body of a result. Rows are
+// decoded while the body is read, so results of any size are read through
+// the buffer of a fixed size.
+func newBodyReader(c *conn, body io.Reader) *bufio.Reader {
+	size := defaultBufferSize
+	if c != nil && c.bufferSize > 0 {
+		size = c.bufferSize
+	}
+	return bufio.NewReaderSize(body, size)
+}
+
+// WithEnumAsNumber returns a copy of ctx which scans the Enum8 and Enum16
+// values of the results of the queries executed with it as the int8 and
+// int16 numbers of the elements if asNumber is set or as their names
+// otherwise, it overrides Config.EnumAsNumber
+func WithEnumAsNumber(ctx context.Context, asNumber bool) context.Context {
+	return context.WithValue(ctx, enumAsNumberKey, asNumber)
+}
+
+func newTextRows(c *conn, body io.ReadCloser, opt *DataParserOptions) (*textRows, error) {
+	tsvReader := csv.NewReader(newBodyReader(c, body))
 	tsvReader.Comma = '\t'
 	tsvReader.LazyQuotes = true
+	tsvReader.ReuseRecord = true
 
 	columns, err := tsvReader.Read()
 	if err != nil {
 		return nil, err
 	}
+	columns = append([]string(nil), columns...)
 
 	types, err := tsvReader.Read()
 	if err != nil {
 		return nil, err
 	}
+	types = append([]string(nil), types...)
+	line, _ := tsvReader.FieldPos(0)
 	for i := range types {
 		types[i], err = readUnquoted(strings.NewReader(types[i]), 0)
 		if err != nil {
@@ -32,67 +62,127 @@ func newTextRows(c *conn, body io.ReadCloser, location *time.Location, useDBLoca
 	}
 
 	parsers := make([]DataParser, len(types), len(types))
+	descs := make([]*TypeDesc, len(types))
 	for i, typ := range types {
 		desc, err := ParseTypeDesc(typ)
 		if err != nil {
 			return nil, err
 		}
+		descs[i] = desc
 
-		parsers[i], err = NewDataParser(desc, &DataParserOptions{
-			Location:      location,
-			UseDBLocation: useDBLocation,
-		})
+		parsers[i], err = NewDataParser(desc, opt)
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	return &textRows{
-		c:        c,
-		respBody: body,
-		tsv:      tsvReader,
-		columns:  columns,
-		types:    types,
-		parsers:  parsers,
+		resultRows: resultRows{
+			c:        c,
+			respBody: body,
+			columns:  columns,
+			types:    types,
+			descs:    descs,
+		},
+		tsv:     tsvReader,
+		parsers: parsers,
+		line:    line,
 	}, nil
 }
 
-type textRows struct {
+// resultRows holds the response and the columns of a query result, the rows
+// of all formats embed it
+type resultRows struct {
 	c        *conn
 	respBody io.ReadCloser
-	tsv      *csv.Reader
 	columns  []string
 	types    []string
+	descs    []*TypeDesc
+	onClose  func()
+	rowsRead int64
+	totals   []driver.Value
+	extremes [][]driver.Value
+}
+
+type textRows struct {
+	resultRows
+	tsv      *csv.Reader
 	parsers  []DataParser
+	checksum *rowsChecksum
+	reader   strings.Reader
+	// sections enables parsing of the totals and the extremes, which follow
+	// the rows after blank lines
+	sections bool
+	line     int
+	extra    [][][]driver.Value
 }
 
-func (r *textRows) Columns() []string {
+func (r *resultRows) Columns() []string {
 	return r.columns
 }
 
-func (r *textRows) Close() error {
-	r.c.cancel = nil
+func (r *resultRows) Close() error {
+	if r.c != nil {
+		r.c.cancel = nil
+	}
+	if r.onClose != nil {
+		r.onClose()
+		r.onClose = nil
+	}
 	return r.respBody.Close()
 }
 
 func (r *textRows) Next(dest []driver.Value) error {
-	row, err := r.tsv.Read()
-	if err != nil {
-		return err
+	for {
+		row, err := r.tsv.Read()
+		if err != nil {
+			if err == io.EOF && r.sections {
+				if serr := r.setSections(r.extra); serr != nil {
+					return serr
+				}
+			}
+			return err
+		}
+		if r.sections {
+			// blank lines are skipped by the reader
+			line, _ := r.tsv.FieldPos(0)
+			if line > r.line+1 {
+				r.extra = append(r.extra, nil)
+			}
+			r.line = line
+			if len(r.extra) > 0 {
+				values := make([]driver.Value, len(row))
+				if err = r.parse(row, values); err != nil {
+					return err
+				}
+				r.extra[len(r.extra)-1] = append(r.extra[len(r.extra)-1], values)
+				continue
+			}
+		}
+		if r.checksum != nil {
+			r.checksum.update(row)
+		}
+		if err = r.parse(row, dest); err != nil {
+			return err
+		}
+		r.rowsRead++
+		return nil
 	}
+}
 
+// parse parses the values of the row into dest
+func (r *textRows) parse(row []string, dest []driver.Value) error {
 	for i, s := range row {
-		reader := strings.NewReader(s)
-		v, err := r.parsers[i].Parse(reader)
+		r.reader.Reset(s)
+		v, err := r.parsers[i].Parse(&r.reader)
 		if err != nil {
 			return err
 		}
-		if _, _, err := reader.ReadRune(); err != io.EOF {
+		if _, _, err := r.reader.ReadRune(); err != io.EOF {
 			return fmt.Errorf("trailing data after parsing the value")
 		}
 		dest[i] = v
 	}
-
 	return nil
 }
 
@@ -102,6 +192,63 @@ func (r *textRows) ColumnTypeScanType(index int) reflect.Type {
 }
 
 // ColumnTypeDatabaseTypeName implements the driver.RowsColumnTypeDatabaseTypeName
-func (r *textRows) ColumnTypeDatabaseTypeName(index int) string {
+func (r *resultRows) ColumnTypeDatabaseTypeName(index int) string {
 	return r.types[index]
 }
+
+// ColumnTypeNullable implements the driver.RowsColumnTypeNullable
+func (r *resultRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	desc := r.descs[index]
+	if desc.Name == "LowCardinality" && len(desc.Args) == 1 {
+		desc = desc.Args[0]
+	}
+	return desc.Name == "Nullable", true
+}
+
+// decimalPrecisions are precisions of the decimal types with the scale argument only
+var decimalPrecisions = map[string]int64{
+	"Decimal32":  9,
+	"Decimal64":  18,
+	"Decimal128": 38,
+	"Decimal256": 76,
+}
+
+// ColumnTypePrecisionScale implements the driver.RowsColumnTypePrecisionScale
+func (r *resultRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	desc := baseType(r.descs[index])
+	var err error
+	switch {
+	case desc.Name == "Decimal" && len(desc.Args) == 2:
+		if precision, err = strconv.ParseInt(desc.Args[0].Name, 10, 64); err != nil {
+			return 0, 0, false
+		}
+		scale, err = strconv.ParseInt(desc.Args[1].Name, 10, 64)
+	case decimalPrecisions[desc.Name] > 0 && len(desc.Args) == 1:
+		precision = decimalPrecisions[desc.Name]
+		scale, err = strconv.ParseInt(desc.Args[0].Name, 10, 64)
+	default:
+		return 0, 0, false
+	}
+	return precision, scale, err == nil
+}
+
+// ColumnTypeLength implements the driver.RowsColumnTypeLength
+func (r *resultRows) ColumnTypeLength(index int) (length int64, ok bool) {
+	desc := baseType(r.descs[index])
+	switch {
+	case desc.Name == "String":
+		return math.MaxInt64, true
+	case desc.Name == "FixedString" && len(desc.Args) == 1:
+		length, err := strconv.ParseInt(desc.Args[0].Name, 10, 64)
+		return length, err == nil
+	}
+	return 0, false
+}
+
+// baseType returns the type wrapped with Nullable and LowCardinality
+func baseType(desc *TypeDesc) *TypeDesc {
+	for (desc.Name == "Nullable" || desc.Name == "LowCardinality") && len(desc.Args) == 1 {
+		desc = desc.Args[0]
+	}
+	return desc
+}
@@ -2,14 +2,19 @@ package clickhouse
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"database/sql/driver"
 	"io"
 	"io/ioutil"
+	"math"
+	"net/http"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type bufReadCloser struct {
@@ -22,7 +27,7 @@ func (r *bufReadCloser) Close() error {
 
 func TestTextRows(t *testing.T) {
 	buf := bytes.NewReader([]byte("Number\tText\nInt32\tString\n1\thello\n2\tworld\n"))
-	rows, err := newTextRows(&conn{}, &bufReadCloser{buf}, time.Local, false)
+	rows, err := newTextRows(&conn{}, &bufReadCloser{buf}, &DataParserOptions{Location: time.Local})
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -53,9 +58,53 @@ func TestTextRows(t *testing.T) {
 	assert.Empty(t, data)
 }
 
+func TestTextRowsColumnTypes(t *testing.T) {
+	buf := bytes.NewReader([]byte("a\tb\tc\td\te\n" +
+		"Decimal(10, 2)\tLowCardinality(String)\tFixedString(16)\tInt32\tArray(String)\n"))
+	rows, err := newTextRows(&conn{}, &bufReadCloser{buf}, &DataParserOptions{Location: time.Local})
+	if !assert.NoError(t, err) {
+		return
+	}
+	for i := range rows.columns {
+		nullable, ok := rows.ColumnTypeNullable(i)
+		assert.True(t, ok)
+		assert.False(t, nullable, i)
+	}
+
+	precision, scale, ok := rows.ColumnTypePrecisionScale(0)
+	assert.Equal(t, []interface{}{int64(10), int64(2), true}, []interface{}{precision, scale, ok})
+	_, _, ok = rows.ColumnTypePrecisionScale(3)
+	assert.False(t, ok)
+
+	length, ok := rows.ColumnTypeLength(1)
+	assert.Equal(t, []interface{}{int64(math.MaxInt64), true}, []interface{}{length, ok})
+	length, ok = rows.ColumnTypeLength(2)
+	assert.Equal(t, []interface{}{int64(16), true}, []interface{}{length, ok})
+	_, ok = rows.ColumnTypeLength(4)
+	assert.False(t, ok)
+
+	// types are described without creating parsers
+	rows = &textRows{}
+	for _, typ := range []string{"Nullable(Decimal64(4))", "LowCardinality(Nullable(String))"} {
+		desc, err := ParseTypeDesc(typ)
+		if !assert.NoError(t, err) {
+			return
+		}
+		rows.descs = append(rows.descs, desc)
+	}
+	nullable, ok := rows.ColumnTypeNullable(0)
+	assert.True(t, nullable && ok)
+	nullable, ok = rows.ColumnTypeNullable(1)
+	assert.True(t, nullable && ok)
+	precision, scale, ok = rows.ColumnTypePrecisionScale(0)
+	assert.Equal(t, []interface{}{int64(18), int64(4), true}, []interface{}{precision, scale, ok})
+	length, ok = rows.ColumnTypeLength(1)
+	assert.Equal(t, []interface{}{int64(math.MaxInt64), true}, []interface{}{length, ok})
+}
+
 func TestTextRowsQuoted(t *testing.T) {
 	buf := bytes.NewReader([]byte("text\nArray(String)\n['Quote: \"here\"']"))
-	rows, err := newTextRows(&conn{}, &bufReadCloser{buf}, time.Local, false)
+	rows, err := newTextRows(&conn{}, &bufReadCloser{buf}, &DataParserOptions{Location: time.Local})
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -70,7 +119,7 @@ func TestTextRowsQuoted(t *testing.T) {
 
 func TestTextRowsNewLine(t *testing.T) {
 	buf := bytes.NewReader([]byte("text\nString\nHello\\nThere"))
-	rows, err := newTextRows(&conn{}, &bufReadCloser{buf}, time.Local, false)
+	rows, err := newTextRows(&conn{}, &bufReadCloser{buf}, &DataParserOptions{Location: time.Local})
 	if !assert.NoError(t, err) {
 		return
 	}
@@ -82,3 +131,54 @@ func TestTextRowsNewLine(t *testing.T) {
 	}
 	assert.Equal(t, []driver.Value{"Hello\nThere"}, dest)
 }
+
+func BenchmarkTextRowsNext(b *testing.B) {
+	var data bytes.Buffer
+	data.WriteString("a\tb\tc\td\nInt32\tString\tFloat64\tDateTime\n")
+	for i := 0; i < 1000; i++ {
+		data.WriteString("123\thello world\t1.5\t2019-01-02 03:04:05\n")
+	}
+	raw := data.Bytes()
+	dest := make([]driver.Value, 4)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rows, err := newTextRows(&conn{}, &bufReadCloser{bytes.NewReader(raw)}, &DataParserOptions{Location: time.UTC})
+		if err != nil {
+			b.Fatal(err)
+		}
+		for {
+			if err := rows.Next(dest); err != nil {
+				if err != io.EOF {
+					b.Fatal(err)
+				}
+				break
+			}
+		}
+	}
+}
+
+func TestScanRoundTripTypes(t *testing.T) {
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		io.WriteString(w, "e\ti\tf\nEnum8(\\'a\\' = 1, \\'b\\' = -2)\tIntervalMinute\tFixedString(3)\nb\t90\tx\\0\xff\n")
+	})
+	defer ts.Close()
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var (
+		name  string
+		d     time.Duration
+		fixed []byte
+	)
+	require.NoError(t, db.QueryRow("SELECT e, i, f FROM t").Scan(&name, &d, &fixed))
+	assert.Equal(t, "b", name)
+	assert.Equal(t, 90*time.Minute, d)
+	assert.Equal(t, []byte{'x', 0, 0xff}, fixed)
+
+	var number int8
+	ctx := WithEnumAsNumber(context.Background(), true)
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT e, i, f FROM t").Scan(&number, &d, &fixed))
+	assert.Equal(t, int8(-2), number)
+}
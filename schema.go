@@ -0,0 +1,110 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+)
+
+const (
+	defaultChangelogTable  = "_ddl_changelog"
+	defaultChangelogEngine = "ReplicatedMergeTree('/clickhouse/tables/{shard}/{database}/_ddl_changelog', '{replica}')"
+)
+
+// DDLEntry is a DDL statement recorded by SchemaTracker
+type DDLEntry struct {
+	Timestamp   time.Time
+	Query       string
+	User        string
+	Application string
+}
+
+// SchemaTracker executes DDL statements and records them into a changelog
+// table, which provides an audit trail of schema changes.
+type SchemaTracker struct {
+	db          *sql.DB
+	application string
+	// Table is the name of the changelog table, _ddl_changelog by default
+	Table string
+	// Engine is the engine of the changelog table, by default it is
+	// ReplicatedMergeTree, which requires the {shard} and {replica} macros
+	Engine string
+}
+
+// NewSchemaTracker returns a new SchemaTracker recording statements
+// executed by application
+func NewSchemaTracker(db *sql.DB, application string) *SchemaTracker {
+	return &SchemaTracker{
+		db:          db,
+		application: application,
+		Table:       defaultChangelogTable,
+		Engine:      defaultChangelogEngine,
+	}
+}
+
+// Init creates the changelog table if it does not exist
+func (t *SchemaTracker) Init(ctx context.Context) error {
+	_, err := t.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+t.Table+" ("+
+		"timestamp DateTime, query String, user String, application String"+
+		") ENGINE = "+t.Engine+" ORDER BY timestamp")
+	return err
+}
+
+// Exec executes the statement and records it with its arguments into the
+// changelog if it is a DDL statement and it succeeds
+func (t *SchemaTracker) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := t.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	ddl, err := isDDL(query)
+	if err != nil || !ddl {
+		return result, err
+	}
+	if len(args) > 0 {
+		values := make([]driver.Value, len(args))
+		for i, arg := range args {
+			if values[i], err = (converter{}).ConvertValue(arg); err != nil {
+				return nil, err
+			}
+		}
+		if query, err = interpolateParams(query, values); err != nil {
+			return nil, err
+		}
+	}
+	_, err = t.db.ExecContext(ctx, "INSERT INTO "+t.Table+" (timestamp, query, user, application) "+
+		"SELECT now(), ?, currentUser(), ?", query, t.application)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// History returns the statements recorded since the given time, oldest first
+func (t *SchemaTracker) History(ctx context.Context, since time.Time) ([]DDLEntry, error) {
+	rows, err := t.db.QueryContext(ctx, "SELECT timestamp, query, user, application FROM "+t.Table+
+		" WHERE timestamp >= ? ORDER BY timestamp", since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []DDLEntry
+	for rows.Next() {
+		var e DDLEntry
+		if err := rows.Scan(&e.Timestamp, &e.Query, &e.User, &e.Application); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// isDDL reports whether the query changes a schema
+func isDDL(query string) (bool, error) {
+	words, err := splitSQL(query)
+	if err != nil || len(words) == 0 {
+		return false, err
+	}
+	return words[0].is("CREATE", "ALTER", "DROP", "RENAME", "TRUNCATE", "EXCHANGE", "ATTACH", "DETACH"), nil
+}
@@ -0,0 +1,57 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaTracker(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		if strings.HasPrefix(query, "SELECT timestamp") {
+			io.WriteString(w, "timestamp\tquery\tuser\tapplication\nDateTime\tString\tString\tString\n"+
+				"2020-01-02 03:04:05\tDROP TABLE t\tdefault\tapp\n")
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	tracker := NewSchemaTracker(db, "app")
+	tracker.Engine = "MergeTree"
+	require.NoError(t, tracker.Init(ctx))
+	_, err = tracker.Exec(ctx, "ALTER TABLE t DELETE WHERE s = ?", "x")
+	require.NoError(t, err)
+	_, err = tracker.Exec(ctx, "INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+
+	since := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries, err := tracker.History(ctx, since)
+	require.NoError(t, err)
+	assert.Equal(t, []DDLEntry{{
+		Timestamp:   time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Query:       "DROP TABLE t",
+		User:        "default",
+		Application: "app",
+	}}, entries)
+
+	assert.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS _ddl_changelog (timestamp DateTime, query String, user String, application String) ENGINE = MergeTree ORDER BY timestamp",
+		"ALTER TABLE t DELETE WHERE s = 'x'",
+		"INSERT INTO _ddl_changelog (timestamp, query, user, application) SELECT now(), 'ALTER TABLE t DELETE WHERE s = \\'x\\'', currentUser(), 'app'",
+		"INSERT INTO t VALUES (1)",
+		"SELECT timestamp, query, user, application FROM _ddl_changelog WHERE timestamp >= '2020-01-01 00:00:00' ORDER BY timestamp",
+	}, queries)
+}
@@ -0,0 +1,178 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// splitStatements splits the script into the statements separated by
+// semicolons, the data of an INSERT ... FORMAT is never split. It returns
+// the query as is if it has a single statement or can not be parsed.
+func splitStatements(query string) []string {
+	var (
+		statements []string
+		start      int
+	)
+	l := &sqlLexer{query: query}
+	for {
+		w, err := l.next()
+		if err != nil {
+			return []string{query}
+		}
+		if len(w.text) == 0 || w.is("FORMAT") {
+			break
+		}
+		if w.text == ";" && !w.quoted {
+			if s := strings.TrimSpace(query[start:w.start]); len(s) > 0 {
+				statements = append(statements, s)
+			}
+			start = w.end
+		}
+	}
+	if s := strings.TrimSpace(query[start:]); len(s) > 0 {
+		statements = append(statements, s)
+	}
+	if len(statements) < 2 {
+		return []string{query}
+	}
+	return statements
+}
+
+// scriptStatements returns the statements of a script with the arguments
+// interpolated, or the single statement of other queries
+func (c *conn) scriptStatements(query string, args []driver.Value) ([]string, error) {
+	if !c.stmtCache.get(query).script {
+		return []string{query}, nil
+	}
+	if len(args) == 0 {
+		return splitStatements(query), nil
+	}
+	query, err := interpolateParams(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return splitStatements(query), nil
+}
+
+// returnsRows reports whether the statement returns a result
+func returnsRows(query string) bool {
+	words, err := splitSQL(query)
+	return err == nil && len(words) > 0 && words[0].is("SELECT", "WITH", "SHOW", "DESCRIBE", "DESC", "EXISTS", "EXPLAIN")
+}
+
+// scriptError is the error of a statement of a script. Errors of the
+// statements after the first one do not match driver.ErrBadConn, so the
+// script is never sent again after some statements were executed.
+func scriptError(i int, err error) error {
+	if i > 0 && errors.Is(err, driver.ErrBadConn) {
+		err = errors.New("connection is lost")
+	}
+	return fmt.Errorf("clickhouse: statement %d of the script: %w", i+1, err)
+}
+
+// execScript executes the statements one by one, the affected rows of the
+// script are the sum of the affected rows of the statements
+func (c *conn) execScript(ctx context.Context, statements []string) (driver.Result, error) {
+	var (
+		total    int64
+		reported bool
+	)
+	for i, statement := range statements {
+		result, err := c.exec(ctx, statement, nil)
+		if err != nil {
+			return nil, scriptError(i, err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			total, reported = total+n, true
+		}
+	}
+	if !reported {
+		return emptyResult, nil
+	}
+	return execResult{rowsAffected: total}, nil
+}
+
+// queryScript executes the statements one by one, the results of the
+// statements which return rows are the result sets of the rows
+func (c *conn) queryScript(ctx context.Context, statements []string) (driver.Rows, error) {
+	rows := &scriptRows{c: c, ctx: ctx, statements: statements}
+	if err := rows.NextResultSet(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// scriptRows are the result sets of a script implementing the
+// driver.RowsNextResultSet, the statements which do not return rows are
+// executed between the result sets
+type scriptRows struct {
+	c          *conn
+	ctx        context.Context
+	statements []string
+	next       int
+	current    driver.Rows
+}
+
+// Columns implements the driver.Rows
+func (r *scriptRows) Columns() []string {
+	if r.current == nil {
+		return nil
+	}
+	return r.current.Columns()
+}
+
+// Next implements the driver.Rows
+func (r *scriptRows) Next(dest []driver.Value) error {
+	if r.current == nil {
+		return io.EOF
+	}
+	return r.current.Next(dest)
+}
+
+// Close implements the driver.Rows, the remaining statements are not executed
+func (r *scriptRows) Close() error {
+	r.statements = nil
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
+
+// HasNextResultSet implements the driver.RowsNextResultSet
+func (r *scriptRows) HasNextResultSet() bool {
+	return r.next < len(r.statements)
+}
+
+// NextResultSet implements the driver.RowsNextResultSet, it executes the
+// statements until the next one which returns rows
+func (r *scriptRows) NextResultSet() error {
+	if r.current != nil {
+		if err := r.current.Close(); err != nil {
+			return err
+		}
+		r.current = nil
+	}
+	for r.next < len(r.statements) {
+		i, statement := r.next, r.statements[r.next]
+		r.next++
+		if !returnsRows(statement) {
+			if _, err := r.c.exec(r.ctx, statement, nil); err != nil {
+				return scriptError(i, err)
+			}
+			continue
+		}
+		rows, err := r.c.query(r.ctx, statement, nil)
+		if err != nil {
+			return scriptError(i, err)
+		}
+		r.current = rows
+		return nil
+	}
+	return io.EOF
+}
@@ -0,0 +1,82 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitStatements(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected []string
+	}{
+		{"SELECT 1", []string{"SELECT 1"}},
+		{"SELECT 1;", []string{"SELECT 1;"}},
+		{"SELECT ';'", []string{"SELECT ';'"}},
+		{"CREATE TABLE t (a UInt8) ENGINE = Memory; -- comment;\nINSERT INTO t VALUES (';');\n\nSELECT * FROM t;",
+			[]string{"CREATE TABLE t (a UInt8) ENGINE = Memory", "-- comment;\nINSERT INTO t VALUES (';')", "SELECT * FROM t"}},
+		{"SELECT 1; /* ; */ SELECT 2", []string{"SELECT 1", "/* ; */ SELECT 2"}},
+		{"INSERT INTO t FORMAT CSV\n1;2\n3;4", []string{"INSERT INTO t FORMAT CSV\n1;2\n3;4"}},
+		{"SELECT 'unterminated; SELECT 2", []string{"SELECT 'unterminated; SELECT 2"}},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, splitStatements(tc.query), tc.query)
+	}
+}
+
+func TestScript(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		switch query {
+		case "SELECT 1":
+			w.Write([]byte("x\nUInt8\n1\n"))
+		case "SELECT 'a', 'b'":
+			w.Write([]byte("x\ty\nString\tString\na\tb\n"))
+		case "DROP TABLE unknown":
+			http.Error(w, "Code: 60. DB::Exception: Table default.unknown does not exist. (UNKNOWN_TABLE)", http.StatusNotFound)
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE t (a UInt8) ENGINE = Memory; INSERT INTO t VALUES (?);", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CREATE TABLE t (a UInt8) ENGINE = Memory", "INSERT INTO t VALUES (1)"}, queries)
+
+	queries = nil
+	_, err = db.Exec("TRUNCATE TABLE t; DROP TABLE unknown; DROP TABLE t")
+	var chErr *Error
+	if assert.True(t, errors.As(err, &chErr), "%v", err) {
+		assert.Equal(t, 60, chErr.Code)
+		assert.Contains(t, err.Error(), "statement 2 of the script")
+	}
+	assert.Equal(t, []string{"TRUNCATE TABLE t", "DROP TABLE unknown"}, queries)
+
+	queries = nil
+	rows, err := db.Query("SELECT 1; TRUNCATE TABLE t; SELECT 'a', 'b'; DROP TABLE t")
+	require.NoError(t, err)
+	defer rows.Close()
+	var sets [][]string
+	for {
+		columns, err := rows.Columns()
+		require.NoError(t, err)
+		sets = append(sets, columns)
+		for rows.Next() {
+		}
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, [][]string{{"x"}, {"x", "y"}}, sets)
+	assert.Equal(t, []string{"SELECT 1", "TRUNCATE TABLE t", "SELECT 'a', 'b'", "DROP TABLE t"}, queries)
+}
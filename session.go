@@ -0,0 +1,74 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
+)
+
+// SessionIDAuto is the value of Config.SessionID (session_id DSN parameter)
+// which gives every connection its own session. SET statements and temporary
+// tables are kept across the queries of a sql.Conn or sql.Tx, the session is
+// replaced when the connection is reused from the pool and Config.OnConnect
+// is called again to set up the new session.
+const SessionIDAuto = "auto"
+
+// sessionParams returns the parameters of the session of a new connection
+func (cfg *Config) sessionParams() map[string]string {
+	if len(cfg.SessionID) == 0 {
+		return nil
+	}
+	params := map[string]string{"session_id": cfg.SessionID}
+	if cfg.SessionID == SessionIDAuto {
+		params["session_id"] = newQueryID()
+	}
+	if cfg.SessionTimeout > 0 {
+		params["session_timeout"] = strconv.Itoa(int(cfg.SessionTimeout.Seconds()))
+	}
+	return params
+}
+
+// IsValid implements the driver.Validator. The connection is discarded by
+// database/sql instead of being reused if it is closed, if a response of it
+// was broken by the network, e.g. by a keep-alive connection closed by the
+// server, or if its host is down while another host of the DSN is healthy.
+func (c *conn) IsValid() bool {
+	if atomic.LoadInt32(&c.closed) != 0 || atomic.LoadInt32(&c.broken) != 0 {
+		return false
+	}
+	return c.hosts == nil || !c.hosts.shouldLeave(c.url.Host)
+}
+
+// markBroken makes the connection invalid if the request failed because of
+// the network rather than of the cancellation of its context
+func (c *conn) markBroken(ctx context.Context, err error) {
+	var netErr net.Error
+	if ctx.Err() == nil && (errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+		atomic.StoreInt32(&c.broken, 1)
+	}
+}
+
+// ResetSession implements the driver.SessionResetter, the connection with
+// the auto session starts a new one and runs Config.OnConnect in it. The
+// connection is discarded if the hook fails, its error is returned by the
+// connection replacing it.
+func (c *conn) ResetSession(ctx context.Context) error {
+	if !c.IsValid() {
+		return driver.ErrBadConn
+	}
+	if c.autoSession {
+		query := c.url.Query()
+		query.Set("session_id", newQueryID())
+		c.url.RawQuery = query.Encode()
+		if c.onConnect != nil {
+			if err := c.onConnect(ctx, c); err != nil {
+				return driver.ErrBadConn
+			}
+		}
+	}
+	return nil
+}
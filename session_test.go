@@ -0,0 +1,158 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	var sessions, timeouts []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		sessions = append(sessions, r.URL.Query().Get("session_id"))
+		timeouts = append(timeouts, r.URL.Query().Get("session_timeout"))
+	})
+	defer ts.Close()
+
+	cfg, err := ParseDSN(dsn + "?session_id=auto&session_timeout=30")
+	require.NoError(t, err)
+	assert.Equal(t, SessionIDAuto, cfg.SessionID)
+	assert.Equal(t, 30*time.Second, cfg.SessionTimeout)
+	assert.Empty(t, cfg.Params)
+	assert.Contains(t, cfg.FormatDSN(), "session_id=auto&session_timeout=30s")
+
+	db, err := sql.Open("clickhouse", cfg.FormatDSN())
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	sqlConn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	_, err = sqlConn.ExecContext(ctx, "CREATE TEMPORARY TABLE t (a UInt8)")
+	require.NoError(t, err)
+	_, err = sqlConn.ExecContext(ctx, "INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	require.NoError(t, sqlConn.Close())
+	// the connection is reused by the pool with a new session
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+
+	require.Len(t, sessions, 3)
+	assert.Len(t, sessions[0], 36)
+	assert.Equal(t, sessions[0], sessions[1])
+	assert.NotEqual(t, sessions[0], sessions[2])
+	assert.Len(t, sessions[2], 36)
+	assert.Equal(t, []string{"30", "30", "30"}, timeouts)
+
+	sessions = nil
+	db2, err := sql.Open("clickhouse", dsn+"?session_id=fixed")
+	require.NoError(t, err)
+	defer db2.Close()
+	_, err = db2.Exec("SELECT 1")
+	require.NoError(t, err)
+	_, err = db2.Exec("SELECT 1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fixed", "fixed"}, sessions)
+}
+
+func TestIsValid(t *testing.T) {
+	cfg, err := ParseDSN("http://localhost:8123,localhost:8124/default")
+	require.NoError(t, err)
+	c := newConn(cfg)
+	defer c.Close()
+	assert.True(t, c.IsValid())
+
+	// the breaks caused by the context do not invalidate the connection
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.markBroken(ctx, io.ErrUnexpectedEOF)
+	assert.True(t, c.IsValid())
+
+	c.hosts.markDown(c.url.Host, errors.New("down"))
+	assert.False(t, c.IsValid())
+	assert.Equal(t, driver.ErrBadConn, c.ResetSession(context.Background()))
+	c.hosts.markUp(c.url.Host)
+	assert.True(t, c.IsValid())
+
+	c.markBroken(context.Background(), io.ErrUnexpectedEOF)
+	assert.False(t, c.IsValid())
+}
+
+func TestOnConnect(t *testing.T) {
+	var queries, sessions []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		sessions = append(sessions, r.URL.Query().Get("session_id"))
+		if query == "SELECT broken" {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+		}
+	})
+	defer ts.Close()
+
+	cfg, err := ParseDSN(dsn + "?session_id=auto")
+	require.NoError(t, err)
+	var connects int
+	cfg.OnConnect = func(ctx context.Context, conn Connection) error {
+		connects++
+		_, err := conn.ExecContext(ctx, "SET max_threads = 1", nil)
+		return err
+	}
+	db := sql.OpenDB(NewConnector(cfg))
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec("SELECT 1")
+	require.NoError(t, err)
+	_, err = db.Exec("SELECT 2")
+	require.NoError(t, err)
+	// the reused connection gets a new session set up by the hook again
+	assert.Equal(t, 2, connects)
+	assert.Equal(t, []string{"SET max_threads = 1", "SELECT 1", "SET max_threads = 1", "SELECT 2"}, queries)
+	assert.Equal(t, sessions[0], sessions[1])
+	assert.Equal(t, sessions[2], sessions[3])
+	assert.NotEqual(t, sessions[1], sessions[3])
+
+	// the connection broken by the server is replaced
+	_, err = db.Exec("SELECT broken")
+	assert.Error(t, err)
+	_, err = db.Exec("SELECT 3")
+	require.NoError(t, err)
+	assert.Equal(t, 4, connects)
+	assert.Equal(t, "SET max_threads = 1", queries[len(queries)-2])
+
+	hookErr := errors.New("replica is read-only")
+	cfg.OnConnect = func(context.Context, Connection) error {
+		return hookErr
+	}
+	failing := sql.OpenDB(NewConnector(cfg))
+	defer failing.Close()
+	assert.Equal(t, hookErr, failing.Ping())
+
+	// the connection whose new session fails the hook is replaced
+	var calls int
+	cfg.OnConnect = func(context.Context, Connection) error {
+		calls++
+		if calls == 2 {
+			return hookErr
+		}
+		return nil
+	}
+	reused := sql.OpenDB(NewConnector(cfg))
+	defer reused.Close()
+	reused.SetMaxOpenConns(1)
+	require.NoError(t, reused.Ping())
+	require.NoError(t, reused.Ping())
+	assert.Equal(t, 3, calls)
+}
@@ -0,0 +1,101 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// WithSettings returns a copy of ctx which overrides the settings for the
+// queries executed with it, e.g.
+//
+//	ctx = clickhouse.WithSettings(ctx, map[string]interface{}{
+//	    "max_execution_time": 30,
+//	    "max_memory_usage":   10 << 30,
+//	})
+//
+// The settings are added to the URL parameters of the requests and override
+// the settings of the DSN and of the parent context. Booleans are sent as 1
+// and 0, durations as seconds, other values are formatted like arguments of
+// queries. A value which can not be formatted fails the query.
+func WithSettings(ctx context.Context, settings map[string]interface{}) context.Context {
+	formatted := make(map[string]string, len(settings))
+	for k, v := range settings {
+		s, err := formatSetting(v)
+		if err != nil {
+			return context.WithValue(ctx, settingsErrKey, fmt.Errorf("clickhouse: setting %s: %v", k, err))
+		}
+		formatted[k] = s
+	}
+	return withSettings(ctx, formatted)
+}
+
+// WithDeduplicationToken returns a copy of ctx which sets the
+// insert_deduplication_token setting of the INSERT queries executed with it.
+// Replicated tables skip the data of an insert with an already inserted
+// token, so the insert can be sent again after a network failure without
+// duplicating rows. Such inserts are retried if Config.MaxRetries is set.
+// The token is an idempotency key of the data, every batch needs its own one.
+func WithDeduplicationToken(ctx context.Context, token string) context.Context {
+	if len(token) == 0 {
+		return context.WithValue(ctx, settingsErrKey, fmt.Errorf("clickhouse: empty deduplication token"))
+	}
+	return withSettings(ctx, map[string]string{"insert_deduplication_token": token})
+}
+
+// WithLogComment returns a copy of ctx which sets the log_comment setting of
+// the queries executed with it. The comment is kept in the log_comment column
+// of system.query_log, e.g. the trace id or the name of the caller of a query.
+func WithLogComment(ctx context.Context, comment string) context.Context {
+	return withSettings(ctx, map[string]string{"log_comment": comment})
+}
+
+// limitExecutionTime limits the max_execution_time setting of the request by
+// the deadline of ctx in whole seconds, so the server stops executing the
+// query once the client stops waiting for it
+func limitExecutionTime(ctx context.Context, req *http.Request) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	seconds := int64(math.Ceil(time.Until(deadline).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	query := req.URL.Query()
+	if v, err := strconv.ParseFloat(query.Get("max_execution_time"), 64); err == nil && v > 0 && v <= float64(seconds) {
+		return
+	}
+	query.Set("max_execution_time", strconv.FormatInt(seconds, 10))
+	req.URL.RawQuery = query.Encode()
+}
+
+// formatSetting formats the value of a setting for the URL parameter
+func formatSetting(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case time.Duration:
+		return strconv.FormatFloat(v.Seconds(), 'f', -1, 64), nil
+	}
+	dv, err := converter{}.ConvertValue(value)
+	if err != nil {
+		return "", err
+	}
+	switch v := dv.(type) {
+	case nil:
+		return "", fmt.Errorf("value is nil")
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	}
+	b, err := textEncode.Encode(dv)
+	return string(b), err
+}
@@ -0,0 +1,100 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithSettings(t *testing.T) {
+	var params url.Values
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		params = r.URL.Query()
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn+"?max_execution_time=10&max_threads=2")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := WithSettings(context.Background(), map[string]interface{}{
+		"max_execution_time": 30 * time.Second,
+		"max_memory_usage":   uint64(10 << 30),
+	})
+	ctx = WithSettings(ctx, map[string]interface{}{
+		"insert_deduplication_token": "token",
+		"async_insert":               true,
+		"max_memory_usage":           1 << 20,
+	})
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, "30", params.Get("max_execution_time"))
+	assert.Equal(t, "1048576", params.Get("max_memory_usage"))
+	assert.Equal(t, "token", params.Get("insert_deduplication_token"))
+	assert.Equal(t, "1", params.Get("async_insert"))
+	assert.Equal(t, "2", params.Get("max_threads"))
+
+	// only the requests with the context get the settings
+	_, err = db.Exec("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, "10", params.Get("max_execution_time"))
+	assert.Empty(t, params.Get("insert_deduplication_token"))
+
+	_, err = db.ExecContext(WithSettings(context.Background(), map[string]interface{}{"max_threads": nil}), "SELECT 1")
+	assert.EqualError(t, err, "clickhouse: setting max_threads: value is nil")
+
+	_, err = db.ExecContext(WithDeduplicationToken(context.Background(), "batch-1"), "INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, "batch-1", params.Get("insert_deduplication_token"))
+	_, err = db.ExecContext(WithDeduplicationToken(context.Background(), ""), "INSERT INTO t VALUES (1)")
+	assert.EqualError(t, err, "clickhouse: empty deduplication token")
+}
+
+func TestDeadlineExecutionTime(t *testing.T) {
+	var params url.Values
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		params = r.URL.Query()
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn+"?max_execution_time=10&tls_handshake_timeout=5s&response_header_timeout=20s")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, "3", params.Get("max_execution_time"))
+
+	// the shorter setting is kept
+	ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, "10", params.Get("max_execution_time"))
+	_, err = db.ExecContext(WithSettings(ctx, map[string]interface{}{"max_execution_time": 0}), "INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	assert.Equal(t, "60", params.Get("max_execution_time"))
+
+	cfg, err := ParseDSN(dsn + "?ignore_deadline=1&tls_handshake_timeout=5s&response_header_timeout=20s&read_timeout=1s")
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, cfg.TLSHandshakeTimeout)
+	assert.Equal(t, 20*time.Second, cfg.ResponseHeaderTimeout)
+	assert.Contains(t, cfg.FormatDSN(), "ignore_deadline=1")
+	assert.Contains(t, cfg.FormatDSN(), "response_header_timeout=20s")
+	assert.Contains(t, cfg.FormatDSN(), "tls_handshake_timeout=5s")
+	c := newConn(cfg)
+	transport := c.transport.(*http.Transport)
+	assert.Equal(t, 5*time.Second, transport.TLSHandshakeTimeout)
+	assert.Equal(t, 20*time.Second, transport.ResponseHeaderTimeout)
+	_, err = c.exec(ctx, "INSERT INTO t VALUES (1)", nil)
+	require.NoError(t, err)
+	assert.Empty(t, params.Get("max_execution_time"))
+}
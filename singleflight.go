@@ -0,0 +1,368 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// NewSingleflightDB returns a new *sql.DB which coalesces concurrent identical
+// queries (the same SQL and the same arguments) into a single query executed
+// on db. The result is buffered in memory and shared among all waiters.
+// Exec calls, queries within transactions and queries with different
+// arguments are never coalesced.
+//
+// Note that the query is executed with the context of the first caller, so
+// its cancellation fails all waiters of the same query. Queries with contexts
+// carrying options of the driver, e.g. WithRowFilter, WithSettings or
+// WithHeaders, are never coalesced, since the options may change the result.
+func NewSingleflightDB(db *sql.DB) *sql.DB {
+	return sql.OpenDB(&singleflightConnector{db: db})
+}
+
+// flightGroup coalesces concurrent calls with the same key into one call.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	wg   sync.WaitGroup
+	dups int
+	val  interface{}
+	err  error
+}
+
+// errFlightPanicked is returned to the callers waiting for a call which panicked
+var errFlightPanicked = errors.New("clickhouse: coalesced query panicked")
+
+// do executes and returns the results of fn, making sure that only one
+// execution is in-flight for a given key at a time. If fn panics, the panic
+// is propagated to the caller executing it and the waiting callers get
+// errFlightPanicked.
+func (g *flightGroup) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if c, ok := g.calls[key]; ok {
+		c.dups++
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := new(flightCall)
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	returned := false
+	defer func() {
+		if !returned {
+			c.val, c.err = nil, errFlightPanicked
+		}
+		c.wg.Done()
+
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+	}()
+	c.val, c.err = fn()
+	returned = true
+	return c.val, c.err
+}
+
+// singleflightKey builds a key of the query, arguments are encoded exactly
+// as they would be sent to the server.
+func singleflightKey(query string, args []driver.NamedValue) (string, error) {
+	var b strings.Builder
+	b.WriteString(query)
+	for _, arg := range args {
+		v, err := converter{}.ConvertValue(arg.Value)
+		if err != nil {
+			return "", err
+		}
+		enc, err := textEncode.Encode(v)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(0)
+		b.WriteString(arg.Name)
+		b.WriteByte('=')
+		b.Write(enc)
+	}
+	return b.String(), nil
+}
+
+func namedValueToInterface(named []driver.NamedValue) []interface{} {
+	args := make([]interface{}, len(named))
+	for n, param := range named {
+		if len(param.Name) > 0 {
+			args[n] = sql.Named(param.Name, param.Value)
+		} else {
+			args[n] = param.Value
+		}
+	}
+	return args
+}
+
+type singleflightConnector struct {
+	db    *sql.DB
+	group flightGroup
+}
+
+// Connect implements the driver.Connector
+func (c *singleflightConnector) Connect(context.Context) (driver.Conn, error) {
+	return &singleflightConn{connector: c}, nil
+}
+
+// Driver implements the driver.Connector
+func (c *singleflightConnector) Driver() driver.Driver {
+	return new(chDriver)
+}
+
+// singleflightConn delegates all calls to the wrapped *sql.DB
+type singleflightConn struct {
+	connector *singleflightConnector
+	tx        *sql.Tx
+}
+
+// Prepare returns a prepared statement, bound to this connection.
+func (c *singleflightConn) Prepare(query string) (driver.Stmt, error) {
+	return &singleflightStmt{c: c, query: query}, nil
+}
+
+// Close implements the driver.Conn
+func (c *singleflightConn) Close() error {
+	if c.tx != nil {
+		err := c.tx.Rollback()
+		c.tx = nil
+		return err
+	}
+	return nil
+}
+
+// Begin starts and returns a new transaction.
+func (c *singleflightConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx implements the driver.ConnBeginTx
+func (c *singleflightConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	tx, err := c.connector.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.IsolationLevel(opts.Isolation),
+		ReadOnly:  opts.ReadOnly,
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.tx = tx
+	return &singleflightTx{c: c}, nil
+}
+
+// CheckNamedValue implements the driver.NamedValueChecker,
+// values are passed as is to the wrapped *sql.DB
+func (c *singleflightConn) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+// ExecContext implements the driver.ExecerContext
+func (c *singleflightConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c.tx != nil {
+		return c.tx.ExecContext(ctx, query, namedValueToInterface(args)...)
+	}
+	return c.connector.db.ExecContext(ctx, query, namedValueToInterface(args)...)
+}
+
+// hasDriverOptions reports whether ctx carries any option of the driver
+func hasDriverOptions(ctx context.Context) bool {
+	for k := key(0); k < contextKeys; k++ {
+		if ctx.Value(k) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// QueryContext implements the driver.QueryerContext
+func (c *singleflightConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.tx != nil || hasDriverOptions(ctx) {
+		var rows *sql.Rows
+		var err error
+		if c.tx != nil {
+			rows, err = c.tx.QueryContext(ctx, query, namedValueToInterface(args)...)
+		} else {
+			rows, err = c.connector.db.QueryContext(ctx, query, namedValueToInterface(args)...)
+		}
+		if err != nil {
+			return nil, err
+		}
+		res, err := readBufferedResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		return &bufferedRows{res: res}, nil
+	}
+	key, err := singleflightKey(query, args)
+	if err != nil {
+		return nil, err
+	}
+	v, err := c.connector.group.do(key, func() (interface{}, error) {
+		rows, err := c.connector.db.QueryContext(ctx, query, namedValueToInterface(args)...)
+		if err != nil {
+			return nil, err
+		}
+		return readBufferedResult(rows)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &bufferedRows{res: v.(*bufferedResult)}, nil
+}
+
+type singleflightTx struct {
+	c *singleflightConn
+}
+
+// Commit implements the driver.Tx
+func (t *singleflightTx) Commit() error {
+	tx := t.c.tx
+	t.c.tx = nil
+	return tx.Commit()
+}
+
+// Rollback implements the driver.Tx
+func (t *singleflightTx) Rollback() error {
+	tx := t.c.tx
+	t.c.tx = nil
+	return tx.Rollback()
+}
+
+type singleflightStmt struct {
+	c     *singleflightConn
+	query string
+}
+
+// Close closes the statement.
+func (s *singleflightStmt) Close() error {
+	return nil
+}
+
+// NumInput returns -1, the number of placeholders is checked by the wrapped *sql.DB
+func (s *singleflightStmt) NumInput() int {
+	return -1
+}
+
+// Exec executes a query that doesn't return rows, such as an INSERT
+func (s *singleflightStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valueToNamedValue(args))
+}
+
+// Query executes a query that may return rows, such as a SELECT
+func (s *singleflightStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valueToNamedValue(args))
+}
+
+// ExecContext implements the driver.StmtExecContext
+func (s *singleflightStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.c.ExecContext(ctx, s.query, args)
+}
+
+// QueryContext implements the driver.StmtQueryContext
+func (s *singleflightStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.c.QueryContext(ctx, s.query, args)
+}
+
+func valueToNamedValue(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for n, v := range args {
+		named[n] = driver.NamedValue{Ordinal: n + 1, Value: v}
+	}
+	return named
+}
+
+// bufferedResult is a result set fully read into memory
+type bufferedResult struct {
+	columns       []string
+	databaseTypes []string
+	scanTypes     []reflect.Type
+	values        [][]driver.Value
+}
+
+func readBufferedResult(rows *sql.Rows) (*bufferedResult, error) {
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	res := &bufferedResult{
+		columns:       columns,
+		databaseTypes: make([]string, len(types)),
+		scanTypes:     make([]reflect.Type, len(types)),
+	}
+	for i, t := range types {
+		res.databaseTypes[i] = t.DatabaseTypeName()
+		res.scanTypes[i] = t.ScanType()
+	}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make([]driver.Value, len(values))
+		for i, v := range values {
+			row[i] = v
+		}
+		res.values = append(res.values, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// bufferedRows iterates over a bufferedResult, which may be shared
+type bufferedRows struct {
+	res *bufferedResult
+	pos int
+}
+
+func (r *bufferedRows) Columns() []string {
+	return r.res.columns
+}
+
+func (r *bufferedRows) Close() error {
+	return nil
+}
+
+func (r *bufferedRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.res.values) {
+		return io.EOF
+	}
+	copy(dest, r.res.values[r.pos])
+	r.pos++
+	return nil
+}
+
+// ColumnTypeScanType implements the driver.RowsColumnTypeScanType
+func (r *bufferedRows) ColumnTypeScanType(index int) reflect.Type {
+	return r.res.scanTypes[index]
+}
+
+// ColumnTypeDatabaseTypeName implements the driver.RowsColumnTypeDatabaseTypeName
+func (r *bufferedRows) ColumnTypeDatabaseTypeName(index int) string {
+	return r.res.databaseTypes[index]
+}
@@ -0,0 +1,186 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSingleflightDB(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		atomic.AddInt32(&hits, 1)
+		if query == "SELECT a FROM t WHERE a > 1" {
+			<-release
+		}
+		io.WriteString(w, "a\nInt32\n2\n3\n")
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	connector := &singleflightConnector{db: db}
+	sdb := sql.OpenDB(connector)
+	defer sdb.Close()
+
+	const n = 5
+	var wg sync.WaitGroup
+	results := make([][]int32, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rows, err := sdb.QueryContext(context.Background(), "SELECT a FROM t WHERE a > ?", 1)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var v int32
+				assert.NoError(t, rows.Scan(&v))
+				results[i] = append(results[i], v)
+			}
+			assert.NoError(t, rows.Err())
+		}(i)
+	}
+	// wait for all queries to join the same flight
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		connector.group.mu.Lock()
+		var dups int
+		for _, c := range connector.group.calls {
+			dups = c.dups
+		}
+		connector.group.mu.Unlock()
+		if dups == n-1 {
+			break
+		}
+	}
+	close(release)
+	wg.Wait()
+
+	assert.EqualValues(t, 1, atomic.LoadInt32(&hits))
+	for _, r := range results {
+		assert.Equal(t, []int32{2, 3}, r)
+	}
+
+	rows, err := sdb.Query("SELECT a FROM t WHERE a > ?", 2)
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	assert.EqualValues(t, 2, atomic.LoadInt32(&hits))
+
+	_, err = sdb.Exec("INSERT INTO t VALUES (1)")
+	assert.NoError(t, err)
+	_, err = sdb.Exec("INSERT INTO t VALUES (1)")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, atomic.LoadInt32(&hits))
+}
+
+func TestSingleflightDBOptions(t *testing.T) {
+	var hits int32
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		atomic.AddInt32(&hits, 1)
+		// let the other query arrive while this one is in flight
+		time.Sleep(50 * time.Millisecond)
+		if strings.Contains(query, "tenant = 2") {
+			io.WriteString(w, "a\nInt32\n2\n")
+			return
+		}
+		io.WriteString(w, "a\nInt32\n1\n")
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	sdb := NewSingleflightDB(db)
+	defer sdb.Close()
+
+	var wg sync.WaitGroup
+	results := make([]int32, 2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := WithRowFilter(context.Background(), "t", fmt.Sprintf("tenant = %d", i+1))
+			assert.NoError(t, sdb.QueryRowContext(ctx, "SELECT a FROM t").Scan(&results[i]))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, 2, atomic.LoadInt32(&hits))
+	assert.Equal(t, []int32{1, 2}, results)
+}
+
+func TestFlightGroupPanic(t *testing.T) {
+	var g flightGroup
+	started, release := make(chan struct{}), make(chan struct{})
+	recovered := make(chan interface{})
+	go func() {
+		defer func() {
+			recovered <- recover()
+		}()
+		g.do("key", func() (interface{}, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+	done := make(chan error)
+	go func() {
+		_, err := g.do("key", func() (interface{}, error) {
+			return nil, nil
+		})
+		done <- err
+	}()
+	// wait for the second call to join the first one
+	for {
+		g.mu.Lock()
+		dups := g.calls["key"].dups
+		g.mu.Unlock()
+		if dups > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	select {
+	case err := <-done:
+		assert.Equal(t, errFlightPanicked, err)
+	case <-time.After(time.Second):
+		t.Fatal("the waiting call is not released")
+	}
+	// the panic is propagated to the caller executing the call
+	assert.Equal(t, "boom", <-recovered)
+
+	// the key is executed again after the panic
+	v, err := g.do("key", func() (interface{}, error) {
+		return 1, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+}
+
+func TestSingleflightKey(t *testing.T) {
+	k1, err := singleflightKey("SELECT ?", nil)
+	require.NoError(t, err)
+	k2, err := singleflightKey("SELECT ?", valueToNamedValue([]driver.Value{int64(1)}))
+	require.NoError(t, err)
+	k3, err := singleflightKey("SELECT ?", valueToNamedValue([]driver.Value{"1"}))
+	require.NoError(t, err)
+	assert.NotEqual(t, k1, k2)
+	assert.NotEqual(t, k2, k3)
+}
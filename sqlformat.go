@@ -0,0 +1,299 @@
+package clickhouse
+
+import (
+	"strings"
+)
+
+var sqlKeywords = map[string]bool{}
+
+func init() {
+	for _, keyword := range strings.Fields(`
+		SELECT FROM WHERE PREWHERE GROUP BY HAVING ORDER LIMIT OFFSET SETTINGS FORMAT
+		UNION INTERSECT EXCEPT ALL DISTINCT AS ON USING WITH TOTALS ROLLUP CUBE FILL TIES
+		JOIN LEFT RIGHT INNER OUTER FULL CROSS ARRAY GLOBAL LOCAL ANY ASOF SEMI ANTI PASTE
+		AND OR NOT IN IS NULL LIKE ILIKE BETWEEN CASE WHEN THEN ELSE END INTERVAL
+		FINAL SAMPLE ASC DESC NULLS OVER WINDOW QUALIFY
+		INSERT INTO VALUES CREATE DROP ALTER TRUNCATE IF EXISTS`) {
+		sqlKeywords[keyword] = true
+	}
+}
+
+// joinModifiers are keywords which may precede JOIN
+var joinModifiers = []string{
+	"GLOBAL", "LOCAL", "ANY", "ALL", "ASOF", "SEMI", "ANTI",
+	"INNER", "OUTER", "LEFT", "RIGHT", "FULL", "CROSS", "ARRAY", "PASTE",
+}
+
+// sqlOperators are operators of two chars, which are split by the lexer
+var sqlOperators = []string{"<=", ">=", "!=", "<>", "==", "||", "->", "::"}
+
+// FormatSQL formats the query in a canonical way: keywords are upper-cased,
+// clauses and joins start on new lines, columns of SELECT are aligned and
+// subqueries are indented. Function calls, casts and literals are kept as is,
+// comments are removed. Formatting of a formatted query does not change it.
+//
+// Identifiers which are keywords (e.g. a column named "all") must be quoted,
+// otherwise they are upper-cased.
+func FormatSQL(query string) (string, error) {
+	words, err := splitSQL(query)
+	if err != nil {
+		return "", err
+	}
+	f := &sqlFormatter{
+		query:  query,
+		words:  mergeOperators(query, words),
+		levels: []formatLevel{{query: true, align: -1}},
+		prev:   -1,
+	}
+	for i := range f.words {
+		f.write(i)
+	}
+	return strings.TrimRight(string(f.buf), " "), nil
+}
+
+// mergeOperators joins chars of operators and copies query parameters
+// like {name:Type} and maps as is
+func mergeOperators(query string, words []sqlWord) []sqlWord {
+	merged := words[:0:0]
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		switch {
+		case w.text == "{" && !w.quoted:
+			depth := 0
+			for j := i; j < len(words); j++ {
+				switch {
+				case words[j].quoted:
+				case words[j].text == "{":
+					depth++
+				case words[j].text == "}":
+					depth--
+				}
+				if depth == 0 || j == len(words)-1 {
+					w.end = words[j].end
+					i = j
+					break
+				}
+			}
+			w.text = query[w.start:w.end]
+		case i+1 < len(words) && w.end == words[i+1].start && !w.quoted && !words[i+1].quoted:
+			for _, op := range sqlOperators {
+				if w.text+words[i+1].text == op {
+					w.text = op
+					w.end = words[i+1].end
+					i++
+					break
+				}
+			}
+		}
+		merged = append(merged, w)
+	}
+	return merged
+}
+
+// formatLevel describes a query or an expression in parentheses
+type formatLevel struct {
+	start      int
+	indent     int
+	query      bool
+	selectList bool
+	align      int
+}
+
+type sqlFormatter struct {
+	query   string
+	words   []sqlWord
+	levels  []formatLevel
+	buf     []byte
+	prev    int
+	noSpace bool
+}
+
+func (f *sqlFormatter) level() *formatLevel {
+	return &f.levels[len(f.levels)-1]
+}
+
+func (f *sqlFormatter) write(i int) {
+	w := f.words[i]
+	lvl := f.level()
+	switch {
+	case f.isPunct(i, "(", "["):
+		next := formatLevel{start: i + 1, indent: lvl.indent, align: -1}
+		next.query = w.text == "(" && i+1 < len(f.words) && f.words[i+1].is("SELECT", "WITH")
+		f.emit(i, w.text)
+		if next.query {
+			next.indent += 4
+			f.newline(next.indent)
+		}
+		f.levels = append(f.levels, next)
+		return
+	case f.isPunct(i, ")", "]"):
+		if len(f.levels) > 1 {
+			closed := *lvl
+			f.levels = f.levels[:len(f.levels)-1]
+			if closed.query {
+				f.newline(f.level().indent)
+			}
+		}
+		f.emit(i, w.text)
+		return
+	case f.isPunct(i, ","):
+		f.emit(i, w.text)
+		if lvl.selectList && lvl.align >= 0 {
+			f.newline(lvl.align)
+		}
+		return
+	}
+	if lvl.query && f.startsClause(i) {
+		lvl.selectList = false
+		f.newline(lvl.indent)
+	}
+	text := w.text
+	if f.isKeyword(i) {
+		text = strings.ToUpper(text)
+	}
+	f.emit(i, text)
+	if lvl.query && w.is("SELECT") {
+		lvl.selectList, lvl.align = true, -1
+	}
+	if f.isPunct(i, "-", "+") && f.isUnary(i) {
+		f.noSpace = true
+	}
+}
+
+// emit writes the word separating it from the previous one if needed
+func (f *sqlFormatter) emit(i int, text string) {
+	if f.needSpace(i) {
+		f.buf = append(f.buf, ' ')
+	}
+	f.noSpace = false
+	if lvl := f.level(); lvl.selectList && lvl.align < 0 && !f.words[i].is("SELECT", "DISTINCT") {
+		lvl.align = f.column()
+	}
+	f.buf = append(f.buf, text...)
+	f.prev = i
+}
+
+func (f *sqlFormatter) needSpace(i int) bool {
+	if f.prev < 0 || f.atLineStart() || f.noSpace {
+		return false
+	}
+	w, prev := f.words[i], f.words[f.prev]
+	adjacent := prev.end == w.start
+	switch {
+	case f.isPunct(i, ",", ")", "]", ";", "::") || f.isPunct(f.prev, "(", "[", "::"):
+		return false
+	case adjacent && (strings.HasPrefix(w.text, ".") || strings.HasSuffix(prev.text, ".") || f.isPunct(i, ".") || f.isPunct(f.prev, ".")):
+		return false
+	case adjacent && f.isPunct(i, "(", "[") && (!f.isPunct(f.prev) || f.isPunct(f.prev, ")", "]")):
+		// function call, parameters of an aggregate function, element of an array
+		return false
+	}
+	return true
+}
+
+// isUnary reports whether the sign at words[i] is an unary operator
+func (f *sqlFormatter) isUnary(i int) bool {
+	if i == 0 {
+		return true
+	}
+	if f.isPunct(i - 1) {
+		return !f.isPunct(i-1, ")", "]")
+	}
+	return f.isKeyword(i-1) && !f.words[i-1].is("NULL", "END")
+}
+
+func (f *sqlFormatter) isPunct(i int, chars ...string) bool {
+	w := f.words[i]
+	if w.quoted || isIdentChar(w.text[0]) {
+		return false
+	}
+	if len(chars) == 0 {
+		return true
+	}
+	for _, c := range chars {
+		if w.text == c {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *sqlFormatter) isFunction(i int) bool {
+	return i+1 < len(f.words) && f.isPunct(i+1, "(") && f.words[i].end == f.words[i+1].start
+}
+
+func (f *sqlFormatter) isKeyword(i int) bool {
+	w := f.words[i]
+	return !w.quoted && sqlKeywords[strings.ToUpper(w.text)] && !f.isFunction(i)
+}
+
+func (f *sqlFormatter) startsClause(i int) bool {
+	w := f.words[i]
+	if w.quoted || f.isFunction(i) {
+		return false
+	}
+	next := sqlWord{}
+	if i+1 < len(f.words) {
+		next = f.words[i+1]
+	}
+	switch {
+	case w.is("SELECT", "FROM", "WHERE", "PREWHERE", "HAVING", "LIMIT", "SETTINGS", "FORMAT", "UNION", "INTERSECT", "WINDOW", "QUALIFY"):
+		return true
+	case w.is("GROUP", "ORDER"):
+		return next.is("BY")
+	case w.is("EXCEPT"):
+		return next.is("SELECT", "DISTINCT", "ALL")
+	case w.is("WITH"):
+		return i == f.level().start
+	case w.is("JOIN"):
+		return i == 0 || !f.words[i-1].is(joinModifiers...)
+	case w.is(joinModifiers...):
+		if i > 0 && f.words[i-1].is(joinModifiers...) {
+			return false
+		}
+		for j := i + 1; j < len(f.words); j++ {
+			if f.words[j].is("JOIN") {
+				return true
+			}
+			if !f.words[j].is(joinModifiers...) {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// newline starts a new line with the given indent
+func (f *sqlFormatter) newline(indent int) {
+	for len(f.buf) > 0 && f.buf[len(f.buf)-1] == ' ' {
+		f.buf = f.buf[:len(f.buf)-1]
+	}
+	if len(f.buf) > 0 && f.buf[len(f.buf)-1] != '\n' {
+		f.buf = append(f.buf, '\n')
+	}
+	for n := 0; n < indent; n++ {
+		f.buf = append(f.buf, ' ')
+	}
+}
+
+func (f *sqlFormatter) column() int {
+	for i := len(f.buf) - 1; i >= 0; i-- {
+		if f.buf[i] == '\n' {
+			return len(f.buf) - i - 1
+		}
+	}
+	return len(f.buf)
+}
+
+func (f *sqlFormatter) atLineStart() bool {
+	for i := len(f.buf) - 1; i >= 0; i-- {
+		switch f.buf[i] {
+		case '\n':
+			return true
+		case ' ':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package clickhouse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatSQL(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{"select 1", "SELECT 1"},
+		{
+			"select a, b as c, count(*) from db.t where a = 1 and b in (1,2) group by a, b order by a desc limit 10",
+			"SELECT a,\n       b AS c,\n       count(*)\nFROM db.t\nWHERE a = 1 AND b IN (1, 2)\nGROUP BY a, b\nORDER BY a DESC\nLIMIT 10",
+		},
+		{
+			"select distinct a,b from t1 left join t2 on t1.id=t2.id global any inner join t3 using (id) array join arr",
+			"SELECT DISTINCT a,\n                b\nFROM t1\nLEFT JOIN t2 ON t1.id = t2.id\nGLOBAL ANY INNER JOIN t3 USING (id)\nARRAY JOIN arr",
+		},
+		{
+			"select x from (select toDate(t) as x, quantiles(0.5, 0.9)(v) q from `db`.`t` prewhere v >= -1.5e-3) where x != '2019-01-01'",
+			"SELECT x\nFROM (\n    SELECT toDate(t) AS x,\n           quantiles(0.5, 0.9)(v) q\n    FROM `db`.`t`\n    PREWHERE v >= -1.5e-3\n)\nWHERE x != '2019-01-01'",
+		},
+		{
+			"SELECT CAST(a AS Nullable(Int32)), b::UInt8, arr[1], t.*, {id:UInt32}, x -> x + 1 FROM t -- comment",
+			"SELECT CAST(a AS Nullable(Int32)),\n       b::UInt8,\n       arr[1],\n       t.*,\n       {id:UInt32},\n       x -> x + 1\nFROM t",
+		},
+		{
+			"select extract(year from d), case when a then -1 else 1 end from t union all select 1, 2",
+			"SELECT extract(year FROM d),\n       CASE WHEN a THEN -1 ELSE 1 END\nFROM t\nUNION ALL\nSELECT 1,\n       2",
+		},
+		{
+			"with 1 as x select sum(x) over (partition by y order by z) from t where a in (select a from s)",
+			"WITH 1 AS x\nSELECT sum(x) OVER (partition BY y ORDER BY z)\nFROM t\nWHERE a IN (\n    SELECT a\n    FROM s\n)",
+		},
+	}
+	for _, tc := range testCases {
+		actual, err := FormatSQL(tc.query)
+		if !assert.NoError(t, err, tc.query) {
+			continue
+		}
+		assert.Equal(t, tc.expected, actual)
+		again, err := FormatSQL(actual)
+		if assert.NoError(t, err) {
+			assert.Equal(t, actual, again, "not idempotent")
+		}
+	}
+
+	_, err := FormatSQL("SELECT 'unterminated")
+	assert.Error(t, err)
+}
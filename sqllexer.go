@@ -0,0 +1,125 @@
+package clickhouse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sqlWord is a keyword, an identifier, a literal or a single punctuation char
+// of a query. Quoted identifiers and string literals are never keywords.
+type sqlWord struct {
+	text   string
+	quoted bool
+	start  int
+	end    int
+}
+
+// is reports whether the word is one of the given keywords
+func (w sqlWord) is(keywords ...string) bool {
+	if w.quoted {
+		return false
+	}
+	for _, keyword := range keywords {
+		if strings.EqualFold(w.text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitSQL splits the query into words skipping whitespaces and comments
+func splitSQL(query string) ([]sqlWord, error) {
+	var words []sqlWord
+	l := &sqlLexer{query: query}
+	for {
+		w, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		if len(w.text) == 0 {
+			return words, nil
+		}
+		words = append(words, w)
+	}
+}
+
+type sqlLexer struct {
+	query string
+	pos   int
+}
+
+func (l *sqlLexer) next() (sqlWord, error) {
+	if err := l.skipSpaceAndComments(); err != nil {
+		return sqlWord{}, err
+	}
+	start := l.pos
+	if l.pos >= len(l.query) {
+		return sqlWord{start: start, end: start}, nil
+	}
+	switch c := l.query[l.pos]; {
+	case c == '`' || c == '"' || c == '\'':
+		for l.pos++; l.pos < len(l.query); l.pos++ {
+			switch l.query[l.pos] {
+			case '\\':
+				l.pos++
+			case c:
+				l.pos++
+				return sqlWord{text: l.query[start:l.pos], quoted: true, start: start, end: l.pos}, nil
+			}
+		}
+		return sqlWord{}, fmt.Errorf("clickhouse: unterminated quoted string in %q", l.query)
+	case isIdentChar(c):
+		for l.pos < len(l.query) && isIdentChar(l.query[l.pos]) {
+			l.pos++
+			if l.isExponentSign(start) {
+				l.pos++
+			}
+		}
+	default:
+		l.pos++
+	}
+	return sqlWord{text: l.query[start:l.pos], start: start, end: l.pos}, nil
+}
+
+// isExponentSign reports whether the char at l.pos is a sign of the exponent
+// of the number starting at start, e.g. 1e-5
+func (l *sqlLexer) isExponentSign(start int) bool {
+	if l.pos+1 >= len(l.query) || l.query[start] < '0' || l.query[start] > '9' {
+		return false
+	}
+	switch l.query[l.pos-1] {
+	case 'e', 'E':
+	default:
+		return false
+	}
+	sign, next := l.query[l.pos], l.query[l.pos+1]
+	return (sign == '-' || sign == '+') && next >= '0' && next <= '9' && !strings.HasPrefix(l.query[start:], "0x")
+}
+
+func (l *sqlLexer) skipSpaceAndComments() error {
+	for l.pos < len(l.query) {
+		switch {
+		case strings.ContainsRune(" \t\r\n", rune(l.query[l.pos])):
+			l.pos++
+		case strings.HasPrefix(l.query[l.pos:], "--"):
+			if i := strings.IndexByte(l.query[l.pos:], '\n'); i >= 0 {
+				l.pos += i + 1
+			} else {
+				l.pos = len(l.query)
+			}
+		case strings.HasPrefix(l.query[l.pos:], "/*"):
+			i := strings.Index(l.query[l.pos+2:], "*/")
+			if i < 0 {
+				return fmt.Errorf("clickhouse: unterminated comment in %q", l.query)
+			}
+			l.pos += i + 4
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -20,6 +20,7 @@ type stmt struct {
 	pattern   string
 	index     []int
 	batchMode bool
+	params    bool
 	args      [][]driver.Value
 }
 
@@ -35,6 +36,7 @@ func newStmt(query string) *stmt {
 	if len(s.index) == 0 {
 		s.batchMode = false
 	}
+	s.params = hasQueryParams(query)
 	return s
 }
 
@@ -58,6 +60,10 @@ func (s *stmt) Close() error {
 
 // NumInput returns the number of placeholder parameters.
 func (s *stmt) NumInput() int {
+	if s.params {
+		// the number of named arguments is unknown
+		return -1
+	}
 	return len(s.index)
 }
 
@@ -8,7 +8,7 @@ import (
 )
 
 func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
-	values, err := namedValueToValue(args)
+	ctx, values, err := namedValueToValue(ctx, args)
 	if err != nil {
 		return nil, err
 	}
@@ -16,9 +16,22 @@ func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driv
 }
 
 func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
-	values, err := namedValueToValue(args)
+	if s.batchMode && hasNamedValues(args) {
+		// the values of a batch are sent with a single query
+		return nil, ErrNameParams
+	}
+	ctx, values, err := namedValueToValue(ctx, args)
 	if err != nil {
 		return nil, err
 	}
 	return s.exec(ctx, values)
 }
+
+func hasNamedValues(args []driver.NamedValue) bool {
+	for _, arg := range args {
+		if len(arg.Name) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -221,6 +222,14 @@ func (s *stmtSuite) TestFixDoubleInterpolateInStmt() {
 	s.NoError(rows.Close())
 }
 
+func TestNewStmtReplace(t *testing.T) {
+	st := newStmt("REPLACE INTO data (i64, s) VALUES (?, ?)")
+	assert.True(t, st.batchMode)
+	assert.Equal(t, "REPLACE INTO data (i64, s) VALUES", st.prefix)
+	assert.Equal(t, "(?, ?)", st.pattern)
+	assert.Equal(t, 2, st.NumInput())
+}
+
 func TestStmt(t *testing.T) {
 	suite.Run(t, new(stmtSuite))
 }
@@ -0,0 +1,84 @@
+package clickhouse
+
+import (
+	"container/list"
+	"sync"
+)
+
+// parsedQuery is what the connection knows about the text of a query before
+// its arguments are interpolated
+type parsedQuery struct {
+	query  string
+	index  []int // the positions of the placeholders
+	script bool  // the query has more than one statement
+	totals bool  // the result may have the totals
+}
+
+func parseQuery(query string) *parsedQuery {
+	return &parsedQuery{
+		query:  query,
+		index:  placeholders(query),
+		script: len(splitStatements(query)) > 1,
+		totals: hasTotals(query),
+	}
+}
+
+// stmtCache is the LRU cache of the parsed queries of a connection, so the
+// queries executed again and again are parsed once
+type stmtCache struct {
+	mu    sync.Mutex
+	size  int
+	items map[string]*list.Element
+	order *list.List
+}
+
+func newStmtCache(size int) *stmtCache {
+	if size <= 0 {
+		return nil
+	}
+	return &stmtCache{
+		size:  size,
+		items: make(map[string]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+// get returns the parsed query, it is parsed and cached if it is not cached
+// yet. The query is parsed every time if the cache is disabled.
+func (sc *stmtCache) get(query string) *parsedQuery {
+	if sc == nil {
+		return parseQuery(query)
+	}
+	sc.mu.Lock()
+	if e, ok := sc.items[query]; ok {
+		sc.order.MoveToFront(e)
+		sc.mu.Unlock()
+		return e.Value.(*parsedQuery)
+	}
+	sc.mu.Unlock()
+
+	pq := parseQuery(query)
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if e, ok := sc.items[query]; ok {
+		sc.order.MoveToFront(e)
+		return e.Value.(*parsedQuery)
+	}
+	sc.items[query] = sc.order.PushFront(pq)
+	if sc.order.Len() > sc.size {
+		oldest := sc.order.Back()
+		sc.order.Remove(oldest)
+		delete(sc.items, oldest.Value.(*parsedQuery).query)
+	}
+	return pq
+}
+
+// len returns the number of cached queries
+func (sc *stmtCache) len() int {
+	if sc == nil {
+		return 0
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.order.Len()
+}
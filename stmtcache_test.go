@@ -0,0 +1,70 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStmtCache(t *testing.T) {
+	sc := newStmtCache(2)
+	a := sc.get("SELECT ?")
+	assert.Equal(t, []int{7}, a.index)
+	assert.False(t, a.script)
+	assert.True(t, a == sc.get("SELECT ?"))
+	assert.True(t, sc.get("SELECT 1; SELECT ?").script)
+	// SELECT ? is used more recently than the script
+	sc.get("SELECT ?")
+	assert.True(t, sc.get("SELECT a FROM t GROUP BY a WITH TOTALS").totals)
+	assert.Equal(t, 2, sc.len())
+	assert.True(t, a == sc.get("SELECT ?"))
+
+	var disabled *stmtCache
+	assert.Equal(t, []int{7}, disabled.get("SELECT ?").index)
+	assert.Equal(t, 0, disabled.len())
+	assert.Nil(t, newStmtCache(0))
+}
+
+func TestStmtCacheQuery(t *testing.T) {
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		w.Write([]byte("n\nUInt8\n1\n"))
+	})
+	defer ts.Close()
+
+	cfg, err := ParseDSN(dsn + "?stmt_cache_size=10")
+	require.NoError(t, err)
+	assert.Equal(t, 10, cfg.StmtCacheSize)
+	assert.Contains(t, cfg.FormatDSN(), "stmt_cache_size=10")
+	db := sql.OpenDB(NewConnector(cfg))
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for i := 0; i < 3; i++ {
+		var n uint8
+		require.NoError(t, db.QueryRow("SELECT n FROM t WHERE id = ?", i).Scan(&n))
+		assert.Equal(t, uint8(1), n)
+	}
+	_, err = db.Exec("INSERT INTO t VALUES (?); INSERT INTO t VALUES (?)", 1, "a;b")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"SELECT n FROM t WHERE id = 0",
+		"SELECT n FROM t WHERE id = 1",
+		"SELECT n FROM t WHERE id = 2",
+		"INSERT INTO t VALUES (1)",
+		"INSERT INTO t VALUES ('a;b')",
+	}, queries)
+
+	sqlConn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	defer sqlConn.Close()
+	require.NoError(t, sqlConn.Raw(func(driverConn interface{}) error {
+		assert.Equal(t, 2, driverConn.(*conn).stmtCache.len())
+		return nil
+	}))
+}
@@ -0,0 +1,122 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// ScanStruct scans the current row of rows into dest, which must be a pointer
+// to a struct. Columns are matched to the struct fields by the "ch" tag or by
+// the case insensitive field name like ScanNested does, columns without a
+// matching field are skipped. Struct fields are scanned from Tuple columns,
+// slices from Array and Nested columns and maps from Map columns, e.g.
+//
+//	var event struct {
+//	    ID    uint64 `ch:"id"`
+//	    Tags  []string
+//	    Point struct {
+//	        X, Y float64
+//	    } `ch:"point"`
+//	}
+//	for rows.Next() {
+//	    if err := clickhouse.ScanStruct(rows, &event); err != nil {
+//	        ...
+//	    }
+//	}
+func ScanStruct(rows *sql.Rows, dest interface{}) error {
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() || dv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("clickhouse: expected pointer to struct, got %T", dest)
+	}
+	dv = dv.Elem()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	targets := make([]interface{}, len(columns))
+	for i, column := range columns {
+		idx := structFieldIndex(dv.Type(), column)
+		if idx < 0 {
+			targets[i] = new(interface{})
+			continue
+		}
+		targets[i] = fieldScanner(dv.Field(idx).Addr())
+	}
+	return rows.Scan(targets...)
+}
+
+// fieldScanner returns the scan destination for the pointer to a field
+func fieldScanner(ptr reflect.Value) interface{} {
+	dest := ptr.Interface()
+	if _, ok := dest.(sql.Scanner); ok {
+		return dest
+	}
+	t := ptr.Elem().Type()
+	switch t.Kind() {
+	case reflect.Struct:
+		if t != reflect.TypeOf(time.Time{}) {
+			return ScanTuple(dest)
+		}
+	case reflect.Slice:
+		switch t.Elem().Kind() {
+		case reflect.Uint8:
+		case reflect.Struct:
+			return ScanNested(dest)
+		default:
+			return ScanArray(dest)
+		}
+	case reflect.Map:
+		return ScanMap(dest)
+	}
+	return dest
+}
+
+// AppendStruct appends the exported fields of src, which must be a struct or
+// a pointer to a struct, to args as the arguments of an insert. Fields are
+// appended in the order of their declaration, fields with the `ch:"-"` tag are
+// skipped. Struct fields are passed as Tuple and slices as Array, e.g.
+//
+//	var args []interface{}
+//	for _, event := range events {
+//	    if args, err = clickhouse.AppendStruct(args, &event); err != nil {
+//	        ...
+//	    }
+//	}
+//	err = batch.Append(args...)
+func AppendStruct(args []interface{}, src interface{}) ([]interface{}, error) {
+	sv := reflect.Indirect(reflect.ValueOf(src))
+	if sv.Kind() != reflect.Struct {
+		return args, fmt.Errorf("clickhouse: expected struct, got %T", src)
+	}
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		if f := st.Field(i); f.PkgPath != "" || f.Tag.Get("ch") == "-" {
+			continue
+		}
+		v := sv.Field(i).Interface()
+		if isTupleStruct(v) {
+			encoded, err := textEncode.Encode(v)
+			if err != nil {
+				return args, err
+			}
+			v = encoded
+		}
+		args = append(args, v)
+	}
+	return args, nil
+}
+
+// isTupleStruct reports whether v is a struct encoded as Tuple
+func isTupleStruct(v interface{}) bool {
+	if reflect.ValueOf(v).Kind() != reflect.Struct {
+		return false
+	}
+	if _, ok := v.(time.Time); ok {
+		return false
+	}
+	_, ok := v.(driver.Valuer)
+	return !ok
+}
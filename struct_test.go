@@ -0,0 +1,99 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanStruct(t *testing.T) {
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		w.Write([]byte("id\tname\ttags\tpoint\titems\tm\tts\textra\n" +
+			"UInt64\tString\tArray(String)\tTuple(x Float64, y Float64)\tArray(Tuple(id UInt8, inner Tuple(v String)))\tMap(String, UInt8)\tDateTime\tUInt8\n" +
+			"1\talice\t['a','b']\t(1.5,2)\t[(1,('x'))]\t{'k':1}\t2021-01-01 00:00:00\t7\n"))
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	type inner struct {
+		V string
+	}
+	var event struct {
+		ID    int64 `ch:"id"`
+		Name  string
+		Tags  []string
+		Point struct {
+			X, Y float64
+		}
+		Items []struct {
+			ID    int
+			Inner inner
+		}
+		M  map[string]int
+		TS time.Time `ch:"ts"`
+	}
+	rows, err := db.Query("SELECT *")
+	require.NoError(t, err)
+	defer rows.Close()
+	require.True(t, rows.Next())
+	require.NoError(t, ScanStruct(rows, &event))
+	assert.EqualValues(t, 1, event.ID)
+	assert.Equal(t, "alice", event.Name)
+	assert.Equal(t, []string{"a", "b"}, event.Tags)
+	assert.Equal(t, 1.5, event.Point.X)
+	assert.Equal(t, float64(2), event.Point.Y)
+	if assert.Len(t, event.Items, 1) {
+		assert.Equal(t, 1, event.Items[0].ID)
+		assert.Equal(t, inner{"x"}, event.Items[0].Inner)
+	}
+	assert.Equal(t, map[string]int{"k": 1}, event.M)
+	assert.Equal(t, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), event.TS)
+
+	assert.Error(t, ScanStruct(rows, event))
+}
+
+func TestAppendStruct(t *testing.T) {
+	type point struct {
+		X, Y float64
+	}
+	type event struct {
+		ID      uint64 `ch:"id"`
+		Tags    []string
+		Point   point
+		Points  []point
+		TS      time.Time
+		Skipped string `ch:"-"`
+		hidden  int
+	}
+	ts := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	args, err := AppendStruct(nil, event{ID: 1, Tags: []string{"a"}, Point: point{1, 2}, Points: []point{{3, 4}}, TS: ts})
+	require.NoError(t, err)
+	args, err = AppendStruct(args, &event{ID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		uint64(1), []string{"a"}, []byte("(1,2)"), []point{{3, 4}}, ts,
+		uint64(2), []string(nil), []byte("(0,0)"), []point(nil), time.Time{},
+	}, args)
+
+	var queries []string
+	srv, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+	})
+	defer srv.Close()
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("INSERT INTO t VALUES (?, ?, ?, ?, ?)", args[:5]...)
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO t VALUES (1, ['a'], (1,2), [(3,4)], '2021-01-01 00:00:00')", queries[0])
+
+	_, err = AppendStruct(nil, 1)
+	assert.Error(t, err)
+}
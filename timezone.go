@@ -0,0 +1,75 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// Interpretations of the time.Time arguments of queries
+const (
+	// TimeParamsServer sends the wall clock of the times in their locations,
+	// the server interprets it in its time zone or in the time zone of the
+	// column, it is the default
+	TimeParamsServer = "server"
+	// TimeParamsUTC sends the times as DateTime values in UTC, so the server
+	// gets the instants of the times regardless of its time zone
+	TimeParamsUTC = "utc"
+)
+
+// WithScanLocation returns a copy of ctx which converts the DateTime and
+// DateTime64 values of the results of the queries executed with it to loc
+// after parsing, it overrides Config.ScanLocation
+func WithScanLocation(ctx context.Context, loc *time.Location) context.Context {
+	return context.WithValue(ctx, scanLocationKey, loc)
+}
+
+// parserOptions returns the options of the parsers of a result of the query
+// executed with ctx
+func (c *conn) parserOptions(ctx context.Context) *DataParserOptions {
+	opt := &DataParserOptions{
+		Location:      c.location,
+		UseDBLocation: c.useDBLocation,
+		ScanLocation:  c.scanLocation,
+		EnumAsNumber:  c.enumAsNumber,
+		ZeroDateAsNil: c.zeroDateAsNil,
+	}
+	if loc, ok := ctx.Value(scanLocationKey).(*time.Location); ok {
+		opt.ScanLocation = loc
+	}
+	if asNumber, ok := ctx.Value(enumAsNumberKey).(bool); ok {
+		opt.EnumAsNumber = asNumber
+	}
+	return opt
+}
+
+// scanLocation returns the location of the decoded values of a column in
+// the location loc, the decoded instants do not depend on it
+func scanLocation(loc *time.Location, opt *DataParserOptions) *time.Location {
+	if opt != nil && opt.ScanLocation != nil {
+		return opt.ScanLocation
+	}
+	return loc
+}
+
+// convertTimeParam converts the time.Time argument of a query according to
+// the TimeParamsUTC mode: positional arguments become raw DateTime literals
+// in UTC, named arguments are sent as the wall clock in UTC, which is
+// correct for the parameters of the DateTime('UTC') type
+func convertTimeParam(nv *driver.NamedValue) {
+	t, ok := nv.Value.(time.Time)
+	if !ok || t.IsZero() {
+		return
+	}
+	t = t.UTC()
+	if len(nv.Name) > 0 {
+		nv.Value = t
+		return
+	}
+	if t.Nanosecond() == 0 {
+		nv.Value = []byte(fmt.Sprintf("toDateTime('%s', 'UTC')", t.Format(timeFormat)))
+		return
+	}
+	nv.Value = []byte(fmt.Sprintf("toDateTime64('%s', 9, 'UTC')", t.Format(dateTime64Format(9))))
+}
@@ -0,0 +1,98 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnTimeZones(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	queries := make(chan string, 1)
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries <- query
+		w.Write([]byte("a\tb\tc\nDateTime(\\'Asia/Tokyo\\')\tDateTime64(3, \\'UTC\\')\tDate\n" +
+			"2021-01-01 09:00:00\t2021-01-01 00:00:00.500\t2021-01-01\n"))
+	})
+	defer ts.Close()
+	instant := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	date := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		params  string
+		ctx     context.Context
+		a, b    time.Time
+		aInTz   *time.Location
+		timeArg string
+	}{
+		// the column time zone is ignored without use_db_location
+		{"", context.Background(), instant.Add(9 * time.Hour), instant.Add(500 * time.Millisecond), time.UTC,
+			"'2021-01-01 09:00:00'"},
+		{"?use_db_location=1", context.Background(), instant, instant.Add(500 * time.Millisecond), tokyo,
+			"'2021-01-01 09:00:00'"},
+		{"?use_db_location=1&scan_location=Europe/Berlin", context.Background(), instant,
+			instant.Add(500 * time.Millisecond), berlin, "'2021-01-01 09:00:00'"},
+		{"?use_db_location=1&scan_location=Europe/Berlin", WithScanLocation(context.Background(), time.UTC), instant,
+			instant.Add(500 * time.Millisecond), time.UTC, "'2021-01-01 09:00:00'"},
+		{"?time_params=utc", context.Background(), instant.Add(9 * time.Hour), instant.Add(500 * time.Millisecond),
+			time.UTC, "toDateTime('2021-01-01 00:00:00', 'UTC')"},
+	} {
+		db, err := sql.Open("clickhouse", dsn+tc.params)
+		require.NoError(t, err)
+		var a, b, c time.Time
+		err = db.QueryRowContext(tc.ctx, "SELECT a, b, c FROM t WHERE a = ?", instant.In(tokyo)).Scan(&a, &b, &c)
+		require.NoError(t, err, tc.params)
+		assert.True(t, tc.a.Equal(a), "%s: %s", tc.params, a)
+		assert.True(t, tc.b.Equal(b), "%s: %s", tc.params, b)
+		assert.Equal(t, tc.aInTz, a.Location(), tc.params)
+		// dates are not converted
+		assert.Equal(t, date, c, tc.params)
+		assert.Equal(t, "SELECT a, b, c FROM t WHERE a = "+tc.timeArg, <-queries, tc.params)
+		db.Close()
+	}
+}
+
+func TestConvertTimeParam(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	c := &conn{utcTimeParams: true}
+	for _, tc := range []struct {
+		nv       driver.NamedValue
+		expected driver.Value
+	}{
+		{driver.NamedValue{Value: time.Date(2021, 1, 1, 9, 0, 0, 0, tokyo)}, []byte("toDateTime('2021-01-01 00:00:00', 'UTC')")},
+		{driver.NamedValue{Value: time.Date(2021, 1, 1, 9, 0, 0, 5, tokyo)},
+			[]byte("toDateTime64('2021-01-01 00:00:00.000000005', 9, 'UTC')")},
+		{driver.NamedValue{Name: "t", Value: time.Date(2021, 1, 1, 9, 0, 0, 0, tokyo)}, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{driver.NamedValue{Value: time.Time{}}, time.Time{}},
+		{driver.NamedValue{Value: "x"}, "x"},
+	} {
+		require.NoError(t, c.CheckNamedValue(&tc.nv))
+		assert.Equal(t, tc.expected, tc.nv.Value)
+	}
+}
+
+func TestParseDSNTimeZones(t *testing.T) {
+	cfg, err := ParseDSN("http://localhost:8123/test?use_db_location=1&scan_location=Asia/Tokyo&time_params=utc")
+	require.NoError(t, err)
+	assert.True(t, cfg.UseDBLocation)
+	assert.Equal(t, "Asia/Tokyo", cfg.ScanLocation.String())
+	assert.Equal(t, TimeParamsUTC, cfg.TimeParams)
+	assert.Empty(t, cfg.Params)
+	assert.Equal(t, "http://localhost:8123/test?idle_timeout=1h0m0s&scan_location=Asia%2FTokyo&time_params=utc&use_db_location=1",
+		cfg.FormatDSN())
+
+	_, err = ParseDSN("http://localhost:8123/test?time_params=local")
+	assert.EqualError(t, err, "clickhouse: unknown time params mode 'local'")
+	_, err = ParseDSN("http://localhost:8123/test?scan_location=Mars/Olympus")
+	assert.Error(t, err)
+}
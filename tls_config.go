@@ -2,6 +2,7 @@ package clickhouse
 
 import (
 	"crypto/tls"
+	"fmt"
 	"sync"
 )
 
@@ -13,8 +14,19 @@ var (
 	tlsConfigRegistry map[string]*tls.Config
 )
 
+// tlsSkipVerify is the name of the predefined tls.Config which does not
+// verify certificates of the server, it is meant for test clusters only
+const tlsSkipVerify = "skip-verify"
+
 // RegisterTLSConfig registers a custom tls.Config to be used with sql.Open.
+// The config is selected by the tls=<key> DSN parameter, so it may contain
+// custom root CAs, client certificates for mutual TLS, etc. The keys "true",
+// "false" and "skip-verify" are reserved.
 func RegisterTLSConfig(key string, config *tls.Config) error {
+	switch key {
+	case "true", "false", tlsSkipVerify:
+		return fmt.Errorf("clickhouse: TLS config key %q is reserved", key)
+	}
 	tlsConfigLock.Lock()
 	if tlsConfigRegistry == nil {
 		tlsConfigRegistry = make(map[string]*tls.Config)
@@ -35,6 +47,9 @@ func DeregisterTLSConfig(key string) {
 }
 
 func getTLSConfigClone(key string) (config *tls.Config) {
+	if key == tlsSkipVerify {
+		return &tls.Config{InsecureSkipVerify: true}
+	}
 	tlsConfigLock.RLock()
 	if v, ok := tlsConfigRegistry[key]; ok {
 		config = v.Clone()
@@ -42,3 +57,10 @@ func getTLSConfigClone(key string) (config *tls.Config) {
 	tlsConfigLock.RUnlock()
 	return
 }
+
+func isTLSConfigRegistered(key string) bool {
+	tlsConfigLock.RLock()
+	defer tlsConfigLock.RUnlock()
+	_, ok := tlsConfigRegistry[key]
+	return ok
+}
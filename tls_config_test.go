@@ -0,0 +1,53 @@
+package clickhouse
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSParam(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Ok.\n"))
+	}))
+	// the failed handshake is expected
+	ts.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	ts.StartTLS()
+	defer ts.Close()
+	addr := ts.Listener.Addr().String()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	require.NoError(t, RegisterTLSConfig("test", &tls.Config{RootCAs: pool}))
+	defer DeregisterTLSConfig("test")
+	assert.Error(t, RegisterTLSConfig(tlsSkipVerify, &tls.Config{}))
+
+	for _, param := range []string{"test", "skip-verify"} {
+		cfg, err := ParseDSN("http://" + addr + "/default?tls=" + param)
+		require.NoError(t, err)
+		assert.Equal(t, "https", cfg.Scheme)
+		assert.Equal(t, param, cfg.TLSConfig)
+
+		db, err := sql.Open("clickhouse", cfg.FormatDSN())
+		require.NoError(t, err)
+		assert.NoError(t, db.Ping(), param)
+		db.Close()
+	}
+
+	// the default config does not trust the certificate of the test server
+	db, err := sql.Open("clickhouse", "http://"+addr+"/default?tls=true")
+	require.NoError(t, err)
+	assert.Error(t, db.Ping())
+	db.Close()
+
+	_, err = ParseDSN("http://" + addr + "/default?tls=unknown")
+	assert.Error(t, err)
+}
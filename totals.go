@@ -0,0 +1,117 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// TotalsReader is implemented by the rows of the driver, e.g. returned by
+// the QueryContext of the connection of sql.Conn.Raw. The totals and the
+// extremes follow the rows in the result, so they are available after Next
+// returned io.EOF.
+type TotalsReader interface {
+	// Totals returns the row of the totals of a query WITH TOTALS, nil if
+	// the result has no totals
+	Totals() []driver.Value
+	// Extremes returns the rows of the minimums and the maximums of the
+	// columns of a query with the setting extremes=1, nil if the result
+	// has no extremes
+	Extremes() (min, max []driver.Value)
+}
+
+// Totals implements the TotalsReader
+func (r *resultRows) Totals() []driver.Value {
+	return r.totals
+}
+
+// Extremes implements the TotalsReader
+func (r *resultRows) Extremes() (min, max []driver.Value) {
+	if len(r.extremes) != 2 {
+		return nil, nil
+	}
+	return r.extremes[0], r.extremes[1]
+}
+
+// setSections sets the totals and the extremes from the sections of the rows
+// which follow the rows of the result
+func (r *resultRows) setSections(sections [][][]driver.Value) error {
+	switch {
+	case len(sections) == 0:
+	case len(sections) == 2 && len(sections[0]) == 1 && len(sections[1]) == 2:
+		r.totals, r.extremes = sections[0][0], sections[1]
+	case len(sections) == 1 && len(sections[0]) == 1:
+		r.totals = sections[0][0]
+	case len(sections) == 1 && len(sections[0]) == 2:
+		r.extremes = sections[0]
+	default:
+		return fmt.Errorf("clickhouse: unexpected %d sections after the rows of the result", len(sections))
+	}
+	return nil
+}
+
+// hasResultSections reports whether the result of the query may have totals
+// or extremes
+func hasResultSections(pq *parsedQuery, req *http.Request) bool {
+	return pq.totals || req.URL.Query().Get("extremes") == "1"
+}
+
+// hasTotals reports whether the query has WITH TOTALS
+func hasTotals(query string) bool {
+	words, _ := splitSQL(query)
+	for _, w := range words {
+		if w.is("TOTALS") {
+			return true
+		}
+	}
+	return false
+}
+
+// WithTotals returns a copy of ctx which keeps the totals and the extremes of
+// the result of the last query executed with it, they can be read with Totals
+// and Extremes once the rows are closed
+func WithTotals(ctx context.Context) context.Context {
+	return context.WithValue(ctx, totalsKey, new(queryTotals))
+}
+
+// Totals returns the totals row of the result of the last query executed with
+// ctx. It returns false if ctx was not created by WithTotals or the result had
+// no totals.
+func Totals(ctx context.Context) ([]driver.Value, bool) {
+	if t, ok := ctx.Value(totalsKey).(*queryTotals); ok {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		return t.totals, t.totals != nil
+	}
+	return nil, false
+}
+
+// Extremes returns the minimums and the maximums of the columns of the result
+// of the last query executed with ctx. It returns false if ctx was not created
+// by WithTotals or the result had no extremes.
+func Extremes(ctx context.Context) (min, max []driver.Value, ok bool) {
+	if t, ok := ctx.Value(totalsKey).(*queryTotals); ok {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		return t.min, t.max, t.min != nil
+	}
+	return nil, nil, false
+}
+
+type queryTotals struct {
+	mu       sync.Mutex
+	totals   []driver.Value
+	min, max []driver.Value
+}
+
+// keepTotals keeps the totals and the extremes of the rows in the context
+func keepTotals(ctx context.Context, rows *resultRows) {
+	if t, ok := ctx.Value(totalsKey).(*queryTotals); ok {
+		t.mu.Lock()
+		t.totals = rows.totals
+		t.min, t.max = rows.Extremes()
+		t.mu.Unlock()
+	}
+}
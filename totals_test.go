@@ -0,0 +1,96 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTotals(t *testing.T) {
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		if r.URL.Query().Get("default_format") == FormatRowBinaryWithNamesAndTypes {
+			// RowBinary is used without totals and extremes
+			b := &rowBinary{}
+			b.le(uint8(2)).str("k").str("c").str("String").str("UInt64")
+			b.str("a").le(uint64(1)).str("b").le(uint64(2))
+			w.Write(b.Bytes())
+			return
+		}
+		body := "k\tc\nString\tUInt64\na\t1\nb\t2\n\n\t3\n"
+		if r.URL.Query().Get("extremes") == "1" {
+			body += "\na\t1\nb\t2\n"
+		}
+		w.Write([]byte(body))
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn+"?format="+FormatRowBinaryWithNamesAndTypes)
+	require.NoError(t, err)
+	defer db.Close()
+
+	query := func(ctx context.Context, q string) []string {
+		rows, err := db.QueryContext(ctx, q)
+		require.NoError(t, err)
+		defer rows.Close()
+		var keys []string
+		for rows.Next() {
+			var (
+				k string
+				c uint64
+			)
+			require.NoError(t, rows.Scan(&k, &c))
+			keys = append(keys, k)
+		}
+		require.NoError(t, rows.Err())
+		return keys
+	}
+
+	ctx := WithTotals(context.Background())
+	assert.Equal(t, []string{"a", "b"}, query(ctx, "SELECT k, count() AS c FROM t GROUP BY k WITH TOTALS"))
+	totals, ok := Totals(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, []driver.Value{"", uint64(3)}, totals)
+	_, _, ok = Extremes(ctx)
+	assert.False(t, ok)
+
+	extremesCtx := WithSettings(ctx, map[string]interface{}{"extremes": 1})
+	assert.Equal(t, []string{"a", "b"}, query(extremesCtx, "SELECT k, count() AS c FROM t GROUP BY k WITH TOTALS"))
+	totals, ok = Totals(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, []driver.Value{"", uint64(3)}, totals)
+	min, max, ok := Extremes(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, []driver.Value{"a", uint64(1)}, min)
+	assert.Equal(t, []driver.Value{"b", uint64(2)}, max)
+
+	assert.Equal(t, []string{"a", "b"}, query(ctx, "SELECT k, count() AS c FROM t GROUP BY k"))
+	_, ok = Totals(ctx)
+	assert.False(t, ok)
+}
+
+func TestTotalsReader(t *testing.T) {
+	rows, err := newTextRows(&conn{}, &bufReadCloser{bytes.NewReader([]byte("k\tc\nString\tUInt64\n\n\t0\n"))}, &DataParserOptions{Location: time.UTC})
+	require.NoError(t, err)
+	rows.sections = true
+	var totals TotalsReader = rows
+	dest := make([]driver.Value, 2)
+	assert.Equal(t, io.EOF, rows.Next(dest))
+	assert.Equal(t, []driver.Value{"", uint64(0)}, totals.Totals())
+	min, max := totals.Extremes()
+	assert.Nil(t, min)
+	assert.Nil(t, max)
+
+	rows, err = newTextRows(&conn{}, &bufReadCloser{bytes.NewReader([]byte("k\nString\na\n\nb\n\nc\n\nd\n"))}, &DataParserOptions{Location: time.UTC})
+	require.NoError(t, err)
+	rows.sections = true
+	require.NoError(t, rows.Next(dest))
+	assert.Error(t, rows.Next(dest))
+}
@@ -0,0 +1,82 @@
+package clickhouse
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// Tracer starts spans of queries. It is implemented by adapters of tracing
+// libraries, e.g. for an OpenTelemetry trace.Tracer:
+//
+//	func (t otelTracer) StartSpan(ctx context.Context, name string) (context.Context, clickhouse.Span) {
+//	    ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//	    return ctx, otelSpan{span}
+//	}
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span of a query started by Tracer. Spans get the attributes
+// db.system, db.statement, clickhouse.query_id and, for the spans of
+// queries returning rows, db.rows_returned and clickhouse.bytes_received.
+type Span interface {
+	// SetAttribute sets the attribute, values are strings or int64 numbers
+	SetAttribute(key string, value interface{})
+	// RecordError records the error of the query
+	RecordError(err error)
+	// TraceParent returns the W3C traceparent of the span, which is sent in
+	// the traceparent header so the server continues the trace, or an
+	// empty string
+	TraceParent() string
+	// End ends the span
+	End()
+}
+
+// startSpan starts the span of the query if the connection has a tracer
+func (c *conn) startSpan(ctx context.Context, name, query string) (context.Context, Span) {
+	if c.tracer == nil {
+		return ctx, nil
+	}
+	ctx, span := c.tracer.StartSpan(ctx, name)
+	span.SetAttribute("db.system", "clickhouse")
+	span.SetAttribute("db.statement", query)
+	return ctx, span
+}
+
+// traceRequest adds the query_id of the request to the span and propagates
+// the trace context to the server
+func traceRequest(span Span, req *http.Request) {
+	if span == nil {
+		return
+	}
+	if queryID := req.URL.Query().Get(queryIDParamName); len(queryID) > 0 {
+		span.SetAttribute("clickhouse.query_id", queryID)
+	}
+	if traceParent := span.TraceParent(); len(traceParent) > 0 {
+		req.Header.Set("traceparent", traceParent)
+	}
+}
+
+// endSpan records the error of the query and ends the span
+func endSpan(span Span, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// countingBody counts the bytes read from the response body
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
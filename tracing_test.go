@@ -0,0 +1,86 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSpan struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *testSpan) RecordError(err error)                      { s.err = err }
+func (s *testSpan) TraceParent() string {
+	return "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+}
+func (s *testSpan) End() { s.ended = true }
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	span := &testSpan{name: name, attrs: make(map[string]interface{})}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+func TestTracer(t *testing.T) {
+	var traceParents, queryIDs []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		traceParents = append(traceParents, r.Header.Get("traceparent"))
+		queryIDs = append(queryIDs, r.URL.Query().Get(queryIDParamName))
+		switch query {
+		case "SELECT a":
+			w.Write([]byte("a\nUInt8\n1\n2\n"))
+		case "DROP TABLE t":
+			http.Error(w, "Code: 60. DB::Exception: Table default.t doesn't exist. (UNKNOWN_TABLE)", http.StatusNotFound)
+		}
+	})
+	defer ts.Close()
+
+	cfg, err := ParseDSN(dsn)
+	require.NoError(t, err)
+	tracer := &testTracer{}
+	cfg.Tracer = tracer
+	db := sql.OpenDB(NewConnector(cfg))
+	defer db.Close()
+
+	rows, err := db.Query("SELECT a")
+	require.NoError(t, err)
+	for rows.Next() {
+	}
+	require.NoError(t, rows.Close())
+	_, err = db.Exec("DROP TABLE t")
+	require.Error(t, err)
+
+	require.Len(t, tracer.spans, 2)
+	query, exec := tracer.spans[0], tracer.spans[1]
+	assert.Equal(t, "clickhouse.query", query.name)
+	assert.True(t, query.ended)
+	assert.NoError(t, query.err)
+	assert.Equal(t, map[string]interface{}{
+		"db.system":                 "clickhouse",
+		"db.statement":              "SELECT a",
+		"clickhouse.query_id":       queryIDs[0],
+		"db.rows_returned":          int64(2),
+		"clickhouse.bytes_received": int64(12),
+	}, query.attrs)
+	assert.Equal(t, "clickhouse.exec", exec.name)
+	assert.True(t, exec.ended)
+	var ex *Exception
+	assert.True(t, errors.As(exec.err, &ex))
+	assert.Equal(t, queryIDs[1], exec.attrs["clickhouse.query_id"])
+	assert.Len(t, queryIDs[1], 36)
+	assert.Equal(t, []string{query.TraceParent(), exec.TraceParent()}, traceParents)
+}
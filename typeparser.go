@@ -2,12 +2,19 @@ package clickhouse
 
 import (
 	"fmt"
+	"strconv"
 )
 
 // TypeDesc describes a (possibly nested) data type returned by ClickHouse.
+// ArgNames holds names of the arguments for the types with named elements,
+// like Nested(a UInt8, b String), otherwise it is nil.
+// EnumValues holds the numeric values of the elements of Enum8 and Enum16,
+// it is nil if the elements are not in the form 'name' = value.
 type TypeDesc struct {
-	Name string
-	Args []*TypeDesc
+	Name       string
+	Args       []*TypeDesc
+	ArgNames   []string
+	EnumValues map[string]int16
 }
 
 func parseTypeDesc(tokens []*token) (*TypeDesc, []*token, error) {
@@ -31,15 +38,17 @@ func parseTypeDesc(tokens []*token) (*TypeDesc, []*token, error) {
 	}
 
 	if name == "Enum8" || name == "Enum16" {
-		// TODO: an Enum's arguments get completely ignored
 		for i := range tokens {
 			if tokens[i].kind == ')' {
+				desc.EnumValues = parseEnumValues(tokens[:i])
 				return &desc, tokens[i+1:], nil
 			}
 		}
 		return nil, nil, fmt.Errorf("unfinished enum type description")
 	}
 
+	var names []string
+	named := false
 	for {
 		var arg *TypeDesc
 		var err error
@@ -48,18 +57,66 @@ func parseTypeDesc(tokens []*token) (*TypeDesc, []*token, error) {
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to parse subtype: %v", err)
 		}
+		argName := ""
+		if len(arg.Args) == 0 && tokens[0].kind == 's' {
+			// named element: the parsed name is followed by its type
+			argName = arg.Name
+			arg, tokens, err = parseTypeDesc(tokens)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse subtype of %s: %v", argName, err)
+			}
+			named = true
+		}
 		desc.Args = append(desc.Args, arg)
+		names = append(names, argName)
 
 		switch tokens[0].kind {
 		case ',':
 			tokens = tokens[1:]
 			continue
 		case ')':
+			if named {
+				desc.ArgNames = names
+			}
 			return &desc, tokens[1:], nil
 		}
 	}
 }
 
+// parseEnumValues parses the elements 'name' = value of an Enum, nil is
+// returned if the elements are malformed
+func parseEnumValues(tokens []*token) map[string]int16 {
+	values := make(map[string]int16)
+	for len(tokens) > 0 {
+		if tokens[0].kind != 'q' {
+			return nil
+		}
+		name := tokens[0].data
+		tokens = tokens[1:]
+		// the value is split into tokens by spaces: = 1, =1 or = -1
+		var value string
+		for len(tokens) > 0 && tokens[0].kind == 's' {
+			value += tokens[0].data
+			tokens = tokens[1:]
+		}
+		if len(value) < 2 || value[0] != '=' {
+			return nil
+		}
+		n, err := strconv.ParseInt(value[1:], 10, 16)
+		if err != nil {
+			return nil
+		}
+		values[name] = int16(n)
+		if len(tokens) > 0 {
+			if tokens[0].kind != ',' {
+				return nil
+			}
+			tokens = tokens[1:]
+		}
+	}
+	return values
+}
+
 // ParseTypeDesc parses the type description that ClickHouse provides.
 //
 // The grammar is quite simple:
@@ -68,13 +125,17 @@ func parseTypeDesc(tokens []*token) (*TypeDesc, []*token, error) {
 //         name()
 //         name(args)
 //     args
+//         arg
+//         arg, args
+//     arg
 //         desc
-//         desc, args
+//         name desc
 //
 // Examples:
 //     String
 //     Nullable(Nothing)
 //     Array(Tuple(Tuple(String, String), Tuple(String, UInt64)))
+//     Nested(id UInt64, name String)
 func ParseTypeDesc(s string) (*TypeDesc, error) {
 	tokens, err := tokenizeString(s)
 	if err != nil {
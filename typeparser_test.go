@@ -40,6 +40,14 @@ func TestParseTypeDesc(t *testing.T) {
 				Args: []*TypeDesc{{Name: "42"}},
 			},
 		},
+		{
+			name:  "enum values",
+			input: "Enum8('one' = 1, 'it\\'s'=-2,'three' =3)",
+			output: &TypeDesc{
+				Name:       "Enum8",
+				EnumValues: map[string]int16{"one": 1, "it's": -2, "three": 3},
+			},
+		},
 		{
 			name:   "args are ignored for Enum",
 			input:  "Enum8(you can = put, 'whatever' here)",
@@ -91,6 +99,18 @@ func TestParseTypeDesc(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:  "named args",
+			input: "Nested(id UInt64, items Array(String))",
+			output: &TypeDesc{
+				Name: "Nested",
+				Args: []*TypeDesc{
+					{Name: "UInt64"},
+					{Name: "Array", Args: []*TypeDesc{{Name: "String"}}},
+				},
+				ArgNames: []string{"id", "items"},
+			},
+		},
 		{
 			name:  "unfinished arg list",
 			input: "Array(Tuple(Tuple(String, String), Tuple(String, UInt64))",
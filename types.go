@@ -12,9 +12,30 @@ func Array(v interface{}) driver.Valuer {
 	return array{v: v}
 }
 
-// Date returns date for t
+// Tuple wraps values into driver.Valuer interface to pass them as Clickhouse Tuple,
+// structs are passed as tuples of their exported fields without the wrapper
+func Tuple(values ...interface{}) driver.Valuer {
+	return tuple(values)
+}
+
+// Date returns date for t for Date columns. Dates out of the range of Date,
+// 1970-01-01 to 2149-06-06, fail instead of being wrapped around by the
+// server. The zero t is sent as the zero date 0000-00-00.
 func Date(t time.Time) driver.Valuer {
-	return date(t)
+	return date{t, dateRange}
+}
+
+// Date32 returns date for t for Date32 columns, which store the dates from
+// 1900-01-01 to 2299-12-31
+func Date32(t time.Time) driver.Valuer {
+	return date{t, date32Range}
+}
+
+// DateTime64 returns t with precision digits of the fractional part of the
+// second for DateTime64(precision) columns. Values are formatted in the
+// location of t, the server interprets them in the timezone of the column.
+func DateTime64(t time.Time, precision int) driver.Valuer {
+	return dateTime64{t, precision}
 }
 
 // UInt64 returns date for t
@@ -49,11 +70,69 @@ func (a array) Value() (driver.Value, error) {
 	return textEncode.Encode(a)
 }
 
-type date time.Time
+type tuple []interface{}
+
+// Value implements driver.Valuer
+func (t tuple) Value() (driver.Value, error) {
+	res := []byte{'('}
+	for i, v := range t {
+		if i > 0 {
+			res = append(res, ',')
+		}
+		if vr, ok := v.(driver.Valuer); ok {
+			var err error
+			if v, err = vr.Value(); err != nil {
+				return nil, err
+			}
+		}
+		tmp, err := textEncode.Encode(v)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, tmp...)
+	}
+	return append(res, ')'), nil
+}
+
+// dateLimits is the range of the dates of a column type
+type dateLimits struct {
+	name     string
+	min, max time.Time
+}
+
+var (
+	dateRange   = dateLimits{"Date", time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2149, 6, 6, 0, 0, 0, 0, time.UTC)}
+	date32Range = dateLimits{"Date32", time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2299, 12, 31, 0, 0, 0, 0, time.UTC)}
+)
+
+type date struct {
+	t     time.Time
+	limit dateLimits
+}
 
 // Value implements driver.Valuer
 func (d date) Value() (driver.Value, error) {
-	return []byte(formatDate(time.Time(d))), nil
+	if !d.t.IsZero() {
+		// the date is sent as it is in the location of t
+		day := time.Date(d.t.Year(), d.t.Month(), d.t.Day(), 0, 0, 0, 0, time.UTC)
+		if day.Before(d.limit.min) || day.After(d.limit.max) {
+			return nil, fmt.Errorf("clickhouse: date %s is out of the range of %s", d.t.Format(dateFormat), d.limit.name)
+		}
+	}
+	return []byte(formatDate(d.t)), nil
+}
+
+type dateTime64 struct {
+	t         time.Time
+	precision int
+}
+
+// Value implements driver.Valuer
+func (d dateTime64) Value() (driver.Value, error) {
+	if d.precision < 0 || d.precision > maxDateTime64Precision {
+		return nil, fmt.Errorf("clickhouse: invalid DateTime64 precision %d", d.precision)
+	}
+	return []byte(formatDateTime64(d.t, d.precision)), nil
 }
 
 type bigUint64 uint64
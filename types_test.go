@@ -30,12 +30,61 @@ func TestArray(t *testing.T) {
 	}
 }
 
+func TestTuple(t *testing.T) {
+	dv, err := Tuple(1, "it's", []int{1, 2}, nil).Value()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []byte("(1,'it\\'s',[1,2],NULL)"), dv)
+	}
+	dv, err = Tuple().Value()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []byte("()"), dv)
+	}
+}
+
 func TestDate(t *testing.T) {
 	d := time.Date(2016, 4, 4, 0, 0, 0, 0, time.Local)
 	dv, err := Date(d).Value()
 	if assert.NoError(t, err) {
 		assert.Equal(t, []byte("'2016-04-04'"), dv)
 	}
+
+	dv, err = Date(time.Time{}).Value()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []byte("'0000-00-00'"), dv)
+	}
+
+	_, err = Date(time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC)).Value()
+	assert.EqualError(t, err, "clickhouse: date 1969-12-31 is out of the range of Date")
+	_, err = Date(time.Date(2149, 6, 7, 0, 0, 0, 0, time.UTC)).Value()
+	assert.Error(t, err)
+	dv, err = Date32(time.Date(1900, 1, 1, 23, 0, 0, 0, time.UTC)).Value()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []byte("'1900-01-01'"), dv)
+	}
+	_, err = Date32(time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC)).Value()
+	assert.EqualError(t, err, "clickhouse: date 2300-01-01 is out of the range of Date32")
+}
+
+func TestDateTime64(t *testing.T) {
+	d := time.Date(2016, 4, 4, 1, 2, 3, 123456789, time.UTC)
+	dv, err := DateTime64(d, 3).Value()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []byte("'2016-04-04 01:02:03.123'"), dv)
+	}
+	dv, err = DateTime64(d, 9).Value()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []byte("'2016-04-04 01:02:03.123456789'"), dv)
+	}
+	dv, err = DateTime64(d, 0).Value()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []byte("'2016-04-04 01:02:03'"), dv)
+	}
+	dv, err = DateTime64(time.Time{}, 2).Value()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []byte("'0000-00-00 00:00:00.00'"), dv)
+	}
+	_, err = DateTime64(d, 10).Value()
+	assert.Error(t, err)
 }
 
 func TestUInt64(t *testing.T) {
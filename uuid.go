@@ -0,0 +1,102 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/hex"
+	"fmt"
+)
+
+// UUID is a value of UUID column, it can be scanned from UUID columns and
+// passed as an argument
+type UUID [16]byte
+
+// ParseUUID parses a UUID in the canonical form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("clickhouse: invalid UUID %q", s)
+	}
+	src := []byte(s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:])
+	if _, err := hex.Decode(u[:], src); err != nil {
+		return u, fmt.Errorf("clickhouse: invalid UUID %q", s)
+	}
+	return u, nil
+}
+
+// String returns the UUID in the canonical form
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// MarshalText implements the encoding.TextMarshaler
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler
+func (u *UUID) UnmarshalText(text []byte) (err error) {
+	*u, err = ParseUUID(string(text))
+	return err
+}
+
+// Scan implements the sql.Scanner
+func (u *UUID) Scan(src interface{}) (err error) {
+	switch v := src.(type) {
+	case string:
+		*u, err = ParseUUID(v)
+	case []byte:
+		*u, err = ParseUUID(string(v))
+	default:
+		err = fmt.Errorf("clickhouse: can not scan %T into UUID", src)
+	}
+	return err
+}
+
+// Value implements the driver.Valuer
+func (u UUID) Value() (driver.Value, error) {
+	return u.String(), nil
+}
+
+// ScanUUID returns a sql.Scanner which scans a UUID column into dest, which
+// must be a *[16]byte, a *string or an encoding.TextUnmarshaler, e.g. the
+// UUID types of the third-party packages
+func ScanUUID(dest interface{}) sql.Scanner {
+	return &uuidScanner{dest: dest}
+}
+
+type uuidScanner struct {
+	dest interface{}
+}
+
+// Scan implements the sql.Scanner
+func (s *uuidScanner) Scan(src interface{}) error {
+	var u UUID
+	if err := u.Scan(src); err != nil {
+		return err
+	}
+	switch d := s.dest.(type) {
+	case *[16]byte:
+		*d = u
+	case *UUID:
+		*d = u
+	case *string:
+		*d = u.String()
+	case encoding.TextUnmarshaler:
+		return d.UnmarshalText([]byte(u.String()))
+	default:
+		return fmt.Errorf("clickhouse: can not scan UUID into %T", s.dest)
+	}
+	return nil
+}
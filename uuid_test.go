@@ -0,0 +1,70 @@
+package clickhouse
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// textUUID is a UUID type of a third-party package, which implements only
+// the text encoding interfaces
+type textUUID struct {
+	s string
+}
+
+func (u textUUID) MarshalText() ([]byte, error) {
+	return []byte(u.s), nil
+}
+
+func (u *textUUID) UnmarshalText(text []byte) error {
+	u.s = strings.ToUpper(string(text))
+	return nil
+}
+
+func TestUUID(t *testing.T) {
+	const s = "6d21b342-8b0a-4b3e-9b8f-00a1b2c3d4e5"
+	u, err := ParseUUID(s)
+	require.NoError(t, err)
+	assert.Equal(t, UUID{0x6d, 0x21, 0xb3, 0x42, 0x8b, 0x0a, 0x4b, 0x3e, 0x9b, 0x8f, 0x00, 0xa1, 0xb2, 0xc3, 0xd4, 0xe5}, u)
+	assert.Equal(t, s, u.String())
+	for _, invalid := range []string{"", "6d21b342-8b0a-4b3e-9b8f-00a1b2c3d4e", "6d21b342+8b0a-4b3e-9b8f-00a1b2c3d4e5", "xd21b342-8b0a-4b3e-9b8f-00a1b2c3d4e5"} {
+		_, err := ParseUUID(invalid)
+		assert.Error(t, err, invalid)
+	}
+
+	var queries []string
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		queries = append(queries, query)
+		if r.Method == http.MethodGet {
+			w.Write([]byte("u\nUUID\n" + s + "\n"))
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var str string
+	require.NoError(t, db.QueryRow("SELECT u").Scan(&str))
+	assert.Equal(t, s, str)
+	var scanned UUID
+	require.NoError(t, db.QueryRow("SELECT u").Scan(&scanned))
+	assert.Equal(t, u, scanned)
+	var raw [16]byte
+	require.NoError(t, db.QueryRow("SELECT u").Scan(ScanUUID(&raw)))
+	assert.Equal(t, [16]byte(u), raw)
+	var text textUUID
+	require.NoError(t, db.QueryRow("SELECT u").Scan(ScanUUID(&text)))
+	assert.Equal(t, strings.ToUpper(s), text.s)
+	var wrong int
+	assert.Error(t, db.QueryRow("SELECT u").Scan(ScanUUID(&wrong)))
+
+	_, err = db.Exec("INSERT INTO t VALUES (?, ?, ?, ?)", u, raw, textUUID{s}, &text)
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO t VALUES ('"+s+"', '"+s+"', '"+s+"', '"+strings.ToUpper(s)+"')", queries[len(queries)-1])
+}
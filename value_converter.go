@@ -2,8 +2,13 @@ package clickhouse
 
 import (
 	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"math/big"
+	"net"
 	"reflect"
 	"strconv"
+	"time"
 )
 
 func (stmt *stmt) ColumnConverter(idx int) driver.ValueConverter {
@@ -12,19 +17,57 @@ func (stmt *stmt) ColumnConverter(idx int) driver.ValueConverter {
 
 func (c *conn) CheckNamedValue(nv *driver.NamedValue) (err error) {
 	nv.Value, err = converter{}.ConvertValue(nv.Value)
+	if err == nil && c.utcTimeParams {
+		convertTimeParam(nv)
+	}
 	return
 }
 
 type converter struct{}
 
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
 const maxAllowedUInt64 = 1<<63 - 1
 
 func (c converter) ConvertValue(v interface{}) (driver.Value, error) {
+	// the values of custom types are converted like the other arguments,
+	// e.g. uint64 values above math.MaxInt64 or int32 values
+	if value, ok, err := callValuer(v); ok {
+		if err != nil || value == nil {
+			return nil, err
+		}
+		return c.ConvertValue(value)
+	}
 	if driver.IsValue(v) {
 		return v, nil
 	}
+	switch vv := v.(type) {
+	case *big.Int, *big.Rat, *big.Float, net.IP:
+		// exact numeric literals and IP addresses
+		return textEncode.Encode(v)
+	case [16]byte:
+		return UUID(vv).String(), nil
+	case time.Duration:
+		return []byte(formatInterval(vv)), nil
+	}
 
 	rv := reflect.ValueOf(v)
+	if m, ok := v.(encoding.TextMarshaler); ok && !(rv.Kind() == reflect.Ptr && rv.IsNil()) {
+		// e.g. UUID types of the third-party packages
+		text, err := m.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return string(text), nil
+	}
+	if m, ok := v.(json.Marshaler); ok && !(rv.Kind() == reflect.Ptr && rv.IsNil()) {
+		// e.g. json.RawMessage for JSON columns
+		b, err := m.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
 	switch rv.Kind() {
 	case reflect.Ptr:
 		// indirect pointers
@@ -32,6 +75,13 @@ func (c converter) ConvertValue(v interface{}) (driver.Value, error) {
 			return nil, nil
 		}
 		return c.ConvertValue(rv.Elem().Interface())
+	case reflect.Map:
+		return textEncode.Encode(v)
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() != reflect.Uint8 {
+			// byte slices are passed as raw strings, use Array for them
+			return textEncode.Encode(v)
+		}
 	case reflect.Uint64:
 		u64 := rv.Uint()
 		if u64 > maxAllowedUInt64 {
@@ -2,9 +2,12 @@ package clickhouse
 
 import (
 	"database/sql/driver"
+	"fmt"
 	"math"
+	"net"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -13,6 +16,15 @@ func getUInt64Ptr(v uint64) *uint64 {
 	return &v
 }
 
+type rawBytes []byte
+
+// userID is a custom numeric type which values are not valid driver values
+type userID uint64
+
+func (id userID) Value() (driver.Value, error) {
+	return uint64(id), nil
+}
+
 func TestConverter(t *testing.T) {
 	testCases := []struct {
 		value    interface{}
@@ -25,6 +37,9 @@ func TestConverter(t *testing.T) {
 		{getUInt64Ptr(maxAllowedUInt64 + 1), []byte("9223372036854775808"), "*uint64(maxAllowedUInt64+1)"},
 		{getUInt64Ptr(maxAllowedUInt64*2 + 1), []byte("18446744073709551615"), "*uint64(maxUInt64)"},
 
+		// net.IP
+		{net.IPv4(10, 0, 0, 1), []byte("'10.0.0.1'"), "net.IP"},
+
 		// int64
 		{int64(0), int64(0), "int64(0)"},
 		{int64(math.MinInt64), int64(-9223372036854775808), "int64(MinInt64)"},
@@ -34,6 +49,32 @@ func TestConverter(t *testing.T) {
 		{uint64(maxAllowedUInt64), uint64(9223372036854775807), "uint64(maxAllowedUInt64)"},
 		{uint64(maxAllowedUInt64 + 1), []byte("9223372036854775808"), "uint64(maxAllowedUInt64+1)"},
 		{uint64(maxAllowedUInt64*2 + 1), []byte("18446744073709551615"), "uint64(maxUInt64)"},
+
+		// slices and arrays
+		{[]int{1, 2}, []byte("[1,2]"), "[]int"},
+		{[][]int32{{1}, {}}, []byte("[[1],[]]"), "[][]int32"},
+		{[]string{"it's", `a\b`}, []byte(`['it\'s','a\\b']`), "[]string"},
+		{[]*int{nil}, []byte("[NULL]"), "[]*int"},
+		{[]interface{}{1, "a", nil}, []byte("[1,'a',NULL]"), "[]interface{}"},
+		{[2]float64{1.5, -1}, []byte("[1.5,-1]"), "[2]float64"},
+		{[]time.Time{time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}, []byte("['2020-01-02 03:04:05']"), "[]time.Time"},
+		{rawBytes("raw"), []byte("raw"), "named []byte"},
+
+		// driver.Valuer
+		{userID(1), uint64(1), "userID(1)"},
+		{userID(math.MaxUint64), []byte("18446744073709551615"), "userID(MaxUint64)"},
+		{(*userID)(nil), nil, "*userID(nil)"},
+		{[]userID{1, math.MaxUint64}, []byte("[1,18446744073709551615]"), "[]userID"},
+		{[]driver.Valuer{Date(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)), nil}, []byte("['2020-01-02',NULL]"), "[]driver.Valuer"},
+		{[]UUID{{0x41, 0x7d}}, []byte("['417d0000-0000-0000-0000-000000000000']"), "[]UUID"},
+		{map[string]userID{"a": 1}, []byte("{'a':1}"), "map[string]userID"},
+
+		// time.Duration
+		{30 * time.Second, []byte("toIntervalSecond(30)"), "30s"},
+		{-1500 * time.Millisecond, []byte("toIntervalMillisecond(-1500)"), "-1.5s"},
+		{time.Microsecond, []byte("toIntervalMicrosecond(1)"), "1µs"},
+		{time.Duration(1), []byte("toIntervalNanosecond(1)"), "1ns"},
+		{[]time.Duration{time.Minute}, []byte("[toIntervalSecond(60)]"), "[]time.Duration"},
 	}
 
 	for _, tc := range testCases {
@@ -46,3 +87,43 @@ func TestConverter(t *testing.T) {
 		}
 	}
 }
+
+// money is an application type which does not implement driver.Valuer
+type money struct {
+	units int64
+	cents int64
+}
+
+func TestRegisterValuer(t *testing.T) {
+	typ := reflect.TypeOf(money{})
+	RegisterValuer(typ, func(v interface{}) (driver.Value, error) {
+		m := v.(money)
+		return []byte(fmt.Sprintf("toDecimal64('%d.%02d', 2)", m.units, m.cents)), nil
+	})
+	defer RegisterValuer(typ, nil)
+
+	dv, err := converter{}.ConvertValue(money{1, 5})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("toDecimal64('1.05', 2)"), dv)
+	dv, err = converter{}.ConvertValue([]money{{2, 50}})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("[toDecimal64('2.50', 2)]"), dv)
+	dv, err = converter{}.ConvertValue(&money{3, 0})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("toDecimal64('3.00', 2)"), dv)
+
+	// the registered function takes precedence over driver.Valuer
+	RegisterValuer(reflect.TypeOf(userID(0)), func(v interface{}) (driver.Value, error) {
+		return fmt.Sprintf("user-%d", v), nil
+	})
+	dv, err = converter{}.ConvertValue(userID(7))
+	RegisterValuer(reflect.TypeOf(userID(0)), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-7", dv)
+
+	RegisterValuer(typ, func(v interface{}) (driver.Value, error) {
+		return v, nil
+	})
+	_, err = converter{}.ConvertValue(money{})
+	assert.EqualError(t, err, "clickhouse: Value of clickhouse.money returns itself")
+}
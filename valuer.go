@@ -0,0 +1,77 @@
+package clickhouse
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ValuerFunc converts an argument of a registered type to a driver value
+type ValuerFunc func(v interface{}) (driver.Value, error)
+
+var (
+	valuersLock sync.RWMutex
+	valuers     = map[reflect.Type]ValuerFunc{}
+)
+
+// RegisterValuer registers fn to convert the arguments of the type typ, e.g.
+// application types or types of third party packages which do not implement
+// driver.Valuer. The values returned by fn are converted like the other
+// arguments, []byte values are sent as raw SQL. The registered functions take
+// precedence over the driver.Valuer of the type and apply to the elements of
+// slices, maps and tuples as well. A nil fn removes the registration.
+func RegisterValuer(typ reflect.Type, fn ValuerFunc) {
+	valuersLock.Lock()
+	if fn == nil {
+		delete(valuers, typ)
+	} else {
+		valuers[typ] = fn
+	}
+	valuersLock.Unlock()
+}
+
+func getValuer(typ reflect.Type) ValuerFunc {
+	valuersLock.RLock()
+	defer valuersLock.RUnlock()
+	return valuers[typ]
+}
+
+// callValuer returns the value of v converted by the registered ValuerFunc
+// of its type or by its driver.Valuer, ok is false if v has neither
+func callValuer(v interface{}) (value driver.Value, ok bool, err error) {
+	if v == nil {
+		return nil, false, nil
+	}
+	rv := reflect.ValueOf(v)
+	if fn := getValuer(rv.Type()); fn != nil {
+		value, err = fn(v)
+	} else if vr, isValuer := v.(driver.Valuer); isValuer {
+		if rv.Kind() == reflect.Ptr && rv.IsNil() && rv.Type().Elem().Implements(valuerType) {
+			// the method of the value can not be called on nil pointer
+			return nil, true, nil
+		}
+		value, err = vr.Value()
+	} else {
+		return nil, false, nil
+	}
+	if err == nil && value != nil && reflect.TypeOf(value) == rv.Type() {
+		err = fmt.Errorf("clickhouse: Value of %T returns itself", v)
+	}
+	return value, true, err
+}
+
+// formatInterval formats the duration as an interval in the largest unit
+// which keeps it exact, e.g. toIntervalSecond(30)
+func formatInterval(d time.Duration) string {
+	switch {
+	case d%time.Second == 0:
+		return fmt.Sprintf("toIntervalSecond(%d)", d/time.Second)
+	case d%time.Millisecond == 0:
+		return fmt.Sprintf("toIntervalMillisecond(%d)", d/time.Millisecond)
+	case d%time.Microsecond == 0:
+		return fmt.Sprintf("toIntervalMicrosecond(%d)", d/time.Microsecond)
+	}
+	return fmt.Sprintf("toIntervalNanosecond(%d)", d)
+}
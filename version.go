@@ -0,0 +1,170 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is a version of the ClickHouse server, e.g. 23.8.2.7
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	Build int
+}
+
+// ParseVersion parses the version returned by version(), e.g. "23.8.2.7"
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) < 2 || len(parts) > 4 {
+		return v, fmt.Errorf("clickhouse: invalid version %q", s)
+	}
+	fields := []*int{&v.Major, &v.Minor, &v.Patch, &v.Build}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("clickhouse: invalid version %q", s)
+		}
+		*fields[i] = n
+	}
+	return v, nil
+}
+
+// String returns the version in the form major.minor.patch.build
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d.%d", v.Major, v.Minor, v.Patch, v.Build)
+}
+
+// AtLeast reports whether the version is major.minor or newer
+func (v Version) AtLeast(major, minor int) bool {
+	return v.Major > major || v.Major == major && v.Minor >= minor
+}
+
+// UnsupportedFeatureError is returned by queries which use a feature of a
+// newer version of the server
+type UnsupportedFeatureError struct {
+	Feature  string
+	Required Version
+	Server   Version
+}
+
+// Error implements the error
+func (e *UnsupportedFeatureError) Error() string {
+	return fmt.Sprintf("clickhouse: %s require ClickHouse %d.%d or newer, the server version is %s",
+		e.Feature, e.Required.Major, e.Required.Minor, e.Server)
+}
+
+// settingFeatures are the features of the settings set by the driver and the
+// versions of the server which support them
+var settingFeatures = []struct {
+	setting string
+	feature string
+	version Version
+}{
+	{"async_insert", "asynchronous inserts", Version{Major: 21, Minor: 11}},
+	{"insert_deduplication_token", "deduplication tokens", Version{Major: 22, Minor: 2}},
+}
+
+// ServerVersion returns the version of the server of a connection of db
+func ServerVersion(ctx context.Context, db *sql.DB) (Version, error) {
+	sqlConn, err := db.Conn(ctx)
+	if err != nil {
+		return Version{}, err
+	}
+	defer sqlConn.Close()
+	var v Version
+	err = sqlConn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*conn)
+		if !ok {
+			return errNotClickHouseConn
+		}
+		v, err = c.ServerVersion(ctx)
+		return err
+	})
+	return v, err
+}
+
+// ServerVersion returns the version of the server, it is queried with
+// SELECT version() once per connection
+func (c *conn) ServerVersion(ctx context.Context) (Version, error) {
+	if c.version != nil {
+		return *c.version, nil
+	}
+	// the values of ctx, e.g. external tables or FINAL, change the query
+	rows, err := c.query(valuelessContext{ctx}, "SELECT version()", nil)
+	if err != nil {
+		return Version{}, err
+	}
+	defer rows.Close()
+	dest := make([]driver.Value, 1)
+	if err = rows.Next(dest); err != nil {
+		if err == io.EOF {
+			err = ErrMalformed
+		}
+		return Version{}, err
+	}
+	s, _ := dest[0].(string)
+	v, err := ParseVersion(s)
+	if err != nil {
+		return Version{}, err
+	}
+	c.version = &v
+	return v, nil
+}
+
+// checkFeatures checks that the server supports the features enabled by the
+// settings of ctx, the version is queried only if such settings are set.
+// If the version can not be queried the server checks the settings itself.
+func (c *conn) checkFeatures(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	settings, _ := ctx.Value(settingsKey).(map[string]string)
+	for _, f := range settingFeatures {
+		if v, ok := settings[f.setting]; !ok || v == "0" {
+			continue
+		}
+		server, err := c.ServerVersion(ctx)
+		if err != nil {
+			c.logf(Logger.Warnf, "failed to get server version: %v", err)
+			return nil
+		}
+		if !server.AtLeast(f.version.Major, f.version.Minor) {
+			return &UnsupportedFeatureError{Feature: f.feature, Required: f.version, Server: server}
+		}
+	}
+	return nil
+}
+
+// valuelessContext is a context which is cancelled with the parent context but
+// has no values
+type valuelessContext struct {
+	parent context.Context
+}
+
+// Deadline implements the context.Context
+func (c valuelessContext) Deadline() (time.Time, bool) {
+	return c.parent.Deadline()
+}
+
+// Done implements the context.Context
+func (c valuelessContext) Done() <-chan struct{} {
+	return c.parent.Done()
+}
+
+// Err implements the context.Context
+func (c valuelessContext) Err() error {
+	return c.parent.Err()
+}
+
+// Value implements the context.Context
+func (c valuelessContext) Value(interface{}) interface{} {
+	return nil
+}
@@ -0,0 +1,76 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	v, err := ParseVersion("23.8.2.7")
+	require.NoError(t, err)
+	assert.Equal(t, Version{23, 8, 2, 7}, v)
+	assert.Equal(t, "23.8.2.7", v.String())
+	assert.True(t, v.AtLeast(23, 8))
+	assert.True(t, v.AtLeast(22, 12))
+	assert.False(t, v.AtLeast(23, 9))
+	v, err = ParseVersion("21.11")
+	require.NoError(t, err)
+	assert.Equal(t, Version{Major: 21, Minor: 11}, v)
+	for _, s := range []string{"", "23", "23.x", "1.2.3.4.5", "23.-1"} {
+		_, err = ParseVersion(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestServerVersion(t *testing.T) {
+	var queries []string
+	version := "21.8.15.7"
+	ts, dsn := newTestServer(func(w http.ResponseWriter, r *http.Request, query string) {
+		if len(query) == 0 {
+			query = r.URL.Query().Get("query")
+		}
+		queries = append(queries, query)
+		if query == "SELECT version()" {
+			w.Write([]byte("version()\nString\n" + version + "\n"))
+		}
+	})
+	defer ts.Close()
+
+	db, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	v, err := ServerVersion(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, Version{21, 8, 15, 7}, v)
+	// the version is queried once per connection
+	_, err = ServerVersion(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SELECT version()"}, queries)
+
+	// the version is not queried without features which depend on it
+	queries = nil
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	_, err = db.ExecContext(WithAsyncInsert(ctx, false), "INSERT INTO t VALUES (1)")
+	if assert.IsType(t, &UnsupportedFeatureError{}, err) {
+		assert.Equal(t, "clickhouse: asynchronous inserts require ClickHouse 21.11 or newer, the server version is 21.8.15.7", err.Error())
+	}
+	_, err = db.ExecContext(WithDeduplicationToken(ctx, "token"), "INSERT INTO t VALUES (1)")
+	assert.IsType(t, &UnsupportedFeatureError{}, err)
+	assert.Equal(t, []string{"INSERT INTO t VALUES (1)"}, queries)
+
+	version = "22.3.1.1"
+	db2, err := sql.Open("clickhouse", dsn)
+	require.NoError(t, err)
+	defer db2.Close()
+	_, err = db2.ExecContext(WithFinal(WithDeduplicationToken(ctx, "token")), "INSERT INTO t VALUES (1)")
+	assert.NoError(t, err)
+}